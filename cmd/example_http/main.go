@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"io"
 	"log"
 	"net/http"
@@ -11,6 +10,8 @@ import (
 	"time"
 
 	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/protobuf/encoding/protojson"
 )
 
 var httpClient = &http.Client{
@@ -75,7 +76,7 @@ func ping(ctx context.Context) {
 		Message: "ping",
 	}
 
-	reqJson, err := json.Marshal(req)
+	reqJson, err := protojson.Marshal(req)
 	if err != nil {
 		log.Fatal("[ERROR] marshalling ping request : ", err.Error())
 	}
@@ -93,6 +94,7 @@ func ping(ctx context.Context) {
 		log.Fatal("[ERROR] creating ping request : ", err.Error())
 	}
 	http.Header.Set(httpReq.Header, "X-Client-Id", clientId)
+	http.Header.Set(httpReq.Header, "Content-Type", "application/json")
 
 	httpResp, err := httpClient.Do(httpReq)
 	if err != nil {
@@ -105,7 +107,7 @@ func ping(ctx context.Context) {
 	}
 
 	var pingResp sharelockPB.ShareLockPingResponse
-	err = json.Unmarshal(respBody, &pingResp)
+	err = protojson.Unmarshal(respBody, &pingResp)
 	if err != nil {
 		log.Fatal("[ERROR] unmarshalling ping response body : ", err.Error())
 	}
@@ -122,7 +124,7 @@ func lock(ctx context.Context, lockKey string, attemptId int) {
 		Key:       lockKey,
 		TimeoutMs: 10_000,
 	}
-	reqJson, err := json.Marshal(req)
+	reqJson, err := protojson.Marshal(req)
 	if err != nil {
 		log.Fatal("[ERROR] marshalling lock request : ", err.Error())
 	}
@@ -139,6 +141,7 @@ func lock(ctx context.Context, lockKey string, attemptId int) {
 		log.Fatal("[ERROR] creating lock request : ", err.Error())
 	}
 	http.Header.Set(httpReq.Header, "X-Client-Id", clientId)
+	http.Header.Set(httpReq.Header, "Content-Type", "application/json")
 
 	httpResp, err := httpClient.Do(httpReq)
 	if err != nil {
@@ -150,7 +153,7 @@ func lock(ctx context.Context, lockKey string, attemptId int) {
 		log.Fatal("[ERROR] reading lock response body : ", err.Error())
 	}
 	var lockResp sharelockPB.LockResponse
-	err = json.Unmarshal(respBody, &lockResp)
+	err = protojson.Unmarshal(respBody, &lockResp)
 	if err != nil {
 		log.Fatal("[ERROR] unmarshalling lock response body : ", err.Error())
 	}
@@ -167,7 +170,7 @@ func unlock(ctx context.Context, lockKey string, attemptId int) {
 		Key: lockKey,
 	}
 
-	jsonReq, err := json.Marshal(req)
+	jsonReq, err := protojson.Marshal(req)
 	if err != nil {
 		log.Fatal("[ERROR] marshalling unlock request : ", err.Error())
 	}
@@ -185,6 +188,7 @@ func unlock(ctx context.Context, lockKey string, attemptId int) {
 		log.Fatal("[ERROR] creating unlock request : ", err.Error())
 	}
 	http.Header.Set(httpReq.Header, "X-Client-Id", clientId)
+	http.Header.Set(httpReq.Header, "Content-Type", "application/json")
 
 	httpResp, err := httpClient.Do(httpReq)
 	if err != nil {
@@ -196,7 +200,7 @@ func unlock(ctx context.Context, lockKey string, attemptId int) {
 		log.Fatal("[ERROR] reading unlock response body : ", err.Error())
 	}
 	var unlockResp sharelockPB.UnlockResponse
-	err = json.Unmarshal(respBody, &unlockResp)
+	err = protojson.Unmarshal(respBody, &unlockResp)
 	if err != nil {
 		log.Fatal("[ERROR] unmarshalling unlock response body : ", err.Error())
 	}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sharelock/config"
+	"sharelock/pkg/audit"
+	"sharelock/pkg/helpers"
+)
+
+const auditUsage = `usage: sharelock audit verify [--config path] [setting flags] [dir]
+
+verify checks the hash chain of the audit log in dir, or in the
+audit_dir of the config. It exits with 1 when a record was changed,
+removed or reordered, and prints the last hash, which seals the log up
+to now.`
+
+// auditCommand runs "sharelock audit ..." and returns the exit code.
+func auditCommand(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("sharelock audit verify", flag.ContinueOnError)
+	src := config.RegisterFlags(fs)
+	err := fs.Parse(args[1:])
+	if err != nil || fs.NArg() > 1 {
+		return 2
+	}
+	helpers.SetupLogging(helpers.LogFormat_Text)
+	helpers.SetLogLevel(slog.LevelWarn)
+
+	dir := fs.Arg(0)
+	if dir == "" {
+		cfg, err := config.Load(*src)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		dir = cfg.Audit.Dir
+	}
+
+	result, err := audit.Verify(dir)
+	var verifyErr *audit.VerifyError
+	switch {
+	case errors.As(err, &verifyErr):
+		fmt.Printf("audit log %s is broken after %d valid records :\n  - %s\n", dir, result.Records, verifyErr)
+		return 1
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("audit log %s is intact : %d records in %d files, seq %d to %d\n", dir, result.Records, result.Files, result.FirstSeq, result.LastSeq)
+	if result.FirstSeq > 1 {
+		fmt.Printf("  records before %d were rotated out, the chain is checked from there\n", result.FirstSeq)
+	}
+	fmt.Printf("  last hash %s\n", result.LastHash)
+	return 0
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sharelock/config"
+	"sharelock/pkg/cluster"
+)
+
+// openCluster joins the raft cluster of cfg.
+func openCluster(cfg *config.Config) (*cluster.Node, error) {
+	peers, err := cluster.ParsePeers(cfg.Cluster.Peers)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := raftTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		slog.Warn("raft runs without tls, any host reaching cluster_raft_address can join the cluster", "raft_address", cfg.Cluster.RaftAddress)
+	}
+	return cluster.Open(cluster.Options{
+		NodeId:        cfg.Cluster.NodeId,
+		RaftAddress:   cfg.Cluster.RaftAddress,
+		RaftAdvertise: cfg.Cluster.RaftAdvertise,
+		Dir:           cfg.Cluster.Dir,
+		Bootstrap:     cfg.Cluster.Bootstrap,
+		Peers:         peers,
+		GrpcAddress:   cfg.Cluster.GrpcAddress,
+		HttpAddress:   cfg.Cluster.HttpAddress,
+		ApplyTimeout:  cfg.Cluster.ApplyTimeout,
+		TLS:           tlsConfig,
+		Insecure:      cfg.Cluster.RaftInsecure,
+	})
+}
+
+// raftTLS is the mutual tls config of the raft transport, nil when
+// cluster_raft_insecure opted out of it.
+func raftTLS(cfg *config.Config) (*tls.Config, error) {
+	if cfg.Cluster.RaftInsecure {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.Cluster.RaftCertPath, cfg.Cluster.RaftKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading raft certificate : %w", err)
+	}
+	pem, err := os.ReadFile(cfg.Cluster.RaftCAPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading raft ca : %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in raft ca %s", cfg.Cluster.RaftCAPath)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+	}, nil
+}
+
+// forwardTLS is the tls config followers dial the gRPC server of the
+// leader with, nil when the gRPC server has no tls.
+func forwardTLS(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.GrpcServer.TLS {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Cluster.CAPath == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.Cluster.CAPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster ca : %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in cluster ca %s", cfg.Cluster.CAPath)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
+
+// leaveCluster hands the lead to another node before this one stops,
+// so the holders and the queues move on without waiting for an
+// election.
+func leaveCluster(node *cluster.Node) {
+	if !node.Leading() {
+		return
+	}
+	slog.Warn("transferring cluster leadership before stopping")
+	err := node.TransferLeadership()
+	if err != nil {
+		slog.Error("transferring cluster leadership, the other nodes elect a new leader", "err", err)
+	}
+}
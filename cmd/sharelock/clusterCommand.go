@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const clusterUsage = `usage: sharelock cluster <command> [flags] [args]
+
+commands:
+  members                         lists the members and the leader
+  add <id> <raft address>         adds a voter, started without bootstrap
+  remove <id>                     removes a member, the leader included
+  transfer                        hands the lead to another voter
+
+flags:
+  --addr host:port    gRPC server of any node, changes go to the leader
+  --api-key key       api key of a principal allowed the admin action
+  --tls               dial with tls
+  --ca path           verifies the server with this ca instead of the system roots
+  --cert path         client certificate, for a server with grpc_client_ca_path
+  --key path          key of the client certificate
+  --grpc-address      gRPC address of the added node
+  --http-address      HTTP address of the added node
+  --timeout           bounds the call, 30s by default`
+
+// clusterCommand runs "sharelock cluster ..." and returns the exit code.
+func clusterCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, clusterUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("sharelock cluster "+args[0], flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:50051", "gRPC server of any node")
+	apiKey := fs.String("api-key", "", "api key of a principal allowed the admin action")
+	useTLS := fs.Bool("tls", false, "dial with tls")
+	caPath := fs.String("ca", "", "ca verifying the server")
+	certPath := fs.String("cert", "", "client certificate")
+	keyPath := fs.String("key", "", "key of the client certificate")
+	grpcAddress := fs.String("grpc-address", "", "gRPC address of the added node")
+	httpAddress := fs.String("http-address", "", "HTTP address of the added node")
+	timeout := fs.Duration("timeout", time.Second*30, "bounds the call")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	conn, err := dialNode(*addr, *useTLS, *caPath, *certPath, *keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer conn.Close()
+	client := sharelockPB.NewShareLockAdminClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if *apiKey != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "X-Api-Key", *apiKey)
+	}
+
+	var info *sharelockPB.ClusterInfo
+	switch {
+	case args[0] == "members" && fs.NArg() == 0:
+		info, err = client.GetCluster(ctx, &sharelockPB.GetClusterRequest{})
+	case args[0] == "add" && fs.NArg() == 2:
+		info, err = client.AddMember(ctx, &sharelockPB.AddMemberRequest{
+			Id:          fs.Arg(0),
+			RaftAddress: fs.Arg(1),
+			GrpcAddress: *grpcAddress,
+			HttpAddress: *httpAddress,
+		})
+	case args[0] == "remove" && fs.NArg() == 1:
+		info, err = client.RemoveMember(ctx, &sharelockPB.RemoveMemberRequest{Id: fs.Arg(0)})
+	case args[0] == "transfer" && fs.NArg() == 0:
+		info, err = client.TransferLeadership(ctx, &sharelockPB.TransferLeadershipRequest{})
+	default:
+		fmt.Fprintln(os.Stderr, clusterUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printCluster(info)
+	return 0
+}
+
+func printCluster(info *sharelockPB.ClusterInfo) {
+	fmt.Printf("cluster as seen by %s :\n", info.NodeId)
+	for _, member := range info.Members {
+		role := "follower"
+		switch {
+		case member.Leader:
+			role = "leader"
+		case !member.Voter:
+			role = "nonvoter"
+		}
+		fmt.Printf("  %-16s %-9s raft %-21s grpc %-21s http %s\n", member.Id, role, member.RaftAddress, member.GrpcAddress, member.HttpAddress)
+	}
+}
+
+// dialNode connects to the gRPC server of a node for the admin commands,
+// with the client certificate of certPath and keyPath when set.
+func dialNode(addr string, useTLS bool, caPath, certPath, keyPath string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if caPath != "" {
+			pem, err := os.ReadFile(caPath)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			tlsConfig.RootCAs.AppendCertsFromPEM(pem)
+		}
+		if certPath != "" {
+			cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+}
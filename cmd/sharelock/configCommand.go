@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/helpers"
+	"sharelock/server"
+)
+
+const configUsage = `usage: sharelock config validate [--config path] [setting flags]
+
+validate reads the config like the server does, from the file, the
+SHARELOCK_ environment variables and the flags, and prints every problem
+it finds. It exits with 1 when the config is invalid.`
+
+// configCommand runs "sharelock config ..." and returns the exit code.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("sharelock config validate", flag.ContinueOnError)
+	src := config.RegisterFlags(fs)
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+	helpers.SetupLogging(helpers.LogFormat_Text)
+	helpers.SetLogLevel(slog.LevelWarn)
+
+	problems := make([]string, 0)
+	cfg, err := config.Load(*src)
+	var validationErr *config.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		problems = append(problems, validationErr.Problems...)
+	case err != nil:
+		problems = append(problems, err.Error())
+	}
+	// the files the config names are only checked when it is valid,
+	// the way the server would load them
+	if len(problems) == 0 {
+		_, err = server.NewAuthenticator(cfg.Auth)
+		if err != nil {
+			problems = append(problems, "auth : "+err.Error())
+		}
+		_, err = auth.NewPolicyStore(cfg.Auth.AclPolicyPath)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		if cfg.Shard.Enable {
+			_, err = shardRing(cfg)
+			if err != nil {
+				problems = append(problems, "shard_members : "+err.Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("config %s is invalid :\n", src.Path)
+		for _, problem := range problems {
+			fmt.Println("  -", problem)
+		}
+		return 1
+	}
+	fmt.Printf("config %s is valid\n", src.Path)
+	return 0
+}
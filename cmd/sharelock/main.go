@@ -2,48 +2,394 @@ package main
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"flag"
+	"log/slog"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"sharelock/config"
+	"sharelock/pkg/audit"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/cluster"
+	"sharelock/pkg/helpers"
 	"sharelock/pkg/locker"
+	"sharelock/pkg/metrics"
+	"sharelock/pkg/shard"
+	"sharelock/pkg/store"
+	"sharelock/pkg/tracing"
 	"sharelock/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "audit" {
+		os.Exit(auditCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "cluster" {
+		os.Exit(clusterCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "shard" {
+		os.Exit(shardCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "state" {
+		os.Exit(stateCommand(os.Args[2:]))
+	}
+	configSource := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
 	// global context
 	globalCtx, cancelGlobalCtx := context.WithCancel(context.Background())
-	cfg := config.ReadConfig()
+	cfg := config.ReadConfig(*configSource)
+	err := helpers.SetupLogging(cfg.Log.Format)
+	if err != nil {
+		fatal("setting up logging", err)
+	}
+	level, err := helpers.ParseLogLevel(cfg.Log.Level)
+	if err != nil {
+		fatal("setting up logging", err)
+	}
+	helpers.SetLogLevel(level)
+
+	shutdownTracing, err := tracing.Setup(globalCtx, tracing.Options{
+		Exporter:     cfg.Tracing.Exporter,
+		OtlpEndpoint: cfg.Tracing.OtlpEndpoint,
+		OtlpInsecure: cfg.Tracing.OtlpInsecure,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+		ServiceName:  cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
+	if cfg.Tracing.Exporter != "" {
+		slog.Info("exporting traces", "exporter", cfg.Tracing.Exporter)
+	}
+
+	var auditLog *audit.Log
+	if cfg.Audit.Enable {
+		auditLog, err = audit.Open(audit.Options{
+			Dir:          cfg.Audit.Dir,
+			MaxFileBytes: int64(cfg.Audit.MaxFileMB) << 20,
+			MaxFiles:     cfg.Audit.MaxFiles,
+		})
+		if err != nil {
+			fatal("opening audit log", err)
+		}
+		slog.Info("writing audit log", "dir", cfg.Audit.Dir)
+	}
+
+	lockerStore, err := store.Open(store.Options{
+		Kind:          cfg.State.Store,
+		Dir:           cfg.State.Dir,
+		Sync:          cfg.State.Sync,
+		SnapshotEvery: cfg.State.SnapshotEvery,
+	})
+	if err != nil {
+		fatal("opening state store", err)
+	}
+	if lockerStore != nil {
+		slog.Info("keeping held locks", "store", cfg.State.Store, "dir", cfg.State.Dir)
+	}
+
+	// in cluster mode the raft log keeps the locks, and the locker waits
+	// on standby until this node leads the cluster
+	var node *cluster.Node
+	var clusterTLS *tls.Config
+	if cfg.Cluster.Enable {
+		node, err = openCluster(cfg)
+		if err != nil {
+			fatal("joining cluster", err)
+		}
+		clusterTLS, err = forwardTLS(cfg)
+		if err != nil {
+			fatal("setting up cluster forwarding", err)
+		}
+		slog.Info("running in cluster mode", "node", cfg.Cluster.NodeId, "raft_address", cfg.Cluster.RaftAddress)
+	}
+
+	// in shard mode every node grants the keys it owns on the ring and
+	// forwards the others
+	var shardMap *shard.Map
+	var shardForwardTLS *tls.Config
+	if cfg.Shard.Enable {
+		ring, err := shardRing(cfg)
+		if err != nil {
+			fatal("building shard ring", err)
+		}
+		shardMap = shard.NewMap(cfg.Shard.NodeId, ring)
+		shardForwardTLS, err = shardTLS(cfg)
+		if err != nil {
+			fatal("setting up shard forwarding", err)
+		}
+		slog.Info("running in shard mode", "node", cfg.Shard.NodeId, "members", len(ring.Members()), "ring_version", ring.Version())
+	}
 
 	// locker
-	locker := locker.NewLocker()
-	go locker.Start(globalCtx)
+	options := lockerOptions(cfg)
+	options = append(options, locker.WithStore(lockerStore))
+	if node != nil {
+		options = append(options, locker.WithStore(node.Store()), locker.WithStandby())
+	}
+	if auditLog != nil {
+		options = append(options, locker.WithAuditor(auditLog))
+	}
+	var lockerMetrics *metrics.Metrics
+	if cfg.Metrics.Enable {
+		lockerMetrics = metrics.New(metrics.Options{
+			KeyPrefixDepth:     cfg.Metrics.KeyPrefixDepth,
+			KeyPrefixSeparator: cfg.Metrics.KeyPrefixSeparator,
+			MaxKeyPrefixes:     cfg.Metrics.MaxKeyPrefixes,
+		})
+		options = append(options, locker.WithObserver(lockerMetrics))
+	}
+	lockerInstance := locker.NewLocker(options...)
+	if lockerMetrics != nil {
+		lockerMetrics.WatchLocker(lockerInstance)
+	}
+	if node == nil {
+		err = lockerInstance.Restore()
+		if err != nil {
+			fatal("restoring held locks", err)
+		}
+	}
+	// the locker stops after the servers, so that the store is closed
+	// once nothing changes it anymore
+	lockerCtx, cancelLockerCtx := context.WithCancel(context.Background())
+	lockerDone := make(chan struct{})
+	go func() {
+		defer close(lockerDone)
+		lockerInstance.Start(lockerCtx)
+	}()
+	if node != nil {
+		go node.Run(lockerCtx, lockerInstance)
+	}
 
-	serversList := make([]server.Server, 0)
-	if cfg.GrpcServer != nil && cfg.GrpcServer.Enable {
-		serversList = append(serversList, server.NewGrpcServer(globalCtx, cfg.GrpcServer, locker))
+	authenticator, err := server.NewAuthenticator(cfg.Auth)
+	if err != nil {
+		fatal("creating authenticator", err)
 	}
-	if cfg.HttpServer != nil && cfg.HttpServer.Enable {
-		serversList = append(serversList, server.NewHttpServer(globalCtx, cfg.HttpServer, locker))
+
+	// the policy store exists even without policy, so a reload can add one
+	policy, err := auth.NewPolicyStore(cfg.Auth.AclPolicyPath)
+	if err != nil {
+		fatal("loading acl policy", err)
 	}
+	service := server.NewLockService(lockerInstance,
+		server.WithPolicy(policy),
+		server.WithMetrics(lockerMetrics),
+		server.WithAuditLog(auditLog),
+		server.WithNamespaceRateLimits(namespaceRateLimits(cfg.Namespaces)),
+		server.WithAdmissionLimits(admissionLimits(cfg.Limits)),
+		server.WithCluster(node, clusterTLS),
+		server.WithShards(shardMap, cfg.Shard.Secret, shardForwardTLS),
+	)
+	go service.WatchRebalance(globalCtx)
+
+	reloader := &reloader{
+		source:     *configSource,
+		current:    cfg,
+		locker:     lockerInstance,
+		service:    service,
+		policy:     policy,
+		grpcServer: server.NewGrpcServer(globalCtx, cfg.GrpcServer, service, authenticator),
+		httpServer: server.NewHttpServer(globalCtx, cfg.HttpServer, service, authenticator),
+	}
+	serversList := []server.Server{reloader.grpcServer, reloader.httpServer}
 
 	// start all the servers
 	for i := range serversList {
 		go serversList[i].Start()
 	}
+	notifyReady(globalCtx, lockerInstance, cfg, reloader.grpcServer, reloader.httpServer)
+	go watchdog(globalCtx, lockerInstance)
+
+	// SIGHUP reloads the config, and so does a change of the config file
+	// with config_watch
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			err := reloader.reload(globalCtx)
+			if err != nil {
+				slog.Error("config reload rejected, keeping the running config", "err", err)
+			}
+		}
+	}()
+
+	// SIGUSR1 switches debug logging on and off again, to follow the
+	// locker state without a restart
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			slog.Warn("debug logging toggled", "debug", helpers.ToggleDebugLogging())
+		}
+	}()
+	if cfg.WatchFile {
+		err = reloader.watch(globalCtx)
+		if err != nil {
+			slog.Error("watching config file, reload it with SIGHUP instead", "err", err)
+		}
+	}
 
-	// Wait for Control C to exit
+	// Wait for Control C or SIGTERM to exit
 	ch := make(chan os.Signal, 3)
-	signal.Notify(ch, os.Interrupt)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 
 	// Block until a signal is received
 	<-ch
+	cfg = reloader.config()
+	notifyStopping()
+
+	if node != nil {
+		// the locks stay with the cluster, the leader hands them over
+		// instead of draining
+		leaveCluster(node)
+	} else {
+		// refuse new locks and give holders the drain period to release
+		// theirs, a second signal skips the rest of it
+		slog.Warn("draining ShareLock", "drain_period", cfg.Shutdown.DrainPeriod)
+		drainCtx, cancelDrainCtx := context.WithTimeout(globalCtx, cfg.Shutdown.DrainPeriod)
+		err = service.Drain(drainCtx)
+		if err != nil {
+			slog.Error("draining locker", "err", err)
+		}
+		waitForRelease(drainCtx, lockerInstance, ch)
+		cancelDrainCtx()
+	}
 
 	// Finally, we stop the server
-	log.Println("[WARN] stopping ShareLock")
+	slog.Warn("stopping ShareLock")
+	stopCtx, cancelStopCtx := context.WithTimeout(globalCtx, cfg.Shutdown.Timeout)
+	var wg sync.WaitGroup
 	for i := range serversList {
-		serversList[i].Stop()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serversList[i].Stop(stopCtx)
+		}()
+	}
+	wg.Wait()
+	cancelStopCtx()
+	service.Close()
+
+	cancelLockerCtx()
+	<-lockerDone
+	if node != nil {
+		err = node.Shutdown()
+		if err != nil {
+			slog.Error("leaving cluster", "err", err)
+		}
+	}
+	if lockerStore != nil {
+		err = lockerStore.Close()
+		if err != nil {
+			slog.Error("closing state store", "err", err)
+		}
+	}
+	if auditLog != nil {
+		auditLog.Close()
 	}
+
+	// spans of the last requests are still buffered
+	flushCtx, cancelFlushCtx := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+	err = shutdownTracing(flushCtx)
+	if err != nil {
+		slog.Warn("flushing traces", "err", err)
+	}
+	cancelFlushCtx()
 	cancelGlobalCtx()
 }
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// waitForRelease returns once no lock is held, ctx is done or another
+// signal arrives.
+func waitForRelease(ctx context.Context, lockerInstance *locker.Locker, signals chan os.Signal) {
+	ticker := time.NewTicker(time.Millisecond * 100)
+	defer ticker.Stop()
+	for {
+		held, err := lockerInstance.HeldLocks(ctx)
+		if err != nil {
+			slog.Warn("drain period over")
+			return
+		}
+		if held == 0 {
+			slog.Info("all locks released")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			slog.Warn("drain period over with locks still held", "held_locks", held)
+			return
+		case <-signals:
+			slog.Warn("drain interrupted with locks still held", "held_locks", held)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func lockerOptions(cfg *config.Config) []locker.Option {
+	return []locker.Option{
+		namespaceLimits(cfg.Namespaces),
+		locker.WithMaxLocksPerClient(cfg.Limits.MaxLocksPerClient),
+		locker.WithDefaultLease(cfg.Lock.DefaultLease),
+	}
+}
+
+func namespaceLimits(cfg *config.Namespaces) locker.Option {
+	if cfg == nil {
+		return locker.WithNamespaceLimits(locker.Limits{}, nil)
+	}
+	lockerLimits := func(limits config.NamespaceLimits) locker.Limits {
+		return locker.Limits{
+			MaxHeldLocks:      limits.MaxHeldLocks,
+			MaxWaiters:        limits.MaxWaiters,
+			MaxLocksPerClient: limits.MaxLocksPerClient,
+		}
+	}
+	perNamespace := make(map[string]locker.Limits, len(cfg.Limits))
+	for name, limits := range cfg.Limits {
+		perNamespace[name] = lockerLimits(limits)
+	}
+	return locker.WithNamespaceLimits(lockerLimits(cfg.Defaults), perNamespace)
+}
+
+func namespaceRateLimits(cfg *config.Namespaces) (server.RateLimit, map[string]server.RateLimit) {
+	rateLimit := func(limits config.NamespaceLimits) server.RateLimit {
+		return server.RateLimit{
+			RequestsPerSecond: limits.RequestsPerSecond,
+			Burst:             limits.RequestBurst,
+		}
+	}
+	perNamespace := make(map[string]server.RateLimit, len(cfg.Limits))
+	for name, limits := range cfg.Limits {
+		perNamespace[name] = rateLimit(limits)
+	}
+	return rateLimit(cfg.Defaults), perNamespace
+}
+
+func admissionLimits(cfg *config.Limits) server.AdmissionLimits {
+	return server.AdmissionLimits{
+		ClientRate: server.RateLimit{
+			RequestsPerSecond: cfg.ClientRequestsPerSecond,
+			Burst:             cfg.ClientRequestBurst,
+		},
+		AddressRate: server.RateLimit{
+			RequestsPerSecond: cfg.AddressRequestsPerSecond,
+			Burst:             cfg.AddressRequestBurst,
+		},
+		MaxPendingPerClient: cfg.MaxPendingPerClient,
+		MaxKeyLength:        cfg.MaxKeyLength,
+	}
+}
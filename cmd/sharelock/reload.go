@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/filewatch"
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/shard"
+	"sharelock/server"
+)
+
+// restartFields are the settings a running ShareLock cannot change, a
+// reload changing any of them is rejected. A field ending with a dot
+// covers everything below it. The client ca path decides at startup
+// whether a server asks for client certificates, and which
+// authenticators run.
+var restartFields = []string{
+	"HttpServer.Enable", "HttpServer.Port", "HttpServer.ListenAddresses", "HttpServer.UnixSocketMode", "HttpServer.TLS", "HttpServer.ClientCAPath",
+	"GrpcServer.Enable", "GrpcServer.Port", "GrpcServer.ListenAddresses", "GrpcServer.UnixSocketMode", "GrpcServer.TLS", "GrpcServer.ClientCAPath",
+	"Auth.", "Metrics.", "Tracing.", "Audit.", "State.", "Cluster.", "WatchFile",
+	"Shard.Enable", "Shard.NodeId", "Shard.VirtualNodes", "Shard.Secret", "Shard.CAPath", "Shard.ClientCertPath", "Shard.ClientKeyPath",
+}
+
+// reloadableAuthFields are the exceptions to restartFields.
+var reloadableAuthFields = []string{"Auth.AclPolicyPath"}
+
+// configWatchDelay groups the events of one save of the config file.
+const configWatchDelay = time.Millisecond * 500
+
+// reloadApplyTimeout bounds the wait for the locker loop once a reload
+// was checked and is being applied.
+const reloadApplyTimeout = time.Second * 5
+
+// reloader applies a new config to the running locker, lock service and
+// servers. Every file the new config names is loaded before anything is
+// changed, so a reload applies entirely or not at all.
+type reloader struct {
+	// source keeps the flags of the command line, they still override
+	// the file and the environment on a reload
+	source  config.Source
+	mu      sync.Mutex
+	current *config.Config
+
+	locker     *locker.Locker
+	service    *server.LockService
+	policy     *auth.PolicyStore
+	grpcServer server.Server
+	httpServer server.Server
+}
+
+// config is the config in force.
+func (r *reloader) config() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// reload reads the config file again and applies it. Held locks and
+// queued clients are kept, new limits and the new default lease apply
+// from the next request on. A rejected reload logs the changes it
+// would have made.
+func (r *reloader) reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := config.Load(r.source)
+	if next == nil {
+		return err
+	}
+	changes := config.Diff(r.current, next)
+	if err == nil {
+		err = r.apply(ctx, next, changes)
+	}
+	if err != nil {
+		for _, change := range changes {
+			slog.Warn("rejected config change", "field", change.Field, "old", change.Old, "new", change.New)
+		}
+		return err
+	}
+	for _, change := range changes {
+		slog.Info("config change", "field", change.Field, "old", change.Old, "new", change.New)
+	}
+	r.current = next
+	slog.Info("config reloaded", "path", r.source.Path, "changes", len(changes))
+	return nil
+}
+
+// apply puts a valid config in force, unless it changes settings that
+// need a restart or names files that cannot be loaded.
+func (r *reloader) apply(ctx context.Context, next *config.Config, changes []config.Change) error {
+	fixed := make([]string, 0)
+	for _, change := range changes {
+		if needsRestart(change.Field) {
+			fixed = append(fixed, change.Field)
+		}
+	}
+	if len(fixed) > 0 {
+		return fmt.Errorf("%s cannot change without a restart", strings.Join(fixed, ", "))
+	}
+
+	commits, err := r.prepare(next)
+	if err != nil {
+		return err
+	}
+	var ring *shard.Ring
+	if next.Shard.Enable && !slices.Equal(next.Shard.Members, r.current.Shard.Members) {
+		ring, err = shardRing(next)
+		if err != nil {
+			return fmt.Errorf("building shard ring : %w", err)
+		}
+		if r.service.ShardRebalancing() {
+			return fmt.Errorf("changing shard members : %w", helpers.Err_Srv_Rebalancing)
+		}
+	}
+
+	// everything was checked, the changes must not stop halfway because
+	// ctx ends
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), reloadApplyTimeout)
+	defer cancel()
+	err = r.locker.Reconfigure(ctx, lockerOptions(next)...)
+	if err != nil {
+		return fmt.Errorf("reconfiguring locker : %w", err)
+	}
+	if ring != nil {
+		err = r.service.SetShardRing(ctx, ring)
+		if err != nil {
+			restoreErr := r.locker.Reconfigure(ctx, lockerOptions(r.current)...)
+			if restoreErr != nil {
+				slog.Error("restoring the locker settings of the running config", "err", restoreErr)
+			}
+			return fmt.Errorf("changing shard members : %w", err)
+		}
+	}
+	for _, commit := range commits {
+		commit()
+	}
+	return nil
+}
+
+// prepare loads the acl policy and the certificates of next and returns
+// the funcs putting next in force.
+func (r *reloader) prepare(next *config.Config) ([]func(), error) {
+	level, err := helpers.ParseLogLevel(next.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	_, err = helpers.NewLogHandler(io.Discard, next.Log.Format)
+	if err != nil {
+		return nil, err
+	}
+	var policy *auth.Policy
+	if next.Auth.AclPolicyPath != "" {
+		policy, err = auth.LoadPolicy(next.Auth.AclPolicyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading acl policy %s : %w", next.Auth.AclPolicyPath, err)
+		}
+	}
+
+	commits := []func(){
+		func() {
+			if next.Log.Format != r.current.Log.Format {
+				helpers.SetupLogging(next.Log.Format)
+			}
+			helpers.SetLogLevel(level)
+		},
+		func() { r.policy.Set(policy) },
+		func() { r.service.SetAdmissionLimits(admissionLimits(next.Limits)) },
+		func() { r.service.SetNamespaceRateLimits(namespaceRateLimits(next.Namespaces)) },
+	}
+	servers := []struct {
+		srv server.Server
+		cfg *config.Server
+	}{
+		{r.grpcServer, next.GrpcServer},
+		{r.httpServer, next.HttpServer},
+	}
+	for _, s := range servers {
+		tlsReloader, ok := s.srv.(server.TLSReloader)
+		if !ok || !s.cfg.TLS {
+			continue
+		}
+		commit, err := tlsReloader.PrepareTLS(s.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificates : %w", err)
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+func needsRestart(field string) bool {
+	for _, reloadable := range reloadableAuthFields {
+		if field == reloadable {
+			return false
+		}
+	}
+	for _, fixed := range restartFields {
+		if field == fixed || (strings.HasSuffix(fixed, ".") && strings.HasPrefix(field, fixed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch reloads the config whenever its file changes, until ctx is done.
+func (r *reloader) watch(ctx context.Context) error {
+	watcher, err := filewatch.New(configWatchDelay, func() {
+		err := r.reload(ctx)
+		if err != nil {
+			slog.Error("config reload rejected, keeping the running config", "err", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	err = watcher.Watch(r.source.Path)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	slog.Info("watching config file", "path", r.source.Path)
+	go watcher.Run(ctx)
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/shard"
+	"sharelock/server"
+)
+
+func TestNeedsRestart(t *testing.T) {
+	tests := []struct {
+		field string
+		want  bool
+	}{
+		{"HttpServer.Port", true},
+		{"HttpServer.ClientCAPath", true},
+		{"GrpcServer.ClientCAPath", true},
+		{"GrpcServer.CertPath", false},
+		{"GrpcServer.KeyPath", false},
+		{"Auth.ApiKeys.ops", true},
+		{"Auth.AclPolicyPath", false},
+		{"Shard.Members", false},
+		{"Shard.Secret", true},
+		{"Limits.MaxKeyLength", false},
+		{"Lock.DefaultLease", false},
+	}
+	for _, tt := range tests {
+		if got := needsRestart(tt.field); got != tt.want {
+			t.Errorf("needsRestart(%s) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+// testConfig is a valid shard node config, extra lines are added to it.
+func testConfig(members string, extra ...string) string {
+	return strings.Join(append([]string{
+		"http_server_enable: false",
+		"grpc_server_enable: true",
+		"grpc_server_port: 50051",
+		"grpc_server_service_name: sharelock",
+		"grpc_tls: true",
+		"grpc_cert_path: /unused.crt",
+		"grpc_key_path: /unused.key",
+		"shard_enable: true",
+		"shard_node_id: node0",
+		"shard_secret: secret",
+		"shard_members: [" + members + "]",
+	}, extra...), "\n")
+}
+
+// defaultLease is the lease the locker grants a request without one.
+func defaultLease(t *testing.T, l *locker.Locker) time.Duration {
+	t.Helper()
+	client := &locker.Client{Ctx: context.Background(), Id: "probe", LockKey: fmt.Sprintf("probe-%d", time.Now().UnixNano()), StatusChan: make(chan locker.Status, 1)}
+	start := time.Now()
+	l.Lock(client)
+	if status := <-client.StatusChan; status != locker.Status_Locked {
+		t.Fatalf("probe got %s", status)
+	}
+	return client.LeaseExpiresAt.Sub(start).Round(time.Minute)
+}
+
+func TestReload(t *testing.T) {
+	const members = "node0=127.0.0.1:1, node1=127.0.0.1:2"
+	tests := []struct {
+		name string
+		// rebalancing starts a ring change before the reload
+		rebalancing bool
+		// cancelled ends the context of the reload before it runs
+		cancelled bool
+		next      string
+		wantErr   string
+		wantLease time.Duration
+	}{
+		{name: "reloadable change", next: testConfig(members, "lock_default_lease: 2m"), wantLease: time.Minute * 2},
+		{name: "restart field", next: testConfig(members, "lock_default_lease: 2m", "tracing_sample_ratio: 0.5"),
+			wantErr: "Tracing.SampleRatio cannot change without a restart", wantLease: time.Minute},
+		{name: "invalid config", next: testConfig(members, "lock_default_lease: 2m", "shard_virtual_nodes: -1"),
+			wantErr: "shard_virtual_nodes", wantLease: time.Minute},
+		{name: "ring refused while rebalancing", rebalancing: true, next: testConfig("node0=127.0.0.1:1, node3=127.0.0.1:4", "lock_default_lease: 2m"),
+			wantErr: "changing shard members", wantLease: time.Minute},
+		{name: "cancelled while applying", cancelled: true, next: testConfig("node0=127.0.0.1:1, node3=127.0.0.1:4", "lock_default_lease: 2m"),
+			wantLease: time.Minute * 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			write := func(content string) {
+				err := os.WriteFile(path, []byte(content), 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			write(testConfig(members, "lock_default_lease: 1m"))
+			current, err := config.Load(config.Source{Path: path})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ring, err := shardRing(current)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			lockerInstance := locker.NewLocker(lockerOptions(current)...)
+			lockerDone := make(chan struct{})
+			go func() {
+				defer close(lockerDone)
+				lockerInstance.Start(ctx)
+			}()
+			m := shard.NewMap("node0", ring)
+			service := server.NewLockService(lockerInstance, server.WithShards(m, "secret", nil))
+			t.Cleanup(func() {
+				cancel()
+				<-lockerDone
+				service.Close()
+			})
+			if tt.rebalancing {
+				members, err := shard.ParseMembers([]string{"node0=127.0.0.1:1", "node2=127.0.0.1:3"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				moving, err := shard.NewRing(members, current.Shard.VirtualNodes)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = service.SetShardRing(ctx, moving)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			ringBefore := m.Ring().Version()
+
+			r := &reloader{source: config.Source{Path: path}, current: current, locker: lockerInstance, service: service, policy: &auth.PolicyStore{}}
+			write(tt.next)
+			reloadCtx, reloadCancel := context.WithCancel(ctx)
+			if tt.cancelled {
+				reloadCancel()
+			}
+			err = r.reload(reloadCtx)
+			reloadCancel()
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+				}
+				if r.config() != current {
+					t.Fatal("a rejected reload replaced the config in force")
+				}
+				if m.Ring().Version() != ringBefore {
+					t.Fatal("a rejected reload changed the shard ring")
+				}
+			}
+			if tt.wantErr == "" && !slices.Equal(r.config().Shard.Members, current.Shard.Members) && m.Ring().Version() == ringBefore {
+				t.Fatal("an applied reload kept the old shard ring")
+			}
+			if lease := defaultLease(t, lockerInstance); lease != tt.wantLease {
+				t.Fatalf("default lease %s, want %s", lease, tt.wantLease)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sharelock/config"
+	"sharelock/pkg/shard"
+)
+
+// shardRing is the ring of the members of cfg.
+func shardRing(cfg *config.Config) (*shard.Ring, error) {
+	members, err := shard.ParseMembers(cfg.Shard.Members)
+	if err != nil {
+		return nil, err
+	}
+	ring, err := shard.NewRing(members, cfg.Shard.VirtualNodes)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ring.Member(cfg.Shard.NodeId); !ok {
+		// a node removed from the ring still serves the keys it holds
+		// until they are handed off
+		slog.Warn("this node is not a shard member, it only serves the keys it still holds", "node", cfg.Shard.NodeId)
+	}
+	return ring, nil
+}
+
+// shardTLS is the tls config the nodes dial each other with. The nodes
+// send their secret on every call, so it is never plaintext.
+func shardTLS(cfg *config.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.Shard.ClientCertPath != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Shard.ClientCertPath, cfg.Shard.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading shard client certificate : %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.Shard.CAPath == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.Shard.CAPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading shard ca : %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in shard ca %s", cfg.Shard.CAPath)
+	}
+	tlsConfig.RootCAs = pool
+	return tlsConfig, nil
+}
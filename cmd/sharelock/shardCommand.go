@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const shardUsage = `usage: sharelock shard <command> [flags]
+
+commands:
+  status    shows the ring of a node and whether it rebalances
+  finish    ends the rebalance of a node without waiting for the others,
+            only when a node of the old ring is gone for good
+
+flags:
+  --addr host:port    gRPC server of the node
+  --secret secret     shard_secret of the nodes, SHARELOCK_SHARD_SECRET by default
+  --tls               dial with tls
+  --ca path           verifies the server with this ca instead of the system roots
+  --cert path         client certificate, for a server with grpc_client_ca_path
+  --key path          key of the client certificate
+  --timeout           bounds the call, 30s by default`
+
+// shardCommand runs "sharelock shard ..." and returns the exit code.
+func shardCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, shardUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("sharelock shard "+args[0], flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:50051", "gRPC server of the node")
+	secret := fs.String("secret", os.Getenv("SHARELOCK_SHARD_SECRET"), "shard_secret of the nodes")
+	useTLS := fs.Bool("tls", false, "dial with tls")
+	caPath := fs.String("ca", "", "ca verifying the server")
+	certPath := fs.String("cert", "", "client certificate")
+	keyPath := fs.String("key", "", "key of the client certificate")
+	timeout := fs.Duration("timeout", time.Second*30, "bounds the call")
+	err := fs.Parse(args[1:])
+	if err != nil {
+		return 2
+	}
+
+	conn, err := dialNode(*addr, *useTLS, *caPath, *certPath, *keyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer conn.Close()
+	client := sharelockPB.NewShareLockShardClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Sharelock-Shard-Secret", *secret)
+
+	var status *sharelockPB.ShardStatus
+	switch {
+	case args[0] == "status" && fs.NArg() == 0:
+		status, err = client.GetShardStatus(ctx, &sharelockPB.GetShardStatusRequest{})
+	case args[0] == "finish" && fs.NArg() == 0:
+		status, err = client.FinishRebalance(ctx, &sharelockPB.FinishRebalanceRequest{})
+	default:
+		fmt.Fprintln(os.Stderr, shardUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printShardStatus(status)
+	return 0
+}
+
+func printShardStatus(status *sharelockPB.ShardStatus) {
+	fmt.Printf("ring %s as seen by %s :\n", status.RingVersion, status.NodeId)
+	for _, member := range status.Members {
+		fmt.Printf("  %-16s grpc %s\n", member.Id, member.GrpcAddress)
+	}
+	if status.Rebalancing {
+		fmt.Printf("rebalancing from ring %s, %d keys held or queued here belong to other nodes\n",
+			status.PreviousRingVersion, status.ForeignKeys)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/listener"
+	"sharelock/pkg/locker"
+	"sharelock/server"
+
+	"github.com/coreos/go-systemd/v22/daemon"
+)
+
+// readyTimeout bounds the wait for the locker loop before READY=1.
+const readyTimeout = time.Second * 10
+
+// notifyReady tells systemd that ShareLock is up once the locker loop
+// answers and every enabled server listens. A server that failed to
+// listen keeps READY=1 from being sent, so systemd fails the start.
+// Outside of systemd it only checks and logs.
+func notifyReady(ctx context.Context, lockerInstance *locker.Locker, cfg *config.Config, grpcServer, httpServer server.Server) {
+	for _, name := range listener.CloseUnclaimed() {
+		slog.Warn("closed a systemd socket, name sockets http or grpc with FileDescriptorName", "name", name)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, readyTimeout)
+	defer cancel()
+	err := lockerInstance.Ping(pingCtx)
+	if err != nil {
+		slog.Error("locker did not start, not notifying readiness", "err", err)
+		sdNotify("STATUS=locker did not start")
+		return
+	}
+
+	servers := []struct {
+		name   string
+		enable bool
+		srv    server.Server
+	}{
+		{"grpc", cfg.GrpcServer.Enable, grpcServer},
+		{"http", cfg.HttpServer.Enable, httpServer},
+	}
+	addresses := make([]string, 0)
+	for _, s := range servers {
+		if !s.enable {
+			continue
+		}
+		listening, ok := s.srv.(server.Listening)
+		if !ok {
+			slog.Error("server is enabled but not listening, not notifying readiness", "server", s.name)
+			sdNotify(fmt.Sprintf("STATUS=%s server is not listening", s.name))
+			return
+		}
+		addresses = append(addresses, listening.Addresses()...)
+	}
+
+	sdNotify(daemon.SdNotifyReady + "\nSTATUS=listening on " + strings.Join(addresses, ", "))
+	slog.Info("ShareLock is ready")
+}
+
+// notifyStopping tells systemd that ShareLock is draining.
+func notifyStopping() {
+	sdNotify(daemon.SdNotifyStopping + "\nSTATUS=draining")
+}
+
+// watchdog pings the systemd watchdog while the locker loop answers,
+// until ctx is done. A wedged loop misses the pings and systemd restarts
+// ShareLock. It does nothing without WatchdogSec.
+func watchdog(ctx context.Context, lockerInstance *locker.Locker) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		slog.Error("reading the systemd watchdog settings", "err", err)
+		return
+	}
+	if interval == 0 {
+		return
+	}
+	// two pings per interval, each waiting for the loop at most until
+	// the next one
+	period := interval / 2
+	slog.Info("systemd watchdog enabled", "period", period)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, period)
+		err := lockerInstance.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("locker loop did not answer, skipping the watchdog ping", "timeout", period)
+			}
+			continue
+		}
+		sdNotify(daemon.SdNotifyWatchdog)
+	}
+}
+
+func sdNotify(state string) {
+	_, err := daemon.SdNotify(false, state)
+	if err != nil {
+		slog.Warn("notifying systemd", "err", err)
+	}
+}
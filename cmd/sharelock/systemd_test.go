@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/locker"
+	"sharelock/server"
+)
+
+// notifySocket listens where sd_notify writes and returns the messages.
+func notifySocket(t *testing.T) <-chan string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	messages := make(chan string, 16)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			messages <- string(buf[:n])
+		}
+	}()
+	return messages
+}
+
+func wantMessage(t *testing.T, messages <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-messages:
+		if got != want {
+			t.Fatalf("sent %q, want %q", got, want)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("nothing sent, want %q", want)
+	}
+}
+
+// listeningServer is a server listening on fixed addresses.
+type listeningServer struct {
+	server.Server
+	addresses []string
+}
+
+func (s listeningServer) Addresses() []string { return s.addresses }
+
+func startTestLocker(t *testing.T) *locker.Locker {
+	t.Helper()
+	lockerInstance := locker.NewLocker()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lockerInstance.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return lockerInstance
+}
+
+func TestNotifyReady(t *testing.T) {
+	grpcServer := listeningServer{server.NewMockServer(), []string{"127.0.0.1:50051", "unix:///run/sharelock/grpc.sock"}}
+	httpServer := listeningServer{server.NewMockServer(), []string{"[::1]:8080"}}
+	cfg := &config.Config{GrpcServer: &config.Server{Enable: true}, HttpServer: &config.Server{Enable: true}}
+
+	t.Run("ready", func(t *testing.T) {
+		messages := notifySocket(t)
+		notifyReady(context.Background(), startTestLocker(t), cfg, grpcServer, httpServer)
+		wantMessage(t, messages, "READY=1\nSTATUS=listening on 127.0.0.1:50051, unix:///run/sharelock/grpc.sock, [::1]:8080")
+	})
+	t.Run("disabled server", func(t *testing.T) {
+		messages := notifySocket(t)
+		cfg := &config.Config{GrpcServer: cfg.GrpcServer, HttpServer: &config.Server{Enable: false}}
+		notifyReady(context.Background(), startTestLocker(t), cfg, grpcServer, server.NewMockServer())
+		wantMessage(t, messages, "READY=1\nSTATUS=listening on 127.0.0.1:50051, unix:///run/sharelock/grpc.sock")
+	})
+	t.Run("server not listening", func(t *testing.T) {
+		messages := notifySocket(t)
+		notifyReady(context.Background(), startTestLocker(t), cfg, grpcServer, server.NewMockServer())
+		wantMessage(t, messages, "STATUS=http server is not listening")
+	})
+	t.Run("locker not started", func(t *testing.T) {
+		messages := notifySocket(t)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		notifyReady(ctx, locker.NewLocker(), cfg, grpcServer, httpServer)
+		wantMessage(t, messages, "STATUS=locker did not start")
+	})
+}
+
+func TestNotifyStopping(t *testing.T) {
+	messages := notifySocket(t)
+	notifyStopping()
+	wantMessage(t, messages, "STOPPING=1\nSTATUS=draining")
+}
+
+func TestWatchdog(t *testing.T) {
+	messages := notifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "100000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watchdog(ctx, startTestLocker(t))
+	}()
+	wantMessage(t, messages, "WATCHDOG=1")
+	wantMessage(t, messages, "WATCHDOG=1")
+	cancel()
+	<-done
+
+	// the watchdog of another process is not ours to ping
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	watchdog(context.Background(), startTestLocker(t))
+	select {
+	case got := <-messages:
+		t.Fatalf("sent %q without a watchdog", got)
+	default:
+	}
+}
@@ -1,106 +1,763 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/listener"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
-const (
-	configFile = "./config.yaml"
-)
+// DefaultPath is read when no --config flag is given.
+const DefaultPath = "./config.yaml"
 
 type Server struct {
-	Enable   bool
-	Port     int
-	TLS      bool
-	CertPath string
-	KeyPath  string
+	// ServiceName names the server in logs and ping responses
+	ServiceName string
+	Enable      bool
+	Port        int
+	// ListenAddresses are host:port, [ipv6]:port or unix:///path. They
+	// replace Port, which listens on every interface.
+	ListenAddresses []string
+	// UnixSocketMode is the file mode of the unix sockets
+	UnixSocketMode os.FileMode
+	TLS            bool
+	CertPath       string
+	KeyPath        string
+	// ClientCAPath is a PEM bundle of the CAs client certificates must
+	// chain to. Setting it turns on mutual TLS.
+	ClientCAPath string
+}
+
+// Auth lists the credentials accepted from clients. With Enable set,
+// every request except ping must carry one of them.
+type Auth struct {
+	Enable bool
+	// ApiKeys maps principal name to its key, or to "sha256:<hex>" of it
+	ApiKeys map[string]string
+	// JwtHmacSecrets maps key id to the secret of HS256/384/512 tokens
+	JwtHmacSecrets map[string]string
+	// JwtPublicKeys maps key id to a PEM file with an RSA, ECDSA or
+	// Ed25519 public key
+	JwtPublicKeys map[string]string
+	JwtIssuer     string
+	JwtAudience   string
+	// ClientCertificates is set when a server runs mutual TLS, the
+	// certificate identity then authenticates callers, with or
+	// without Enable
+	ClientCertificates bool
+	// AclPolicyPath is a yaml, json or toml file of auth.PolicyFile,
+	// reloaded on SIGHUP
+	AclPolicyPath string
+}
+
+// NamespaceLimits are the quotas of one namespace, zero is unlimited.
+type NamespaceLimits struct {
+	MaxHeldLocks      int     `yaml:"max_held_locks" env:"max_held_locks"`
+	MaxWaiters        int     `yaml:"max_waiters" env:"max_waiters"`
+	MaxLocksPerClient int     `yaml:"max_locks_per_client" env:"max_locks_per_client"`
+	RequestsPerSecond float64 `yaml:"requests_per_second" env:"requests_per_second"`
+	// RequestBurst defaults to one second worth of requests
+	RequestBurst int `yaml:"request_burst" env:"request_burst"`
+}
+
+type Namespaces struct {
+	Defaults NamespaceLimits
+	// Limits replace Defaults for the namespaces they name
+	Limits map[string]NamespaceLimits
 }
 
 type Config struct {
 	HttpServer *Server
 	GrpcServer *Server
+	Auth       *Auth
+	Namespaces *Namespaces
+	Limits     *Limits
+	Shutdown   *Shutdown
+	Lock       *Lock
+	Log        *Log
+	Metrics    *Metrics
+	Tracing    *Tracing
+	Audit      *Audit
+	State      *State
+	Cluster    *Cluster
+	Shard      *Shard
+	// WatchFile reloads the config file whenever it changes, in
+	// addition to SIGHUP
+	WatchFile bool
+}
+
+type Lock struct {
+	// DefaultLease is the lease of clients that do not ask for one
+	DefaultLease time.Duration
+}
+
+type Log struct {
+	// Level is one of debug, info, warn or error
+	Level string
+	// Format is text or json
+	Format string
+}
+
+// Metrics are served on /metrics of the http server.
+type Metrics struct {
+	Enable bool
+	// KeyPrefixDepth is the number of leading key segments of the
+	// key_prefix label, zero leaves the label out
+	KeyPrefixDepth     int
+	KeyPrefixSeparator string
+	// MaxKeyPrefixes caps the values of the key_prefix label
+	MaxKeyPrefixes int
+}
+
+// Audit writes a hash chained log of the lock operations.
+type Audit struct {
+	Enable bool
+	Dir    string
+	// MaxFileMB starts a new file past this size, zero never rotates
+	MaxFileMB int
+	// MaxFiles removes the oldest files beyond it, zero keeps them all
+	MaxFiles int
+}
+
+// State keeps the held locks on disk across restarts.
+type State struct {
+	// Store is wal, bolt or empty to keep the locks in memory only
+	Store string
+	Dir   string
+	// Sync flushes every change to the disk before answering the client
+	Sync bool
+	// SnapshotEvery compacts the wal after this many changes
+	SnapshotEvery int
+}
+
+// Cluster replicates the locker over raft, only the leader grants
+// locks.
+type Cluster struct {
+	Enable bool
+	NodeId string
+	// RaftAddress is listened on for the other nodes, RaftAdvertise is
+	// the address they dial, RaftAddress when empty
+	RaftAddress   string
+	RaftAdvertise string
+	Dir           string
+	// Bootstrap starts a new cluster of Peers, on the first start only
+	Bootstrap bool
+	// Peers are the id=host:port raft addresses of the first members
+	Peers []string
+	// GrpcAddress and HttpAddress are where other nodes and clients
+	// reach the servers of this node
+	GrpcAddress string
+	HttpAddress string
+	// CAPath verifies the gRPC server of the leader when grpc_tls is on,
+	// the system roots when empty
+	CAPath       string
+	ApplyTimeout time.Duration
+	// RaftCertPath and RaftKeyPath are presented to the other nodes on
+	// the raft transport, RaftCAPath verifies theirs
+	RaftCertPath string
+	RaftKeyPath  string
+	RaftCAPath   string
+	// RaftInsecure runs the raft transport in plaintext and without
+	// authentication, for a network nobody else can reach
+	RaftInsecure bool
+}
+
+// Shard partitions the keys between nodes, every node grants the keys
+// it owns on a consistent hash ring.
+type Shard struct {
+	Enable bool
+	NodeId string
+	// Members are the id=host:port gRPC addresses of all nodes, this one
+	// included. A reload changing them moves the keys.
+	Members      []string
+	VirtualNodes int
+	// Secret authenticates the calls between the nodes
+	Secret string
+	// CAPath verifies the gRPC servers of the other nodes, the system
+	// roots when empty
+	CAPath string
+	// ClientCertPath and ClientKeyPath are presented to the other nodes,
+	// whose gRPC servers require client certificates with
+	// grpc_client_ca_path
+	ClientCertPath string
+	ClientKeyPath  string
+}
+
+// Tracing exports OpenTelemetry spans of the lock requests.
+type Tracing struct {
+	// Exporter is otlp, stdout or empty for none
+	Exporter     string
+	OtlpEndpoint string
+	OtlpInsecure bool
+	// SampleRatio is the share of new traces recorded
+	SampleRatio float64
+	ServiceName string
+}
+
+type Shutdown struct {
+	// DrainPeriod is how long holders get to release their locks after
+	// SIGTERM or SIGINT, new locks are refused meanwhile
+	DrainPeriod time.Duration
+	// Timeout is how long in-flight requests get once the servers stop
+	Timeout time.Duration
+}
+
+// Limits protect the server from a single client, zero is unlimited.
+type Limits struct {
+	ClientRequestsPerSecond  float64
+	ClientRequestBurst       int
+	AddressRequestsPerSecond float64
+	AddressRequestBurst      int
+	// MaxLocksPerClient counts the locks of a client in all namespaces
+	MaxLocksPerClient   int
+	MaxPendingPerClient int
+	MaxKeyLength        int
 }
 
 type ConfigFlat struct {
-	Http_Server_Enable      bool   `yaml:"http_server_enable" env:"http_server_enable"`
-	Http_Server_Port        int    `yaml:"http_server_port" env:"http_server_port"`
-	Http_Server_ServiceName string `yaml:"http_server_service_name" env:"http_server_service_name"`
+	Http_Server_Enable      bool     `yaml:"http_server_enable" env:"http_server_enable"`
+	Http_Server_Port        int      `yaml:"http_server_port" env:"http_server_port"`
+	Http_Server_ServiceName string   `yaml:"http_server_service_name" env:"http_server_service_name"`
+	Http_ListenAddresses    []string `yaml:"http_listen_addresses" env:"http_listen_addresses"`
+	Http_UnixSocketMode     string   `yaml:"http_unix_socket_mode" env:"http_unix_socket_mode" env-default:"0660"`
+	Http_TLS                bool     `yaml:"http_tls" env:"http_tls"`
+	Http_CertPath           string   `yaml:"http_cert_path" env:"http_cert_path"`
+	Http_KeyPath            string   `yaml:"http_key_path" env:"http_key_path"`
+	Http_ClientCAPath       string   `yaml:"http_client_ca_path" env:"http_client_ca_path"`
+
+	Grpc_Server_Enable      bool     `yaml:"grpc_server_enable" env:"grpc_server_enable"`
+	Grpc_Server_Port        int      `yaml:"grpc_server_port" env:"grpc_server_port"`
+	Grpc_Server_ServiceName string   `yaml:"grpc_server_service_name" env:"grpc_server_service_name"`
+	Grpc_ListenAddresses    []string `yaml:"grpc_listen_addresses" env:"grpc_listen_addresses"`
+	Grpc_UnixSocketMode     string   `yaml:"grpc_unix_socket_mode" env:"grpc_unix_socket_mode" env-default:"0660"`
+	Grpc_TLS                bool     `yaml:"grpc_tls" env:"grpc_tls"`
+	Grpc_CertPath           string   `yaml:"grpc_cert_path" env:"grpc_cert_path"`
+	Grpc_KeyPath            string   `yaml:"grpc_key_path" env:"grpc_key_path"`
+	Grpc_ClientCAPath       string   `yaml:"grpc_client_ca_path" env:"grpc_client_ca_path"`
+
+	Auth_Enable         bool              `yaml:"auth_enable" env:"auth_enable"`
+	Auth_ApiKeys        map[string]string `yaml:"auth_api_keys" env:"auth_api_keys"`
+	Auth_JwtHmacSecrets map[string]string `yaml:"auth_jwt_hmac_secrets" env:"auth_jwt_hmac_secrets"`
+	Auth_JwtPublicKeys  map[string]string `yaml:"auth_jwt_public_keys" env:"auth_jwt_public_keys"`
+	Auth_JwtIssuer      string            `yaml:"auth_jwt_issuer" env:"auth_jwt_issuer"`
+	Auth_JwtAudience    string            `yaml:"auth_jwt_audience" env:"auth_jwt_audience"`
+	Auth_AclPolicyPath  string            `yaml:"auth_acl_policy_path" env:"auth_acl_policy_path"`
+
+	Namespace_DefaultLimits NamespaceLimits            `yaml:"namespace_default_limits" env-prefix:"namespace_default_"`
+	Namespace_Limits        map[string]NamespaceLimits `yaml:"namespace_limits"`
+
+	Limit_ClientRequestsPerSecond  float64 `yaml:"limit_client_requests_per_second" env:"limit_client_requests_per_second"`
+	Limit_ClientRequestBurst       int     `yaml:"limit_client_request_burst" env:"limit_client_request_burst"`
+	Limit_AddressRequestsPerSecond float64 `yaml:"limit_address_requests_per_second" env:"limit_address_requests_per_second"`
+	Limit_AddressRequestBurst      int     `yaml:"limit_address_request_burst" env:"limit_address_request_burst"`
+	Limit_MaxLocksPerClient        int     `yaml:"limit_max_locks_per_client" env:"limit_max_locks_per_client"`
+	Limit_MaxPendingPerClient      int     `yaml:"limit_max_pending_per_client" env:"limit_max_pending_per_client"`
+	Limit_MaxKeyLength             int     `yaml:"limit_max_key_length" env:"limit_max_key_length" env-default:"1024"`
+
+	Shutdown_DrainPeriod time.Duration `yaml:"shutdown_drain_period" env:"shutdown_drain_period" env-default:"10s"`
+	Shutdown_Timeout     time.Duration `yaml:"shutdown_timeout" env:"shutdown_timeout" env-default:"5s"`
+
+	Lock_DefaultLease time.Duration `yaml:"lock_default_lease" env:"lock_default_lease" env-default:"1m"`
+	Log_Level         string        `yaml:"log_level" env:"log_level" env-default:"info"`
+	Log_Format        string        `yaml:"log_format" env:"log_format" env-default:"text"`
+	Config_Watch      bool          `yaml:"config_watch" env:"config_watch"`
+
+	Metrics_Enable             bool   `yaml:"metrics_enable" env:"metrics_enable" env-default:"true"`
+	Metrics_KeyPrefixDepth     int    `yaml:"metrics_key_prefix_depth" env:"metrics_key_prefix_depth"`
+	Metrics_KeyPrefixSeparator string `yaml:"metrics_key_prefix_separator" env:"metrics_key_prefix_separator" env-default:"/"`
+	Metrics_MaxKeyPrefixes     int    `yaml:"metrics_max_key_prefixes" env:"metrics_max_key_prefixes" env-default:"100"`
+
+	Tracing_Exporter     string  `yaml:"tracing_exporter" env:"tracing_exporter"`
+	Tracing_OtlpEndpoint string  `yaml:"tracing_otlp_endpoint" env:"tracing_otlp_endpoint"`
+	Tracing_OtlpInsecure bool    `yaml:"tracing_otlp_insecure" env:"tracing_otlp_insecure"`
+	Tracing_SampleRatio  float64 `yaml:"tracing_sample_ratio" env:"tracing_sample_ratio" env-default:"1"`
+	Tracing_ServiceName  string  `yaml:"tracing_service_name" env:"tracing_service_name" env-default:"sharelock"`
 
-	Grpc_Server_Enable      bool   `yaml:"grpc_server_enable" env:"grpc_server_enable"`
-	Grpc_Server_Port        int    `yaml:"grpc_server_port" env:"grpc_server_port"`
-	Groc_Server_ServiceName string `yaml:"grpc_server_service_name" env:"grpc_server_service_name"`
-	Grpc_TLS                bool   `yaml:"grpc_tls" env:"grpc_tls"`
-	Grpc_CertPath           string `yaml:"grpc_cert_path" env:"grpc_cert_path"`
-	Grpc_KeyPath            string `yaml:"grpc_key_path" env:"grpc_key_path"`
+	Audit_Enable    bool   `yaml:"audit_enable" env:"audit_enable"`
+	Audit_Dir       string `yaml:"audit_dir" env:"audit_dir" env-default:"./audit"`
+	Audit_MaxFileMB int    `yaml:"audit_max_file_mb" env:"audit_max_file_mb" env-default:"100"`
+	Audit_MaxFiles  int    `yaml:"audit_max_files" env:"audit_max_files"`
+
+	State_Store         string `yaml:"state_store" env:"state_store"`
+	State_Dir           string `yaml:"state_dir" env:"state_dir" env-default:"./state"`
+	State_Sync          bool   `yaml:"state_sync" env:"state_sync" env-default:"true"`
+	State_SnapshotEvery int    `yaml:"state_snapshot_every" env:"state_snapshot_every" env-default:"10000"`
+
+	Cluster_Enable        bool          `yaml:"cluster_enable" env:"cluster_enable"`
+	Cluster_NodeId        string        `yaml:"cluster_node_id" env:"cluster_node_id"`
+	Cluster_RaftAddress   string        `yaml:"cluster_raft_address" env:"cluster_raft_address"`
+	Cluster_RaftAdvertise string        `yaml:"cluster_raft_advertise" env:"cluster_raft_advertise"`
+	Cluster_Dir           string        `yaml:"cluster_dir" env:"cluster_dir" env-default:"./raft"`
+	Cluster_Bootstrap     bool          `yaml:"cluster_bootstrap" env:"cluster_bootstrap"`
+	Cluster_Peers         []string      `yaml:"cluster_peers" env:"cluster_peers"`
+	Cluster_GrpcAddress   string        `yaml:"cluster_grpc_address" env:"cluster_grpc_address"`
+	Cluster_HttpAddress   string        `yaml:"cluster_http_address" env:"cluster_http_address"`
+	Cluster_CAPath        string        `yaml:"cluster_ca_path" env:"cluster_ca_path"`
+	Cluster_ApplyTimeout  time.Duration `yaml:"cluster_apply_timeout" env:"cluster_apply_timeout" env-default:"5s"`
+	Cluster_RaftCertPath  string        `yaml:"cluster_raft_cert_path" env:"cluster_raft_cert_path"`
+	Cluster_RaftKeyPath   string        `yaml:"cluster_raft_key_path" env:"cluster_raft_key_path"`
+	Cluster_RaftCAPath    string        `yaml:"cluster_raft_ca_path" env:"cluster_raft_ca_path"`
+	Cluster_RaftInsecure  bool          `yaml:"cluster_raft_insecure" env:"cluster_raft_insecure"`
+
+	Shard_Enable         bool     `yaml:"shard_enable" env:"shard_enable"`
+	Shard_NodeId         string   `yaml:"shard_node_id" env:"shard_node_id"`
+	Shard_Members        []string `yaml:"shard_members" env:"shard_members"`
+	Shard_VirtualNodes   int      `yaml:"shard_virtual_nodes" env:"shard_virtual_nodes" env-default:"128"`
+	Shard_Secret         string   `yaml:"shard_secret" env:"shard_secret"`
+	Shard_CAPath         string   `yaml:"shard_ca_path" env:"shard_ca_path"`
+	Shard_ClientCertPath string   `yaml:"shard_client_cert_path" env:"shard_client_cert_path"`
+	Shard_ClientKeyPath  string   `yaml:"shard_client_key_path" env:"shard_client_key_path"`
 }
 
-func ReadConfig() *Config {
-	// read config
-	var readConfig ConfigFlat
-	log.Print("[INFO] reading config file : ", configFile)
-	err := cleanenv.ReadConfig(configFile, &readConfig)
+// ReadConfig loads the config of src and exits on any error.
+func ReadConfig(src Source) *Config {
+	cfg, err := Load(src)
 	if err != nil {
-		log.Printf("[ERROR] reading config file %s : %s", configFile, err.Error())
-		log.Printf("[INFO] reading config from env")
+		slog.Error("reading config", "err", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// Load reads and validates the config of src: the file, overridden by
+// the SHARELOCK_ environment variables, overridden by the flags. A
+// missing file leaves only the other two. The unprefixed environment
+// variables of older releases are still read, below the prefixed ones.
+// Load has no side effect, so a reload can check a new config before
+// using any of it. A config that is read but invalid is returned along
+// with the error, to show what it would have changed.
+func Load(src Source) (*Config, error) {
+	var readConfig ConfigFlat
+	slog.Info("reading config file", "path", src.Path)
+	err := cleanenv.ReadConfig(src.Path, &readConfig)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("config file not found, reading config from env and flags", "path", src.Path)
 		err = cleanenv.ReadEnv(&readConfig)
 		if err != nil {
-			log.Fatal("[ERROR] reading config from env : ", err.Error())
+			return nil, fmt.Errorf("reading config from env : %w", err)
 		}
+	} else if err != nil {
+		return nil, fmt.Errorf("reading config file %s : %w", src.Path, err)
+	}
+	err = src.override(&readConfig)
+	if err != nil {
+		return nil, err
 	}
-	log.Println("[INFO] config file read successfully")
-
-	validate(&readConfig)
 
-	return &Config{
+	cfg := &Config{
 		HttpServer: &Server{
-			Enable: readConfig.Http_Server_Enable,
-			Port:   readConfig.Http_Server_Port,
+			ServiceName:     readConfig.Http_Server_ServiceName,
+			Enable:          readConfig.Http_Server_Enable,
+			Port:            readConfig.Http_Server_Port,
+			ListenAddresses: readConfig.Http_ListenAddresses,
+			UnixSocketMode:  parseSocketMode(readConfig.Http_UnixSocketMode),
+			TLS:             readConfig.Http_TLS,
+			CertPath:        readConfig.Http_CertPath,
+			KeyPath:         readConfig.Http_KeyPath,
+			ClientCAPath:    readConfig.Http_ClientCAPath,
 		},
 		GrpcServer: &Server{
-			Enable:   readConfig.Grpc_Server_Enable,
-			Port:     readConfig.Grpc_Server_Port,
-			TLS:      readConfig.Grpc_TLS,
-			CertPath: readConfig.Grpc_CertPath,
-			KeyPath:  readConfig.Grpc_KeyPath,
+			ServiceName:     readConfig.Grpc_Server_ServiceName,
+			Enable:          readConfig.Grpc_Server_Enable,
+			Port:            readConfig.Grpc_Server_Port,
+			ListenAddresses: readConfig.Grpc_ListenAddresses,
+			UnixSocketMode:  parseSocketMode(readConfig.Grpc_UnixSocketMode),
+			TLS:             readConfig.Grpc_TLS,
+			CertPath:        readConfig.Grpc_CertPath,
+			KeyPath:         readConfig.Grpc_KeyPath,
+			ClientCAPath:    readConfig.Grpc_ClientCAPath,
+		},
+		Auth: &Auth{
+			Enable:         readConfig.Auth_Enable,
+			ApiKeys:        readConfig.Auth_ApiKeys,
+			JwtHmacSecrets: readConfig.Auth_JwtHmacSecrets,
+			JwtPublicKeys:  readConfig.Auth_JwtPublicKeys,
+			JwtIssuer:      readConfig.Auth_JwtIssuer,
+			JwtAudience:    readConfig.Auth_JwtAudience,
+			AclPolicyPath:  readConfig.Auth_AclPolicyPath,
+			ClientCertificates: (readConfig.Http_Server_Enable && readConfig.Http_ClientCAPath != "") ||
+				(readConfig.Grpc_Server_Enable && readConfig.Grpc_ClientCAPath != ""),
+		},
+		Namespaces: &Namespaces{
+			Defaults: readConfig.Namespace_DefaultLimits,
+			Limits:   readConfig.Namespace_Limits,
+		},
+		Shutdown: &Shutdown{
+			DrainPeriod: readConfig.Shutdown_DrainPeriod,
+			Timeout:     readConfig.Shutdown_Timeout,
+		},
+		Limits: &Limits{
+			ClientRequestsPerSecond:  readConfig.Limit_ClientRequestsPerSecond,
+			ClientRequestBurst:       readConfig.Limit_ClientRequestBurst,
+			AddressRequestsPerSecond: readConfig.Limit_AddressRequestsPerSecond,
+			AddressRequestBurst:      readConfig.Limit_AddressRequestBurst,
+			MaxLocksPerClient:        readConfig.Limit_MaxLocksPerClient,
+			MaxPendingPerClient:      readConfig.Limit_MaxPendingPerClient,
+			MaxKeyLength:             readConfig.Limit_MaxKeyLength,
+		},
+		Lock: &Lock{
+			DefaultLease: readConfig.Lock_DefaultLease,
+		},
+		Log: &Log{
+			Level:  readConfig.Log_Level,
+			Format: readConfig.Log_Format,
+		},
+		Metrics: &Metrics{
+			Enable:             readConfig.Metrics_Enable,
+			KeyPrefixDepth:     readConfig.Metrics_KeyPrefixDepth,
+			KeyPrefixSeparator: readConfig.Metrics_KeyPrefixSeparator,
+			MaxKeyPrefixes:     readConfig.Metrics_MaxKeyPrefixes,
 		},
+		Tracing: &Tracing{
+			Exporter:     readConfig.Tracing_Exporter,
+			OtlpEndpoint: readConfig.Tracing_OtlpEndpoint,
+			OtlpInsecure: readConfig.Tracing_OtlpInsecure,
+			SampleRatio:  readConfig.Tracing_SampleRatio,
+			ServiceName:  readConfig.Tracing_ServiceName,
+		},
+		Audit: &Audit{
+			Enable:    readConfig.Audit_Enable,
+			Dir:       readConfig.Audit_Dir,
+			MaxFileMB: readConfig.Audit_MaxFileMB,
+			MaxFiles:  readConfig.Audit_MaxFiles,
+		},
+		State: &State{
+			Store:         readConfig.State_Store,
+			Dir:           readConfig.State_Dir,
+			Sync:          readConfig.State_Sync,
+			SnapshotEvery: readConfig.State_SnapshotEvery,
+		},
+		Cluster: &Cluster{
+			Enable:        readConfig.Cluster_Enable,
+			NodeId:        readConfig.Cluster_NodeId,
+			RaftAddress:   readConfig.Cluster_RaftAddress,
+			RaftAdvertise: readConfig.Cluster_RaftAdvertise,
+			Dir:           readConfig.Cluster_Dir,
+			Bootstrap:     readConfig.Cluster_Bootstrap,
+			Peers:         readConfig.Cluster_Peers,
+			GrpcAddress:   readConfig.Cluster_GrpcAddress,
+			HttpAddress:   readConfig.Cluster_HttpAddress,
+			CAPath:        readConfig.Cluster_CAPath,
+			ApplyTimeout:  readConfig.Cluster_ApplyTimeout,
+			RaftCertPath:  readConfig.Cluster_RaftCertPath,
+			RaftKeyPath:   readConfig.Cluster_RaftKeyPath,
+			RaftCAPath:    readConfig.Cluster_RaftCAPath,
+			RaftInsecure:  readConfig.Cluster_RaftInsecure,
+		},
+		Shard: &Shard{
+			Enable:         readConfig.Shard_Enable,
+			NodeId:         readConfig.Shard_NodeId,
+			Members:        readConfig.Shard_Members,
+			VirtualNodes:   readConfig.Shard_VirtualNodes,
+			Secret:         readConfig.Shard_Secret,
+			CAPath:         readConfig.Shard_CAPath,
+			ClientCertPath: readConfig.Shard_ClientCertPath,
+			ClientKeyPath:  readConfig.Shard_ClientKeyPath,
+		},
+		WatchFile: readConfig.Config_Watch,
 	}
+
+	err = validate(&readConfig)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, nil
 }
 
-func validate(cfg *ConfigFlat) {
-	if cfg == nil {
-		log.Fatal("[ERROR] config is nil")
+// parseSocketMode reads an octal file mode, zero if it is not one.
+func parseSocketMode(mode string) os.FileMode {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m == 0 || m > 0o777 {
+		return 0
+	}
+	return os.FileMode(m)
+}
+
+// ValidationError lists every problem of an invalid config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config : " + strings.Join(e.Problems, ", ")
+}
+
+func validate(cfg *ConfigFlat) error {
+	problems := make([]string, 0)
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// server checks
+	servers := []struct {
+		prefix            string
+		enable, tls       bool
+		port              int
+		listen            []string
+		socketMode        string
+		serviceName       string
+		cert, key, caPath string
+	}{
+		{"http", cfg.Http_Server_Enable, cfg.Http_TLS, cfg.Http_Server_Port, cfg.Http_ListenAddresses, cfg.Http_UnixSocketMode,
+			cfg.Http_Server_ServiceName, cfg.Http_CertPath, cfg.Http_KeyPath, cfg.Http_ClientCAPath},
+		{"grpc", cfg.Grpc_Server_Enable, cfg.Grpc_TLS, cfg.Grpc_Server_Port, cfg.Grpc_ListenAddresses, cfg.Grpc_UnixSocketMode,
+			cfg.Grpc_Server_ServiceName, cfg.Grpc_CertPath, cfg.Grpc_KeyPath, cfg.Grpc_ClientCAPath},
+	}
+	for _, srv := range servers {
+		if !srv.enable {
+			continue
+		}
+		if len(srv.listen) == 0 && (srv.port <= 0 || srv.port > 65535) {
+			fail("%s_server_port %d is invalid", srv.prefix, srv.port)
+		}
+		for _, address := range srv.listen {
+			_, _, err := listener.Parse(address)
+			if err != nil {
+				fail("%s_listen_addresses : %s", srv.prefix, err)
+			}
+		}
+		if parseSocketMode(srv.socketMode) == 0 {
+			fail("%s_unix_socket_mode %q is not an octal file mode such as 0660", srv.prefix, srv.socketMode)
+		}
+		if srv.serviceName == "" {
+			fail("%s_server_service_name is not set", srv.prefix)
+		}
+		if srv.tls && srv.cert == "" {
+			fail("%s_cert_path is not set", srv.prefix)
+		}
+		if srv.tls && srv.key == "" {
+			fail("%s_key_path is not set", srv.prefix)
+		}
+		if srv.caPath != "" && !srv.tls {
+			fail("%s_client_ca_path requires %s_tls", srv.prefix, srv.prefix)
+		}
+	}
+	if cfg.Http_Server_Enable && cfg.Grpc_Server_Enable && len(cfg.Http_ListenAddresses) == 0 && len(cfg.Grpc_ListenAddresses) == 0 &&
+		cfg.Http_Server_Port == cfg.Grpc_Server_Port {
+		fail("http_server_port and grpc_server_port are both %d", cfg.Http_Server_Port)
 	}
 
-	// http server checks
-	if cfg.Http_Server_Enable {
-		log.Print("[INFO] http server is enabled in config")
-		if cfg.Http_Server_Port <= 0 {
-			log.Fatal("[ERROR] server_port is invalid")
+	// auth checks
+	if cfg.Auth_Enable {
+		if len(cfg.Auth_ApiKeys) == 0 && len(cfg.Auth_JwtHmacSecrets) == 0 && len(cfg.Auth_JwtPublicKeys) == 0 &&
+			cfg.Http_ClientCAPath == "" && cfg.Grpc_ClientCAPath == "" {
+			fail("auth_enable is set but no auth_api_keys, auth_jwt_hmac_secrets, auth_jwt_public_keys or client ca is configured")
 		}
-		if cfg.Http_Server_ServiceName == "" {
-			log.Fatal("[ERROR] server_service_name is not set")
+	}
+
+	// with client certificates as the only credentials, a server without
+	// mutual tls could not authenticate anybody
+	headerCredentials := cfg.Auth_Enable && (len(cfg.Auth_ApiKeys) > 0 || len(cfg.Auth_JwtHmacSecrets) > 0 || len(cfg.Auth_JwtPublicKeys) > 0)
+	mtls := (cfg.Http_Server_Enable && cfg.Http_ClientCAPath != "") || (cfg.Grpc_Server_Enable && cfg.Grpc_ClientCAPath != "")
+	if cfg.Auth_AclPolicyPath != "" && !headerCredentials && !mtls {
+		fail("auth_acl_policy_path needs authenticated callers, set auth_enable or a client ca")
+	}
+	if mtls && !headerCredentials {
+		if cfg.Http_Server_Enable && cfg.Http_ClientCAPath == "" {
+			fail("http server needs http_client_ca_path, client certificates are the only credentials")
+		}
+		if cfg.Grpc_Server_Enable && cfg.Grpc_ClientCAPath == "" {
+			fail("grpc server needs grpc_client_ca_path, client certificates are the only credentials")
 		}
 	}
 
-	// grpc server checks
-	if cfg.Grpc_Server_Enable {
-		log.Print("[INFO] grpc server is enabled in config")
-		if cfg.Grpc_Server_Port <= 0 {
-			log.Fatal("[ERROR] grpc_server_port is invalid")
+	// namespace checks
+	if !validNamespaceLimits(cfg.Namespace_DefaultLimits) {
+		fail("namespace_default_limits must not be negative")
+	}
+	names := make([]string, 0, len(cfg.Namespace_Limits))
+	for name := range cfg.Namespace_Limits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			fail("namespace_limits has an empty namespace name")
+		}
+		if !validNamespaceLimits(cfg.Namespace_Limits[name]) {
+			fail("namespace_limits.%s must not be negative", name)
 		}
-		if cfg.Groc_Server_ServiceName == "" {
-			log.Fatal("[ERROR] grpc_server_service_name is not set")
+	}
+
+	// shutdown checks
+	if cfg.Shutdown_DrainPeriod < 0 {
+		fail("shutdown_drain_period must not be negative")
+	}
+	if cfg.Shutdown_Timeout < 0 {
+		fail("shutdown_timeout must not be negative")
+	}
+
+	// limit checks
+	limits := []struct {
+		name  string
+		value float64
+	}{
+		{"limit_client_requests_per_second", cfg.Limit_ClientRequestsPerSecond},
+		{"limit_client_request_burst", float64(cfg.Limit_ClientRequestBurst)},
+		{"limit_address_requests_per_second", cfg.Limit_AddressRequestsPerSecond},
+		{"limit_address_request_burst", float64(cfg.Limit_AddressRequestBurst)},
+		{"limit_max_locks_per_client", float64(cfg.Limit_MaxLocksPerClient)},
+		{"limit_max_pending_per_client", float64(cfg.Limit_MaxPendingPerClient)},
+		{"limit_max_key_length", float64(cfg.Limit_MaxKeyLength)},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			fail("%s must not be negative", limit.name)
 		}
-		if cfg.Grpc_TLS {
-			log.Print("[INFO] tls is enabled in grpc server")
-			if cfg.Grpc_CertPath == "" {
-				log.Fatal("[ERROR] grpc_cert_path is not set")
+	}
+
+	// lock and log checks
+	if cfg.Lock_DefaultLease <= 0 {
+		fail("lock_default_lease must be positive")
+	}
+	_, err := helpers.ParseLogLevel(cfg.Log_Level)
+	if err != nil {
+		fail("log_level : %s", err)
+	}
+	_, err = helpers.NewLogHandler(io.Discard, cfg.Log_Format)
+	if err != nil {
+		fail("log_format : %s", err)
+	}
+
+	// metrics checks
+	if cfg.Metrics_KeyPrefixDepth < 0 {
+		fail("metrics_key_prefix_depth must not be negative")
+	}
+	if cfg.Metrics_KeyPrefixDepth > 0 && cfg.Metrics_MaxKeyPrefixes <= 0 {
+		fail("metrics_max_key_prefixes must be positive with metrics_key_prefix_depth")
+	}
+
+	// tracing checks
+	switch cfg.Tracing_Exporter {
+	case "", "otlp", "stdout":
+	default:
+		fail("tracing_exporter %q is unknown, use otlp, stdout or leave it empty", cfg.Tracing_Exporter)
+	}
+	if cfg.Tracing_SampleRatio < 0 || cfg.Tracing_SampleRatio > 1 {
+		fail("tracing_sample_ratio must be between 0 and 1")
+	}
+
+	// audit checks
+	if cfg.Audit_Enable && cfg.Audit_Dir == "" {
+		fail("audit_dir is required with audit_enable")
+	}
+	if cfg.Audit_MaxFileMB < 0 {
+		fail("audit_max_file_mb must not be negative")
+	}
+	if cfg.Audit_MaxFiles < 0 {
+		fail("audit_max_files must not be negative")
+	}
+
+	// state checks
+	switch cfg.State_Store {
+	case "", "wal", "bolt":
+	default:
+		fail("state_store %q is unknown, use wal, bolt or leave it empty", cfg.State_Store)
+	}
+	if cfg.State_Store != "" && cfg.State_Dir == "" {
+		fail("state_dir is required with state_store")
+	}
+	if cfg.State_SnapshotEvery < 0 {
+		fail("state_snapshot_every must not be negative")
+	}
+
+	// cluster checks
+	if cfg.Cluster_Enable {
+		if cfg.Cluster_NodeId == "" {
+			fail("cluster_node_id is required with cluster_enable")
+		}
+		if cfg.Cluster_RaftAddress == "" {
+			fail("cluster_raft_address is required with cluster_enable")
+		}
+		if cfg.Cluster_Dir == "" {
+			fail("cluster_dir is required with cluster_enable")
+		}
+		if cfg.Cluster_GrpcAddress == "" || !cfg.Grpc_Server_Enable {
+			fail("cluster_grpc_address and grpc_server_enable are required with cluster_enable, followers forward to the leader over gRPC")
+		}
+		if cfg.Http_Server_Enable && cfg.Cluster_HttpAddress == "" {
+			fail("cluster_http_address is required with cluster_enable and http_server_enable")
+		}
+		if cfg.State_Store != "" {
+			fail("state_store must be empty with cluster_enable, the raft log keeps the locks")
+		}
+		if cfg.Auth_AclPolicyPath == "" {
+			fail("auth_acl_policy_path is required with cluster_enable, only callers with an admin grant may change the members")
+		}
+		if cfg.Cluster_ApplyTimeout <= 0 {
+			fail("cluster_apply_timeout must be positive")
+		}
+		raftTLS := cfg.Cluster_RaftCertPath != "" || cfg.Cluster_RaftKeyPath != "" || cfg.Cluster_RaftCAPath != ""
+		switch {
+		case raftTLS && cfg.Cluster_RaftInsecure:
+			fail("cluster_raft_insecure cannot be combined with cluster_raft_cert_path, cluster_raft_key_path and cluster_raft_ca_path")
+		case raftTLS && (cfg.Cluster_RaftCertPath == "" || cfg.Cluster_RaftKeyPath == "" || cfg.Cluster_RaftCAPath == ""):
+			fail("cluster_raft_cert_path, cluster_raft_key_path and cluster_raft_ca_path are required together")
+		case !raftTLS && !cfg.Cluster_RaftInsecure:
+			fail("cluster_raft_cert_path, cluster_raft_key_path and cluster_raft_ca_path are required with cluster_enable, or cluster_raft_insecure to run raft without tls")
+		}
+		for _, peer := range cfg.Cluster_Peers {
+			if !strings.Contains(peer, "=") {
+				fail("cluster_peers entry %q must be id=host:port", peer)
 			}
-			if cfg.Grpc_KeyPath == "" {
-				log.Fatal("[ERROR] grpc_key_path is not set")
+		}
+	}
+
+	// shard checks
+	if cfg.Shard_Enable {
+		if cfg.Cluster_Enable {
+			fail("shard_enable and cluster_enable cannot be combined")
+		}
+		if cfg.Shard_NodeId == "" {
+			fail("shard_node_id is required with shard_enable")
+		}
+		if cfg.Shard_Secret == "" {
+			fail("shard_secret is required with shard_enable, the nodes authenticate each other with it")
+		}
+		if !cfg.Grpc_Server_Enable {
+			fail("grpc_server_enable is required with shard_enable, the nodes forward requests over gRPC")
+		}
+		if !cfg.Grpc_TLS {
+			fail("grpc_tls is required with shard_enable, the nodes send shard_secret to each other")
+		}
+		if (cfg.Shard_ClientCertPath == "") != (cfg.Shard_ClientKeyPath == "") {
+			fail("shard_client_cert_path and shard_client_key_path are required together")
+		}
+		if cfg.Grpc_ClientCAPath != "" && cfg.Shard_ClientCertPath == "" {
+			fail("shard_client_cert_path and shard_client_key_path are required with shard_enable and grpc_client_ca_path, the nodes present them to each other")
+		}
+		if len(cfg.Shard_Members) == 0 {
+			fail("shard_members is required with shard_enable")
+		}
+		if cfg.Shard_VirtualNodes <= 0 {
+			fail("shard_virtual_nodes must be positive")
+		}
+		for _, member := range cfg.Shard_Members {
+			id, address, ok := strings.Cut(member, "=")
+			if !ok || id == "" || address == "" {
+				fail("shard_members entry %q must be id=host:port", member)
 			}
 		}
 	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validNamespaceLimits(limits NamespaceLimits) bool {
+	return limits.MaxHeldLocks >= 0 && limits.MaxWaiters >= 0 && limits.MaxLocksPerClient >= 0 &&
+		limits.RequestsPerSecond >= 0 && limits.RequestBurst >= 0
 }
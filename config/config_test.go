@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `http_server_enable: true
+http_server_service_name: from-file
+http_server_port: 8080
+lock_default_lease: 1m
+log_level: warn
+`)
+	// the unprefixed variables of older releases sit below the
+	// prefixed ones
+	t.Setenv("log_level", "error")
+	t.Setenv(EnvPrefix+"LOG_LEVEL", "debug")
+	t.Setenv(EnvPrefix+"LOCK_DEFAULT_LEASE", "2m")
+	t.Setenv(EnvPrefix+"HTTP_SERVER_PORT", "9090")
+
+	cfg, err := Load(Source{Path: path, Flags: map[string]string{"http_server_port": "9191"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.HttpServer.Port != 9191 {
+		t.Errorf("port %d, want the flag 9191", cfg.HttpServer.Port)
+	}
+	if cfg.Lock.DefaultLease != time.Minute*2 {
+		t.Errorf("lease %s, want the env 2m", cfg.Lock.DefaultLease)
+	}
+	if cfg.Log.Level != "debug" {
+		t.Errorf("log level %s, want the prefixed env debug", cfg.Log.Level)
+	}
+	if cfg.HttpServer.ServiceName != "from-file" {
+		t.Errorf("service name %q, want the file from-file", cfg.HttpServer.ServiceName)
+	}
+	if cfg.Shutdown.Timeout != time.Second*5 {
+		t.Errorf("shutdown timeout %s, want the default 5s", cfg.Shutdown.Timeout)
+	}
+}
+
+func TestLoadBadOverride(t *testing.T) {
+	path := writeConfig(t, "http_server_enable: false\n")
+	t.Setenv(EnvPrefix+"LOCK_DEFAULT_LEASE", "soon")
+	_, err := Load(Source{Path: path})
+	if err == nil || !strings.Contains(err.Error(), "SHARELOCK_LOCK_DEFAULT_LEASE") {
+		t.Fatalf("got %v, want an error naming the env variable", err)
+	}
+
+	t.Setenv(EnvPrefix+"LOCK_DEFAULT_LEASE", "2m")
+	_, err = Load(Source{Path: path, Flags: map[string]string{"http_server_port": "http"}})
+	if err == nil || !strings.Contains(err.Error(), "--http-server-port") {
+		t.Fatalf("got %v, want an error naming the flag", err)
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	path := writeConfig(t, `http_server_enable: true
+http_server_port: 70000
+http_tls: true
+lock_default_lease: -1s
+tracing_sample_ratio: 2
+`)
+	cfg, err := Load(Source{Path: path})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	want := []string{
+		"http_server_port 70000 is invalid",
+		"http_server_service_name is not set",
+		"http_cert_path is not set",
+		"http_key_path is not set",
+		"lock_default_lease must be positive",
+		"tracing_sample_ratio must be between 0 and 1",
+	}
+	for _, problem := range want {
+		if !slices.Contains(invalid.Problems, problem) {
+			t.Errorf("problems %q miss %q", invalid.Problems, problem)
+		}
+	}
+	if cfg == nil || cfg.HttpServer.Port != 70000 {
+		t.Fatal("an invalid config was not returned along with the error")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	// Load relies on cleanenv keeping the error of the file system
+	var flat ConfigFlat
+	err := cleanenv.ReadConfig(path, &flat)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("cleanenv returned %v for a missing file, want fs.ErrNotExist", err)
+	}
+
+	t.Setenv(EnvPrefix+"LOCK_DEFAULT_LEASE", "3m")
+	cfg, err := Load(Source{Path: path, Flags: map[string]string{"log_level": "debug"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lock.DefaultLease != time.Minute*3 || cfg.Log.Level != "debug" {
+		t.Fatalf("got lease %s and log level %s, want the env and the flag", cfg.Lock.DefaultLease, cfg.Log.Level)
+	}
+	if cfg.Limits.MaxKeyLength != 1024 {
+		t.Fatalf("max key length %d, want the default 1024", cfg.Limits.MaxKeyLength)
+	}
+
+	_, err = Load(Source{Path: writeConfig(t, "http_server_port: [")})
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v for a broken file, want a read error", err)
+	}
+}
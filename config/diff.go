@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is one setting that differs between two configs.
+type Change struct {
+	// Field is the path of the setting, such as Limits.MaxKeyLength
+	Field string
+	Old   string
+	New   string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s : %s -> %s", c.Field, c.Old, c.New)
+}
+
+// secretFields are never printed, a change only shows as redacted.
+var secretFields = map[string]bool{
+	"Auth.ApiKeys":        true,
+	"Auth.JwtHmacSecrets": true,
+	"Shard.Secret":        true,
+}
+
+// Diff lists the settings that differ between old and new, sorted by
+// field. Map entries are compared one by one.
+func Diff(old, new *Config) []Change {
+	changes := make([]Change, 0)
+	diffValue("", reflect.ValueOf(old), reflect.ValueOf(new), &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+func diffValue(field string, old, new reflect.Value, changes *[]Change) {
+	if secretFields[field] {
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, Change{Field: field, Old: "<redacted>", New: "<redacted>"})
+		}
+		return
+	}
+
+	switch old.Kind() {
+	case reflect.Pointer:
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				*changes = append(*changes, Change{Field: field, Old: formatValue(old), New: formatValue(new)})
+			}
+			return
+		}
+		diffValue(field, old.Elem(), new.Elem(), changes)
+	case reflect.Struct:
+		for i := 0; i < old.NumField(); i++ {
+			diffValue(joinField(field, old.Type().Field(i).Name), old.Field(i), new.Field(i), changes)
+		}
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range old.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for _, key := range new.MapKeys() {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for name, key := range keys {
+			entry := joinField(field, name)
+			oldEntry, newEntry := old.MapIndex(key), new.MapIndex(key)
+			switch {
+			case !oldEntry.IsValid():
+				*changes = append(*changes, Change{Field: entry, Old: "<unset>", New: formatValue(newEntry)})
+			case !newEntry.IsValid():
+				*changes = append(*changes, Change{Field: entry, Old: formatValue(oldEntry), New: "<unset>"})
+			default:
+				diffValue(entry, oldEntry, newEntry, changes)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(old.Interface(), new.Interface()) {
+			*changes = append(*changes, Change{Field: field, Old: formatValue(old), New: formatValue(new)})
+		}
+	}
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "<unset>"
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.String {
+		return fmt.Sprintf("%q", v.String())
+	}
+	return strings.TrimSpace(fmt.Sprintf("%+v", v.Interface()))
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			HttpServer: &Server{Port: 8080, CertPath: "cert.pem"},
+			Auth: &Auth{
+				ApiKeys:        map[string]string{"ci": "secret"},
+				JwtHmacSecrets: map[string]string{"main": "secret"},
+			},
+			Namespaces: &Namespaces{Limits: map[string]NamespaceLimits{
+				"jobs": {MaxWaiters: 10},
+			}},
+			Lock: &Lock{DefaultLease: time.Minute},
+		}
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		want   []Change
+	}{
+		{name: "same", change: func(c *Config) {}, want: []Change{}},
+		{name: "nested fields sorted", change: func(c *Config) {
+			c.Lock.DefaultLease = time.Minute * 2
+			c.HttpServer.CertPath = "other.pem"
+		}, want: []Change{
+			{Field: "HttpServer.CertPath", Old: `"cert.pem"`, New: `"other.pem"`},
+			{Field: "Lock.DefaultLease", Old: "1m0s", New: "2m0s"},
+		}},
+		{name: "pointer set", change: func(c *Config) {
+			c.Shard = &Shard{}
+		}, want: []Change{
+			{Field: "Shard", Old: "<unset>", New: "{Enable:false NodeId: Members:[] VirtualNodes:0 Secret: CAPath: ClientCertPath: ClientKeyPath:}"},
+		}},
+		{name: "map entries", change: func(c *Config) {
+			c.Namespaces.Limits = map[string]NamespaceLimits{
+				"jobs":   {MaxWaiters: 20},
+				"builds": {MaxHeldLocks: 1},
+			}
+		}, want: []Change{
+			{Field: "Namespaces.Limits.builds", Old: "<unset>", New: "{MaxHeldLocks:1 MaxWaiters:0 MaxLocksPerClient:0 RequestsPerSecond:0 RequestBurst:0}"},
+			{Field: "Namespaces.Limits.jobs.MaxWaiters", Old: "10", New: "20"},
+		}},
+		{name: "map entry removed", change: func(c *Config) {
+			delete(c.Namespaces.Limits, "jobs")
+		}, want: []Change{
+			{Field: "Namespaces.Limits.jobs", Old: "{MaxHeldLocks:0 MaxWaiters:10 MaxLocksPerClient:0 RequestsPerSecond:0 RequestBurst:0}", New: "<unset>"},
+		}},
+		{name: "secrets redacted", change: func(c *Config) {
+			c.Auth.ApiKeys["ci"] = "rotated"
+			c.Auth.JwtHmacSecrets["other"] = "secret"
+		}, want: []Change{
+			{Field: "Auth.ApiKeys", Old: "<redacted>", New: "<redacted>"},
+			{Field: "Auth.JwtHmacSecrets", Old: "<redacted>", New: "<redacted>"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base()
+			tt.change(next)
+			got := Diff(base(), next)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
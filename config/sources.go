@@ -0,0 +1,197 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvPrefix starts the environment variable of every setting, such as
+// SHARELOCK_HTTP_SERVER_PORT for http_server_port.
+const EnvPrefix = "SHARELOCK_"
+
+// Source is where Load reads a config from. The file comes first, the
+// environment overrides it and the command line flags override both.
+type Source struct {
+	Path string
+	// Flags are the settings given on the command line, by name
+	Flags map[string]string
+}
+
+// setting is one field of ConfigFlat that can be set from the
+// environment or the command line. It is named after its yaml key, the
+// fields of a nested struct after its env-prefix and their own key.
+type setting struct {
+	name  string
+	index []int
+	typ   reflect.Type
+}
+
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(s.name)
+}
+
+func (s setting) flagName() string {
+	return strings.ReplaceAll(s.name, "_", "-")
+}
+
+// settings lists every setting, sorted by name. Maps of structs, such
+// as namespace_limits, are only read from the file.
+var settings = sync.OnceValue(func() []setting {
+	list := make([]setting, 0)
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			fieldIndex := append(append([]int{}, index...), i)
+			if field.Type.Kind() == reflect.Struct {
+				walk(field.Type, prefix+field.Tag.Get("env-prefix"), fieldIndex)
+				continue
+			}
+			if field.Type.Kind() == reflect.Map && field.Type.Elem().Kind() != reflect.String {
+				continue
+			}
+			list = append(list, setting{
+				name:  prefix + field.Tag.Get("yaml"),
+				index: fieldIndex,
+				typ:   field.Type,
+			})
+		}
+	}
+	walk(reflect.TypeOf(ConfigFlat{}), "", nil)
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+})
+
+// override sets the settings found in the environment, then the ones
+// given as flags.
+func (src Source) override(cfg *ConfigFlat) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, s := range settings() {
+		raw, ok := os.LookupEnv(s.envName())
+		if !ok {
+			continue
+		}
+		err := parseSetting(v.FieldByIndex(s.index), raw)
+		if err != nil {
+			return fmt.Errorf("parsing env %s : %w", s.envName(), err)
+		}
+	}
+	for _, s := range settings() {
+		raw, ok := src.Flags[s.name]
+		if !ok {
+			continue
+		}
+		err := parseSetting(v.FieldByIndex(s.index), raw)
+		if err != nil {
+			return fmt.Errorf("parsing flag --%s : %w", s.flagName(), err)
+		}
+	}
+	return nil
+}
+
+// parseSetting sets v from raw. Lists are separated by commas, and so
+// are the key:value pairs of maps.
+func parseSetting(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		i, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(i))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item != "" {
+				list = reflect.Append(list, reflect.ValueOf(item))
+			}
+		}
+		v.Set(list)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(raw, ",") {
+			if pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, ":")
+			if !ok {
+				return fmt.Errorf("%q is not a key:value pair", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// RegisterFlags adds --config and a flag for every setting to fs. The
+// returned Source is filled in when fs is parsed, it only holds the
+// flags actually given.
+func RegisterFlags(fs *flag.FlagSet) *Source {
+	src := &Source{Flags: make(map[string]string)}
+	fs.StringVar(&src.Path, "config", DefaultPath, "path of the yaml, json or toml config file")
+	for _, s := range settings() {
+		fs.Var(&settingFlag{src: src, setting: s}, s.flagName(),
+			fmt.Sprintf("overrides %s and env %s", s.name, s.envName()))
+	}
+	return src
+}
+
+type settingFlag struct {
+	src     *Source
+	setting setting
+}
+
+func (f *settingFlag) String() string {
+	if f.src == nil {
+		return ""
+	}
+	return f.src.Flags[f.setting.name]
+}
+
+// Set checks the value right away, so a typo fails flag parsing.
+func (f *settingFlag) Set(raw string) error {
+	err := parseSetting(reflect.New(f.setting.typ).Elem(), raw)
+	if err != nil {
+		return err
+	}
+	f.src.Flags[f.setting.name] = raw
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool {
+	return f.setting.typ.Kind() == reflect.Bool
+}
@@ -1 +1 @@
-protoc -I="." --go-grpc_out="pkg" --go_out="pkg" sharelock.proto
\ No newline at end of file
+protoc -I="." -I="third_party" --go-grpc_out="pkg" --go_out="pkg" sharelock.proto
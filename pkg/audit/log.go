@@ -0,0 +1,385 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sharelock/pkg/locker"
+)
+
+const (
+	filePrefix = "audit-"
+	fileSuffix = ".jsonl"
+	// queueSize records may wait for the writer before Append blocks
+	queueSize = 10_000
+)
+
+type Options struct {
+	// Dir holds the audit files, it is created when missing
+	Dir string
+	// MaxFileBytes starts a new file once the current one would grow
+	// past it, zero never rotates
+	MaxFileBytes int64
+	// MaxFiles removes the oldest files beyond it, zero keeps them all
+	MaxFiles int
+}
+
+// Log appends hash chained records to JSON lines files in a directory.
+// The files are named after the sequence number of their first record,
+// the chain goes on across them. Records are written by a goroutine of
+// their own and synced once the queue is empty, so the locker loop
+// does not wait for the disk unless the queue is full. A failed write
+// is cut off the file and tried once more, the chain only goes on with
+// records written whole.
+type Log struct {
+	opts    Options
+	records chan Record
+	done    chan struct{}
+
+	// mu keeps Append from sending on a closed queue
+	mu     sync.RWMutex
+	closed bool
+
+	// the fields below belong to run
+	file *os.File
+	// size is the length of the file up to its last whole record
+	size     int64
+	seq      uint64
+	lastHash string
+	// torn is set when a failed write could not be cut off, the next
+	// write tries again first
+	torn bool
+}
+
+// Open continues the chain of the newest file of opts.Dir, or starts
+// one. A partial last line, left by a crash, is cut off.
+func Open(opts Options) (*Log, error) {
+	err := os.MkdirAll(opts.Dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating audit dir : %w", err)
+	}
+	l := &Log{
+		opts:    opts,
+		records: make(chan Record, queueSize),
+		done:    make(chan struct{}),
+	}
+	files, err := listFiles(opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		err = l.create()
+	} else {
+		err = l.resume(files[len(files)-1])
+	}
+	if err != nil {
+		return nil, err
+	}
+	go l.run()
+	return l, nil
+}
+
+// listFiles returns the audit files of dir, oldest first.
+func listFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit dir : %w", err)
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	// the sequence numbers are zero padded
+	sort.Strings(files)
+	return files, nil
+}
+
+func (l *Log) resume(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening audit file : %w", err)
+	}
+	valid, last, err := lastRecord(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("reading audit file %s : %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if valid < info.Size() {
+		slog.Warn("cutting off a partial audit record", "file", path, "bytes", info.Size()-valid)
+		err = file.Truncate(valid)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("truncating audit file %s : %w", path, err)
+		}
+	}
+	_, err = file.Seek(valid, io.SeekStart)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if last != nil {
+		l.seq, l.lastHash = last.Seq, last.Hash
+	} else {
+		// an empty file is named after the record it waits for
+		var next uint64
+		_, err = fmt.Sscanf(filepath.Base(path), filePrefix+"%d"+fileSuffix, &next)
+		if err != nil || next == 0 {
+			file.Close()
+			return fmt.Errorf("audit file %s is empty and not named after a sequence number", path)
+		}
+		l.seq = next - 1
+		if l.seq > 0 {
+			prev, err := previousHash(l.opts.Dir, path)
+			if err != nil {
+				file.Close()
+				return err
+			}
+			l.lastHash = prev
+		}
+	}
+	l.file, l.size = file, valid
+	return nil
+}
+
+// lastRecord returns the size of the complete valid lines of file and
+// the last record among them. Only the end of the file may be damaged,
+// anything else is for Verify to report.
+func lastRecord(file *os.File) (int64, *Record, error) {
+	reader := bufio.NewReader(file)
+	var (
+		offset, valid int64
+		last          *Record
+	)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			record, decodeErr := decodeRecord(bytes.TrimSuffix(line, []byte("\n")))
+			offset += int64(len(line))
+			if decodeErr != nil {
+				if _, peekErr := reader.Peek(1); peekErr == nil {
+					return 0, nil, fmt.Errorf("record at byte %d : %w, run sharelock audit verify", offset-int64(len(line)), decodeErr)
+				}
+				// a damaged last line is treated like a partial one
+				return valid, last, nil
+			}
+			valid, last = offset, &record
+		}
+		if err == io.EOF {
+			return valid, last, nil
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+}
+
+// previousHash is the hash of the last record of the file before path.
+func previousHash(dir, path string) (string, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	i := sort.SearchStrings(files, path)
+	if i == 0 {
+		return "", fmt.Errorf("audit file %s continues a chain whose files are gone", path)
+	}
+	file, err := os.Open(files[i-1])
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	_, last, err := lastRecord(file)
+	if err != nil {
+		return "", fmt.Errorf("reading audit file %s : %w", files[i-1], err)
+	}
+	if last == nil {
+		return "", fmt.Errorf("audit file %s is empty", files[i-1])
+	}
+	return last.Hash, nil
+}
+
+// create starts the file of the next record.
+func (l *Log) create() error {
+	path := filepath.Join(l.opts.Dir, fmt.Sprintf("%s%020d%s", filePrefix, l.seq+1, fileSuffix))
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating audit file : %w", err)
+	}
+	l.file, l.size, l.torn = file, 0, false
+	return nil
+}
+
+// Audit implements locker.Auditor.
+func (l *Log) Audit(event locker.AuditEvent) {
+	record := Record{
+		Time:      event.Time.UTC(),
+		Event:     string(event.Type),
+		Namespace: event.Namespace,
+		Key:       event.Key,
+		ClientId:  event.ClientId,
+		Holder:    event.Holder,
+		LeaseId:   event.LeaseId,
+		Source:    SourceFromContext(event.Ctx),
+	}
+	// releases and expiries end the lease, only grants and renewals
+	// have an expiry worth recording
+	if event.Type == locker.AuditEvent_Acquire || event.Type == locker.AuditEvent_Renew {
+		expires := event.LeaseExpiresAt.UTC()
+		record.LeaseExpiresAt = &expires
+	}
+	l.Append(record)
+}
+
+// Append queues record, its sequence number and hashes are set when it
+// is written. It blocks while the queue is full, an operation is never
+// left out of the log.
+func (l *Log) Append(record Record) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	l.records <- record
+}
+
+func (l *Log) run() {
+	defer close(l.done)
+	for record := range l.records {
+		l.write(record)
+		// one sync for every burst of records
+		if len(l.records) == 0 {
+			l.sync()
+		}
+	}
+	l.sync()
+	l.file.Close()
+}
+
+func (l *Log) write(record Record) {
+	record.Seq = l.seq + 1
+	record.PrevHash = l.lastHash
+	line, err := record.encode()
+	if err != nil {
+		slog.Error("encoding audit record", "seq", record.Seq, "event", record.Event, "err", err)
+		return
+	}
+	line = append(line, '\n')
+	if l.opts.MaxFileBytes > 0 && l.size > 0 && l.size+int64(len(line)) > l.opts.MaxFileBytes {
+		err = l.rotate()
+		if err != nil {
+			slog.Error("rotating audit file", "err", err)
+		}
+	}
+	err = l.writeLine(line)
+	if err != nil {
+		slog.Warn("writing audit record, retrying", "seq", record.Seq, "event", record.Event, "err", err)
+		err = l.writeLine(line)
+	}
+	if err != nil {
+		// the chain goes on from the last record written whole
+		slog.Error("writing audit record", "seq", record.Seq, "event", record.Event, "err", err)
+		return
+	}
+	l.size += int64(len(line))
+	l.seq, l.lastHash = record.Seq, record.Hash
+}
+
+// writeLine appends line after the last whole record, or cuts off what
+// it wrote of it.
+func (l *Log) writeLine(line []byte) error {
+	if l.torn {
+		err := l.rewind()
+		if err != nil {
+			return err
+		}
+	}
+	_, err := l.file.Write(line)
+	if err != nil {
+		rewindErr := l.rewind()
+		if rewindErr != nil {
+			slog.Warn("cutting off a failed audit record", "file", l.file.Name(), "err", rewindErr)
+		}
+	}
+	return err
+}
+
+// rewind truncates the file to its last whole record.
+func (l *Log) rewind() error {
+	err := l.file.Truncate(l.size)
+	if err == nil {
+		_, err = l.file.Seek(l.size, io.SeekStart)
+	}
+	l.torn = err != nil
+	return err
+}
+
+func (l *Log) sync() {
+	err := l.file.Sync()
+	if err != nil {
+		slog.Error("syncing audit file", "file", l.file.Name(), "err", err)
+	}
+}
+
+// rotate starts the next file, closes the current one and removes the
+// oldest files beyond MaxFiles. The records go on to the current file
+// while the next one cannot be created.
+func (l *Log) rotate() error {
+	l.sync()
+	current := l.file
+	err := l.create()
+	if err != nil {
+		return err
+	}
+	err = current.Close()
+	if err != nil {
+		slog.Warn("closing audit file", "file", current.Name(), "err", err)
+	}
+	if l.opts.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := listFiles(l.opts.Dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > l.opts.MaxFiles {
+		err = os.Remove(files[0])
+		if err != nil {
+			return err
+		}
+		slog.Info("removed old audit file", "file", files[0])
+		files = files[1:]
+	}
+	return nil
+}
+
+// Close writes the queued records and closes the current file. Records
+// appended later are dropped.
+func (l *Log) Close() {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.records)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
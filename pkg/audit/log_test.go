@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestLog is a Log of a new dir whose records the test writes itself,
+// without the goroutine of Open.
+func newTestLog(t *testing.T, opts Options) *Log {
+	t.Helper()
+	opts.Dir = t.TempDir()
+	l := &Log{opts: opts}
+	err := l.create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.file.Close() })
+	return l
+}
+
+func testRecord(i int) Record {
+	return Record{Time: time.Unix(int64(i), 0).UTC(), Event: "acquire", Key: fmt.Sprintf("key-%d", i), ClientId: "client"}
+}
+
+func TestLogWriteError(t *testing.T) {
+	l := newTestLog(t, Options{})
+	l.write(testRecord(1))
+
+	// a read only handle fails the write, and cutting it off too
+	writable := l.file
+	readOnly, err := os.Open(writable.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.file = readOnly
+	l.write(testRecord(2))
+	if l.seq != 1 {
+		t.Fatalf("a failed write moved the chain to %d", l.seq)
+	}
+	l.file = writable
+	readOnly.Close()
+	l.write(testRecord(3))
+	l.sync()
+
+	result, err := Verify(l.opts.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Records != 2 || result.LastSeq != 2 {
+		t.Fatalf("verified %d records up to %d, want 2 up to 2", result.Records, result.LastSeq)
+	}
+}
+
+func TestLogRotateError(t *testing.T) {
+	l := newTestLog(t, Options{MaxFileBytes: 1})
+	l.write(testRecord(1))
+
+	// a directory in the way of the next file fails the rotation
+	next := filepath.Join(l.opts.Dir, fmt.Sprintf("%s%020d%s", filePrefix, 2, fileSuffix))
+	err := os.Mkdir(next, 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.write(testRecord(2))
+	err = os.Remove(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.write(testRecord(3))
+	l.sync()
+
+	result, err := Verify(l.opts.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 2 || result.Records != 3 {
+		t.Fatalf("verified %d records in %d files, want 3 in 2", result.Records, result.Files)
+	}
+}
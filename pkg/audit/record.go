@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Event_Denied records a request refused for lack of credentials or
+// permission, or because a quota or a rate limit was reached. The other
+// events are the locker.AuditEventType values.
+const Event_Denied = "denied"
+
+// Source is what the server knows about the caller of a request.
+type Source struct {
+	Principal  string `json:"principal,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Transport  string `json:"transport,omitempty"`
+	RequestId  string `json:"request_id,omitempty"`
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying source, the records of the
+// locker events caused by the request of ctx get it.
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+func SourceFromContext(ctx context.Context) Source {
+	if ctx == nil {
+		return Source{}
+	}
+	source, _ := ctx.Value(sourceKey{}).(Source)
+	return source
+}
+
+// Record is one line of the audit log. Hash is the SHA-256 of the line
+// with an empty hash, and the line includes PrevHash, so changing,
+// removing or reordering records breaks the chain from there on.
+type Record struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	Namespace string    `json:"namespace,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	ClientId  string    `json:"client_id,omitempty"`
+	// Holder held the lock, it differs from ClientId on a force release
+	Holder string `json:"holder,omitempty"`
+	// LeaseId is the fencing value of the lease, it only grows
+	LeaseId        uint64     `json:"lease_id,omitempty"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+	// Operation and Reason tell what was denied and why
+	Operation string `json:"operation,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Source
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// hashSuffix ends every line, with the hash between the quotes.
+var hashSuffix = []byte(`"hash":""}`)
+
+// encode sets the hash of r and returns its line, without newline.
+func (r *Record) encode() ([]byte, error) {
+	r.Hash = ""
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasSuffix(line, hashSuffix) {
+		return nil, errors.New("audit record does not end with its hash")
+	}
+	sum := sha256.Sum256(line)
+	r.Hash = hex.EncodeToString(sum[:])
+	prefix := line[:len(line)-len(hashSuffix)]
+	return fmt.Appendf(prefix[:len(prefix):len(prefix)], `"hash":%q}`, r.Hash), nil
+}
+
+// decodeRecord parses line and checks that its hash matches its content.
+func decodeRecord(line []byte) (Record, error) {
+	var r Record
+	err := json.Unmarshal(line, &r)
+	if err != nil {
+		return r, fmt.Errorf("not a record : %w", err)
+	}
+	hashed := fmt.Appendf(nil, `"hash":%q}`, r.Hash)
+	if !bytes.HasSuffix(line, hashed) {
+		return r, errors.New("hash is not the last field")
+	}
+	unhashed := append(line[:len(line)-len(hashed):len(line)-len(hashed)], hashSuffix...)
+	sum := sha256.Sum256(unhashed)
+	if hex.EncodeToString(sum[:]) != r.Hash {
+		return r, errors.New("hash does not match the record")
+	}
+	return r, nil
+}
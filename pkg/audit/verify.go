@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type VerifyResult struct {
+	Files   int
+	Records uint64
+	// FirstSeq is above 1 once the oldest files were removed, the chain
+	// is then only checked from there on. Records cut off the start of
+	// the log look the same, only a hash kept elsewhere tells them apart.
+	FirstSeq uint64
+	LastSeq  uint64
+	// LastHash seals every record before it. Keeping it elsewhere is
+	// the only way to notice records removed from the end.
+	LastHash string
+}
+
+// VerifyError locates the first record breaking the chain.
+type VerifyError struct {
+	File    string
+	Line    int
+	Problem string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("%s line %d : %s", e.File, e.Line, e.Problem)
+}
+
+// Verify checks the records of every audit file of dir: each must match
+// its own hash, follow the previous record in sequence and name its
+// hash. It stops at the first broken record with a *VerifyError.
+func Verify(dir string) (VerifyResult, error) {
+	result := VerifyResult{}
+	files, err := listFiles(dir)
+	if err != nil {
+		return result, err
+	}
+	if len(files) == 0 {
+		return result, fmt.Errorf("no audit file in %s", dir)
+	}
+	var prev *Record
+	for _, path := range files {
+		result.Files++
+		err = verifyFile(path, &prev, &result)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func verifyFile(path string, prev **Record, result *VerifyResult) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	name := filepath.Base(path)
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		fail := func(format string, args ...any) error {
+			return &VerifyError{File: name, Line: lineNumber, Problem: fmt.Sprintf(format, args...)}
+		}
+		if !bytes.HasSuffix(line, []byte("\n")) {
+			return fail("partial record, the file does not end with a newline")
+		}
+		record, err := decodeRecord(bytes.TrimSuffix(line, []byte("\n")))
+		if err != nil {
+			return fail("%s", err)
+		}
+		switch {
+		case *prev == nil:
+			if record.Seq == 0 {
+				return fail("sequence number 0")
+			}
+			if record.Seq == 1 && record.PrevHash != "" {
+				return fail("first record names a previous hash")
+			}
+			result.FirstSeq = record.Seq
+		case record.Seq != (*prev).Seq+1:
+			return fail("sequence number %d follows %d", record.Seq, (*prev).Seq)
+		case record.PrevHash != (*prev).Hash:
+			return fail("previous hash does not match record %d", (*prev).Seq)
+		}
+		*prev = &record
+		result.Records++
+		result.LastSeq, result.LastHash = record.Seq, record.Hash
+	}
+}
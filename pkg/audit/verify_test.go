@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// editLines rewrites the lines of the oldest audit file of dir.
+func editLines(t *testing.T, dir string, edit func(lines [][]byte) [][]byte) {
+	t.Helper()
+	files, err := listFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	lines = edit(lines[:len(lines)-1])
+	err = os.WriteFile(files[0], bytes.Join(lines, nil), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		// edit changes the oldest file after four records were written
+		edit func(lines [][]byte) [][]byte
+		// wantLine is the line of the broken record, zero when the
+		// chain holds
+		wantLine     int
+		wantProblem  string
+		wantFirstSeq uint64
+	}{
+		{name: "intact", wantFirstSeq: 1},
+		{
+			name: "modified record",
+			edit: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte("key-2"), []byte("key-9"), 1)
+				return lines
+			},
+			wantLine:    2,
+			wantProblem: "hash",
+		},
+		{
+			name: "reordered records",
+			edit: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			wantLine:    2,
+			wantProblem: "sequence number 3 follows 1",
+		},
+		{
+			name: "deleted middle record",
+			edit: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantLine:    2,
+			wantProblem: "sequence number 3 follows 1",
+		},
+		{
+			// a head cut off within a file is only reported as the first
+			// sequence number, like rotated files
+			name: "deleted first record",
+			edit: func(lines [][]byte) [][]byte {
+				return lines[1:]
+			},
+			wantFirstSeq: 2,
+		},
+		{
+			// one record per file, the oldest two files are removed
+			name:         "rotated out",
+			opts:         Options{MaxFileBytes: 1, MaxFiles: 2},
+			wantFirstSeq: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLog(t, tt.opts)
+			for i := 1; i <= 4; i++ {
+				l.write(testRecord(i))
+			}
+			l.sync()
+			if tt.edit != nil {
+				editLines(t, l.opts.Dir, tt.edit)
+			}
+
+			result, err := Verify(l.opts.Dir)
+			if tt.wantLine == 0 {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.FirstSeq != tt.wantFirstSeq || result.LastSeq != 4 {
+					t.Fatalf("verified seq %d to %d, want %d to 4", result.FirstSeq, result.LastSeq, tt.wantFirstSeq)
+				}
+				return
+			}
+			var verifyErr *VerifyError
+			if !errors.As(err, &verifyErr) {
+				t.Fatalf("got %v, want a broken chain", err)
+			}
+			if verifyErr.Line != tt.wantLine || !strings.Contains(verifyErr.Problem, tt.wantProblem) ||
+				verifyErr.File != filepath.Base(l.file.Name()) {
+				t.Fatalf("got %v, want line %d about %q", verifyErr, tt.wantLine, tt.wantProblem)
+			}
+		})
+	}
+}
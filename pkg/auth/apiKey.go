@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type apiKeyAuthenticator struct {
+	// sha256 of every key, so lookups do not leak key bytes through timing
+	keys map[[sha256.Size]byte]string
+}
+
+// NewApiKeyAuthenticator accepts static keys, given as principal name
+// to key. A key written as "sha256:<hex>" is the digest of the real key,
+// which keeps plain keys out of the config file.
+func NewApiKeyAuthenticator(keys map[string]string) (Authenticator, error) {
+	a := &apiKeyAuthenticator{
+		keys: make(map[[sha256.Size]byte]string, len(keys)),
+	}
+	for principal, key := range keys {
+		if principal == "" || key == "" {
+			return nil, fmt.Errorf("api key for principal %q is empty", principal)
+		}
+		var digest [sha256.Size]byte
+		if hexDigest, ok := strings.CutPrefix(key, "sha256:"); ok {
+			raw, err := hex.DecodeString(hexDigest)
+			if err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("api key for principal %q is not a valid sha256 digest", principal)
+			}
+			copy(digest[:], raw)
+		} else {
+			digest = sha256.Sum256([]byte(key))
+		}
+		if _, exist := a.keys[digest]; exist {
+			return nil, fmt.Errorf("api key for principal %q is used twice", principal)
+		}
+		a.keys[digest] = principal
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Principal, error) {
+	if creds.ApiKey == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	digest := sha256.Sum256([]byte(creds.ApiKey))
+	for known, principal := range a.keys {
+		if subtle.ConstantTimeCompare(known[:], digest[:]) == 1 {
+			return Principal{Name: principal, Method: "api_key"}, nil
+		}
+	}
+	return Principal{}, ErrInvalidCredentials
+}
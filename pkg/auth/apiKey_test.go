@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApiKeyAuthenticator(t *testing.T) {
+	digest := sha256.Sum256([]byte("deploy-key"))
+	authenticator, err := NewApiKeyAuthenticator(map[string]string{
+		"ci":     "ci-key",
+		"deploy": "sha256:" + hex.EncodeToString(digest[:]),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// revoking a key removes it from the config
+	revoked, err := NewApiKeyAuthenticator(map[string]string{"deploy": "sha256:" + hex.EncodeToString(digest[:])})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		key           string
+		want          string
+		wantErr       error
+	}{
+		{name: "plain key", authenticator: authenticator, key: "ci-key", want: "ci"},
+		{name: "digest key", authenticator: authenticator, key: "deploy-key", want: "deploy"},
+		{name: "digest is not the key", authenticator: authenticator, key: "sha256:" + hex.EncodeToString(digest[:]), wantErr: ErrInvalidCredentials},
+		{name: "unknown key", authenticator: authenticator, key: "other-key", wantErr: ErrInvalidCredentials},
+		{name: "key prefix", authenticator: authenticator, key: "ci-ke", wantErr: ErrInvalidCredentials},
+		{name: "revoked key", authenticator: revoked, key: "ci-key", wantErr: ErrInvalidCredentials},
+		{name: "no key", authenticator: authenticator, key: "", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := tt.authenticator.Authenticate(context.Background(), Credentials{ApiKey: tt.key})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal != (Principal{Name: tt.want, Method: "api_key"}) {
+				t.Fatalf("got principal %+v, want %s", principal, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewApiKeyAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string]string
+		wantErr string
+	}{
+		{name: "empty key", keys: map[string]string{"ci": ""}, wantErr: "is empty"},
+		{name: "empty principal", keys: map[string]string{"": "key"}, wantErr: "is empty"},
+		{name: "bad digest", keys: map[string]string{"ci": "sha256:zz"}, wantErr: "not a valid sha256 digest"},
+		{name: "short digest", keys: map[string]string{"ci": "sha256:abcd"}, wantErr: "not a valid sha256 digest"},
+		{name: "key used twice", keys: map[string]string{"ci": "same", "deploy": "same"}, wantErr: "used twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewApiKeyAuthenticator(tt.keys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
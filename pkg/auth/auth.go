@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials presented")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name string
+	// Method is the authenticator that accepted the caller
+	Method string
+}
+
+// Credentials are what a transport found on a request.
+type Credentials struct {
+	// BearerToken is the token of an "Authorization: Bearer" header
+	BearerToken string
+	// ApiKey is the value of an X-Api-Key header
+	ApiKey string
+	// Certificate is the client certificate verified during the TLS
+	// handshake, nil without mutual TLS
+	Certificate *x509.Certificate
+}
+
+// CredentialsFromHeaders reads credentials from the value of the
+// Authorization and X-Api-Key headers or metadata entries.
+func CredentialsFromHeaders(authorization, apiKey string) Credentials {
+	creds := Credentials{ApiKey: strings.TrimSpace(apiKey)}
+	scheme, token, ok := strings.Cut(strings.TrimSpace(authorization), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		creds.BearerToken = strings.TrimSpace(token)
+	}
+	return creds
+}
+
+// Authenticator verifies one kind of credentials. It returns
+// ErrNoCredentials when the request carries none of its kind.
+type Authenticator interface {
+	Authenticate(ctx context.Context, creds Credentials) (Principal, error)
+}
+
+type chain []Authenticator
+
+// NewChain returns an Authenticator that accepts a request as soon as
+// one of the given authenticators does. Invalid credentials of any kind
+// reject the request.
+func NewChain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+func (c chain) Authenticate(ctx context.Context, creds Credentials) (Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(ctx, creds)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal stores the authenticated caller in ctx.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, principal)
+}
+
+// PrincipalFromContext returns the caller stored by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalCtxKey{}).(Principal)
+	return principal, ok
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+)
+
+type certificateAuthenticator struct{}
+
+// NewCertificateAuthenticator accepts callers that presented a client
+// certificate the TLS handshake already verified. The principal is the
+// first URI SAN, a SPIFFE id such as spiffe://example.org/ns/team-a,
+// or else the subject common name.
+func NewCertificateAuthenticator() Authenticator {
+	return certificateAuthenticator{}
+}
+
+func (certificateAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Principal, error) {
+	if creds.Certificate == nil {
+		return Principal{}, ErrNoCredentials
+	}
+	name := CertificateIdentity(creds.Certificate)
+	if name == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: name, Method: "mtls"}, nil
+}
+
+// CertificateIdentity is the principal name of a client certificate.
+func CertificateIdentity(cert *x509.Certificate) string {
+	for _, uri := range cert.URIs {
+		if uri != nil && uri.String() != "" {
+			return uri.String()
+		}
+	}
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestCertificateAuthenticator(t *testing.T) {
+	uri := func(raw string) *url.URL {
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		want    string
+		wantErr error
+	}{
+		{name: "common name", cert: &x509.Certificate{Subject: pkix.Name{CommonName: "worker"}}, want: "worker"},
+		{name: "first uri san", cert: &x509.Certificate{
+			Subject: pkix.Name{CommonName: "worker"},
+			URIs:    []*url.URL{uri("spiffe://example.org/ns/team-a"), uri("spiffe://example.org/ns/team-b")},
+		}, want: "spiffe://example.org/ns/team-a"},
+		{name: "dns san is not a name", cert: &x509.Certificate{DNSNames: []string{"worker.example.org"}}, wantErr: ErrInvalidCredentials},
+		{name: "no certificate", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := NewCertificateAuthenticator().Authenticate(context.Background(), Credentials{Certificate: tt.cert})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal != (Principal{Name: tt.want, Method: "mtls"}) {
+				t.Fatalf("got principal %+v, want %s", principal, tt.want)
+			}
+		})
+	}
+}
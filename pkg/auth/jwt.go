@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JwtConfig lists the local keys bearer tokens are checked against.
+// Keys are selected by the "kid" header of the token, a token without
+// kid is tried against every key of its algorithm family.
+type JwtConfig struct {
+	// HmacSecrets maps key id to shared secret, for HS256/384/512
+	HmacSecrets map[string]string
+	// PublicKeyFiles maps key id to a PEM file with an RSA, ECDSA or
+	// Ed25519 public key, for RS*, PS*, ES* and EdDSA
+	PublicKeyFiles map[string]string
+	Issuer         string
+	Audience       string
+}
+
+type jwtAuthenticator struct {
+	hmac   map[string][]byte
+	public map[string]crypto.PublicKey
+	parser *jwt.Parser
+}
+
+func NewJwtAuthenticator(cfg JwtConfig) (Authenticator, error) {
+	a := &jwtAuthenticator{
+		hmac:   make(map[string][]byte, len(cfg.HmacSecrets)),
+		public: make(map[string]crypto.PublicKey, len(cfg.PublicKeyFiles)),
+	}
+	for kid, secret := range cfg.HmacSecrets {
+		if len(secret) < 32 {
+			return nil, fmt.Errorf("hmac secret %q must be at least 32 bytes", kid)
+		}
+		a.hmac[kid] = []byte(secret)
+	}
+	for kid, path := range cfg.PublicKeyFiles {
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading public key %q : %w", kid, err)
+		}
+		key, err := parsePublicKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key %q : %w", kid, err)
+		}
+		a.public[kid] = key
+	}
+	if len(a.hmac) == 0 && len(a.public) == 0 {
+		return nil, fmt.Errorf("no jwt keys configured")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, creds Credentials) (Principal, error) {
+	if creds.BearerToken == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	claims := jwt.RegisteredClaims{}
+	_, err := a.parser.ParseWithClaims(creds.BearerToken, &claims, a.keyFunc)
+	if err != nil || claims.Subject == "" {
+		return Principal{}, ErrInvalidCredentials
+	}
+	return Principal{Name: claims.Subject, Method: "jwt"}, nil
+}
+
+func (a *jwtAuthenticator) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	_, isHmac := token.Method.(*jwt.SigningMethodHMAC)
+
+	if kid != "" {
+		if isHmac {
+			if secret, ok := a.hmac[kid]; ok {
+				return secret, nil
+			}
+		} else if key, ok := a.public[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys := jwt.VerificationKeySet{}
+	if isHmac {
+		for _, secret := range a.hmac {
+			keys.Keys = append(keys.Keys, secret)
+		}
+	} else {
+		for _, key := range a.public {
+			keys.Keys = append(keys.Keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func parsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(pemBytes); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(pemBytes); err == nil {
+		return key, nil
+	}
+	return jwt.ParseEdPublicKeyFromPEM(pemBytes)
+}
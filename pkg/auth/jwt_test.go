@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testHmacSecret = "0123456789abcdef0123456789abcdef"
+
+// testJwtKeys writes an ECDSA public key and returns the config of an
+// authenticator knowing it as "ec" and testHmacSecret as "hs", with the
+// private key and the PEM of the public key.
+func testJwtKeys(t *testing.T) (JwtConfig, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	path := filepath.Join(t.TempDir(), "ec.pem")
+	err = os.WriteFile(path, publicPem, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := JwtConfig{
+		HmacSecrets:    map[string]string{"hs": testHmacSecret},
+		PublicKeyFiles: map[string]string{"ec": path},
+		Issuer:         "https://issuer.example",
+		Audience:       "sharelock",
+	}
+	return cfg, key, publicPem
+}
+
+func testClaims(subject string) jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Subject:   subject,
+		Issuer:    "https://issuer.example",
+		Audience:  jwt.ClaimStrings{"sharelock"},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.RegisteredClaims, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestJwtAuthenticator(t *testing.T) {
+	cfg, ecKey, publicPem := testJwtKeys(t)
+	authenticator, err := NewJwtAuthenticator(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := func(change func(c *jwt.RegisteredClaims)) jwt.RegisteredClaims {
+		c := testClaims("ci")
+		change(&c)
+		return c
+	}
+	unsigned := func() string {
+		token := jwt.NewWithClaims(jwt.SigningMethodNone, testClaims("ci"))
+		signed, err := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "hmac with kid", token: signToken(t, jwt.SigningMethodHS256, "hs", testClaims("ci"), []byte(testHmacSecret))},
+		{name: "hmac without kid", token: signToken(t, jwt.SigningMethodHS512, "", testClaims("ci"), []byte(testHmacSecret))},
+		{name: "ecdsa with kid", token: signToken(t, jwt.SigningMethodES256, "ec", testClaims("ci"), ecKey)},
+		{name: "ecdsa without kid", token: signToken(t, jwt.SigningMethodES256, "", testClaims("ci"), ecKey)},
+		{name: "no token", token: "", wantErr: ErrNoCredentials},
+		{name: "malformed", token: "not.a.token", wantErr: ErrInvalidCredentials},
+		{name: "wrong hmac secret", token: signToken(t, jwt.SigningMethodHS256, "hs", testClaims("ci"), []byte(strings.Repeat("x", 32))), wantErr: ErrInvalidCredentials},
+		{name: "wrong ecdsa key", token: signToken(t, jwt.SigningMethodES256, "ec", testClaims("ci"), otherKey), wantErr: ErrInvalidCredentials},
+		{name: "unknown kid", token: signToken(t, jwt.SigningMethodHS256, "other", testClaims("ci"), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		// the public key used as an hmac secret must not verify
+		{name: "alg confusion with kid", token: signToken(t, jwt.SigningMethodHS256, "ec", testClaims("ci"), publicPem), wantErr: ErrInvalidCredentials},
+		{name: "alg confusion without kid", token: signToken(t, jwt.SigningMethodHS256, "", testClaims("ci"), publicPem), wantErr: ErrInvalidCredentials},
+		{name: "alg none", token: unsigned(), wantErr: ErrInvalidCredentials},
+		{name: "expired", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		{name: "no expiry", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.ExpiresAt = nil
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		{name: "not yet valid", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		{name: "wrong issuer", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.Issuer = "https://other.example"
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		{name: "wrong audience", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.Audience = jwt.ClaimStrings{"other"}
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+		{name: "no subject", token: signToken(t, jwt.SigningMethodHS256, "hs", claims(func(c *jwt.RegisteredClaims) {
+			c.Subject = ""
+		}), []byte(testHmacSecret)), wantErr: ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := authenticator.Authenticate(context.Background(), Credentials{BearerToken: tt.token})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal != (Principal{Name: "ci", Method: "jwt"}) {
+				t.Fatalf("got principal %+v", principal)
+			}
+		})
+	}
+}
+
+func TestNewJwtAuthenticator(t *testing.T) {
+	cfg, _, _ := testJwtKeys(t)
+	tests := []struct {
+		name    string
+		cfg     JwtConfig
+		wantErr string
+	}{
+		{name: "no keys", cfg: JwtConfig{}, wantErr: "no jwt keys"},
+		{name: "short secret", cfg: JwtConfig{HmacSecrets: map[string]string{"hs": "short"}}, wantErr: "at least 32 bytes"},
+		{name: "missing key file", cfg: JwtConfig{PublicKeyFiles: map[string]string{"ec": filepath.Join(t.TempDir(), "missing.pem")}}, wantErr: "reading public key"},
+		{name: "not a key", cfg: JwtConfig{PublicKeyFiles: map[string]string{"hs": writeTestFile(t, "key.pem", "not a key")}}, wantErr: "parsing public key"},
+		{name: "valid", cfg: cfg},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewJwtAuthenticator(tt.cfg)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+type Action string
+
+const (
+	Action_Lock   Action = "lock"
+	Action_Unlock Action = "unlock"
+	// Action_Inspect reads the holder, lease and waiters of a key
+	Action_Inspect Action = "inspect"
+	// Action_ForceRelease releases a lock held by somebody else
+	Action_ForceRelease Action = "force_release"
+	// Action_Admin changes the membership of a cluster, keys and
+	// namespaces of the rule do not matter
+	Action_Admin Action = "admin"
+)
+
+// PolicyRule allows the listed principals the listed actions on the
+// matching keys of the matching namespaces, every namespace when none
+// are listed. Principals, namespaces and keys are globs: ? matches one
+// character but "/", * matches within one "/" separated segment, **
+// matches anything, so "team-a/**" is every key below the team-a/
+// prefix. A lone "*" principal is any caller.
+type PolicyRule struct {
+	Principals []string `yaml:"principals" json:"principals"`
+	Namespaces []string `yaml:"namespaces" json:"namespaces"`
+	Keys       []string `yaml:"keys" json:"keys"`
+	Actions    []Action `yaml:"actions" json:"actions"`
+}
+
+type PolicyFile struct {
+	Rules []PolicyRule `yaml:"rules" json:"rules"`
+}
+
+// Policy is a set of allow rules, anything no rule allows is denied.
+type Policy struct {
+	rules []policyRule
+}
+
+type policyRule struct {
+	principals []*regexp.Regexp
+	namespaces []*regexp.Regexp
+	keys       []*regexp.Regexp
+	actions    map[Action]bool
+}
+
+func NewPolicy(rules []PolicyRule) (*Policy, error) {
+	p := &Policy{rules: make([]policyRule, 0, len(rules))}
+	for i, rule := range rules {
+		if len(rule.Principals) == 0 || len(rule.Keys) == 0 || len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %d : principals, keys and actions must not be empty", i)
+		}
+		compiled := policyRule{actions: make(map[Action]bool, len(rule.Actions))}
+		for _, action := range rule.Actions {
+			switch action {
+			case Action_Lock, Action_Unlock, Action_Inspect, Action_ForceRelease, Action_Admin:
+				compiled.actions[action] = true
+			default:
+				return nil, fmt.Errorf("rule %d : unknown action %q", i, action)
+			}
+		}
+		for _, principal := range rule.Principals {
+			if principal == "*" {
+				principal = "**"
+			}
+			compiled.principals = append(compiled.principals, compileGlob(principal))
+		}
+		for _, ns := range rule.Namespaces {
+			compiled.namespaces = append(compiled.namespaces, compileGlob(ns))
+		}
+		for _, key := range rule.Keys {
+			if key == "" {
+				return nil, fmt.Errorf("rule %d : empty key pattern", i)
+			}
+			compiled.keys = append(compiled.keys, compileGlob(key))
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// LoadPolicy reads a yaml, json or toml policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	var file PolicyFile
+	err := cleanenv.ReadConfig(path, &file)
+	if err != nil {
+		return nil, err
+	}
+	return NewPolicy(file.Rules)
+}
+
+// Len is the number of rules of the policy.
+func (p *Policy) Len() int {
+	return len(p.rules)
+}
+
+func (p *Policy) Allowed(principal string, action Action, namespace, key string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.actions[action] && matchAny(rule.principals, principal) &&
+			rule.inNamespace(namespace) && matchAny(rule.keys, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedNamespace reports whether principal may read the usage of a
+// namespace, which any inspect permission inside it grants.
+func (p *Policy) AllowedNamespace(principal, namespace string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.actions[Action_Inspect] && matchAny(rule.principals, principal) && rule.inNamespace(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedAdmin reports whether principal may manage the cluster.
+func (p *Policy) AllowedAdmin(principal string) bool {
+	if principal == "" {
+		return false
+	}
+	for _, rule := range p.rules {
+		if rule.actions[Action_Admin] && matchAny(rule.principals, principal) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r policyRule) inNamespace(namespace string) bool {
+	return len(r.namespaces) == 0 || matchAny(r.namespaces, namespace)
+}
+
+func matchAny(patterns []*regexp.Regexp, value string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileGlob(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			b.WriteString("[^/]*")
+		case glob[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// PolicyStore holds the policy in force and swaps it on Set, requests
+// in flight keep the policy they started with. Without a policy every
+// caller may do everything but the admin actions, which need a grant.
+type PolicyStore struct {
+	current atomic.Pointer[Policy]
+}
+
+// NewPolicyStore loads the policy file at path, an empty path starts
+// without policy.
+func NewPolicyStore(path string) (*PolicyStore, error) {
+	s := &PolicyStore{}
+	if path == "" {
+		return s, nil
+	}
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading acl policy %s : %w", path, err)
+	}
+	s.Set(policy)
+	slog.Info("acl policy loaded", "path", path, "rules", policy.Len())
+	return s, nil
+}
+
+// Set replaces the policy, nil removes access control.
+func (s *PolicyStore) Set(policy *Policy) {
+	s.current.Store(policy)
+}
+
+func (s *PolicyStore) Allowed(principal string, action Action, namespace, key string) bool {
+	policy := s.current.Load()
+	return policy == nil || policy.Allowed(principal, action, namespace, key)
+}
+
+func (s *PolicyStore) AllowedNamespace(principal, namespace string) bool {
+	policy := s.current.Load()
+	return policy == nil || policy.AllowedNamespace(principal, namespace)
+}
+
+func (s *PolicyStore) AllowedAdmin(principal string) bool {
+	policy := s.current.Load()
+	return policy != nil && policy.AllowedAdmin(principal)
+}
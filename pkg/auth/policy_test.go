@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		value string
+		want  bool
+	}{
+		{glob: "team-a/*", value: "team-a/build", want: true},
+		{glob: "team-a/*", value: "team-a/build/1", want: false},
+		{glob: "team-a/*", value: "team-a/", want: true},
+		{glob: "team-a/**", value: "team-a/build/1", want: true},
+		{glob: "team-a/**", value: "team-b/build", want: false},
+		{glob: "**", value: "any/thing/at/all", want: true},
+		{glob: "*/deploy", value: "team-a/deploy", want: true},
+		{glob: "*/deploy", value: "org/team-a/deploy", want: false},
+		{glob: "**/deploy", value: "org/team-a/deploy", want: true},
+		{glob: "job-?", value: "job-1", want: true},
+		{glob: "job-?", value: "job-12", want: false},
+		{glob: "job-?", value: "job-/", want: false},
+		{glob: "a.b+c", value: "a.b+c", want: true},
+		{glob: "a.b+c", value: "aXbbc", want: false},
+		{glob: "exact", value: "exact/suffix", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.value, func(t *testing.T) {
+			got := compileGlob(tt.glob).MatchString(tt.value)
+			if got != tt.want {
+				t.Fatalf("glob %q on %q : got %v, want %v", tt.glob, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []PolicyRule
+		wantErr string
+	}{
+		{name: "valid", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Keys: []string{"**"}, Actions: []Action{Action_Lock, Action_Unlock}},
+		}},
+		{name: "no rules"},
+		{name: "no principals", rules: []PolicyRule{
+			{Keys: []string{"**"}, Actions: []Action{Action_Lock}},
+		}, wantErr: "must not be empty"},
+		{name: "no keys", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Actions: []Action{Action_Lock}},
+		}, wantErr: "must not be empty"},
+		{name: "no actions", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Keys: []string{"**"}},
+		}, wantErr: "must not be empty"},
+		{name: "unknown action", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Keys: []string{"**"}, Actions: []Action{"delete"}},
+		}, wantErr: `unknown action "delete"`},
+		{name: "empty key", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Keys: []string{""}, Actions: []Action{Action_Lock}},
+		}, wantErr: "empty key pattern"},
+		{name: "error names the rule", rules: []PolicyRule{
+			{Principals: []string{"ci"}, Keys: []string{"**"}, Actions: []Action{Action_Lock}},
+			{Principals: []string{"ci"}, Keys: []string{"**"}},
+		}, wantErr: "rule 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.rules)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if policy.Len() != len(tt.rules) {
+					t.Fatalf("got %d rules, want %d", policy.Len(), len(tt.rules))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func testPolicy(t *testing.T) *Policy {
+	t.Helper()
+	policy, err := NewPolicy([]PolicyRule{
+		{Principals: []string{"team-a/*"}, Keys: []string{"team-a/**"}, Actions: []Action{Action_Lock, Action_Unlock}},
+		{Principals: []string{"ci"}, Namespaces: []string{"builds"}, Keys: []string{"*"}, Actions: []Action{Action_Lock, Action_Inspect}},
+		{Principals: []string{"*"}, Namespaces: []string{"public"}, Keys: []string{"public/*"}, Actions: []Action{Action_Inspect}},
+		{Principals: []string{"operator"}, Keys: []string{"**"}, Actions: []Action{Action_Admin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	policy := testPolicy(t)
+	tests := []struct {
+		name      string
+		principal string
+		action    Action
+		namespace string
+		key       string
+		want      bool
+	}{
+		{name: "any namespace without a list", principal: "team-a/alice", action: Action_Lock, namespace: "anything", key: "team-a/deploy/prod", want: true},
+		{name: "principal glob stays in its segment", principal: "team-a/bots/x", action: Action_Lock, key: "team-a/deploy", want: false},
+		{name: "action not granted", principal: "team-a/alice", action: Action_ForceRelease, key: "team-a/deploy", want: false},
+		{name: "key outside the prefix", principal: "team-a/alice", action: Action_Lock, key: "team-b/deploy", want: false},
+		{name: "listed namespace", principal: "ci", action: Action_Lock, namespace: "builds", key: "main", want: true},
+		{name: "other namespace", principal: "ci", action: Action_Lock, namespace: "deploys", key: "main", want: false},
+		{name: "single segment key", principal: "ci", action: Action_Lock, namespace: "builds", key: "main/1", want: false},
+		{name: "lone star principal", principal: "someone/else", action: Action_Inspect, namespace: "public", key: "public/status", want: true},
+		{name: "lone star principal other action", principal: "someone/else", action: Action_Lock, namespace: "public", key: "public/status", want: false},
+		{name: "no principal", principal: "", action: Action_Inspect, namespace: "public", key: "public/status", want: false},
+		{name: "admin is not a key action", principal: "operator", action: Action_Lock, key: "anything", want: false},
+		{name: "default deny", principal: "stranger", action: Action_Unlock, key: "somewhere", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.Allowed(tt.principal, tt.action, tt.namespace, tt.key)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowedNamespace(t *testing.T) {
+	policy := testPolicy(t)
+	tests := []struct {
+		principal string
+		namespace string
+		want      bool
+	}{
+		{principal: "ci", namespace: "builds", want: true},
+		{principal: "ci", namespace: "deploys", want: false},
+		{principal: "anyone", namespace: "public", want: true},
+		{principal: "anyone", namespace: "deploys", want: false},
+		{principal: "", namespace: "builds", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.principal+" "+tt.namespace, func(t *testing.T) {
+			got := policy.AllowedNamespace(tt.principal, tt.namespace)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowedAdmin(t *testing.T) {
+	policy := testPolicy(t)
+	tests := []struct {
+		principal string
+		want      bool
+	}{
+		{principal: "operator", want: true},
+		{principal: "team-a/alice", want: false},
+		{principal: "ci", want: false},
+		{principal: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.principal, func(t *testing.T) {
+			got := policy.AllowedAdmin(tt.principal)
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyStore(t *testing.T) {
+	store := &PolicyStore{}
+	if !store.Allowed("", Action_ForceRelease, "", "any") || !store.AllowedNamespace("", "any") {
+		t.Fatal("a store without policy denied a key action")
+	}
+	if store.AllowedAdmin("operator") {
+		t.Fatal("a store without policy allowed an admin action")
+	}
+
+	store.Set(testPolicy(t))
+	if !store.Allowed("ci", Action_Lock, "builds", "main") || store.Allowed("ci", Action_Lock, "deploys", "main") {
+		t.Fatal("the store did not apply the policy")
+	}
+
+	reloaded, err := NewPolicy([]PolicyRule{
+		{Principals: []string{"ci"}, Namespaces: []string{"deploys"}, Keys: []string{"*"}, Actions: []Action{Action_Lock}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set(reloaded)
+	if store.Allowed("ci", Action_Lock, "builds", "main") || !store.Allowed("ci", Action_Lock, "deploys", "main") {
+		t.Fatal("the store kept the old policy after Set")
+	}
+	if store.AllowedAdmin("operator") {
+		t.Fatal("the admin grant of the old policy survived Set")
+	}
+
+	store.Set(nil)
+	if !store.Allowed("ci", Action_Lock, "builds", "main") {
+		t.Fatal("removing the policy kept access control")
+	}
+}
+
+func TestNewPolicyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	err := os.WriteFile(path, []byte(`rules:
+  - principals: ["ci"]
+    keys: ["builds/*"]
+    actions: ["lock"]
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewPolicyStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.Allowed("ci", Action_Lock, "", "builds/main") || store.Allowed("ci", Action_Unlock, "", "builds/main") {
+		t.Fatal("the loaded policy was not applied")
+	}
+
+	_, err = NewPolicyStore(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err == nil {
+		t.Fatal("loaded a missing policy file")
+	}
+}
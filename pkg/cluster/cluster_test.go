@@ -0,0 +1,439 @@
+package cluster_test
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/cluster"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+	"sharelock/server"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The nodes authenticate callers with api keys. The operator may do
+// everything, the client may only lock.
+const (
+	operatorKey = "operator-key"
+	clientKey   = "client-key"
+)
+
+var testRules = []auth.PolicyRule{
+	{Principals: []string{"operator"}, Keys: []string{"**"}, Actions: []auth.Action{auth.Action_Lock, auth.Action_Unlock, auth.Action_Inspect, auth.Action_Admin}},
+	{Principals: []string{"client"}, Keys: []string{"**"}, Actions: []auth.Action{auth.Action_Lock, auth.Action_Unlock}},
+}
+
+// testNode is one in-process member of the cluster, with its locker,
+// its servers and a gRPC client of its own server.
+type testNode struct {
+	id        string
+	raftAddr  string
+	grpcAddr  string
+	httpAddr  string
+	node      *cluster.Node
+	service   *server.LockService
+	servers   []server.Server
+	cancel    context.CancelFunc
+	lockerEnd chan struct{}
+	conn      *grpc.ClientConn
+	locks     sharelockPB.ShareLockServiceClient
+	admin     sharelockPB.ShareLockAdminClient
+	stopped   bool
+}
+
+// testCluster is a cluster of in-process nodes on localhost, talking
+// raft over mutual tls.
+type testCluster struct {
+	t       *testing.T
+	dir     string
+	raftTLS func(name string) *tls.Config
+	nodes   []*testNode
+}
+
+func newTestCluster(t *testing.T, size int) *testCluster {
+	c := &testCluster{t: t, dir: t.TempDir(), raftTLS: cluster.RaftTLS(t)}
+	for i := 0; i < size; i++ {
+		c.nodes = append(c.nodes, c.newNode(i))
+	}
+	peers := make(map[string]string)
+	for _, n := range c.nodes[1:] {
+		peers[n.id] = n.raftAddr
+	}
+	for i, n := range c.nodes {
+		// the first node bootstraps the cluster, the others wait for it
+		if i == 0 {
+			c.start(n, peers)
+		} else {
+			c.start(n, nil)
+		}
+	}
+	return c
+}
+
+// newNode picks the addresses of node i.
+func (c *testCluster) newNode(i int) *testNode {
+	return &testNode{
+		id:        fmt.Sprintf("node%d", i),
+		raftAddr:  cluster.FreeAddr(c.t),
+		grpcAddr:  cluster.FreeAddr(c.t),
+		httpAddr:  cluster.FreeAddr(c.t),
+		lockerEnd: make(chan struct{}),
+	}
+}
+
+// start runs n until the test ends, bootstrapping a cluster of peers
+// unless peers is nil.
+func (c *testCluster) start(n *testNode, peers map[string]string) {
+	t := c.t
+	var err error
+	n.node, err = cluster.Open(cluster.Options{
+		NodeId:           n.id,
+		RaftAddress:      n.raftAddr,
+		Dir:              filepath.Join(c.dir, n.id),
+		Bootstrap:        peers != nil,
+		Peers:            peers,
+		GrpcAddress:      n.grpcAddr,
+		HttpAddress:      n.httpAddr,
+		HeartbeatTimeout: time.Millisecond * 300,
+		ElectionTimeout:  time.Millisecond * 300,
+		TLS:              c.raftTLS(n.id),
+	})
+	if err != nil {
+		t.Fatalf("opening %s : %v", n.id, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.cancel = cancel
+	lockerInstance := locker.NewLocker(locker.WithStore(n.node.Store()), locker.WithStandby())
+	go func() {
+		defer close(n.lockerEnd)
+		lockerInstance.Start(ctx)
+	}()
+	go n.node.Run(ctx, lockerInstance)
+
+	authenticator, err := auth.NewApiKeyAuthenticator(map[string]string{"operator": operatorKey, "client": clientKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := auth.NewPolicy(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := &auth.PolicyStore{}
+	policies.Set(policy)
+	n.service = server.NewLockService(lockerInstance, server.WithCluster(n.node, nil), server.WithPolicy(policies))
+	n.servers = []server.Server{
+		server.NewGrpcServer(ctx, &config.Server{Enable: true, ListenAddresses: []string{n.grpcAddr}, ServiceName: n.id}, n.service, authenticator),
+		server.NewHttpServer(ctx, &config.Server{Enable: true, ListenAddresses: []string{n.httpAddr}, ServiceName: n.id}, n.service, authenticator),
+	}
+	for i := range n.servers {
+		go n.servers[i].Start()
+	}
+	t.Cleanup(n.stop)
+
+	n.conn, err = grpc.NewClient(n.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.locks = sharelockPB.NewShareLockServiceClient(n.conn)
+	n.admin = sharelockPB.NewShareLockAdminClient(n.conn)
+}
+
+// stop kills the node without handing over the lead, like a crash.
+func (n *testNode) stop() {
+	if n.stopped {
+		return
+	}
+	n.stopped = true
+	stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), time.Second)
+	defer cancelStopCtx()
+	n.node.Shutdown()
+	for _, srv := range n.servers {
+		srv.Stop(stopCtx)
+	}
+	n.cancel()
+	<-n.lockerEnd
+	n.service.Close()
+	if n.conn != nil {
+		n.conn.Close()
+	}
+}
+
+// leader waits for a running node to lead the cluster.
+func (c *testCluster) leader(ctx context.Context) *testNode {
+	c.t.Helper()
+	for {
+		for _, n := range c.nodes {
+			if !n.stopped && n.node.Leading() {
+				return n
+			}
+		}
+		select {
+		case <-ctx.Done():
+			c.t.Fatal("no leader elected")
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+}
+
+// follower is a running node that does not lead the cluster.
+func (c *testCluster) follower(leader *testNode) *testNode {
+	for _, n := range c.nodes {
+		if !n.stopped && n != leader {
+			return n
+		}
+	}
+	c.t.Fatal("no follower left")
+	return nil
+}
+
+func lock(ctx context.Context, n *testNode, clientId, key string, timeout, lease time.Duration) (*sharelockPB.LockResponse, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	return n.locks.Lock(ctx, &sharelockPB.LockRequest{
+		Key:       key,
+		TimeoutMs: int32(timeout.Milliseconds()),
+		LeaseMs:   int32(lease.Milliseconds()),
+	})
+}
+
+func unlock(ctx context.Context, n *testNode, clientId, key string) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	_, err := n.locks.Unlock(ctx, &sharelockPB.UnlockRequest{Key: key})
+	return err
+}
+
+// TestCluster runs three nodes and checks that only the leader grants
+// locks, that followers forward and redirect to it, that a new leader
+// keeps the holders, the lease deadlines and the queues of a leader
+// that crashed, and that members can be added and removed with the
+// admin RPCs. The steps run in order on the same cluster.
+func TestCluster(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs a raft cluster")
+	}
+	c := newTestCluster(t, 3)
+	steps := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, c *testCluster)
+	}{
+		{"one leader is elected", testElected},
+		{"followers forward locks to the leader", testForwarded},
+		{"followers redirect http requests to the leader", testRedirected},
+		{"a new leader keeps holders, leases and queues", testFailover},
+		{"admin calls need an admin grant", testAdminDenied},
+		{"members are added and removed", testMembership},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+			ctx = metadata.AppendToOutgoingContext(ctx, "X-Api-Key", operatorKey)
+			c.t = t
+			step.run(t, ctx, c)
+		})
+	}
+}
+
+func testElected(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	leading := 0
+	for _, n := range c.nodes {
+		if n.node.Leading() {
+			leading++
+		}
+	}
+	if leading != 1 {
+		t.Fatalf("%d nodes lead the cluster", leading)
+	}
+	info, err := leader.admin.GetCluster(ctx, &sharelockPB.GetClusterRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Members) != 3 {
+		t.Fatalf("%d members, want 3", len(info.Members))
+	}
+}
+
+func testForwarded(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	follower := c.follower(leader)
+	resp, err := lock(ctx, follower, "client-a", "forwarded", time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("locking through %s : %v", follower.id, err)
+	}
+	if resp.Status != sharelockPB.Status_Acquired {
+		t.Fatalf("lock status %s", resp.Status)
+	}
+	// the leader holds the lock, the other client times out on it
+	_, err = lock(ctx, leader, "client-b", "forwarded", time.Millisecond*200, time.Minute)
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("second client got %v, want a lock wait timeout", err)
+	}
+	err = unlock(ctx, follower, "client-a", "forwarded")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testRedirected(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	follower := c.follower(leader)
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://"+follower.httpAddr+"/v2/locks/redirected", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Client-Id", "client-a")
+	req.Header.Set("X-Api-Key", operatorKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	want := "http://" + leader.httpAddr + "/v2/locks/redirected"
+	if resp.StatusCode != http.StatusTemporaryRedirect || resp.Header.Get("Location") != want {
+		t.Fatalf("got %d to %q, want 307 to %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+}
+
+func testFailover(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	follower := c.follower(leader)
+	resp, err := lock(ctx, follower, "holder", "failover", time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("locking : %v", err)
+	}
+	leaseExpire := resp.Lock.LeaseExpireTime.AsTime()
+
+	// two waiters queue up behind the holder, in this order
+	order := make(chan string, 2)
+	errs := make(chan error, 2)
+	for _, waiter := range []string{"first", "second"} {
+		go func() {
+			_, err := lock(ctx, follower, waiter, "failover", time.Second*20, time.Minute)
+			if err != nil {
+				errs <- fmt.Errorf("waiter %s : %w", waiter, err)
+				return
+			}
+			order <- waiter
+		}()
+		time.Sleep(time.Millisecond * 300)
+	}
+
+	leader.stop()
+	newLeader := c.leader(ctx)
+
+	infoCtx := metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "holder")
+	info, err := follower.locks.GetLock(infoCtx, &sharelockPB.GetLockRequest{Key: "failover"})
+	if err != nil {
+		t.Fatalf("inspecting on %s : %v", newLeader.id, err)
+	}
+	if info.Holder != "operator/holder" || !info.LeaseExpireTime.AsTime().Equal(leaseExpire) {
+		t.Fatalf("holder %q until %s, want holder until %s", info.Holder, info.LeaseExpireTime.AsTime(), leaseExpire)
+	}
+	if info.Waiters != 2 {
+		t.Fatalf("%d waiters after the failover, want 2", info.Waiters)
+	}
+
+	for _, tt := range []struct{ release, next string }{{"holder", "first"}, {"first", "second"}} {
+		err = unlock(ctx, follower, tt.release, "failover")
+		if err != nil {
+			t.Fatalf("unlocking %s : %v", tt.release, err)
+		}
+		select {
+		case got := <-order:
+			if got != tt.next {
+				t.Fatalf("%q got the lock after %s, want %s", got, tt.release, tt.next)
+			}
+		case err := <-errs:
+			t.Fatal(err)
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+	err = unlock(ctx, follower, "second", "failover")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testAdminDenied(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	add := &sharelockPB.AddMemberRequest{Id: "intruder", RaftAddress: cluster.FreeAddr(t)}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		wantCode codes.Code
+	}{
+		{name: "unauthenticated", ctx: context.Background(), wantCode: codes.Unauthenticated},
+		{name: "no admin grant", ctx: metadata.AppendToOutgoingContext(context.Background(), "X-Api-Key", clientKey), wantCode: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		callCtx, cancel := context.WithTimeout(tt.ctx, time.Second*5)
+		_, err := leader.admin.AddMember(callCtx, add)
+		cancel()
+		if status.Code(err) != tt.wantCode {
+			t.Fatalf("%s: AddMember got %v, want %s", tt.name, err, tt.wantCode)
+		}
+	}
+	info, err := leader.admin.GetCluster(ctx, &sharelockPB.GetClusterRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Members) != 3 {
+		t.Fatalf("%d members after the refused calls, want 3", len(info.Members))
+	}
+}
+
+func testMembership(t *testing.T, ctx context.Context, c *testCluster) {
+	leader := c.leader(ctx)
+	follower := c.follower(leader)
+
+	// a new node joins through the admin rpc of a follower
+	joined := c.newNode(3)
+	c.start(joined, nil)
+	defer joined.stop()
+	_, err := follower.admin.AddMember(ctx, &sharelockPB.AddMemberRequest{
+		Id:          joined.id,
+		RaftAddress: joined.raftAddr,
+		GrpcAddress: joined.grpcAddr,
+		HttpAddress: joined.httpAddr,
+	})
+	if err != nil {
+		t.Fatalf("adding %s : %v", joined.id, err)
+	}
+	resp, err := lock(ctx, joined, "client-a", "joined", time.Second*5, time.Minute)
+	if err != nil || resp.Status != sharelockPB.Status_Acquired {
+		t.Fatalf("locking through %s : %v", joined.id, err)
+	}
+	err = unlock(ctx, joined, "client-a", "joined")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := follower.admin.RemoveMember(ctx, &sharelockPB.RemoveMemberRequest{Id: joined.id})
+	if err != nil {
+		t.Fatalf("removing %s : %v", joined.id, err)
+	}
+	for _, member := range info.Members {
+		if member.Id == joined.id {
+			t.Fatalf("%s is still a member", joined.id)
+		}
+	}
+}
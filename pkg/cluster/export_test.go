@@ -0,0 +1,18 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+// RaftTLS gives the tests of package cluster_test the raft tls configs
+// of nodes sharing one ca.
+func RaftTLS(t *testing.T) func(name string) *tls.Config {
+	ca := newTestCA(t)
+	return func(name string) *tls.Config {
+		return ca.tlsConfig(t, name)
+	}
+}
+
+// FreeAddr is freeAddr for the tests of package cluster_test.
+var FreeAddr = freeAddr
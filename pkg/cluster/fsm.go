@@ -0,0 +1,245 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"sharelock/pkg/locker"
+
+	"github.com/hashicorp/raft"
+)
+
+const (
+	op_Hold         = "hold"
+	op_Release      = "release"
+	op_Queue        = "queue"
+	op_Dequeue      = "dequeue"
+	op_Member       = "member"
+	op_ForgetMember = "forget_member"
+)
+
+// command is one entry of the raft log, a change the leader made to
+// its locker or to the membership.
+type command struct {
+	Op        string        `json:"op"`
+	Lock      *lockRecord   `json:"lock,omitempty"`
+	Queued    *queuedRecord `json:"queued,omitempty"`
+	Member    *Member       `json:"member,omitempty"`
+	Namespace string        `json:"namespace,omitempty"`
+	Key       string        `json:"key,omitempty"`
+	ClientId  string        `json:"client_id,omitempty"`
+}
+
+type lockRecord struct {
+	Namespace      string    `json:"namespace"`
+	Key            string    `json:"key"`
+	Holder         string    `json:"holder"`
+	LeaseId        uint64    `json:"lease_id"`
+	HeldSince      time.Time `json:"held_since"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+type queuedRecord struct {
+	Namespace string        `json:"namespace"`
+	Key       string        `json:"key"`
+	ClientId  string        `json:"client_id"`
+	Lease     time.Duration `json:"lease"`
+	QueuedAt  time.Time     `json:"queued_at"`
+	WaitUntil time.Time     `json:"wait_until"`
+}
+
+// snapshot is the whole state of the fsm, as raft snapshots keep it.
+type snapshot struct {
+	LeaseSeq uint64         `json:"lease_seq"`
+	Locks    []lockRecord   `json:"locks"`
+	Queued   []queuedRecord `json:"queued"`
+	Members  []Member       `json:"members"`
+}
+
+type lockId struct {
+	namespace string
+	key       string
+}
+
+// fsm is the replicated state: the held locks and queued requests of
+// the leader's locker, and the client addresses of the members. Every
+// member applies the same log, so a new leader restores its locker
+// from its own fsm.
+type fsm struct {
+	mu       sync.RWMutex
+	leaseSeq uint64
+	locks    map[lockId]lockRecord
+	// queues keep the requests of every key in arrival order
+	queues  map[lockId][]queuedRecord
+	members map[string]Member
+}
+
+func newFsm() *fsm {
+	return &fsm{
+		locks:   make(map[lockId]lockRecord),
+		queues:  make(map[lockId][]queuedRecord),
+		members: make(map[string]Member),
+	}
+}
+
+// Apply implements raft.FSM, it returns an error for entries it cannot
+// read.
+func (f *fsm) Apply(entry *raft.Log) any {
+	var cmd command
+	err := json.Unmarshal(entry.Data, &cmd)
+	if err != nil {
+		return fmt.Errorf("decoding raft log entry %d : %w", entry.Index, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case cmd.Op == op_Hold && cmd.Lock != nil:
+		id := lockId{cmd.Lock.Namespace, cmd.Lock.Key}
+		f.locks[id] = *cmd.Lock
+		f.leaseSeq = max(f.leaseSeq, cmd.Lock.LeaseId)
+		f.dequeue(id, cmd.Lock.Holder)
+	case cmd.Op == op_Release:
+		delete(f.locks, lockId{cmd.Namespace, cmd.Key})
+	case cmd.Op == op_Queue && cmd.Queued != nil:
+		id := lockId{cmd.Queued.Namespace, cmd.Queued.Key}
+		f.dequeue(id, cmd.Queued.ClientId)
+		f.queues[id] = append(f.queues[id], *cmd.Queued)
+	case cmd.Op == op_Dequeue:
+		f.dequeue(lockId{cmd.Namespace, cmd.Key}, cmd.ClientId)
+	case cmd.Op == op_Member && cmd.Member != nil:
+		f.members[cmd.Member.Id] = *cmd.Member
+	case cmd.Op == op_ForgetMember && cmd.Member != nil:
+		delete(f.members, cmd.Member.Id)
+	default:
+		return fmt.Errorf("unknown raft log operation %q in entry %d", cmd.Op, entry.Index)
+	}
+	return nil
+}
+
+func (f *fsm) dequeue(id lockId, clientId string) {
+	queue := f.queues[id]
+	for i, queued := range queue {
+		if queued.ClientId == clientId {
+			queue = append(queue[:i:i], queue[i+1:]...)
+			break
+		}
+	}
+	if len(queue) == 0 {
+		delete(f.queues, id)
+		return
+	}
+	f.queues[id] = queue
+}
+
+// snapshot copies the state, sorted so that equal states encode alike.
+func (f *fsm) snapshot() snapshot {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	s := snapshot{
+		LeaseSeq: f.leaseSeq,
+		Locks:    make([]lockRecord, 0, len(f.locks)),
+		Queued:   make([]queuedRecord, 0),
+		Members:  make([]Member, 0, len(f.members)),
+	}
+	for _, lock := range f.locks {
+		s.Locks = append(s.Locks, lock)
+	}
+	sort.Slice(s.Locks, func(i, j int) bool {
+		return lessId(lockId{s.Locks[i].Namespace, s.Locks[i].Key}, lockId{s.Locks[j].Namespace, s.Locks[j].Key})
+	})
+	ids := make([]lockId, 0, len(f.queues))
+	for id := range f.queues {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return lessId(ids[i], ids[j]) })
+	for _, id := range ids {
+		s.Queued = append(s.Queued, f.queues[id]...)
+	}
+	for _, member := range f.members {
+		s.Members = append(s.Members, member)
+	}
+	sort.Slice(s.Members, func(i, j int) bool { return s.Members[i].Id < s.Members[j].Id })
+	return s
+}
+
+func lessId(a, b lockId) bool {
+	if a.namespace != b.namespace {
+		return a.namespace < b.namespace
+	}
+	return a.key < b.key
+}
+
+// state is the fsm as the locker restores it.
+func (f *fsm) state() locker.State {
+	s := f.snapshot()
+	state := locker.State{
+		LeaseSeq: s.LeaseSeq,
+		Locks:    make([]locker.HeldLock, 0, len(s.Locks)),
+		Queued:   make([]locker.QueuedLock, 0, len(s.Queued)),
+	}
+	for _, lock := range s.Locks {
+		state.Locks = append(state.Locks, locker.HeldLock(lock))
+	}
+	for _, queued := range s.Queued {
+		state.Queued = append(state.Queued, locker.QueuedLock(queued))
+	}
+	return state
+}
+
+func (f *fsm) member(id string) (Member, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	member, ok := f.members[id]
+	return member, ok
+}
+
+// Snapshot implements raft.FSM.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return &fsmSnapshot{state: f.snapshot()}, nil
+}
+
+// Restore implements raft.FSM.
+func (f *fsm) Restore(reader io.ReadCloser) error {
+	defer reader.Close()
+	var s snapshot
+	err := json.NewDecoder(reader).Decode(&s)
+	if err != nil {
+		return fmt.Errorf("decoding raft snapshot : %w", err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.leaseSeq = s.LeaseSeq
+	f.locks = make(map[lockId]lockRecord, len(s.Locks))
+	for _, lock := range s.Locks {
+		f.locks[lockId{lock.Namespace, lock.Key}] = lock
+	}
+	f.queues = make(map[lockId][]queuedRecord)
+	for _, queued := range s.Queued {
+		id := lockId{queued.Namespace, queued.Key}
+		f.queues[id] = append(f.queues[id], queued)
+	}
+	f.members = make(map[string]Member, len(s.Members))
+	for _, member := range s.Members {
+		f.members[member.Id] = member
+	}
+	return nil
+}
+
+type fsmSnapshot struct {
+	state snapshot
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	err := json.NewEncoder(sink).Encode(s.state)
+	if err != nil {
+		sink.Cancel()
+		return fmt.Errorf("writing raft snapshot : %w", err)
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {}
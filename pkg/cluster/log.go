@@ -0,0 +1,99 @@
+package cluster
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// raftLogger passes the log lines of raft to slog. Its level follows
+// the one of slog, SetLevel does nothing.
+type raftLogger struct {
+	logger *slog.Logger
+	name   string
+	args   []any
+}
+
+func newRaftLogger() hclog.Logger {
+	return &raftLogger{logger: slog.Default().With("component", "raft"), name: "raft"}
+}
+
+func slogLevel(level hclog.Level) slog.Level {
+	switch level {
+	case hclog.Trace:
+		return slog.LevelDebug - 4
+	case hclog.Debug:
+		return slog.LevelDebug
+	case hclog.Warn:
+		return slog.LevelWarn
+	case hclog.Error:
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func (l *raftLogger) Log(level hclog.Level, msg string, args ...any) {
+	l.logger.Log(context.Background(), slogLevel(level), msg, args...)
+}
+
+func (l *raftLogger) Trace(msg string, args ...any) { l.Log(hclog.Trace, msg, args...) }
+func (l *raftLogger) Debug(msg string, args ...any) { l.Log(hclog.Debug, msg, args...) }
+func (l *raftLogger) Info(msg string, args ...any)  { l.Log(hclog.Info, msg, args...) }
+func (l *raftLogger) Warn(msg string, args ...any)  { l.Log(hclog.Warn, msg, args...) }
+func (l *raftLogger) Error(msg string, args ...any) { l.Log(hclog.Error, msg, args...) }
+
+func (l *raftLogger) enabled(level hclog.Level) bool {
+	return l.logger.Enabled(context.Background(), slogLevel(level))
+}
+
+func (l *raftLogger) IsTrace() bool { return l.enabled(hclog.Trace) }
+func (l *raftLogger) IsDebug() bool { return l.enabled(hclog.Debug) }
+func (l *raftLogger) IsInfo() bool  { return l.enabled(hclog.Info) }
+func (l *raftLogger) IsWarn() bool  { return l.enabled(hclog.Warn) }
+func (l *raftLogger) IsError() bool { return l.enabled(hclog.Error) }
+
+func (l *raftLogger) ImpliedArgs() []any { return l.args }
+
+func (l *raftLogger) With(args ...any) hclog.Logger {
+	return &raftLogger{
+		logger: l.logger.With(args...),
+		name:   l.name,
+		args:   append(append([]any{}, l.args...), args...),
+	}
+}
+
+func (l *raftLogger) Name() string { return l.name }
+
+func (l *raftLogger) Named(name string) hclog.Logger {
+	return l.ResetNamed(l.name + "." + name)
+}
+
+func (l *raftLogger) ResetNamed(name string) hclog.Logger {
+	return &raftLogger{
+		logger: slog.Default().With("component", name).With(l.args...),
+		name:   name,
+		args:   l.args,
+	}
+}
+
+func (l *raftLogger) SetLevel(level hclog.Level) {}
+
+func (l *raftLogger) GetLevel() hclog.Level {
+	for _, level := range []hclog.Level{hclog.Trace, hclog.Debug, hclog.Info, hclog.Warn} {
+		if l.enabled(level) {
+			return level
+		}
+	}
+	return hclog.Error
+}
+
+func (l *raftLogger) StandardLogger(opts *hclog.StandardLoggerOptions) *log.Logger {
+	return slog.NewLogLogger(l.logger.Handler(), slog.LevelInfo)
+}
+
+func (l *raftLogger) StandardWriter(opts *hclog.StandardLoggerOptions) io.Writer {
+	return l.StandardLogger(opts).Writer()
+}
@@ -0,0 +1,425 @@
+// Package cluster replicates the locker of ShareLock over raft. Only
+// the leader grants locks, every change it makes to its locker is
+// committed to the raft log before the client hears of it, and a new
+// leader restores the held locks, their leases and the queued requests
+// from the log.
+package cluster
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sharelock/pkg/locker"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb/v2"
+)
+
+const (
+	// RaftFile is the name of the raft log in the dir of a node
+	RaftFile = "raft.db"
+	// DefaultApplyTimeout bounds the commit of one change
+	DefaultApplyTimeout = time.Second * 5
+
+	// leadFailures failed activations hand the lead to another node,
+	// leadRetryDelay grows up to leadRetryMaxDelay between them
+	leadFailures      = 3
+	leadRetryDelay    = time.Millisecond * 200
+	leadRetryMaxDelay = time.Second * 5
+
+	snapshotsRetained = 2
+	transportPool     = 3
+	transportTimeout  = time.Second * 10
+)
+
+// Member is one node of the cluster. The client addresses of a member
+// are known once it led the cluster or joined it through AddMember.
+type Member struct {
+	Id          string `json:"id"`
+	RaftAddress string `json:"raft_address,omitempty"`
+	GrpcAddress string `json:"grpc_address,omitempty"`
+	HttpAddress string `json:"http_address,omitempty"`
+	Voter       bool   `json:"-"`
+	Leader      bool   `json:"-"`
+}
+
+type Options struct {
+	NodeId string
+	// RaftAddress is the host:port the raft transport listens on
+	RaftAddress string
+	// RaftAdvertise is the address other nodes reach the transport at,
+	// RaftAddress when empty
+	RaftAdvertise string
+	// Dir keeps the raft log and snapshots
+	Dir string
+	// Bootstrap creates a new cluster of this node and Peers, unless
+	// Dir already holds one
+	Bootstrap bool
+	// Peers are the other voters of a bootstrapped cluster, id to raft
+	// address
+	Peers map[string]string
+	// GrpcAddress and HttpAddress are where clients and the other
+	// nodes reach the servers of this node
+	GrpcAddress string
+	HttpAddress string
+	// ApplyTimeout bounds the commit of one change
+	ApplyTimeout time.Duration
+	// HeartbeatTimeout and ElectionTimeout tune the failover, zero uses
+	// the raft defaults
+	HeartbeatTimeout time.Duration
+	ElectionTimeout  time.Duration
+	// TLS carries the raft traffic over mutual tls: its certificate is
+	// presented to the other nodes, and ClientCAs and RootCAs verify
+	// theirs. Without it Insecure must be set, the raft traffic is then
+	// plaintext and unauthenticated.
+	TLS      *tls.Config
+	Insecure bool
+}
+
+// Node is the raft member of one ShareLock process.
+type Node struct {
+	opts      Options
+	raft      *raft.Raft
+	fsm       *fsm
+	logs      *raftboltdb.BoltStore
+	transport *raft.NetworkTransport
+	notify    chan bool
+	// leading is set while the locker of this node is active
+	leading atomic.Bool
+}
+
+// Open starts the raft member of opts. The node follows until Run
+// activates the locker on winning an election.
+func Open(opts Options) (*Node, error) {
+	if opts.TLS == nil && !opts.Insecure {
+		return nil, ErrInsecureTransport
+	}
+	if opts.ApplyTimeout <= 0 {
+		opts.ApplyTimeout = DefaultApplyTimeout
+	}
+	if opts.RaftAdvertise == "" {
+		opts.RaftAdvertise = opts.RaftAddress
+	}
+	err := os.MkdirAll(opts.Dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating raft dir : %w", err)
+	}
+
+	logger := newRaftLogger()
+	n := &Node{
+		opts:   opts,
+		fsm:    newFsm(),
+		notify: make(chan bool, 16),
+	}
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(opts.NodeId)
+	conf.Logger = logger
+	conf.NotifyCh = n.notify
+	if opts.HeartbeatTimeout > 0 {
+		conf.HeartbeatTimeout = opts.HeartbeatTimeout
+		conf.LeaderLeaseTimeout = min(conf.LeaderLeaseTimeout, opts.HeartbeatTimeout)
+	}
+	if opts.ElectionTimeout > 0 {
+		conf.ElectionTimeout = opts.ElectionTimeout
+	}
+
+	n.transport, err = newTransport(opts, logger)
+	if err != nil {
+		return nil, fmt.Errorf("listening for raft : %w", err)
+	}
+	snapshots, err := raft.NewFileSnapshotStoreWithLogger(opts.Dir, snapshotsRetained, logger)
+	if err != nil {
+		n.transport.Close()
+		return nil, fmt.Errorf("opening raft snapshots : %w", err)
+	}
+	n.logs, err = raftboltdb.New(raftboltdb.Options{Path: filepath.Join(opts.Dir, RaftFile)})
+	if err != nil {
+		n.transport.Close()
+		return nil, fmt.Errorf("opening raft log : %w", err)
+	}
+
+	if opts.Bootstrap {
+		err = n.bootstrap(conf, snapshots)
+		if err != nil {
+			n.close()
+			return nil, err
+		}
+	}
+	n.raft, err = raft.NewRaft(conf, n.fsm, n.logs, n.logs, snapshots, n.transport)
+	if err != nil {
+		n.close()
+		return nil, fmt.Errorf("starting raft : %w", err)
+	}
+	return n, nil
+}
+
+func (n *Node) bootstrap(conf *raft.Config, snapshots raft.SnapshotStore) error {
+	existing, err := raft.HasExistingState(n.logs, n.logs, snapshots)
+	if err != nil {
+		return fmt.Errorf("reading raft state : %w", err)
+	}
+	if existing {
+		return nil
+	}
+	servers := []raft.Server{{ID: conf.LocalID, Address: n.transport.LocalAddr()}}
+	for id, address := range n.opts.Peers {
+		servers = append(servers, raft.Server{ID: raft.ServerID(id), Address: raft.ServerAddress(address)})
+	}
+	err = raft.BootstrapCluster(conf, n.logs, n.logs, snapshots, n.transport, raft.Configuration{Servers: servers})
+	if err != nil {
+		return fmt.Errorf("bootstrapping cluster : %w", err)
+	}
+	slog.Info("bootstrapped cluster", "voters", len(servers))
+	return nil
+}
+
+func (n *Node) close() {
+	n.transport.Close()
+	if n.logs != nil {
+		n.logs.Close()
+	}
+}
+
+// Id is the id of this node.
+func (n *Node) Id() string {
+	return n.opts.NodeId
+}
+
+// Store is the store of the locker of this node. It must be given to a
+// locker created WithStandby, which Run activates.
+func (n *Node) Store() locker.Store {
+	return &raftStore{node: n}
+}
+
+// Run activates l while this node leads the cluster and puts it back
+// on standby when it stops leading, until ctx is done. Before activating
+// it waits for every change of the previous leader to be applied, so the
+// locker starts from the whole state.
+func (n *Node) Run(ctx context.Context, l *locker.Locker) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case leader := <-n.notify:
+			if leader {
+				n.lead(ctx, l)
+			} else {
+				n.follow(ctx, l)
+			}
+		}
+	}
+}
+
+// lead activates l once this node won an election. A failed attempt is
+// retried with a growing delay as long as this node leads. After
+// leadFailures attempts it also hands the lead to another node, a leader
+// with a locker on standby would fail every call with NOT_LEADER.
+func (n *Node) lead(ctx context.Context, l *locker.Locker) {
+	slog.Info("won the cluster election, restoring the locker", "node", n.opts.NodeId)
+	delay := leadRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := n.activate(ctx, l)
+		if err == nil {
+			n.leading.Store(true)
+			slog.Info("leading the cluster", "node", n.opts.NodeId)
+			return
+		}
+		if ctx.Err() != nil || n.raft.State() != raft.Leader {
+			slog.Warn("lost the cluster leadership before activating the locker", "err", err)
+			return
+		}
+		if attempt == leadFailures {
+			slog.Error("activating the locker, handing the lead to another node", "attempts", attempt, "err", err)
+			err = n.raft.LeadershipTransfer().Error()
+			if err == nil {
+				return
+			}
+			slog.Error("transferring leadership, retrying to activate the locker", "err", err)
+		} else {
+			slog.Warn("activating the locker, retrying", "attempt", attempt, "delay", delay, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, leadRetryMaxDelay)
+	}
+}
+
+// activate restores the locker from the whole raft log and activates it.
+func (n *Node) activate(ctx context.Context, l *locker.Locker) error {
+	err := n.raft.Barrier(n.opts.ApplyTimeout).Error()
+	if err != nil {
+		return fmt.Errorf("catching up with the raft log : %w", err)
+	}
+	err = n.apply(command{Op: op_Member, Member: &Member{
+		Id:          n.opts.NodeId,
+		RaftAddress: n.opts.RaftAdvertise,
+		GrpcAddress: n.opts.GrpcAddress,
+		HttpAddress: n.opts.HttpAddress,
+	}})
+	if err != nil {
+		slog.Warn("recording the client addresses of the leader", "err", err)
+	}
+	return l.Activate(ctx)
+}
+
+func (n *Node) follow(ctx context.Context, l *locker.Locker) {
+	n.leading.Store(false)
+	err := l.Deactivate(ctx)
+	if err != nil {
+		slog.Error("putting the locker on standby", "err", err)
+		return
+	}
+	slog.Info("lost the cluster leadership, locker on standby", "node", n.opts.NodeId)
+}
+
+// Leading reports whether this node leads the cluster with an active
+// locker.
+func (n *Node) Leading() bool {
+	return n.leading.Load() && n.raft.State() == raft.Leader
+}
+
+// Leader is the current leader, false while there is none. Its client
+// addresses are empty until it recorded them.
+func (n *Node) Leader() (Member, bool) {
+	address, id := n.raft.LeaderWithID()
+	if id == "" {
+		return Member{}, false
+	}
+	member, ok := n.fsm.member(string(id))
+	if !ok {
+		member = Member{Id: string(id)}
+	}
+	member.RaftAddress = string(address)
+	member.Voter = true
+	member.Leader = true
+	return member, true
+}
+
+// Members lists the nodes of the raft configuration.
+func (n *Node) Members() ([]Member, error) {
+	future := n.raft.GetConfiguration()
+	err := future.Error()
+	if err != nil {
+		return nil, fmt.Errorf("reading cluster configuration : %w", err)
+	}
+	_, leaderId := n.raft.LeaderWithID()
+	members := make([]Member, 0)
+	for _, server := range future.Configuration().Servers {
+		member, ok := n.fsm.member(string(server.ID))
+		if !ok {
+			member = Member{Id: string(server.ID)}
+		}
+		member.RaftAddress = string(server.Address)
+		member.Voter = server.Suffrage == raft.Voter
+		member.Leader = server.ID == leaderId
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// AddMember adds a voter to the cluster and records its client
+// addresses. Only the leader may.
+func (n *Node) AddMember(member Member) error {
+	if n.raft.State() != raft.Leader {
+		return locker.ErrNotLeader
+	}
+	err := n.raft.AddVoter(raft.ServerID(member.Id), raft.ServerAddress(member.RaftAddress), 0, n.opts.ApplyTimeout).Error()
+	if err != nil {
+		return fmt.Errorf("adding member %s : %w", member.Id, notLeader(err))
+	}
+	member.Voter, member.Leader = false, false
+	return n.apply(command{Op: op_Member, Member: &member})
+}
+
+// RemoveMember removes a node from the cluster. Only the leader may, it
+// may remove itself, another node then takes over.
+func (n *Node) RemoveMember(id string) error {
+	if n.raft.State() != raft.Leader {
+		return locker.ErrNotLeader
+	}
+	err := n.apply(command{Op: op_ForgetMember, Member: &Member{Id: id}})
+	if err != nil {
+		return err
+	}
+	err = n.raft.RemoveServer(raft.ServerID(id), 0, n.opts.ApplyTimeout).Error()
+	if err != nil {
+		return fmt.Errorf("removing member %s : %w", id, notLeader(err))
+	}
+	return nil
+}
+
+// TransferLeadership hands the lead to another voter. Only the leader
+// may.
+func (n *Node) TransferLeadership() error {
+	if n.raft.State() != raft.Leader {
+		return locker.ErrNotLeader
+	}
+	err := n.raft.LeadershipTransfer().Error()
+	if err != nil {
+		return fmt.Errorf("transferring leadership : %w", notLeader(err))
+	}
+	return nil
+}
+
+// Shutdown stops the raft member of this node.
+func (n *Node) Shutdown() error {
+	n.leading.Store(false)
+	err := n.raft.Shutdown().Error()
+	n.close()
+	return err
+}
+
+// apply commits cmd and waits until this node applied it.
+func (n *Node) apply(cmd command) error {
+	if n.raft.State() != raft.Leader {
+		return locker.ErrNotLeader
+	}
+	data, err := encodeCommand(cmd)
+	if err != nil {
+		return err
+	}
+	future := n.raft.Apply(data, n.opts.ApplyTimeout)
+	err = future.Error()
+	if err != nil {
+		return fmt.Errorf("committing %s : %w", cmd.Op, notLeader(err))
+	}
+	if err, ok := future.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// notLeader wraps the raft errors of a node that is no longer leader in
+// locker.ErrNotLeader. A change that failed this way may still have been
+// committed, which keeps a key held at worst until its lease runs out.
+func notLeader(err error) error {
+	if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrLeadershipLost) ||
+		errors.Is(err, raft.ErrLeadershipTransferInProgress) || errors.Is(err, raft.ErrRaftShutdown) {
+		return fmt.Errorf("%w : %w", locker.ErrNotLeader, err)
+	}
+	return err
+}
+
+// ParsePeers reads peers written as id=host:port.
+func ParsePeers(peers []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(peers))
+	for _, peer := range peers {
+		id, address, ok := strings.Cut(peer, "=")
+		if !ok || id == "" || address == "" {
+			return nil, fmt.Errorf("peer %q is not id=host:port", peer)
+		}
+		parsed[id] = address
+	}
+	return parsed, nil
+}
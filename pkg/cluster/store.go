@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sharelock/pkg/locker"
+)
+
+// raftStore is the locker.QueueStore of a node: every change is a raft
+// log entry, committed to a quorum before the locker goes on.
+type raftStore struct {
+	node *Node
+}
+
+func encodeCommand(cmd command) ([]byte, error) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("encoding raft command : %w", err)
+	}
+	return data, nil
+}
+
+// Load implements locker.Store with the state of the fsm of the node.
+func (s *raftStore) Load() (locker.State, error) {
+	return s.node.fsm.state(), nil
+}
+
+func (s *raftStore) Hold(lock locker.HeldLock) error {
+	record := lockRecord(lock)
+	return s.node.apply(command{Op: op_Hold, Lock: &record})
+}
+
+func (s *raftStore) Release(namespace, key string) error {
+	return s.node.apply(command{Op: op_Release, Namespace: namespace, Key: key})
+}
+
+func (s *raftStore) Queue(queued locker.QueuedLock) error {
+	record := queuedRecord(queued)
+	return s.node.apply(command{Op: op_Queue, Queued: &record})
+}
+
+func (s *raftStore) Dequeue(namespace, key, clientId string) error {
+	return s.node.apply(command{Op: op_Dequeue, Namespace: namespace, Key: key, ClientId: clientId})
+}
+
+// Close does nothing, the node is shut down on its own.
+func (s *raftStore) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package cluster
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+)
+
+// ErrInsecureTransport refuses a node without raft tls that was not
+// explicitly allowed to run without it.
+var ErrInsecureTransport = errors.New("the raft transport needs tls, or an explicit insecure opt-in")
+
+// tlsStreamLayer carries the raft traffic over mutual tls. Both ends
+// verify the certificate of the other against the cluster ca, so only
+// nodes holding a certificate of it can join or replicate.
+type tlsStreamLayer struct {
+	net.Listener
+	advertise net.Addr
+	config    *tls.Config
+}
+
+func (s *tlsStreamLayer) Addr() net.Addr {
+	return s.advertise
+}
+
+func (s *tlsStreamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout}, Config: s.config}
+	return dialer.Dial("tcp", string(address))
+}
+
+// newTransport listens for the other nodes on opts.RaftAddress, over
+// tls unless Open was allowed to run without it.
+func newTransport(opts Options, logger hclog.Logger) (*raft.NetworkTransport, error) {
+	advertise, err := net.ResolveTCPAddr("tcp", opts.RaftAdvertise)
+	if err != nil {
+		return nil, fmt.Errorf("resolving raft advertise address : %w", err)
+	}
+	if opts.TLS == nil {
+		return raft.NewTCPTransportWithLogger(opts.RaftAddress, advertise, transportPool, transportTimeout, logger)
+	}
+	if advertise.IP == nil || advertise.IP.IsUnspecified() {
+		return nil, fmt.Errorf("raft advertise address %s is not one the other nodes can dial", opts.RaftAdvertise)
+	}
+	config := opts.TLS.Clone()
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	listener, err := tls.Listen("tcp", opts.RaftAddress, config)
+	if err != nil {
+		return nil, err
+	}
+	stream := &tlsStreamLayer{Listener: listener, advertise: advertise, config: config}
+	return raft.NewNetworkTransportWithConfig(&raft.NetworkTransportConfig{
+		Stream:  stream,
+		MaxPool: transportPool,
+		Timeout: transportTimeout,
+		Logger:  logger,
+	}), nil
+}
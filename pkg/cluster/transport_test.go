@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// testCA issues certificates for 127.0.0.1.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sharelock test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// tlsConfig is the raft tls config of a node holding a certificate of
+// ca for 127.0.0.1.
+func (ca *testCA) tlsConfig(t *testing.T, name string) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		RootCAs:      ca.pool,
+		ClientCAs:    ca.pool,
+	}
+}
+
+// freeAddr is a localhost address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestOpenNeedsTLS(t *testing.T) {
+	_, err := Open(Options{NodeId: "node0", RaftAddress: "127.0.0.1:0", Dir: t.TempDir()})
+	if !errors.Is(err, ErrInsecureTransport) {
+		t.Fatalf("opening without tls got %v, want %v", err, ErrInsecureTransport)
+	}
+}
+
+func TestTransportVerifiesPeers(t *testing.T) {
+	ca := newTestCA(t)
+	address := freeAddr(t)
+	transport, err := newTransport(Options{
+		RaftAddress:   address,
+		RaftAdvertise: address,
+		TLS:           ca.tlsConfig(t, "node0"),
+	}, newRaftLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer transport.Close()
+
+	tests := []struct {
+		name   string
+		config *tls.Config
+		ok     bool
+	}{
+		{"certificate of the cluster ca", ca.tlsConfig(t, "node1"), true},
+		{"certificate of another ca", newTestCA(t).tlsConfig(t, "intruder"), false},
+		{"no certificate", &tls.Config{RootCAs: ca.pool}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config.Clone()
+			config.RootCAs = ca.pool
+			conn, err := tls.Dial("tcp", address, config)
+			if err == nil {
+				defer conn.Close()
+				// the server checks the client certificate after the
+				// handshake of the client, its refusal shows on read
+				conn.SetReadDeadline(time.Now().Add(time.Millisecond * 200))
+				_, err = conn.Read(make([]byte, 1))
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					err = nil
+				}
+			}
+			if tt.ok && err != nil {
+				t.Fatalf("a node of the cluster could not connect : %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("a caller outside of the cluster connected")
+			}
+		})
+	}
+}
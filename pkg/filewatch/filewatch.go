@@ -0,0 +1,119 @@
+package filewatch
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher calls a func when one of a set of files changes. It watches
+// the directories of the files rather than the files, which keeps
+// working when a file is replaced by a rename, as editors, cert-manager
+// and Kubernetes secrets and config maps do.
+type Watcher struct {
+	watcher *fsnotify.Watcher
+	// delay groups the events of one update, files are often written
+	// in several steps and a key pair as two files
+	delay    time.Duration
+	onChange func()
+
+	mu    sync.Mutex
+	files map[string]bool
+	dirs  map[string]bool
+}
+
+func New(delay time.Duration, onChange func()) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		watcher:  watcher,
+		delay:    delay,
+		onChange: onChange,
+		files:    make(map[string]bool),
+		dirs:     make(map[string]bool),
+	}, nil
+}
+
+// Watch replaces the watched files by paths, empty paths are skipped.
+func (w *Watcher) Watch(paths ...string) error {
+	files := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		path = filepath.Clean(path)
+		files[path] = true
+		dirs[filepath.Dir(path)] = true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for dir := range dirs {
+		if w.dirs[dir] {
+			continue
+		}
+		err := w.watcher.Add(dir)
+		if err != nil {
+			return err
+		}
+	}
+	for dir := range w.dirs {
+		if !dirs[dir] {
+			w.watcher.Remove(dir)
+		}
+	}
+	w.files, w.dirs = files, dirs
+	return nil
+}
+
+// Close stops a watcher that is not running.
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// Run delivers the changes until ctx is done, then closes the watcher.
+func (w *Watcher) Run(ctx context.Context) {
+	defer w.watcher.Close()
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.matches(event.Name) {
+				timer = time.After(w.delay)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Error("watching files", "err", err)
+		case <-timer:
+			timer = nil
+			w.onChange()
+		}
+	}
+}
+
+// matches reports whether an event on name may have changed a watched
+// file. Kubernetes updates a mounted volume by swapping the ..data
+// symlink of its directory.
+func (w *Watcher) matches(name string) bool {
+	name = filepath.Clean(name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.files[name] {
+		return true
+	}
+	return filepath.Base(name) == "..data" && w.dirs[filepath.Dir(name)]
+}
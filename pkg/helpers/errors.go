@@ -1,11 +1,158 @@
 package helpers
 
 import (
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// ErrorDomain is the domain of every google.rpc.ErrorInfo detail
+// attached to ShareLock errors.
+const ErrorDomain = "sharelock"
+
+// Reasons are stable, machine readable identifiers of a failure. They
+// are sent as google.rpc.ErrorInfo over gRPC and as the "reason" field
+// of the error body over HTTP. New reasons may be added, existing ones
+// are never renamed.
+const (
+	Reason_NilRequest       = "NIL_REQUEST"
+	Reason_MalformedRequest = "MALFORMED_REQUEST"
+	Reason_KeyMissing       = "KEY_MISSING"
+	Reason_ClientIdMissing  = "CLIENT_ID_MISSING"
+	Reason_InvalidData      = "INVALID_DATA"
+	Reason_LockWaitTimeout  = "LOCK_WAIT_TIMEOUT"
+	Reason_LockNotHeld      = "LOCK_NOT_HELD"
+	Reason_RequestTimeout   = "REQUEST_TIMEOUT"
+	Reason_RequestCancelled = "REQUEST_CANCELLED"
+	Reason_MethodNotAllowed = "METHOD_NOT_ALLOWED"
+	Reason_RequestTooLarge  = "REQUEST_TOO_LARGE"
+	// Reason_UnsupportedMediaType is a body neither JSON nor protobuf
+	Reason_UnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+	// Reason_NotAcceptable is an Accept header allowing neither JSON
+	// nor protobuf
+	Reason_NotAcceptable    = "NOT_ACCEPTABLE"
+	Reason_InvalidLease     = "INVALID_LEASE"
+	Reason_Unauthenticated  = "UNAUTHENTICATED"
+	Reason_PermissionDenied = "PERMISSION_DENIED"
+	Reason_InvalidNamespace = "INVALID_NAMESPACE"
+	Reason_QuotaExceeded    = "QUOTA_EXCEEDED"
+	Reason_RateLimited      = "RATE_LIMITED"
+	Reason_TooManyPending   = "TOO_MANY_PENDING"
+	Reason_KeyTooLong       = "KEY_TOO_LONG"
+	Reason_ShuttingDown     = "SHUTTING_DOWN"
+	Reason_NotReady         = "NOT_READY"
+	Reason_NotLeader        = "NOT_LEADER"
+	Reason_InvalidMember    = "INVALID_MEMBER"
+	Reason_ClusterChange    = "CLUSTER_CHANGE_FAILED"
+	Reason_KeyMoved         = "KEY_MOVED"
+	Reason_Rebalancing      = "REBALANCING"
+	Reason_InvalidSnapshot  = "INVALID_SNAPSHOT"
+	Reason_StateNotEmpty    = "STATE_NOT_EMPTY"
+	Reason_IdempotencyKey   = "IDEMPOTENCY_KEY_REUSED"
+	Reason_Internal         = "INTERNAL"
 )
 
 var (
-	Err_Srv_NilRequest         = status.Error(codes.InvalidArgument, "nil request")
-	Err_Srv_Request_KeyMissing = status.Error(codes.InvalidArgument, "request key missing")
+	Err_Srv_NilRequest              = NewError(codes.InvalidArgument, Reason_NilRequest, "nil request")
+	Err_Srv_MalformedRequest        = NewError(codes.InvalidArgument, Reason_MalformedRequest, "request body could not be decoded")
+	Err_Srv_Request_KeyMissing      = NewError(codes.InvalidArgument, Reason_KeyMissing, "request key missing")
+	Err_Srv_Request_ClientIdMissing = NewError(codes.InvalidArgument, Reason_ClientIdMissing, "request client id missing")
+	Err_Srv_InvalidData             = NewError(codes.InvalidArgument, Reason_InvalidData, "request rejected by locker")
+	Err_Srv_LockWaitTimeout         = NewError(codes.DeadlineExceeded, Reason_LockWaitTimeout, "lock not acquired before the wait timeout")
+	Err_Srv_LockNotHeld             = NewError(codes.NotFound, Reason_LockNotHeld, "lock is not held by this client")
+	Err_Srv_RequestTimeout          = NewError(codes.DeadlineExceeded, Reason_RequestTimeout, "request deadline exceeded")
+	Err_Srv_RequestCancelled        = NewError(codes.Canceled, Reason_RequestCancelled, "request cancelled")
+	Err_Srv_MethodNotAllowed        = NewError(codes.Unimplemented, Reason_MethodNotAllowed, "method not allowed")
+	Err_Srv_RequestTooLarge         = NewError(codes.InvalidArgument, Reason_RequestTooLarge, "request body too large")
+	Err_Srv_UnsupportedMediaType    = NewError(codes.InvalidArgument, Reason_UnsupportedMediaType, "request body must be application/json or application/x-protobuf")
+	Err_Srv_NotAcceptable           = NewError(codes.InvalidArgument, Reason_NotAcceptable, "response can only be application/json or application/x-protobuf")
+	Err_Srv_InvalidLease            = NewError(codes.InvalidArgument, Reason_InvalidLease, "lease must not be negative")
+	Err_Srv_Unauthenticated         = NewError(codes.Unauthenticated, Reason_Unauthenticated, "missing or invalid credentials")
+	Err_Srv_PermissionDenied        = NewError(codes.PermissionDenied, Reason_PermissionDenied, "not allowed by the acl policy")
+	Err_Srv_InvalidNamespace        = NewError(codes.InvalidArgument, Reason_InvalidNamespace, "namespace must be 1 to 63 letters, digits, '.', '_' or '-'")
+	Err_Srv_QuotaExceeded           = NewError(codes.ResourceExhausted, Reason_QuotaExceeded, "lock quota exceeded")
+	Err_Srv_RateLimited             = NewError(codes.ResourceExhausted, Reason_RateLimited, "request rate limit exceeded")
+	Err_Srv_TooManyPending          = NewError(codes.ResourceExhausted, Reason_TooManyPending, "too many pending requests for this client")
+	Err_Srv_KeyTooLong              = NewError(codes.InvalidArgument, Reason_KeyTooLong, "lock key too long")
+	Err_Srv_ShuttingDown            = NewError(codes.Unavailable, Reason_ShuttingDown, "server is shutting down, retry on another instance")
+	Err_Srv_NotReady                = NewError(codes.Unavailable, Reason_NotReady, "locker is not responding")
+	Err_Srv_NotLeader               = NewError(codes.Unavailable, Reason_NotLeader, "this node does not lead the cluster, retry on the leader")
+	Err_Srv_InvalidMember           = NewError(codes.InvalidArgument, Reason_InvalidMember, "member id and raft address are required")
+	Err_Srv_KeyMoved                = NewError(codes.Unavailable, Reason_KeyMoved, "key moved to another node, retry")
+	Err_Srv_Rebalancing             = NewError(codes.FailedPrecondition, Reason_Rebalancing, "the last ring change is still rebalancing")
+	Err_Srv_StateNotEmpty           = NewError(codes.FailedPrecondition, Reason_StateNotEmpty, "the locker holds or queues keys already, import into a fresh instance")
+	Err_Srv_IdempotencyKeyReused    = NewError(codes.FailedPrecondition, Reason_IdempotencyKey, "idempotency key was used for another request")
+	Err_Srv_Internal                = NewError(codes.Internal, Reason_Internal, "internal error")
 )
+
+// NewError returns a gRPC status error carrying a google.rpc.ErrorInfo
+// detail with the given reason.
+func NewError(code codes.Code, reason, message string) error {
+	return NewErrorWithMetadata(code, reason, message, nil)
+}
+
+// NewErrorWithMetadata is NewError with metadata in the ErrorInfo, such
+// as the address of the leader a client should retry on.
+func NewErrorWithMetadata(code codes.Code, reason, message string, metadata map[string]string) error {
+	st := status.New(code, message)
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// WithRetryDelay returns err with a google.rpc.RetryInfo detail telling
+// the client how long to wait before trying again. Errors that are not
+// gRPC statuses are returned unchanged.
+func WithRetryDelay(err error, delay time.Duration) error {
+	st, ok := status.FromError(err)
+	if !ok || delay <= 0 {
+		return err
+	}
+	withDetails, detailErr := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(delay),
+	})
+	if detailErr != nil {
+		return err
+	}
+	return withDetails.Err()
+}
+
+// RetryDelay returns the RetryInfo delay attached to err, if any.
+func RetryDelay(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.RetryInfo)
+		if ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration(), true
+		}
+	}
+	return 0, false
+}
+
+// ErrorReason returns the ErrorInfo reason of a ShareLock error, or an
+// empty string when err carries none.
+func ErrorReason(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if ok && info.GetDomain() == ErrorDomain {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
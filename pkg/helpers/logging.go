@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	LogFormat_Text = "text"
+	LogFormat_Json = "json"
+)
+
+var (
+	// logLevel is shared by every handler SetupLogging installs, so the
+	// level changes without replacing them
+	logLevel slog.LevelVar
+
+	logLevelMu sync.Mutex
+	// configuredLevel is the level of the config, forcedDebug overrides
+	// it until it is toggled off again
+	configuredLevel slog.Level
+	forcedDebug     bool
+)
+
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "info", "":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q, use debug, info, warn or error", name)
+}
+
+// NewLogHandler writes to w in format, text or json, and drops the
+// records below the level of SetLogLevel.
+func NewLogHandler(w io.Writer, format string) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	switch strings.ToLower(format) {
+	case LogFormat_Text, "":
+		return slog.NewTextHandler(w, opts), nil
+	case LogFormat_Json:
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, use text or json", format)
+}
+
+// SetupLogging makes the default slog logger write to stderr in format.
+// The standard logger goes through it too, at info level. It can be
+// called again to change the format.
+func SetupLogging(format string) error {
+	handler, err := NewLogHandler(os.Stderr, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// SetLogLevel drops the records below level from now on, unless debug
+// logging was toggled on.
+func SetLogLevel(level slog.Level) {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	configuredLevel = level
+	applyLogLevel()
+}
+
+// ToggleDebugLogging switches debug logging on, or back to the level of
+// SetLogLevel, and returns whether it is now on.
+func ToggleDebugLogging() bool {
+	logLevelMu.Lock()
+	defer logLevelMu.Unlock()
+	forcedDebug = !forcedDebug
+	applyLogLevel()
+	return forcedDebug
+}
+
+func applyLogLevel() {
+	if forcedDebug {
+		logLevel.Set(slog.LevelDebug)
+		return
+	}
+	logLevel.Set(configuredLevel)
+}
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger, usually one
+// with the fields of the request ctx belongs to.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger of ContextWithLogger.
+func LoggerFromContext(ctx context.Context) (*slog.Logger, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	logger, ok := ctx.Value(loggerKey{}).(*slog.Logger)
+	return logger, ok
+}
+
+// Logger is the logger of ctx, or the default one.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := LoggerFromContext(ctx); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,110 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+const unixScheme = "unix://"
+
+// Parse splits a listen address into the network and address of
+// net.Listen. An address is either host:port, with an IPv6 host in
+// brackets and an empty host for every interface, or unix:///path for
+// a unix domain socket.
+func Parse(address string) (network, addr string, err error) {
+	if path, ok := strings.CutPrefix(address, unixScheme); ok {
+		if !strings.HasPrefix(path, "/") {
+			return "", "", fmt.Errorf("unix socket %q needs an absolute path, as in unix:///run/sharelock.sock", address)
+		}
+		return "unix", path, nil
+	}
+	if strings.Contains(address, "://") {
+		return "", "", fmt.Errorf("listen address %q has an unknown scheme, use host:port or unix:///path", address)
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", fmt.Errorf("listen address %q : %w", address, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return "", "", fmt.Errorf("listen address %q : %w", address, err)
+	}
+	return "tcp", address, nil
+}
+
+// Listen opens a listener on every address, or none if one fails. Unix
+// sockets get socketMode as file mode, a stale socket left by a previous
+// run is replaced.
+func Listen(addresses []string, socketMode os.FileMode) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		lis, err := listen(address, socketMode)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, lis)
+	}
+	return listeners, nil
+}
+
+func listen(address string, socketMode os.FileMode) (net.Listener, error) {
+	network, addr, err := Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		err = removeStaleSocket(addr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lis, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" && socketMode != 0 {
+		err = os.Chmod(addr, socketMode)
+		if err != nil {
+			lis.Close()
+			return nil, fmt.Errorf("setting mode of unix socket %s : %w", addr, err)
+		}
+	}
+	return lis, nil
+}
+
+// removeStaleSocket deletes the socket file of a previous run that did
+// not exit cleanly. A socket somebody still accepts on, or anything but
+// a socket, is left alone and net.Listen fails on it.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return nil
+	}
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("unix socket %s is in use", path)
+	}
+	return os.Remove(path)
+}
+
+// Describe is the listen address of lis, in the form Parse reads.
+func Describe(lis net.Listener) string {
+	addr := lis.Addr()
+	if addr.Network() == "unix" {
+		return unixScheme + addr.String()
+	}
+	return addr.String()
+}
@@ -0,0 +1,146 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		address     string
+		wantNetwork string
+		wantAddr    string
+		wantErr     string
+	}{
+		{address: "127.0.0.1:50051", wantNetwork: "tcp", wantAddr: "127.0.0.1:50051"},
+		{address: ":8080", wantNetwork: "tcp", wantAddr: ":8080"},
+		{address: "[::1]:8080", wantNetwork: "tcp", wantAddr: "[::1]:8080"},
+		{address: "localhost:http", wantNetwork: "tcp", wantAddr: "localhost:http"},
+		{address: "unix:///run/sharelock.sock", wantNetwork: "unix", wantAddr: "/run/sharelock.sock"},
+		{address: "unix://run/sharelock.sock", wantErr: "absolute path"},
+		{address: "tcp://127.0.0.1:1", wantErr: "unknown scheme"},
+		{address: "::1:8080", wantErr: "too many colons"},
+		{address: "127.0.0.1", wantErr: "missing port"},
+		{address: "127.0.0.1:nope", wantErr: "unknown port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			network, addr, err := Parse(tt.address)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if network != tt.wantNetwork || addr != tt.wantAddr {
+				t.Fatalf("got %s %s, want %s %s", network, addr, tt.wantNetwork, tt.wantAddr)
+			}
+		})
+	}
+}
+
+func TestListenUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sharelock.sock")
+	address := unixScheme + path
+
+	listeners, err := Listen([]string{address}, 0o660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o660 {
+		t.Fatalf("socket mode %o, want 660", info.Mode().Perm())
+	}
+	if Describe(listeners[0]) != address {
+		t.Fatalf("described as %s, want %s", Describe(listeners[0]), address)
+	}
+
+	// a socket somebody accepts on is not taken over
+	_, err = Listen([]string{address}, 0o660)
+	if err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Fatalf("got %v, want an error about a socket in use", err)
+	}
+
+	// the socket of a run that did not exit cleanly is replaced
+	listeners[0].(*net.UnixListener).SetUnlinkOnClose(false)
+	listeners[0].Close()
+	if _, err = os.Stat(path); err != nil {
+		t.Fatalf("the stale socket is gone already : %v", err)
+	}
+	listeners, err = Listen([]string{address}, 0o600)
+	if err != nil {
+		t.Fatalf("replacing a stale socket : %v", err)
+	}
+	defer listeners[0].Close()
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestListenKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("keep me"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Listen([]string{unixScheme + path}, 0o660)
+	if err == nil {
+		t.Fatal("listened on a regular file")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "keep me" {
+		t.Fatalf("the file was changed : %q %v", data, err)
+	}
+}
+
+func TestListenIPv6(t *testing.T) {
+	probe, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback : %v", err)
+	}
+	probe.Close()
+
+	listeners, err := Listen([]string{"[::1]:0"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listeners[0].Close()
+	address := Describe(listeners[0])
+	if !strings.HasPrefix(address, "[::1]:") {
+		t.Fatalf("listening on %s, want [::1]", address)
+	}
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+}
+
+func TestListenAllOrNothing(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	socket := filepath.Join(t.TempDir(), "sharelock.sock")
+
+	_, err = Listen([]string{unixScheme + socket, taken.Addr().String()}, 0o660)
+	if err == nil {
+		t.Fatal("listened on an address in use")
+	}
+	// the listener opened before the failure was closed
+	if _, err = os.Stat(socket); !os.IsNotExist(err) {
+		t.Fatalf("the socket of the first address is left : %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package listener
+
+import (
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+
+	"github.com/coreos/go-systemd/v22/activation"
+)
+
+// inherited are the sockets systemd passed with LISTEN_FDS, by the
+// FileDescriptorName of their socket unit. They are read once, and the
+// variables are cleared so child processes do not take them too.
+var (
+	inheritedOnce sync.Once
+	inheritedMu   sync.Mutex
+	inherited     map[string][]net.Listener
+)
+
+func loadInherited() {
+	inheritedOnce.Do(func() {
+		listeners, err := activation.ListenersWithNames()
+		if err != nil {
+			slog.Error("reading the sockets passed by systemd", "err", err)
+			return
+		}
+		inherited = listeners
+	})
+}
+
+// Inherited takes the sockets systemd passed under name, nil if there
+// are none. Each socket is handed out only once.
+func Inherited(name string) []net.Listener {
+	loadInherited()
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	listeners := inherited[name]
+	delete(inherited, name)
+	return listeners
+}
+
+// CloseUnclaimed closes the sockets passed by systemd that no server
+// took and returns their names.
+func CloseUnclaimed() []string {
+	loadInherited()
+	inheritedMu.Lock()
+	defer inheritedMu.Unlock()
+	names := make([]string, 0, len(inherited))
+	for name, listeners := range inherited {
+		for _, lis := range listeners {
+			lis.Close()
+		}
+		names = append(names, name)
+	}
+	clear(inherited)
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// inheritedChildEnv holds the addresses the child run of
+// TestInheritedChild expects under http and grpc.
+const inheritedChildEnv = "SHARELOCK_TEST_INHERITED"
+
+// TestInherited passes two sockets to a child run of the test binary the
+// way systemd does. LISTEN_PID must be the pid of the child, so a shell
+// sets it before it execs the binary.
+func TestInherited(t *testing.T) {
+	tests := []struct {
+		name      string
+		listenPid string
+		want      string
+	}{
+		{name: "passed to us", listenPid: "$$"},
+		{name: "passed to another process", listenPid: "1", want: "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := make([]*os.File, 0, 2)
+			addresses := make([]string, 0, 2)
+			for range 2 {
+				lis, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				file, err := lis.(*net.TCPListener).File()
+				lis.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				files = append(files, file)
+				addresses = append(addresses, lis.Addr().String())
+			}
+			want := tt.want
+			if want == "" {
+				want = strings.Join(addresses, ",")
+			}
+
+			cmd := exec.Command("sh", "-c", `LISTEN_PID=`+tt.listenPid+` exec "$0" -test.run=^TestInheritedChild$ -test.v`, os.Args[0])
+			cmd.Env = append(os.Environ(), "LISTEN_FDS=2", "LISTEN_FDNAMES=http:grpc", inheritedChildEnv+"="+want)
+			cmd.ExtraFiles = files
+			out, err := cmd.CombinedOutput()
+			if err != nil || !strings.Contains(string(out), "--- PASS: TestInheritedChild") {
+				t.Fatalf("child run failed : %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestInheritedChild(t *testing.T) {
+	want, ok := os.LookupEnv(inheritedChildEnv)
+	if !ok {
+		t.Skip("only run by TestInherited")
+	}
+	if want == "none" {
+		if lis := Inherited("http"); lis != nil {
+			t.Fatalf("took %d sockets passed to another process", len(lis))
+		}
+		if names := CloseUnclaimed(); len(names) != 0 {
+			t.Fatalf("closed %q passed to another process", names)
+		}
+		return
+	}
+	addresses := strings.Split(want, ",")
+
+	http := Inherited("http")
+	if len(http) != 1 || http[0].Addr().String() != addresses[0] {
+		t.Fatalf("got %v for http, want %s", http, addresses[0])
+	}
+	defer http[0].Close()
+	conn, err := net.Dial("tcp", addresses[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	// each socket is handed out once
+	if again := Inherited("http"); again != nil {
+		t.Fatalf("http was handed out twice")
+	}
+	if other := Inherited("admin"); other != nil {
+		t.Fatalf("got %v for a name that was not passed", other)
+	}
+	// the variables are cleared for child processes
+	for _, name := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		if value, ok := os.LookupEnv(name); ok {
+			t.Fatalf("%s=%s is left for child processes", name, value)
+		}
+	}
+
+	names := CloseUnclaimed()
+	if !slices.Equal(names, []string{"grpc"}) {
+		t.Fatalf("closed %q, want grpc", names)
+	}
+	if names := CloseUnclaimed(); len(names) != 0 {
+		t.Fatalf("closed %q twice", names)
+	}
+	if grpc := Inherited("grpc"); grpc != nil {
+		t.Fatal("a closed socket was handed out")
+	}
+}
@@ -0,0 +1,71 @@
+package locker
+
+import (
+	"context"
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditEvent_Acquire      AuditEventType = "acquire"
+	AuditEvent_Renew        AuditEventType = "renew"
+	AuditEvent_Release      AuditEventType = "release"
+	AuditEvent_ForceRelease AuditEventType = "force_release"
+	AuditEvent_Expire       AuditEventType = "expire"
+)
+
+// AuditEvent is a change of the holder or the lease of a key.
+type AuditEvent struct {
+	Type      AuditEventType
+	Time      time.Time
+	Namespace string
+	Key       string
+	// ClientId caused the event, for an expiry it is the holder
+	ClientId string
+	// Holder held the lock, it differs from ClientId when a force
+	// release took it away
+	Holder string
+	// LeaseId numbers the lease granted or renewed, or the one that
+	// ended. It only grows, so it fences out stale holders.
+	LeaseId        uint64
+	LeaseExpiresAt time.Time
+	// Ctx is the context of the request of ClientId, it carries what the
+	// server knows about its source
+	Ctx context.Context
+}
+
+// Auditor is told about every AuditEvent from inside the loop of Start.
+// Audit must return quickly and must not call the locker.
+type Auditor interface {
+	Audit(event AuditEvent)
+}
+
+// WithAuditor reports the grants, renewals and releases of the locker
+// to a.
+func WithAuditor(a Auditor) Option {
+	return func(l *Locker) {
+		if a != nil {
+			l.auditor = a
+		}
+	}
+}
+
+type nopAuditor struct{}
+
+func (nopAuditor) Audit(event AuditEvent) {}
+
+// audit reports event of key k, caused by client.
+func (k *KeyHandler) audit(eventType AuditEventType, client *Client) {
+	k.locker.auditor.Audit(AuditEvent{
+		Type:           eventType,
+		Time:           k.locker.clock.Now(),
+		Namespace:      k.ns.name,
+		Key:            k.key,
+		ClientId:       client.Id,
+		Holder:         k.holdingId,
+		LeaseId:        k.leaseId,
+		LeaseExpiresAt: k.leaseExpiresAt,
+		Ctx:            client.Ctx,
+	})
+}
@@ -1,6 +1,10 @@
 package locker
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
 type Status int
 
@@ -10,11 +14,99 @@ const (
 	Status_Timeout
 	Status_UnknownLock
 	Status_InvalidData
+	Status_Renewed
+	// Status_QuotaExceeded means the namespace of the client is full
+	Status_QuotaExceeded
+	// Status_Shutdown means the locker is draining and grants no locks,
+	// or is frozen and changes nothing
+	Status_Shutdown
+	// Status_StoreFailed means the store could not save the lock or
+	// the renewal, the client does not get it
+	Status_StoreFailed
+	// Status_NotLeader means the locker is on standby, another member
+	// of its cluster grants the locks
+	Status_NotLeader
+	// Status_Moved means the key was handed off to another locker, see
+	// Locker.HandOff
+	Status_Moved
 )
 
+// Client is a single lock, unlock or renew request. StatusChan must be
+// buffered, the locker never blocks on a caller that went away.
 type Client struct {
-	Ctx        context.Context
-	Id         string
+	Ctx context.Context
+	Id  string
+	// Namespace is the key space of LockKey, empty is DefaultNamespace
+	Namespace  string
 	LockKey    string
 	StatusChan chan Status
+	// Lease is how long the lock is held before it expires on its own.
+	// Zero uses the default lease of the locker.
+	Lease time.Duration
+	// Force makes an unlock release the lock whoever holds it.
+	Force bool
+	// Foreign locks a key that belongs to another locker: it is only
+	// queued while the key is busy here, an idle key is handed off and
+	// the request answered with Status_Moved.
+	Foreign bool
+	// LeaseExpiresAt is set by the locker before it reports
+	// Status_Locked or Status_Renewed.
+	LeaseExpiresAt time.Time
+	// queuedAt is when the locker queued a lock request
+	queuedAt time.Time
+	// saved is set once the store keeps the queued request
+	saved bool
+	// restored requests were loaded from the store, nobody waits on
+	// them until their client retries, see KeyHandler.reattach
+	restored bool
+	// waitUntil ends the wait of a restored request, which has no
+	// context of its own
+	waitUntil time.Time
+}
+
+// waiting reports whether a queued client still wants the lock.
+func (c *Client) waiting(now time.Time) bool {
+	if c.restored {
+		return now.Before(c.waitUntil)
+	}
+	return c.Ctx.Err() == nil
+}
+
+// notify delivers status without blocking and reports whether the
+// caller received it.
+func (c *Client) notify(status Status) bool {
+	select {
+	case c.StatusChan <- status:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s Status) String() string {
+	switch s {
+	case Status_Unlocked:
+		return "Unlocked"
+	case Status_Locked:
+		return "Locked"
+	case Status_Timeout:
+		return "Timeout"
+	case Status_UnknownLock:
+		return "UnknownLock"
+	case Status_InvalidData:
+		return "InvalidData"
+	case Status_Renewed:
+		return "Renewed"
+	case Status_QuotaExceeded:
+		return "QuotaExceeded"
+	case Status_Shutdown:
+		return "Shutdown"
+	case Status_StoreFailed:
+		return "StoreFailed"
+	case Status_NotLeader:
+		return "NotLeader"
+	case Status_Moved:
+		return "Moved"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
 }
@@ -0,0 +1,30 @@
+package locker
+
+import "time"
+
+// Clock is the source of time for the locker and its lease timers.
+// Production code uses the wall clock, simulations use FakeClock.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is the subset of time.Timer used by the locker.
+type Timer interface {
+	Stop() bool
+}
+
+type realClock struct{}
+
+// NewRealClock returns a Clock backed by the time package.
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
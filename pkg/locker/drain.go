@@ -0,0 +1,60 @@
+package locker
+
+import (
+	"context"
+)
+
+// Drain prepares the locker for shutdown. Every queued waiter and every
+// later lock request is answered with Status_Shutdown, while holders
+// keep their locks and may still renew and unlock them.
+func (l *Locker) Drain(ctx context.Context) error {
+	return l.control(ctx, l.drain)
+}
+
+func (l *Locker) drain() {
+	l.draining = true
+	for _, ns := range l.namespaces {
+		for _, keyHandler := range ns.keys {
+			for _, client := range keyHandler.queue {
+				if client != nil {
+					l.forgetQueued(client)
+					client.notify(Status_Shutdown)
+				}
+			}
+			ns.waiters -= len(keyHandler.queue)
+			clear(keyHandler.queue)
+			keyHandler.queue = keyHandler.queue[:0]
+		}
+	}
+}
+
+// HeldLocks is the number of keys currently held in all namespaces.
+func (l *Locker) HeldLocks(ctx context.Context) (int, error) {
+	held := 0
+	err := l.control(ctx, func() {
+		held = l.held
+	})
+	return held, err
+}
+
+// Ping returns once the loop of Start has answered, an error if it did
+// not before ctx was done.
+func (l *Locker) Ping(ctx context.Context) error {
+	return l.control(ctx, func() {})
+}
+
+// control runs f inside the loop of Start and waits for it to finish.
+func (l *Locker) control(ctx context.Context, f func()) error {
+	done := make(chan struct{})
+	select {
+	case l.controlChan <- func() { f(); close(done) }:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
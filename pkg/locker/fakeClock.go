@@ -0,0 +1,105 @@
+package locker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a manually advanced Clock. Timers created from it fire
+// only when Advance moves the clock past their deadline, and their
+// functions run on the goroutine calling Advance.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock starting at the given time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.seq++
+	t := &fakeTimer{
+		clock:    f,
+		seq:      f.seq,
+		deadline: f.now.Add(d),
+		fn:       fn,
+	}
+	f.timers = append(f.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d and runs every timer whose
+// deadline has passed, in deadline order. Timers sharing a deadline run
+// in the order they were created.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	due := make([]*fakeTimer, 0)
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if !t.deadline.After(f.now) {
+			due = append(due, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	f.timers = pending
+	f.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool {
+		if due[i].deadline.Equal(due[j].deadline) {
+			return due[i].seq < due[j].seq
+		}
+		return due[i].deadline.Before(due[j].deadline)
+	})
+	for _, t := range due {
+		t.fn()
+	}
+}
+
+// NextDeadline returns the earliest pending timer deadline, if any.
+func (f *FakeClock) NextDeadline() (time.Time, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var next time.Time
+	found := false
+	for _, t := range f.timers {
+		if !found || t.deadline.Before(next) {
+			next = t.deadline
+			found = true
+		}
+	}
+	return next, found
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	seq      uint64
+	deadline time.Time
+	fn       func()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i := range t.clock.timers {
+		if t.clock.timers[i] == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package locker
+
+import (
+	"context"
+	"log/slog"
+)
+
+// fenceKey identifies a key across namespaces in Locker.fenced.
+func fenceKey(namespace, key string) string {
+	return namespace + "\x00" + key
+}
+
+// busy reports whether a key is held or waited for.
+func (l *Locker) busy(namespace, key string) bool {
+	_, exist := l.keyHandler(namespace, key)
+	return exist
+}
+
+// fenceIdle fences a key nobody holds or waits for, and reports whether
+// it is fenced. A fenced key belongs to another locker: lock requests
+// for it are answered with Status_Moved until Unfence.
+func (l *Locker) fenceIdle(namespace, key string) bool {
+	if l.fenced[fenceKey(namespace, key)] {
+		return true
+	}
+	if l.busy(namespace, key) {
+		return false
+	}
+	l.fenced[fenceKey(namespace, key)] = true
+	return true
+}
+
+// HandOff gives a key to another locker if nobody holds or waits for it
+// here, and reports whether it did. Once handed off, the key is never
+// granted here again until Unfence, so the other locker may grant it
+// without both holding it at once. A busy key stays here, the other
+// locker should send its requests here until it is idle.
+func (l *Locker) HandOff(ctx context.Context, namespace, key string) (bool, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	handed := false
+	err := l.control(ctx, func() {
+		handed = l.fenceIdle(namespace, key)
+		if handed {
+			slog.Debug("key handed off", "namespace", namespace, "key", key)
+		}
+	})
+	return handed, err
+}
+
+// Unfence forgets every hand-off, once the keys may come back here.
+func (l *Locker) Unfence(ctx context.Context) error {
+	return l.control(ctx, func() {
+		l.fenced = make(map[string]bool)
+	})
+}
+
+// ForeignKeys counts the keys held or waited for here that owns says
+// belong to another locker.
+func (l *Locker) ForeignKeys(ctx context.Context, owns func(namespace, key string) bool) (int, error) {
+	foreign := 0
+	err := l.control(ctx, func() {
+		for _, ns := range l.namespaces {
+			for key := range ns.keys {
+				if !owns(ns.name, key) {
+					foreign++
+				}
+			}
+		}
+	})
+	return foreign, err
+}
@@ -2,79 +2,291 @@ package locker
 
 import (
 	"context"
+	"log/slog"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+const DefaultLease = time.Minute
+
+// Locker serialises every request through the loop in Start. The loop
+// is the only goroutine touching the key handlers, lease timers merely
+// post an expiry back into it.
 type Locker struct {
-	keys          map[string]*KeyHandler
-	deleteKeyChan chan string
-	lockChan      chan *Client
-	unlockChan    chan *Client
+	namespaces  map[string]*namespace
+	lockChan    chan *Client
+	unlockChan  chan *Client
+	renewChan   chan *Client
+	inspectChan chan *inspection
+	expireChan  chan leaseExpiry
+	// controlChan runs functions inside the loop, for rare requests such
+	// as draining that need no channel of their own
+	controlChan  chan func()
+	clock        Clock
+	defaultLease time.Duration
+	// leaseSeq numbers every lease granted or renewed by this locker
+	leaseSeq uint64
+	// defaultLimits apply to namespaces missing from namespaceLimits
+	defaultLimits   Limits
+	namespaceLimits map[string]Limits
+	// clientLocks counts the keys every client holds, in all namespaces
+	clientLocks       map[string]int
+	maxLocksPerClient int
+	held              int
+	// draining refuses new locks, see Drain
+	draining bool
+	// standby refuses every request, see WithStandby
+	standby bool
+	// frozen refuses every change, see Export
+	frozen bool
+	// fenced keys were handed off to another locker, see HandOff
+	fenced   map[string]bool
+	observer Observer
+	auditor  Auditor
+	store    Store
+}
+
+type Option func(*Locker)
+
+// WithClock makes the locker and its lease timers read time from the
+// given clock instead of the wall clock.
+func WithClock(clock Clock) Option {
+	return func(l *Locker) {
+		if clock != nil {
+			l.clock = clock
+		}
+	}
 }
 
-func NewLocker() *Locker {
-	return &Locker{
-		keys:          make(map[string]*KeyHandler, 10_000),
-		deleteKeyChan: make(chan string, 10_000),
-		lockChan:      make(chan *Client, 10_000),
-		unlockChan:    make(chan *Client, 10_000),
+// WithDefaultLease sets the lease of clients that do not ask for one.
+func WithDefaultLease(lease time.Duration) Option {
+	return func(l *Locker) {
+		if lease > 0 {
+			l.defaultLease = lease
+		}
 	}
 }
 
+func NewLocker(opts ...Option) *Locker {
+	l := &Locker{
+		namespaces:   make(map[string]*namespace),
+		clientLocks:  make(map[string]int),
+		fenced:       make(map[string]bool),
+		lockChan:     make(chan *Client, 10_000),
+		unlockChan:   make(chan *Client, 10_000),
+		renewChan:    make(chan *Client, 10_000),
+		inspectChan:  make(chan *inspection, 10_000),
+		expireChan:   make(chan leaseExpiry, 10_000),
+		controlChan:  make(chan func(), 100),
+		clock:        NewRealClock(),
+		defaultLease: DefaultLease,
+		observer:     nopObserver{},
+		auditor:      nopAuditor{},
+		store:        nopStore{},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Reconfigure applies opts inside the loop of Start, typically new
+// limits or a new default lease. Held locks, their leases and queued
+// clients are kept, the new values apply from the next request on.
+func (l *Locker) Reconfigure(ctx context.Context, opts ...Option) error {
+	return l.control(ctx, func() {
+		for _, opt := range opts {
+			opt(l)
+		}
+	})
+}
+
 func (l *Locker) Start(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case toRemoveKey := <-l.deleteKeyChan:
-			delete(l.keys, toRemoveKey)
 		case client := <-l.lockChan:
-			if client == nil {
-				continue
-			}
-			keyHandler, exist := l.keys[client.LockKey]
-			if exist {
-				keyHandler.clientsChan <- client
-			} else {
-				newKeyHandler := &KeyHandler{
-					key:           client.LockKey,
-					releaseChan:   make(chan bool, 1),
-					clientsChan:   make(chan *Client, 10_000),
-					holdingId:     "",
-					deleteKeyChan: l.deleteKeyChan,
-				}
-				newKeyHandler.releaseChan <- true
-				l.keys[client.LockKey] = newKeyHandler
-				go newKeyHandler.Handle()
-				newKeyHandler.clientsChan <- client
-			}
+			l.lock(client)
 		case client := <-l.unlockChan:
-			if client == nil {
-				continue
-			}
-			keyHandler, exist := l.keys[client.LockKey]
-			if exist {
-				if keyHandler.holdingId == client.Id {
-					keyHandler.holdingId = ""
-					client.StatusChan <- Status_Unlocked
-					if len(keyHandler.clientsChan) == 0 {
-						l.deleteKeyChan <- keyHandler.key
-					}
-					keyHandler.releaseChan <- true
-					continue
-				}
-			}
-			client.StatusChan <- Status_UnknownLock
+			l.unlock(client)
+		case client := <-l.renewChan:
+			l.renew(client)
+		case req := <-l.inspectChan:
+			l.inspect(req)
+		case expiry := <-l.expireChan:
+			l.expire(expiry)
+		case f := <-l.controlChan:
+			f()
 		}
 	}
 }
 
-func (l *Locker) Lock(client *Client) {
+func (l *Locker) lock(client *Client) {
 	if client == nil {
 		return
 	}
-	if client.StatusChan == nil ||
-		client.Id == "" || client.LockKey == "" {
+	if l.standby {
+		client.notify(Status_NotLeader)
+		return
+	}
+	if l.draining || l.frozen {
+		client.notify(Status_Shutdown)
+		return
+	}
+	if l.fenced[fenceKey(client.namespace(), client.LockKey)] || (client.Foreign && l.fenceIdle(client.namespace(), client.LockKey)) {
+		client.notify(Status_Moved)
+		return
+	}
+	ns := l.namespace(client.namespace())
+	keyHandler, exist := ns.keys[client.LockKey]
+	if exist && keyHandler.reattach(client) {
+		return
+	}
+	if exist && keyHandler.holdingId != "" && !ns.canWait() {
+		logTransition(client, "lock refused, too many waiters in namespace", slog.String("namespace", ns.name))
+		client.notify(Status_QuotaExceeded)
+		return
+	}
+	if !exist {
+		keyHandler = &KeyHandler{
+			key:    client.LockKey,
+			locker: l,
+			ns:     ns,
+		}
+		ns.keys[client.LockKey] = keyHandler
+	}
+	client.queuedAt = l.clock.Now()
+	keyHandler.enqueue(client)
+}
+
+func (l *Locker) unlock(client *Client) {
+	if client == nil {
+		return
+	}
+	if l.standby {
+		client.notify(Status_NotLeader)
+		return
+	}
+	if l.frozen {
+		client.notify(Status_Shutdown)
+		return
+	}
+	keyHandler, exist := l.keyHandler(client.namespace(), client.LockKey)
+	if exist && keyHandler.holdingId != "" && (client.Force || keyHandler.holdingId == client.Id) {
+		event := AuditEvent_Release
+		if client.Force && keyHandler.holdingId != client.Id {
+			event = AuditEvent_ForceRelease
+			logClient(client, slog.LevelWarn, "force released a lock held by another client",
+				slog.String("holder", keyHandler.holdingId))
+		}
+		keyHandler.audit(event, client)
+		held := l.clock.Now().Sub(keyHandler.heldSince)
+		traceEvent(client.Ctx, "sharelock.released",
+			attribute.String("sharelock.holder", keyHandler.holdingId),
+			attribute.Int64("sharelock.held_ms", held.Milliseconds()),
+		)
+		logTransition(client, "lock released",
+			slog.String("namespace", keyHandler.ns.name),
+			slog.String("holder", keyHandler.holdingId),
+			slog.Duration("duration", held),
+		)
+		client.notify(Status_Unlocked)
+		keyHandler.release(false)
+		return
+	}
+	client.notify(Status_UnknownLock)
+}
+
+func (l *Locker) renew(client *Client) {
+	if client == nil {
+		return
+	}
+	if l.standby {
+		client.notify(Status_NotLeader)
+		return
+	}
+	if l.frozen {
+		client.notify(Status_Shutdown)
+		return
+	}
+	keyHandler, exist := l.keyHandler(client.namespace(), client.LockKey)
+	if exist && keyHandler.holdingId == client.Id {
+		err := keyHandler.startLease(client.Lease)
+		if err != nil {
+			logClient(client, slog.LevelError, "saving renewed lease, keeping the current one", slog.Any("err", err))
+			client.notify(storeStatus(err))
+			return
+		}
+		client.LeaseExpiresAt = keyHandler.leaseExpiresAt
+		keyHandler.audit(AuditEvent_Renew, client)
+		logTransition(client, "lease renewed",
+			slog.String("namespace", keyHandler.ns.name),
+			slog.Time("lease_expires_at", keyHandler.leaseExpiresAt),
+		)
+		client.notify(Status_Renewed)
+		return
+	}
+	client.notify(Status_UnknownLock)
+}
+
+func (l *Locker) expire(expiry leaseExpiry) {
+	keyHandler, exist := l.keyHandler(expiry.namespace, expiry.key)
+	if !exist || keyHandler.leaseId != expiry.leaseId {
+		// the lease was released or renewed after the timer fired
+		return
+	}
+	holder := keyHandler.holderClient()
+	keyHandler.audit(AuditEvent_Expire, holder)
+	logTransition(holder, "lease expired",
+		slog.String("namespace", keyHandler.ns.name),
+		slog.Duration("duration", l.clock.Now().Sub(keyHandler.heldSince)),
+	)
+	keyHandler.release(true)
+}
+
+func (l *Locker) inspect(req *inspection) {
+	if req.usage != nil {
+		info := NamespaceInfo{Namespace: req.namespace, Limits: l.limits(req.namespace)}
+		ns, exist := l.namespaces[req.namespace]
+		if exist {
+			info = ns.info()
+		}
+		req.usage <- info
+		return
+	}
+	info := KeyInfo{Namespace: req.namespace, Key: req.key}
+	keyHandler, exist := l.keyHandler(req.namespace, req.key)
+	if exist {
+		info = keyHandler.info()
+	}
+	req.reply <- info
+}
+
+func (l *Locker) keyHandler(namespace, key string) (*KeyHandler, bool) {
+	ns, exist := l.namespaces[namespace]
+	if !exist {
+		return nil, false
+	}
+	keyHandler, exist := ns.keys[key]
+	return keyHandler, exist
+}
+
+// deleteKey forgets a key nobody holds or waits for, and its namespace
+// once that has no keys left.
+func (l *Locker) deleteKey(k *KeyHandler) {
+	delete(k.ns.keys, k.key)
+	if len(k.ns.keys) == 0 {
+		delete(l.namespaces, k.ns.name)
+	}
+}
+
+func (l *Locker) Lock(client *Client) {
+	if client == nil || client.StatusChan == nil {
+		return
+	}
+	if client.Id == "" || client.LockKey == "" {
 		client.StatusChan <- Status_InvalidData
 		return
 	}
@@ -89,49 +301,332 @@ func (l *Locker) Unlock(client *Client) {
 	l.unlockChan <- client
 }
 
+// Renew restarts the lease of a held lock with client.Lease, or the
+// default lease when it is zero.
+func (l *Locker) Renew(client *Client) {
+	if client == nil || client.StatusChan == nil ||
+		client.Id == "" || client.LockKey == "" {
+		return
+	}
+	l.renewChan <- client
+}
+
+// Abandon takes back the lock granted to client when its caller stopped
+// waiting before it read the answer. The grant can race with the end of
+// the wait, the key would then stay held until its lease expires. It
+// reports whether a lock was released. Call it once client.Ctx is done,
+// the locker grants nothing to such a request anymore.
+func (l *Locker) Abandon(ctx context.Context, client *Client) (bool, error) {
+	abandoned := false
+	err := l.control(ctx, func() {
+		abandoned = l.abandon(client)
+	})
+	return abandoned, err
+}
+
+// abandon is Abandon inside the loop.
+func (l *Locker) abandon(client *Client) bool {
+	select {
+	case status := <-client.StatusChan:
+		if status != Status_Locked {
+			return false
+		}
+	default:
+		return false
+	}
+	keyHandler, exist := l.keyHandler(client.namespace(), client.LockKey)
+	if !exist || keyHandler.holder != client || l.frozen {
+		return false
+	}
+	keyHandler.audit(AuditEvent_Release, client)
+	logTransition(client, "lock abandoned, its caller stopped waiting",
+		slog.String("namespace", keyHandler.ns.name),
+	)
+	keyHandler.release(false)
+	return true
+}
+
+// KeyInfo is a point in time view of one key.
+type KeyInfo struct {
+	Namespace      string
+	Key            string
+	HoldingId      string
+	LeaseExpiresAt time.Time
+	Waiters        int
+}
+
+// inspection asks for a KeyInfo on reply, or for a NamespaceInfo on
+// usage when that is set.
+type inspection struct {
+	namespace string
+	key       string
+	reply     chan KeyInfo
+	usage     chan NamespaceInfo
+}
+
+// Inspect returns the current state of key in namespace. A key nobody
+// holds or waits for is reported with an empty HoldingId.
+func (l *Locker) Inspect(ctx context.Context, namespace, key string) (KeyInfo, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	req := &inspection{
+		namespace: namespace,
+		key:       key,
+		reply:     make(chan KeyInfo, 1),
+	}
+	select {
+	case l.inspectChan <- req:
+	case <-ctx.Done():
+		return KeyInfo{}, ctx.Err()
+	}
+	select {
+	case info := <-req.reply:
+		return info, nil
+	case <-ctx.Done():
+		return KeyInfo{}, ctx.Err()
+	}
+}
+
+type leaseExpiry struct {
+	namespace string
+	key       string
+	leaseId   uint64
+}
+
+// KeyHandler is the state of one key: its holder, the holder's lease
+// and the queue of waiting clients in arrival order.
 type KeyHandler struct {
-	key               string
-	releaseChan       chan bool
-	clientsChan       chan *Client
-	holdingId         string
-	deleteKeyChan     chan string
-	clientTimeout     *time.Ticker
-	clientTimeoutChan <-chan time.Time
+	key       string
+	locker    *Locker
+	ns        *namespace
+	holdingId string
+	// holder is the request granted the lock, its expiry is logged with it
+	holder         *Client
+	heldSince      time.Time
+	leaseExpiresAt time.Time
+	leaseTimer     Timer
+	// leaseId tells a stale expiry apart from the current lease, zero
+	// while the key is free
+	leaseId uint64
+	queue   []*Client
 }
 
-func (k *KeyHandler) Handle() {
-	defaultTimeTicker := time.NewTicker(time.Minute)
+func (k *KeyHandler) enqueue(client *Client) {
+	if k.holdingId != "" {
+		err := k.locker.saveQueued(client)
+		if err != nil {
+			logClient(client, slog.LevelError, "saving queued lock, refusing it", slog.Any("err", err))
+			client.notify(storeStatus(err))
+			return
+		}
+	}
+	k.queue = append(k.queue, client)
+	k.ns.waiters++
+	if k.holdingId == "" {
+		k.grantNext()
+		return
+	}
+	traceEvent(client.Ctx, "sharelock.queued",
+		attribute.String("sharelock.holder", k.holdingId),
+		attribute.Int("sharelock.queue_position", len(k.queue)),
+	)
+	logTransition(client, "lock queued",
+		slog.String("namespace", k.ns.name),
+		slog.String("holder", k.holdingId),
+		slog.Int("queue_position", len(k.queue)),
+	)
+}
 
-	for {
-		select {
-		case <-defaultTimeTicker.C:
-			if len(k.clientsChan) == 0 {
-				k.deleteKeyChan <- k.key
-				return
-			}
-		case <-k.clientTimeoutChan:
-			k.releaseChan <- true
-		case <-k.releaseChan:
-			if k.clientTimeout != nil {
-				k.clientTimeout.Stop()
-			}
-			if len(k.clientsChan) == 0 {
-				k.deleteKeyChan <- k.key
-				return
-			}
-			client := <-k.clientsChan
-			if client == nil {
-				k.releaseChan <- true
-				continue
-			}
-			if client.Ctx.Err() != nil {
-				k.releaseChan <- true
-				continue
-			}
-			k.holdingId = client.Id
-			k.clientTimeout = time.NewTicker(time.Minute)
-			k.clientTimeoutChan = k.clientTimeout.C
-			client.StatusChan <- Status_Locked
+// grantNext hands the lock to the first queued client that is still
+// waiting and within the quota of its namespace. The key is forgotten
+// once nobody holds or waits for it.
+func (k *KeyHandler) grantNext() {
+	for len(k.queue) > 0 {
+		client := k.queue[0]
+		k.queue[0] = nil
+		k.queue = k.queue[1:]
+		k.ns.waiters--
+		if client == nil {
+			continue
+		}
+		if !client.waiting(k.locker.clock.Now()) {
+			k.locker.forgetQueued(client)
+			continue
 		}
+		if !k.ns.canHold(client.Id) {
+			logTransition(client, "lock refused, too many locks held in namespace", slog.String("namespace", k.ns.name))
+			k.locker.forgetQueued(client)
+			client.notify(Status_QuotaExceeded)
+			continue
+		}
+		k.holdingId = client.Id
+		k.holder = client
+		k.heldSince = k.locker.clock.Now()
+		k.ns.hold(client.Id)
+		err := k.startLease(client.Lease)
+		if err != nil {
+			logClient(client, slog.LevelError, "saving granted lock, refusing it", slog.Any("err", err))
+			k.ns.unhold(client.Id)
+			k.holdingId, k.holder, k.heldSince = "", nil, time.Time{}
+			k.locker.forgetQueued(client)
+			client.notify(storeStatus(err))
+			continue
+		}
+		client.LeaseExpiresAt = k.leaseExpiresAt
+		if client.notify(Status_Locked) {
+			k.audit(AuditEvent_Acquire, client)
+			waited := k.heldSince.Sub(client.queuedAt)
+			k.locker.observer.Granted(k.ns.name, k.key, waited)
+			traceEvent(client.Ctx, "sharelock.granted",
+				attribute.Int64("sharelock.wait_ms", waited.Milliseconds()),
+				attribute.String("sharelock.lease_expires_at", k.leaseExpiresAt.Format(time.RFC3339Nano)),
+			)
+			logTransition(client, "lock granted",
+				slog.String("namespace", k.ns.name),
+				slog.Duration("duration", waited),
+				slog.Time("lease_expires_at", k.leaseExpiresAt),
+			)
+			return
+		}
+		logClient(client, slog.LevelError, "client left before the lock was granted")
+		k.stopLease()
+	}
+	k.locker.deleteKey(k)
+}
+
+// release takes the lock from its holder, expired tells an expiry from
+// an unlock.
+func (k *KeyHandler) release(expired bool) {
+	k.locker.observer.Released(k.ns.name, k.key, k.locker.clock.Now().Sub(k.heldSince), expired)
+	k.stopLease()
+	k.grantNext()
+}
+
+// reattach hands a restored request of the same client over to client:
+// the lock it was granted, with its current lease, or its place in the
+// queue. Restored requests were loaded from the store by Restore or
+// Activate, nobody waits on them until their client retries.
+func (k *KeyHandler) reattach(client *Client) bool {
+	if k.holdingId == client.Id && (k.holder == nil || k.holder.restored) {
+		k.holder = client
+		client.LeaseExpiresAt = k.leaseExpiresAt
+		logTransition(client, "restored lock reattached",
+			slog.String("namespace", k.ns.name),
+			slog.Time("lease_expires_at", k.leaseExpiresAt),
+		)
+		client.notify(Status_Locked)
+		return true
+	}
+	for i, queued := range k.queue {
+		if queued != nil && queued.restored && queued.Id == client.Id {
+			client.queuedAt = queued.queuedAt
+			client.saved = queued.saved
+			k.queue[i] = client
+			logTransition(client, "restored queued lock reattached",
+				slog.String("namespace", k.ns.name),
+				slog.String("holder", k.holdingId),
+				slog.Int("queue_position", i+1),
+			)
+			return true
+		}
+	}
+	return false
+}
+
+// holderClient is the request granted the lock, or a stand-in with the
+// id of the holder when it is unknown.
+func (k *KeyHandler) holderClient() *Client {
+	if k.holder != nil {
+		return k.holder
+	}
+	return &Client{Ctx: context.Background(), Id: k.holdingId, Namespace: k.ns.name, LockKey: k.key}
+}
+
+// startLease gives the holder a new lease id and a lease of lease, or
+// of the default lease when it is zero. Nothing changes when the store
+// fails to save it.
+func (k *KeyHandler) startLease(lease time.Duration) error {
+	if lease <= 0 {
+		lease = k.locker.defaultLease
+	}
+	leaseId := k.locker.leaseSeq + 1
+	expiresAt := k.locker.clock.Now().Add(lease)
+	err := k.locker.store.Hold(k.heldLock(leaseId, expiresAt))
+	if err != nil {
+		return err
+	}
+	k.locker.leaseSeq = leaseId
+	k.leaseId = leaseId
+	k.leaseExpiresAt = expiresAt
+	k.armLease()
+	return nil
+}
+
+// armLease starts the timer posting the expiry of the current lease.
+func (k *KeyHandler) armLease() {
+	if k.leaseTimer != nil {
+		k.leaseTimer.Stop()
+	}
+	expiry := leaseExpiry{namespace: k.ns.name, key: k.key, leaseId: k.leaseId}
+	expireChan := k.locker.expireChan
+	k.leaseTimer = k.locker.clock.AfterFunc(max(k.leaseExpiresAt.Sub(k.locker.clock.Now()), 0), func() {
+		expireChan <- expiry
+	})
+}
+
+func (k *KeyHandler) stopLease() {
+	if k.leaseTimer != nil {
+		k.leaseTimer.Stop()
+		k.leaseTimer = nil
+	}
+	if k.holdingId != "" {
+		k.ns.unhold(k.holdingId)
+		// a release the store missed keeps the key held until its lease
+		// runs out after a restart, which is safe
+		err := k.locker.store.Release(k.ns.name, k.key)
+		if err != nil {
+			slog.Error("saving released lock", "namespace", k.ns.name, "key", k.key, "err", err)
+		}
+	}
+	k.leaseId = 0
+	k.holdingId = ""
+	k.holder = nil
+	k.heldSince = time.Time{}
+	k.leaseExpiresAt = time.Time{}
+}
+
+// dropCancelled removes clients that stopped waiting from the queue.
+func (k *KeyHandler) dropCancelled() {
+	now := k.locker.clock.Now()
+	queue := k.queue[:0]
+	for _, client := range k.queue {
+		if client != nil && client.waiting(now) {
+			queue = append(queue, client)
+			continue
+		}
+		if client != nil {
+			k.locker.forgetQueued(client)
+		}
+		k.ns.waiters--
+	}
+	clear(k.queue[len(queue):])
+	k.queue = queue
+}
+
+func (k *KeyHandler) info() KeyInfo {
+	now := k.locker.clock.Now()
+	waiters := 0
+	for _, client := range k.queue {
+		if client != nil && client.waiting(now) {
+			waiters++
+		}
+	}
+	return KeyInfo{
+		Namespace:      k.ns.name,
+		Key:            k.key,
+		HoldingId:      k.holdingId,
+		LeaseExpiresAt: k.leaseExpiresAt,
+		Waiters:        waiters,
 	}
 }
@@ -0,0 +1,105 @@
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// startLocker runs a new locker until the test ends.
+func startLocker(t *testing.T, opts ...Option) *Locker {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewLocker(opts...)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return l
+}
+
+// waitHolder waits until key is held by id, or fails the test.
+func waitHolder(t *testing.T, l *Locker, key, id string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		info, err := l.Inspect(context.Background(), "", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.HoldingId == id {
+			return
+		}
+	}
+	t.Fatalf("key %s is not held by %q", key, id)
+}
+
+func TestAbandon(t *testing.T) {
+	tests := []struct {
+		name string
+		// other holds the key before client asks for it
+		other bool
+		// read tells that the caller received the answer
+		read          bool
+		wantAbandoned bool
+		wantHolder    string
+	}{
+		{name: "grant nobody read", wantAbandoned: true, wantHolder: ""},
+		{name: "grant the caller read", read: true, wantAbandoned: false, wantHolder: "client"},
+		{name: "still queued", other: true, wantAbandoned: false, wantHolder: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := startLocker(t)
+			if tt.other {
+				other := &Client{Ctx: context.Background(), Id: "other", LockKey: "key", StatusChan: make(chan Status, 1)}
+				l.Lock(other)
+				if status := <-other.StatusChan; status != Status_Locked {
+					t.Fatalf("other got %s", status)
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			client := &Client{Ctx: ctx, Id: "client", LockKey: "key", StatusChan: make(chan Status, 1)}
+			go l.Lock(client)
+			if tt.other {
+				for {
+					info, err := l.Inspect(context.Background(), "", "key")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if info.Waiters == 1 {
+						break
+					}
+					time.Sleep(time.Millisecond)
+				}
+			} else {
+				waitHolder(t, l, "key", "client")
+			}
+			if tt.read {
+				<-client.StatusChan
+			}
+			// the wait ends as the locker answers
+			cancel()
+
+			abandoned, err := l.Abandon(context.Background(), client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if abandoned != tt.wantAbandoned {
+				t.Fatalf("abandoned %v, want %v", abandoned, tt.wantAbandoned)
+			}
+			info, err := l.Inspect(context.Background(), "", "key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.HoldingId != tt.wantHolder {
+				t.Fatalf("key held by %q, want %q", info.HoldingId, tt.wantHolder)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package locker
+
+import (
+	"context"
+	"log/slog"
+
+	"sharelock/pkg/helpers"
+)
+
+// logTransition logs a change of the state of a key caused by client at
+// debug level.
+func logTransition(client *Client, msg string, attrs ...slog.Attr) {
+	logClient(client, slog.LevelDebug, msg, attrs...)
+}
+
+// logClient logs with the logger of the request of client, which already
+// carries its key, client id and transport. Clients without one get the
+// key and id added. Nothing is formatted below the log level.
+func logClient(client *Client, level slog.Level, msg string, attrs ...slog.Attr) {
+	ctx := client.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	logger, ok := helpers.LoggerFromContext(ctx)
+	if !ok {
+		logger = slog.Default()
+	}
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	if !ok {
+		logger = logger.With("key", client.LockKey, "client_id", client.Id)
+	}
+	logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,171 @@
+package locker
+
+import (
+	"context"
+)
+
+// DefaultNamespace holds the keys of clients that name no namespace.
+const DefaultNamespace = "default"
+
+// Limits caps what one namespace may use, zero means unlimited.
+type Limits struct {
+	// MaxHeldLocks is the number of keys held at the same time
+	MaxHeldLocks int
+	// MaxWaiters is the number of clients queued behind holders
+	MaxWaiters int
+	// MaxLocksPerClient is the number of keys one client holds at once
+	MaxLocksPerClient int
+}
+
+// WithNamespaceLimits sets the limits of every namespace, perNamespace
+// overrides defaults for the namespaces it names.
+func WithNamespaceLimits(defaults Limits, perNamespace map[string]Limits) Option {
+	return func(l *Locker) {
+		l.defaultLimits = defaults
+		l.namespaceLimits = perNamespace
+	}
+}
+
+// WithMaxLocksPerClient caps the keys one client id holds at once,
+// across all namespaces. Zero is unlimited.
+func WithMaxLocksPerClient(max int) Option {
+	return func(l *Locker) {
+		l.maxLocksPerClient = max
+	}
+}
+
+func (l *Locker) limits(name string) Limits {
+	if limits, ok := l.namespaceLimits[name]; ok {
+		return limits
+	}
+	return l.defaultLimits
+}
+
+// namespace is an isolated key space with its own usage counters. It
+// lives as long as it has keys.
+type namespace struct {
+	name   string
+	locker *Locker
+	keys   map[string]*KeyHandler
+	held   int
+	// waiters counts queued clients, cancelled ones included until they
+	// are dropped from their queue
+	waiters     int
+	clientLocks map[string]int
+}
+
+func (l *Locker) namespace(name string) *namespace {
+	ns, exist := l.namespaces[name]
+	if !exist {
+		ns = &namespace{
+			name:        name,
+			locker:      l,
+			keys:        make(map[string]*KeyHandler),
+			clientLocks: make(map[string]int),
+		}
+		l.namespaces[name] = ns
+	}
+	return ns
+}
+
+func (ns *namespace) canHold(clientId string) bool {
+	limits := ns.locker.limits(ns.name)
+	if limits.MaxHeldLocks > 0 && ns.held >= limits.MaxHeldLocks {
+		return false
+	}
+	if limits.MaxLocksPerClient > 0 && ns.clientLocks[clientId] >= limits.MaxLocksPerClient {
+		return false
+	}
+	max := ns.locker.maxLocksPerClient
+	return max <= 0 || ns.locker.clientLocks[clientId] < max
+}
+
+// canWait reports whether one more client may queue. Cancelled clients
+// only leave their queue when the key changes hands, so they are swept
+// before a client is turned away.
+func (ns *namespace) canWait() bool {
+	limits := ns.locker.limits(ns.name)
+	if limits.MaxWaiters <= 0 || ns.waiters < limits.MaxWaiters {
+		return true
+	}
+	for _, keyHandler := range ns.keys {
+		keyHandler.dropCancelled()
+	}
+	return ns.waiters < limits.MaxWaiters
+}
+
+func (ns *namespace) hold(clientId string) {
+	ns.held++
+	ns.locker.held++
+	ns.clientLocks[clientId]++
+	ns.locker.clientLocks[clientId]++
+}
+
+func (ns *namespace) unhold(clientId string) {
+	ns.held--
+	ns.locker.held--
+	decrement(ns.clientLocks, clientId)
+	decrement(ns.locker.clientLocks, clientId)
+}
+
+func decrement(counts map[string]int, key string) {
+	counts[key]--
+	if counts[key] <= 0 {
+		delete(counts, key)
+	}
+}
+
+// NamespaceInfo is a point in time view of the usage of one namespace.
+type NamespaceInfo struct {
+	Namespace string
+	Keys      int
+	HeldLocks int
+	Waiters   int
+	// Clients is the number of clients holding at least one lock
+	Clients int
+	Limits  Limits
+}
+
+func (ns *namespace) info() NamespaceInfo {
+	waiters := 0
+	for _, keyHandler := range ns.keys {
+		waiters += keyHandler.info().Waiters
+	}
+	return NamespaceInfo{
+		Namespace: ns.name,
+		Keys:      len(ns.keys),
+		HeldLocks: ns.held,
+		Waiters:   waiters,
+		Clients:   len(ns.clientLocks),
+		Limits:    ns.locker.limits(ns.name),
+	}
+}
+
+// InspectNamespace returns the usage and limits of a namespace.
+func (l *Locker) InspectNamespace(ctx context.Context, name string) (NamespaceInfo, error) {
+	if name == "" {
+		name = DefaultNamespace
+	}
+	req := &inspection{
+		namespace: name,
+		usage:     make(chan NamespaceInfo, 1),
+	}
+	select {
+	case l.inspectChan <- req:
+	case <-ctx.Done():
+		return NamespaceInfo{}, ctx.Err()
+	}
+	select {
+	case info := <-req.usage:
+		return info, nil
+	case <-ctx.Done():
+		return NamespaceInfo{}, ctx.Err()
+	}
+}
+
+func (c *Client) namespace() string {
+	if c.Namespace == "" {
+		return DefaultNamespace
+	}
+	return c.Namespace
+}
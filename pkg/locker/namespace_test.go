@@ -0,0 +1,163 @@
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// request sends a lock or an unlock and waits for the answer.
+func request(t *testing.T, call func(*Client), ns, id, key string) Status {
+	t.Helper()
+	client := &Client{Ctx: context.Background(), Id: id, Namespace: ns, LockKey: key, StatusChan: make(chan Status, 1)}
+	call(client)
+	select {
+	case status := <-client.StatusChan:
+		return status
+	case <-time.After(time.Second * 5):
+		t.Fatalf("no answer for %s on %s/%s", id, ns, key)
+		return 0
+	}
+}
+
+// queue sends a lock for a held key and waits until it is queued. The
+// returned func cancels the wait.
+func queue(t *testing.T, l *Locker, ns, id, key string) (*Client, context.CancelFunc) {
+	t.Helper()
+	before, err := l.Inspect(context.Background(), ns, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	client := &Client{Ctx: ctx, Id: id, Namespace: ns, LockKey: key, StatusChan: make(chan Status, 1)}
+	l.Lock(client)
+	for deadline := time.Now().Add(time.Second * 5); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		info, err := l.Inspect(context.Background(), ns, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Waiters > before.Waiters {
+			return client, cancel
+		}
+	}
+	t.Fatalf("%s was not queued on %s/%s", id, ns, key)
+	return nil, nil
+}
+
+func wantStatus(t *testing.T, got, want Status) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestMaxHeldLocks(t *testing.T) {
+	l := startLocker(t, WithNamespaceLimits(Limits{}, map[string]Limits{"jobs": {MaxHeldLocks: 2}}))
+	wantStatus(t, request(t, l.Lock, "jobs", "c1", "a"), Status_Locked)
+	wantStatus(t, request(t, l.Lock, "jobs", "c2", "b"), Status_Locked)
+	wantStatus(t, request(t, l.Lock, "jobs", "c3", "c"), Status_QuotaExceeded)
+	// other namespaces keep the defaults
+	wantStatus(t, request(t, l.Lock, "", "c3", "c"), Status_Locked)
+
+	wantStatus(t, request(t, l.Unlock, "jobs", "c1", "a"), Status_Unlocked)
+	wantStatus(t, request(t, l.Lock, "jobs", "c3", "c"), Status_Locked)
+
+	info, err := l.InspectNamespace(context.Background(), "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.HeldLocks != 2 || info.Clients != 2 || info.Limits.MaxHeldLocks != 2 {
+		t.Fatalf("got %+v", info)
+	}
+}
+
+func TestMaxLocksPerClient(t *testing.T) {
+	t.Run("in a namespace", func(t *testing.T) {
+		l := startLocker(t, WithNamespaceLimits(Limits{MaxLocksPerClient: 1}, nil))
+		wantStatus(t, request(t, l.Lock, "jobs", "c1", "a"), Status_Locked)
+		wantStatus(t, request(t, l.Lock, "jobs", "c1", "b"), Status_QuotaExceeded)
+		wantStatus(t, request(t, l.Lock, "jobs", "c2", "b"), Status_Locked)
+		// the limit counts per namespace
+		wantStatus(t, request(t, l.Lock, "builds", "c1", "b"), Status_Locked)
+	})
+	t.Run("across namespaces", func(t *testing.T) {
+		l := startLocker(t, WithMaxLocksPerClient(2))
+		wantStatus(t, request(t, l.Lock, "ns1", "c1", "a"), Status_Locked)
+		wantStatus(t, request(t, l.Lock, "ns2", "c1", "a"), Status_Locked)
+		wantStatus(t, request(t, l.Lock, "ns3", "c1", "a"), Status_QuotaExceeded)
+		wantStatus(t, request(t, l.Unlock, "ns1", "c1", "a"), Status_Unlocked)
+		wantStatus(t, request(t, l.Lock, "ns3", "c1", "a"), Status_Locked)
+	})
+	t.Run("when a waiter gets the key", func(t *testing.T) {
+		l := startLocker(t, WithNamespaceLimits(Limits{MaxLocksPerClient: 1}, nil))
+		wantStatus(t, request(t, l.Lock, "", "c1", "a"), Status_Locked)
+		wantStatus(t, request(t, l.Lock, "", "c2", "b"), Status_Locked)
+		waiter, _ := queue(t, l, "", "c2", "a")
+		wantStatus(t, request(t, l.Unlock, "", "c1", "a"), Status_Unlocked)
+		wantStatus(t, <-waiter.StatusChan, Status_QuotaExceeded)
+		info, err := l.Inspect(context.Background(), "", "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.HoldingId != "" || info.Waiters != 0 {
+			t.Fatalf("got %+v, want a free key", info)
+		}
+	})
+}
+
+func TestMaxWaiters(t *testing.T) {
+	l := startLocker(t, WithNamespaceLimits(Limits{}, map[string]Limits{"jobs": {MaxWaiters: 1}}))
+	wantStatus(t, request(t, l.Lock, "jobs", "c1", "a"), Status_Locked)
+	wantStatus(t, request(t, l.Lock, "jobs", "c1", "b"), Status_Locked)
+	_, cancel := queue(t, l, "jobs", "c2", "a")
+	// the limit covers every key of the namespace
+	wantStatus(t, request(t, l.Lock, "jobs", "c3", "b"), Status_QuotaExceeded)
+	// a free key needs no wait
+	wantStatus(t, request(t, l.Lock, "jobs", "c3", "c"), Status_Locked)
+
+	// a cancelled waiter is swept before a client is turned away
+	cancel()
+	waiter, _ := queue(t, l, "jobs", "c3", "b")
+	wantStatus(t, request(t, l.Unlock, "jobs", "c1", "b"), Status_Unlocked)
+	wantStatus(t, <-waiter.StatusChan, Status_Locked)
+}
+
+func TestReconfigureLimits(t *testing.T) {
+	l := startLocker(t, WithNamespaceLimits(Limits{MaxHeldLocks: 1}, nil))
+	wantStatus(t, request(t, l.Lock, "", "c1", "a"), Status_Locked)
+	wantStatus(t, request(t, l.Lock, "", "c2", "b"), Status_QuotaExceeded)
+
+	err := l.Reconfigure(context.Background(), WithNamespaceLimits(Limits{MaxHeldLocks: 2}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, request(t, l.Lock, "", "c2", "b"), Status_Locked)
+
+	// lowering a limit keeps the locks already held
+	err = l.Reconfigure(context.Background(), WithNamespaceLimits(Limits{MaxHeldLocks: 1}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantStatus(t, request(t, l.Lock, "", "c3", "c"), Status_QuotaExceeded)
+	for _, key := range []string{"a", "b"} {
+		info, err := l.Inspect(context.Background(), "", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.HoldingId == "" {
+			t.Fatalf("%s was released by a lower limit", key)
+		}
+	}
+	info, err := l.InspectNamespace(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Limits.MaxHeldLocks != 1 || info.HeldLocks != 2 {
+		t.Fatalf("got %+v", info)
+	}
+	wantStatus(t, request(t, l.Unlock, "", "c1", "a"), Status_Unlocked)
+	wantStatus(t, request(t, l.Lock, "", "c3", "c"), Status_QuotaExceeded)
+	wantStatus(t, request(t, l.Unlock, "", "c2", "b"), Status_Unlocked)
+	wantStatus(t, request(t, l.Lock, "", "c3", "c"), Status_Locked)
+}
@@ -0,0 +1,74 @@
+package locker
+
+import (
+	"context"
+	"time"
+)
+
+// Observer is told about grants and releases from inside the loop of
+// Start, typically to export metrics. Its methods must return quickly
+// and must not call the locker.
+type Observer interface {
+	// Granted is called when a client gets key after waiting in its
+	// queue for waited.
+	Granted(namespace, key string, waited time.Duration)
+	// Released is called when the holder of key loses it after holding
+	// it for held, through an unlock or, when expired is set, the end
+	// of its lease.
+	Released(namespace, key string, held time.Duration, expired bool)
+}
+
+// WithObserver reports the grants and releases of the locker to o.
+func WithObserver(o Observer) Option {
+	return func(l *Locker) {
+		if o != nil {
+			l.observer = o
+		}
+	}
+}
+
+type nopObserver struct{}
+
+func (nopObserver) Granted(namespace, key string, waited time.Duration) {}
+
+func (nopObserver) Released(namespace, key string, held time.Duration, expired bool) {}
+
+// Stats is a point in time view of the whole locker.
+type Stats struct {
+	Namespaces int
+	// Keys is the number of keys held or waited for, each has its
+	// KeyHandler
+	Keys      int
+	HeldLocks int
+	// Waiters counts queued clients, cancelled ones included until they
+	// are dropped from their queue
+	Waiters int
+}
+
+// Stats returns the usage of all namespaces together.
+func (l *Locker) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{}
+	err := l.control(ctx, func() {
+		stats.Namespaces = len(l.namespaces)
+		stats.HeldLocks = l.held
+		for _, ns := range l.namespaces {
+			stats.Keys += len(ns.keys)
+			stats.Waiters += ns.waiters
+		}
+	})
+	return stats, err
+}
+
+// QueueDepths is the number of requests buffered in front of the loop
+// of Start, by channel. It reads the channels directly, so it answers
+// even when the loop is stuck.
+func (l *Locker) QueueDepths() map[string]int {
+	return map[string]int{
+		"lock":    len(l.lockChan),
+		"unlock":  len(l.unlockChan),
+		"renew":   len(l.renewChan),
+		"inspect": len(l.inspectChan),
+		"expire":  len(l.expireChan),
+		"control": len(l.controlChan),
+	}
+}
@@ -0,0 +1,379 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var ErrSimulationWedged = errors.New("locker kept handling events without settling")
+
+// simMaxEvents bounds the events a single settle handles. A locker
+// that keeps queuing events for itself never goes idle, the real loop
+// would spin the same way.
+const simMaxEvents = 100_000
+
+type SimOp int
+
+const (
+	// SimOp_Lock queues a lock request for Client on Key.
+	SimOp_Lock SimOp = iota
+	// SimOp_Unlock sends an unlock request for Client on Key.
+	SimOp_Unlock
+	// SimOp_Renew renews the lease Client holds on Key with Lease.
+	SimOp_Renew
+	// SimOp_Cancel ends the wait of the lock request of Client on Key,
+	// as a transport does when the caller disconnects. A lock already
+	// granted to it is abandoned as if the grant arrived as the wait
+	// ended, so the key is released.
+	SimOp_Cancel
+	// SimOp_Advance moves the fake clock forward by Advance.
+	SimOp_Advance
+	// SimOp_ExpectHolder asserts that Client holds Key, or that nobody
+	// holds it when Client is empty.
+	SimOp_ExpectHolder
+	// SimOp_Drain starts draining the locker, as on shutdown.
+	SimOp_Drain
+)
+
+// SimStep is one scripted action of a simulation.
+type SimStep struct {
+	Op     SimOp
+	Client string
+	Key    string
+	// Wait is how long a lock request stays queued before the caller
+	// gives up. Zero uses the 10 second default of the servers.
+	Wait time.Duration
+	// Lease of a lock or renew request. Zero uses the locker default.
+	Lease   time.Duration
+	Advance time.Duration
+	// Expect, when not nil, lists the outcomes that must be observed
+	// while the step settles, in order. An empty non-nil slice asserts
+	// that nothing happened.
+	Expect []SimEvent
+}
+
+// SimEvent is an outcome observed by a simulated caller.
+type SimEvent struct {
+	At     time.Duration
+	Client string
+	Key    string
+	Status Status
+}
+
+func (e SimEvent) String() string {
+	return fmt.Sprintf("%s %s/%s=%s", e.At, e.Client, e.Key, e.Status)
+}
+
+// Simulation drives a Locker with a FakeClock. The locker loop is
+// stepped by hand instead of running in Start, so a script always
+// produces the same outcome. A non zero seed picks the order in which
+// ready requests and lease expiries are handled, which lets a test
+// explore other interleavings reproducibly.
+type Simulation struct {
+	Clock    *FakeClock
+	locker   *Locker
+	start    time.Time
+	rnd      *rand.Rand
+	requests []*simRequest
+	// granted are the lock requests answered with Status_Locked, a
+	// cancel can still abandon them
+	granted []*simRequest
+	// abandons are the lock requests whose caller gave up, each waits
+	// for the loop like the Abandon of a transport
+	abandons []*Client
+	events   []SimEvent
+	pending  []SimEvent
+	err      error
+}
+
+type simRequest struct {
+	op       SimOp
+	client   *Client
+	cancel   context.CancelFunc
+	deadline time.Time
+}
+
+func NewSimulation(seed int64) *Simulation {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Simulation{
+		Clock: NewFakeClock(start),
+		start: start,
+	}
+	if seed != 0 {
+		s.rnd = rand.New(rand.NewSource(seed))
+	}
+	s.locker = NewLocker(WithClock(s.Clock))
+	return s
+}
+
+// Run executes the script and returns the first failed expectation.
+func (s *Simulation) Run(steps []SimStep) error {
+	for i, step := range steps {
+		err := s.Step(step)
+		if err != nil {
+			return fmt.Errorf("step %d : %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Step executes a single scripted action and checks its expectations.
+func (s *Simulation) Step(step SimStep) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.pending = s.pending[:0]
+
+	switch step.Op {
+	case SimOp_Lock:
+		wait := step.Wait
+		if wait <= 0 {
+			wait = time.Second * 10
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		req := &simRequest{
+			op: SimOp_Lock,
+			client: &Client{
+				Ctx:        ctx,
+				Id:         step.Client,
+				LockKey:    step.Key,
+				StatusChan: make(chan Status, 1),
+				Lease:      step.Lease,
+			},
+			cancel:   cancel,
+			deadline: s.Clock.Now().Add(wait),
+		}
+		s.requests = append(s.requests, req)
+		s.locker.Lock(req.client)
+		s.settle()
+	case SimOp_Unlock:
+		req := &simRequest{
+			op: SimOp_Unlock,
+			client: &Client{
+				Ctx:        context.Background(),
+				Id:         step.Client,
+				LockKey:    step.Key,
+				StatusChan: make(chan Status, 1),
+			},
+			cancel: func() {},
+		}
+		s.requests = append(s.requests, req)
+		s.locker.Unlock(req.client)
+		s.settle()
+	case SimOp_Renew:
+		req := &simRequest{
+			op: SimOp_Renew,
+			client: &Client{
+				Ctx:        context.Background(),
+				Id:         step.Client,
+				LockKey:    step.Key,
+				StatusChan: make(chan Status, 1),
+				Lease:      step.Lease,
+			},
+			cancel: func() {},
+		}
+		s.requests = append(s.requests, req)
+		s.locker.Renew(req.client)
+		s.settle()
+	case SimOp_Cancel:
+		s.cancel(step.Client, step.Key)
+		s.settle()
+	case SimOp_Advance:
+		s.advance(step.Advance)
+	case SimOp_Drain:
+		s.locker.controlChan <- s.locker.drain
+		s.settle()
+	case SimOp_ExpectHolder:
+		holder := s.Holder(step.Key)
+		if holder != step.Client {
+			return fmt.Errorf("holder of %q is %q, expected %q", step.Key, holder, step.Client)
+		}
+	default:
+		return fmt.Errorf("unknown simulation op %d", step.Op)
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	if step.Expect == nil {
+		return nil
+	}
+	if len(step.Expect) != len(s.pending) {
+		return fmt.Errorf("observed %v, expected %v", s.pending, step.Expect)
+	}
+	for i := range step.Expect {
+		got, want := s.pending[i], step.Expect[i]
+		if got.Client != want.Client || got.Key != want.Key || got.Status != want.Status {
+			return fmt.Errorf("observed %v, expected %v", s.pending, step.Expect)
+		}
+	}
+	return nil
+}
+
+// Holder returns the client currently holding key, or an empty string.
+func (s *Simulation) Holder(key string) string {
+	keyHandler, exist := s.locker.keyHandler(DefaultNamespace, key)
+	if !exist {
+		return ""
+	}
+	return keyHandler.holdingId
+}
+
+// Events returns every outcome observed so far.
+func (s *Simulation) Events() []SimEvent {
+	return s.events
+}
+
+// advance moves the clock in increments that stop at every timer and
+// request deadline, settling the locker after each one. The lease
+// timers fire during the increment and only queue their expiry, which
+// the settle after it handles.
+func (s *Simulation) advance(d time.Duration) {
+	target := s.Clock.Now().Add(d)
+	for {
+		next, ok := s.Clock.NextDeadline()
+		for _, req := range s.requests {
+			if req.op == SimOp_Lock && (!ok || req.deadline.Before(next)) {
+				next, ok = req.deadline, true
+			}
+		}
+		if !ok || next.After(target) {
+			break
+		}
+		s.Clock.Advance(next.Sub(s.Clock.Now()))
+		s.settle()
+		s.expireRequests()
+	}
+	s.Clock.Advance(target.Sub(s.Clock.Now()))
+	s.settle()
+	s.expireRequests()
+}
+
+func (s *Simulation) expireRequests() {
+	now := s.Clock.Now()
+	for i := 0; i < len(s.requests); i++ {
+		req := s.requests[i]
+		if req.op == SimOp_Lock && !req.deadline.After(now) {
+			s.finish(i, Status_Timeout)
+			i--
+		}
+	}
+	s.settle()
+}
+
+// settle handles ready events until the locker is idle, then collects
+// the statuses delivered to callers. Events run on the calling
+// goroutine, like in the loop of Start: the locker handlers never block,
+// they notify callers without waiting for them.
+func (s *Simulation) settle() {
+	for handled := 0; s.err == nil; handled++ {
+		ready := s.readyEvents()
+		if len(ready) == 0 {
+			break
+		}
+		if handled == simMaxEvents {
+			s.err = ErrSimulationWedged
+			return
+		}
+		pick := 0
+		if s.rnd != nil {
+			pick = s.rnd.Intn(len(ready))
+		}
+		ready[pick]()
+		s.collect()
+	}
+	s.collect()
+}
+
+func (s *Simulation) readyEvents() []func() {
+	l := s.locker
+	ready := make([]func(), 0)
+	if len(l.lockChan) > 0 {
+		ready = append(ready, func() { l.lock(<-l.lockChan) })
+	}
+	if len(l.unlockChan) > 0 {
+		ready = append(ready, func() { l.unlock(<-l.unlockChan) })
+	}
+	if len(l.renewChan) > 0 {
+		ready = append(ready, func() { l.renew(<-l.renewChan) })
+	}
+	if len(l.inspectChan) > 0 {
+		ready = append(ready, func() { l.inspect(<-l.inspectChan) })
+	}
+	if len(l.expireChan) > 0 {
+		ready = append(ready, func() { l.expire(<-l.expireChan) })
+	}
+	if len(l.controlChan) > 0 {
+		ready = append(ready, func() { (<-l.controlChan)() })
+	}
+	if len(s.abandons) > 0 {
+		ready = append(ready, func() {
+			client := s.abandons[0]
+			s.abandons = s.abandons[1:]
+			l.abandon(client)
+		})
+	}
+	return ready
+}
+
+// cancel ends the wait of the lock request of id on key. A request the
+// locker already granted gets its answer back, as if the caller had not
+// read it yet, and is abandoned like a waiting one.
+func (s *Simulation) cancel(id, key string) {
+	for i, req := range s.requests {
+		if req.op == SimOp_Lock && req.client.Id == id && req.client.LockKey == key {
+			s.finish(i, Status_Timeout)
+			return
+		}
+	}
+	for i := len(s.granted) - 1; i >= 0; i-- {
+		req := s.granted[i]
+		if req.client.Id == id && req.client.LockKey == key {
+			s.granted = append(s.granted[:i], s.granted[i+1:]...)
+			req.client.StatusChan <- Status_Locked
+			s.requests = append(s.requests, req)
+			s.finish(len(s.requests)-1, Status_Timeout)
+			return
+		}
+	}
+}
+
+func (s *Simulation) collect() {
+	for i := 0; i < len(s.requests); i++ {
+		select {
+		case status := <-s.requests[i].client.StatusChan:
+			if s.requests[i].op == SimOp_Lock && status == Status_Locked {
+				s.granted = append(s.granted, s.requests[i])
+			}
+			s.finish(i, status)
+			i--
+		default:
+		}
+	}
+}
+
+// finish records the outcome of the i-th outstanding request and
+// forgets it. A timeout mirrors a transport giving up: the context is
+// cancelled and the locker abandons a grant that raced with it, the
+// settle after it handles the abandon.
+func (s *Simulation) finish(i int, status Status) {
+	req := s.requests[i]
+	if status == Status_Timeout {
+		req.cancel()
+		if req.op == SimOp_Lock {
+			s.abandons = append(s.abandons, req.client)
+		}
+	}
+	s.requests = append(s.requests[:i], s.requests[i+1:]...)
+	event := SimEvent{
+		At:     s.Clock.Now().Sub(s.start),
+		Client: req.client.Id,
+		Key:    req.client.LockKey,
+		Status: status,
+	}
+	s.events = append(s.events, event)
+	s.pending = append(s.pending, event)
+}
@@ -0,0 +1,197 @@
+package locker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSimulation(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []SimStep
+	}{
+		{
+			name: "unlock hands the key to the waiter",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k", Expect: []SimEvent{{Client: "a", Key: "k", Status: Status_Locked}}},
+				{Op: SimOp_Lock, Client: "b", Key: "k", Expect: []SimEvent{}},
+				{Op: SimOp_Unlock, Client: "a", Key: "k", Expect: []SimEvent{
+					{Client: "b", Key: "k", Status: Status_Locked},
+					{Client: "a", Key: "k", Status: Status_Unlocked},
+				}},
+				{Op: SimOp_ExpectHolder, Client: "b", Key: "k"},
+			},
+		},
+		{
+			name: "expiry hands the key to the waiter",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k", Lease: time.Second},
+				{Op: SimOp_Lock, Client: "b", Key: "k", Wait: time.Second * 5},
+				{Op: SimOp_Advance, Advance: time.Second - time.Millisecond, Expect: []SimEvent{}},
+				{Op: SimOp_Advance, Advance: time.Millisecond, Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Locked}}},
+				{Op: SimOp_ExpectHolder, Client: "b", Key: "k"},
+			},
+		},
+		{
+			name: "renew before expiry keeps the lock",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k", Lease: time.Second},
+				{Op: SimOp_Lock, Client: "b", Key: "k", Wait: time.Second * 5},
+				{Op: SimOp_Advance, Advance: time.Millisecond * 900, Expect: []SimEvent{}},
+				{Op: SimOp_Renew, Client: "a", Key: "k", Lease: time.Second, Expect: []SimEvent{{Client: "a", Key: "k", Status: Status_Renewed}}},
+				// the timer of the first lease fires and finds it renewed
+				{Op: SimOp_Advance, Advance: time.Millisecond * 900, Expect: []SimEvent{}},
+				{Op: SimOp_ExpectHolder, Client: "a", Key: "k"},
+				{Op: SimOp_Advance, Advance: time.Millisecond * 100, Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Locked}}},
+			},
+		},
+		{
+			name: "renew after expiry",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k", Lease: time.Second},
+				{Op: SimOp_Advance, Advance: time.Second, Expect: []SimEvent{}},
+				{Op: SimOp_Renew, Client: "a", Key: "k", Expect: []SimEvent{{Client: "a", Key: "k", Status: Status_UnknownLock}}},
+				{Op: SimOp_ExpectHolder, Client: "", Key: "k"},
+			},
+		},
+		{
+			name: "waiter times out",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k"},
+				{Op: SimOp_Lock, Client: "b", Key: "k", Wait: time.Second * 2},
+				{Op: SimOp_Advance, Advance: time.Second * 2, Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Timeout}}},
+				{Op: SimOp_Unlock, Client: "a", Key: "k", Expect: []SimEvent{{Client: "a", Key: "k", Status: Status_Unlocked}}},
+				{Op: SimOp_ExpectHolder, Client: "", Key: "k"},
+			},
+		},
+		{
+			name: "cancelled waiter is skipped",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k"},
+				{Op: SimOp_Lock, Client: "b", Key: "k"},
+				{Op: SimOp_Lock, Client: "c", Key: "k"},
+				{Op: SimOp_Cancel, Client: "b", Key: "k", Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Timeout}}},
+				{Op: SimOp_Unlock, Client: "a", Key: "k", Expect: []SimEvent{
+					{Client: "c", Key: "k", Status: Status_Locked},
+					{Client: "a", Key: "k", Status: Status_Unlocked},
+				}},
+				{Op: SimOp_ExpectHolder, Client: "c", Key: "k"},
+				// the wait of b already ended
+				{Op: SimOp_Cancel, Client: "b", Key: "k", Expect: []SimEvent{}},
+				{Op: SimOp_ExpectHolder, Client: "c", Key: "k"},
+			},
+		},
+		{
+			name: "cancel after grant releases",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k"},
+				{Op: SimOp_Lock, Client: "b", Key: "k"},
+				{Op: SimOp_Lock, Client: "c", Key: "k"},
+				{Op: SimOp_Unlock, Client: "a", Key: "k", Expect: []SimEvent{
+					{Client: "b", Key: "k", Status: Status_Locked},
+					{Client: "a", Key: "k", Status: Status_Unlocked},
+				}},
+				{Op: SimOp_Cancel, Client: "b", Key: "k", Expect: []SimEvent{
+					{Client: "b", Key: "k", Status: Status_Timeout},
+					{Client: "c", Key: "k", Status: Status_Locked},
+				}},
+				{Op: SimOp_ExpectHolder, Client: "c", Key: "k"},
+				{Op: SimOp_Cancel, Client: "c", Key: "k", Expect: []SimEvent{{Client: "c", Key: "k", Status: Status_Timeout}}},
+				{Op: SimOp_ExpectHolder, Client: "", Key: "k"},
+				{Op: SimOp_Lock, Client: "b", Key: "k", Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Locked}}},
+			},
+		},
+		{
+			name: "drain answers the waiters and keeps the holder",
+			steps: []SimStep{
+				{Op: SimOp_Lock, Client: "a", Key: "k"},
+				{Op: SimOp_Lock, Client: "b", Key: "k"},
+				{Op: SimOp_Drain, Expect: []SimEvent{{Client: "b", Key: "k", Status: Status_Shutdown}}},
+				{Op: SimOp_Lock, Client: "c", Key: "k", Expect: []SimEvent{{Client: "c", Key: "k", Status: Status_Shutdown}}},
+				{Op: SimOp_ExpectHolder, Client: "a", Key: "k"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSimulation(0).Run(tt.steps)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestSimulationRenewRacesExpiry queues a renew as the lease timer
+// fires, the seed picks which of the two the locker handles first.
+func TestSimulationRenewRacesExpiry(t *testing.T) {
+	outcomes := make(map[Status]int)
+	for seed := int64(0); seed < 20; seed++ {
+		s := NewSimulation(seed)
+		err := s.Run([]SimStep{
+			{Op: SimOp_Lock, Client: "a", Key: "k", Lease: time.Second},
+			{Op: SimOp_Lock, Client: "b", Key: "k", Wait: time.Second * 5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		renew := &Client{Ctx: context.Background(), Id: "a", LockKey: "k", StatusChan: make(chan Status, 1), Lease: time.Second}
+		s.requests = append(s.requests, &simRequest{op: SimOp_Renew, client: renew, cancel: func() {}})
+		s.locker.Renew(renew)
+		s.pending = s.pending[:0]
+		s.advance(time.Second)
+		if s.err != nil {
+			t.Fatal(s.err)
+		}
+
+		var renewed Status
+		granted := false
+		for _, event := range s.pending {
+			switch event.Client {
+			case "a":
+				renewed = event.Status
+			case "b":
+				granted = event.Status == Status_Locked
+			}
+		}
+		switch {
+		case renewed == Status_Renewed && !granted && s.Holder("k") == "a":
+		case renewed == Status_UnknownLock && granted && s.Holder("k") == "b":
+		default:
+			t.Fatalf("seed %d: renew %s, waiter granted %v, holder %q", seed, renewed, granted, s.Holder("k"))
+		}
+		outcomes[renewed]++
+	}
+	if outcomes[Status_Renewed] == 0 || outcomes[Status_UnknownLock] == 0 {
+		t.Fatalf("the seeds explored only one order: %v", outcomes)
+	}
+}
+
+func TestSimulationIsReproducible(t *testing.T) {
+	steps := []SimStep{
+		{Op: SimOp_Lock, Client: "a", Key: "x", Lease: time.Second},
+		{Op: SimOp_Lock, Client: "a", Key: "y", Lease: time.Second},
+		{Op: SimOp_Lock, Client: "b", Key: "x", Wait: time.Second * 3},
+		{Op: SimOp_Lock, Client: "b", Key: "y", Wait: time.Second * 3},
+		// both leases expire together, the seed orders the grants
+		{Op: SimOp_Advance, Advance: time.Second * 2},
+	}
+	for seed := int64(1); seed <= 5; seed++ {
+		first, second := NewSimulation(seed), NewSimulation(seed)
+		if err := first.Run(steps); err != nil {
+			t.Fatal(err)
+		}
+		if err := second.Run(steps); err != nil {
+			t.Fatal(err)
+		}
+		if len(first.Events()) != len(second.Events()) {
+			t.Fatalf("seed %d: %v then %v", seed, first.Events(), second.Events())
+		}
+		for i := range first.Events() {
+			if first.Events()[i] != second.Events()[i] {
+				t.Fatalf("seed %d: %v then %v", seed, first.Events(), second.Events())
+			}
+		}
+	}
+}
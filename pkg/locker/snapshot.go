@@ -0,0 +1,141 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// ErrNotEmpty refuses to import a state into a locker that holds or
+// queues a key already.
+var ErrNotEmpty = errors.New("the locker holds or queues keys already")
+
+// Export returns the held locks, the requests queued behind them in
+// queue order and the last lease id, as a Store would restore them.
+// With freeze the locker stops changing: every later lock, unlock and
+// renewal and every queued request is answered with Status_Shutdown,
+// until Thaw. Leases still run out.
+func (l *Locker) Export(ctx context.Context, freeze bool) (State, error) {
+	state := State{}
+	err := l.control(ctx, func() {
+		state = l.state()
+		if freeze && !l.frozen {
+			l.frozen = true
+			l.refuseQueued()
+			slog.Warn("locker frozen", "held_locks", l.held)
+		}
+	})
+	return state, err
+}
+
+// state is the locker as a Store keeps it. Requests without a deadline
+// are left out, like saveQueued does.
+func (l *Locker) state() State {
+	now := l.clock.Now()
+	state := State{LeaseSeq: l.leaseSeq, Locks: make([]HeldLock, 0, l.held)}
+	for _, ns := range l.namespaces {
+		for _, keyHandler := range ns.keys {
+			if keyHandler.holdingId != "" {
+				state.Locks = append(state.Locks, keyHandler.heldLock(keyHandler.leaseId, keyHandler.leaseExpiresAt))
+			}
+			for _, client := range keyHandler.queue {
+				if client == nil || !client.waiting(now) {
+					continue
+				}
+				waitUntil := client.waitUntil
+				if !client.restored {
+					deadline, ok := client.Ctx.Deadline()
+					if !ok {
+						continue
+					}
+					waitUntil = deadline
+				}
+				state.Queued = append(state.Queued, QueuedLock{
+					Namespace: ns.name,
+					Key:       keyHandler.key,
+					ClientId:  client.Id,
+					Lease:     client.Lease,
+					QueuedAt:  client.queuedAt,
+					WaitUntil: waitUntil,
+				})
+			}
+		}
+	}
+	return state
+}
+
+// refuseQueued tells the clients waiting in a queue to retry elsewhere.
+// Their requests stay queued, an exported state keeps their place.
+func (l *Locker) refuseQueued() {
+	for _, ns := range l.namespaces {
+		for _, keyHandler := range ns.keys {
+			for _, client := range keyHandler.queue {
+				if client != nil && !client.restored {
+					client.notify(Status_Shutdown)
+				}
+			}
+		}
+	}
+}
+
+// Thaw lets a locker frozen by Export change again.
+func (l *Locker) Thaw(ctx context.Context) error {
+	return l.control(ctx, func() {
+		if l.frozen {
+			l.frozen = false
+			slog.Warn("locker thawed")
+		}
+	})
+}
+
+// Import loads an exported state into a locker that holds and queues
+// nothing, and saves it in the store. The holders keep their lease
+// deadlines and lease ids, and the queued requests their place, until
+// their clients retry against this locker, see KeyHandler.reattach.
+// Leases that ran out since the export expire right away.
+func (l *Locker) Import(ctx context.Context, state State) error {
+	var err error
+	controlErr := l.control(ctx, func() {
+		if len(l.namespaces) > 0 {
+			err = ErrNotEmpty
+			return
+		}
+		err = l.save(state)
+		if err != nil {
+			return
+		}
+		queued := l.load(state)
+		slog.Warn("locker state imported", "locks", len(state.Locks), "queued", queued, "lease_seq", l.leaseSeq)
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return err
+}
+
+// save writes state to the store, entirely or not at all.
+func (l *Locker) save(state State) error {
+	queueStore, keepsQueue := l.store.(QueueStore)
+	for i, lock := range state.Locks {
+		err := l.store.Hold(lock)
+		if err != nil {
+			for _, saved := range state.Locks[:i] {
+				l.store.Release(saved.Namespace, saved.Key)
+			}
+			return fmt.Errorf("saving imported lock : %w", err)
+		}
+	}
+	if !keepsQueue {
+		return nil
+	}
+	for _, queued := range state.Queued {
+		err := queueStore.Queue(queued)
+		if err != nil {
+			// a queued request the store misses is only dropped on a
+			// restart
+			slog.Error("saving imported queued lock", "namespace", queued.Namespace, "key", queued.Key, "err", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// failingStore fails the Hold calls from the failAt-th one on and
+// records what it keeps.
+type failingStore struct {
+	nopStore
+	failAt int
+	holds  int
+	held   map[string]bool
+}
+
+func (s *failingStore) Hold(lock HeldLock) error {
+	s.holds++
+	if s.failAt > 0 && s.holds >= s.failAt {
+		return errors.New("disk full")
+	}
+	s.held[lock.Namespace+"/"+lock.Key] = true
+	return nil
+}
+
+func (s *failingStore) Release(namespace, key string) error {
+	delete(s.held, namespace+"/"+key)
+	return nil
+}
+
+func sortState(state State) {
+	sort.Slice(state.Locks, func(i, j int) bool { return state.Locks[i].Key < state.Locks[j].Key })
+}
+
+func TestImport(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	state := State{
+		LeaseSeq: 7,
+		Locks: []HeldLock{
+			{Namespace: DefaultNamespace, Key: "a", Holder: "one", LeaseId: 5, HeldSince: now, LeaseExpiresAt: now.Add(time.Minute)},
+			{Namespace: DefaultNamespace, Key: "b", Holder: "two", LeaseId: 6, HeldSince: now, LeaseExpiresAt: now.Add(time.Minute)},
+		},
+		Queued: []QueuedLock{
+			{Namespace: DefaultNamespace, Key: "a", ClientId: "three", Lease: time.Second, QueuedAt: now, WaitUntil: now.Add(time.Minute)},
+		},
+	}
+	tests := []struct {
+		name string
+		// failAt fails the failAt-th Hold of the store, zero never
+		failAt int
+		// holding is a key the locker holds before the import
+		holding string
+		wantErr bool
+	}{
+		{name: "round trip"},
+		{name: "store fails", failAt: 2, wantErr: true},
+		{name: "not empty", holding: "c", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &failingStore{failAt: tt.failAt, held: make(map[string]bool)}
+			l := startLocker(t, WithStore(store))
+			ctx := context.Background()
+			if tt.holding != "" {
+				client := &Client{Ctx: ctx, Id: "other", LockKey: tt.holding, StatusChan: make(chan Status, 1)}
+				l.Lock(client)
+				<-client.StatusChan
+			}
+			err := l.Import(ctx, state)
+			if tt.holding != "" && !errors.Is(err, ErrNotEmpty) {
+				t.Fatalf("got %v, want %v", err, ErrNotEmpty)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want an error %v", err, tt.wantErr)
+			}
+			exported, err := l.Export(ctx, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr {
+				if tt.failAt > 0 && (len(exported.Locks) != 0 || len(store.held) != 0) {
+					t.Fatalf("a failed import left %v in the locker and %v in the store", exported.Locks, store.held)
+				}
+				return
+			}
+			sortState(exported)
+			if !reflect.DeepEqual(exported.Locks, state.Locks) || exported.LeaseSeq != state.LeaseSeq {
+				t.Fatalf("exported %+v, imported %+v", exported, state)
+			}
+			if len(exported.Queued) != 1 || exported.Queued[0] != state.Queued[0] {
+				t.Fatalf("exported queue %+v, imported %+v", exported.Queued, state.Queued)
+			}
+			if len(store.held) != 2 {
+				t.Fatalf("store keeps %v", store.held)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package locker
+
+import (
+	"context"
+	"log/slog"
+)
+
+// WithStandby starts the locker on standby: every lock, unlock and
+// renewal is answered with Status_NotLeader until Activate. The members
+// of a cluster that do not lead it stay on standby.
+func WithStandby() Option {
+	return func(l *Locker) {
+		l.standby = true
+	}
+}
+
+// Activate replaces the state of the locker with the one of its store
+// and starts granting locks. A member calls it once it leads its
+// cluster and its store caught up with the last leader.
+func (l *Locker) Activate(ctx context.Context) error {
+	var err error
+	controlErr := l.control(ctx, func() {
+		l.reset()
+		err = l.restore()
+		l.standby = err != nil
+	})
+	if controlErr != nil {
+		return controlErr
+	}
+	return err
+}
+
+// Deactivate puts the locker back on standby and forgets its state,
+// which the store keeps for the next leader. Queued clients are told
+// Status_NotLeader, so they retry on the new leader.
+func (l *Locker) Deactivate(ctx context.Context) error {
+	return l.control(ctx, func() {
+		l.standby = true
+		l.reset()
+	})
+}
+
+// reset forgets every key without touching the store.
+func (l *Locker) reset() {
+	dropped := 0
+	for _, ns := range l.namespaces {
+		for _, keyHandler := range ns.keys {
+			if keyHandler.leaseTimer != nil {
+				keyHandler.leaseTimer.Stop()
+			}
+			for _, client := range keyHandler.queue {
+				if client != nil && !client.restored {
+					client.notify(Status_NotLeader)
+					dropped++
+				}
+			}
+		}
+	}
+	if len(l.namespaces) > 0 {
+		slog.Info("locker state dropped", "held_locks", l.held, "waiters", dropped)
+	}
+	l.namespaces = make(map[string]*namespace)
+	l.clientLocks = make(map[string]int)
+	l.held = 0
+}
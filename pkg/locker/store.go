@@ -0,0 +1,233 @@
+package locker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrNotLeader is returned by the stores of a cluster once this member
+// lost the lead, the client gets Status_NotLeader.
+var ErrNotLeader = errors.New("not the cluster leader")
+
+// HeldLock is a held key as a Store keeps it.
+type HeldLock struct {
+	Namespace      string
+	Key            string
+	Holder         string
+	LeaseId        uint64
+	HeldSince      time.Time
+	LeaseExpiresAt time.Time
+}
+
+// QueuedLock is a lock request waiting for a held key.
+type QueuedLock struct {
+	Namespace string
+	Key       string
+	ClientId  string
+	Lease     time.Duration
+	QueuedAt  time.Time
+	// WaitUntil is when the request stops waiting
+	WaitUntil time.Time
+}
+
+// State is what a Store restores into a new locker.
+type State struct {
+	// LeaseSeq is the last lease id handed out, the ids of the restored
+	// locker start above it so fencing values keep growing
+	LeaseSeq uint64
+	Locks    []HeldLock
+	// Queued are in queue order for every key, only a QueueStore keeps
+	// them
+	Queued []QueuedLock
+}
+
+// Store keeps the held locks across restarts. Hold and Release are
+// called from inside the loop of Start, a lock or a renewal is only
+// reported to its client once Hold returned, so a restart never grants
+// a key that a client was told it holds.
+type Store interface {
+	// Load returns the state saved so far.
+	Load() (State, error)
+	// Hold saves a granted or renewed lock.
+	Hold(lock HeldLock) error
+	// Release forgets a held key.
+	Release(namespace, key string) error
+	Close() error
+}
+
+// QueueStore is a Store that also keeps the queued lock requests, so
+// that a locker restored from it keeps the waiters in order. Holding a
+// key forgets the queued request of its holder.
+type QueueStore interface {
+	Store
+	// Queue saves a lock request waiting for a held key.
+	Queue(queued QueuedLock) error
+	// Dequeue forgets a queued request that stopped waiting.
+	Dequeue(namespace, key, clientId string) error
+}
+
+// WithStore saves the held locks in s, see Restore.
+func WithStore(s Store) Option {
+	return func(l *Locker) {
+		if s != nil {
+			l.store = s
+		}
+	}
+}
+
+type nopStore struct{}
+
+func (nopStore) Load() (State, error) { return State{}, nil }
+
+func (nopStore) Hold(lock HeldLock) error { return nil }
+
+func (nopStore) Release(namespace, key string) error { return nil }
+
+func (nopStore) Close() error { return nil }
+
+// Restore loads the held locks of the store, with their holders, lease
+// deadlines and lease ids, and its queued requests. It must be called
+// before Start. Leases that ran out while the locker was down expire as
+// soon as Start runs.
+func (l *Locker) Restore() error {
+	return l.restore()
+}
+
+func (l *Locker) restore() error {
+	state, err := l.store.Load()
+	if err != nil {
+		return fmt.Errorf("loading locker state : %w", err)
+	}
+	queued := l.load(state)
+	if len(state.Locks) > 0 || queued > 0 {
+		slog.Info("restored held locks", "locks", len(state.Locks), "queued", queued, "lease_seq", l.leaseSeq)
+	}
+	return nil
+}
+
+// load puts the held locks and the queued requests of state into the
+// locker and returns how many requests still wait. The requests wait
+// on their own until their client retries, see KeyHandler.reattach.
+func (l *Locker) load(state State) int {
+	l.leaseSeq = max(l.leaseSeq, state.LeaseSeq)
+	for _, lock := range state.Locks {
+		ns := l.namespace(lock.Namespace)
+		keyHandler := &KeyHandler{
+			key:            lock.Key,
+			locker:         l,
+			ns:             ns,
+			holdingId:      lock.Holder,
+			heldSince:      lock.HeldSince,
+			leaseId:        lock.LeaseId,
+			leaseExpiresAt: lock.LeaseExpiresAt,
+		}
+		ns.keys[lock.Key] = keyHandler
+		ns.hold(lock.Holder)
+		keyHandler.armLease()
+		l.leaseSeq = max(l.leaseSeq, lock.LeaseId)
+	}
+
+	now := l.clock.Now()
+	queued := 0
+	for _, request := range state.Queued {
+		client := &Client{
+			Ctx:        context.Background(),
+			Id:         request.ClientId,
+			Namespace:  request.Namespace,
+			LockKey:    request.Key,
+			StatusChan: make(chan Status, 1),
+			Lease:      request.Lease,
+			queuedAt:   request.QueuedAt,
+			saved:      true,
+			restored:   true,
+			waitUntil:  request.WaitUntil,
+		}
+		if !client.waiting(now) {
+			l.forgetQueued(client)
+			continue
+		}
+		ns := l.namespace(request.Namespace)
+		keyHandler, exist := ns.keys[request.Key]
+		if !exist {
+			keyHandler = &KeyHandler{key: request.Key, locker: l, ns: ns}
+			ns.keys[request.Key] = keyHandler
+		}
+		keyHandler.queue = append(keyHandler.queue, client)
+		ns.waiters++
+		queued++
+	}
+	// a key released just before the state was saved has waiters but
+	// no holder yet
+	for _, ns := range l.namespaces {
+		for _, keyHandler := range ns.keys {
+			if keyHandler.holdingId == "" {
+				keyHandler.grantNext()
+			}
+		}
+	}
+
+	return queued
+}
+
+// saveQueued keeps a request queued behind a holder in a QueueStore.
+func (l *Locker) saveQueued(client *Client) error {
+	store, ok := l.store.(QueueStore)
+	if !ok {
+		return nil
+	}
+	deadline, ok := client.Ctx.Deadline()
+	if !ok {
+		// restored, a request without deadline would wait for ever
+		return nil
+	}
+	err := store.Queue(QueuedLock{
+		Namespace: client.namespace(),
+		Key:       client.LockKey,
+		ClientId:  client.Id,
+		Lease:     client.Lease,
+		QueuedAt:  client.queuedAt,
+		WaitUntil: deadline,
+	})
+	if err != nil {
+		return err
+	}
+	client.saved = true
+	return nil
+}
+
+// forgetQueued removes a request that stopped waiting from the store. A
+// failure only leaves a request that is dropped once its wait ends.
+func (l *Locker) forgetQueued(client *Client) {
+	store, ok := l.store.(QueueStore)
+	if !ok || !client.saved {
+		return
+	}
+	err := store.Dequeue(client.namespace(), client.LockKey, client.Id)
+	if err != nil {
+		logClient(client, slog.LevelDebug, "removing queued lock from the store", slog.Any("err", err))
+	}
+}
+
+// storeStatus is what a client is told when the store failed err.
+func storeStatus(err error) Status {
+	if errors.Is(err, ErrNotLeader) {
+		return Status_NotLeader
+	}
+	return Status_StoreFailed
+}
+
+// heldLock is k as its store keeps it, with a lease ending at expiresAt
+// under leaseId.
+func (k *KeyHandler) heldLock(leaseId uint64, expiresAt time.Time) HeldLock {
+	return HeldLock{
+		Namespace:      k.ns.name,
+		Key:            k.key,
+		Holder:         k.holdingId,
+		LeaseId:        leaseId,
+		HeldSince:      k.heldSince,
+		LeaseExpiresAt: expiresAt,
+	}
+}
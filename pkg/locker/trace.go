@@ -0,0 +1,21 @@
+package locker
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// traceEvent adds an event to the span of the request ctx belongs to.
+// Requests that are not traced cost a context lookup.
+func traceEvent(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	if ctx == nil {
+		return
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.AddEvent(name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sharelock/pkg/locker"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "sharelock"
+
+// OtherKeyPrefix replaces the key prefixes seen after MaxKeyPrefixes
+// distinct ones.
+const OtherKeyPrefix = "_other"
+
+// statsTimeout bounds the round trip through the locker loop of a scrape.
+const statsTimeout = time.Second
+
+type Options struct {
+	// KeyPrefixDepth is the number of leading key segments kept in the
+	// key_prefix label, zero leaves the label out
+	KeyPrefixDepth int
+	// KeyPrefixSeparator splits keys into segments
+	KeyPrefixSeparator string
+	// MaxKeyPrefixes caps the distinct values of the key_prefix label
+	MaxKeyPrefixes int
+}
+
+// Metrics exports the requests of the lock service and the state of its
+// locker in the Prometheus format. It has its own registry, so several
+// lockers in one process do not collide.
+type Metrics struct {
+	registry    *prometheus.Registry
+	prefixes    *keyPrefixes
+	requests    *prometheus.CounterVec
+	wait        *prometheus.HistogramVec
+	hold        *prometheus.HistogramVec
+	expirations *prometheus.CounterVec
+}
+
+func New(opts Options) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		prefixes: newKeyPrefixes(opts),
+	}
+	keyLabels := []string{}
+	if m.prefixes != nil {
+		keyLabels = []string{"key_prefix"}
+	}
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "Requests handled by the lock service, by operation, transport and status. The status is the error reason, or OK.",
+	}, []string{"operation", "transport", "status"})
+	m.wait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_wait_seconds",
+		Help:      "Time granted lock requests spent queued behind other holders.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, keyLabels)
+	m.hold = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "lock_hold_seconds",
+		Help:      "Time locks were held before an unlock or the end of their lease.",
+		Buckets:   []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600},
+	}, keyLabels)
+	m.expirations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lease_expirations_total",
+		Help:      "Locks released because their lease ran out.",
+	}, keyLabels)
+	m.registry.MustRegister(
+		m.requests, m.wait, m.hold, m.expirations,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if m.prefixes != nil {
+		m.registry.MustRegister(m.prefixes.dropped)
+	}
+	return m
+}
+
+// Handler serves the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest counts one request of the lock service.
+func (m *Metrics) ObserveRequest(operation, transport, status string) {
+	m.requests.WithLabelValues(operation, transport, status).Inc()
+}
+
+// WatchLocker exports the gauges of l, read on every scrape.
+func (m *Metrics) WatchLocker(l *locker.Locker) {
+	m.registry.MustRegister(&lockerCollector{locker: l})
+}
+
+func (m *Metrics) keyLabels(key string) []string {
+	if m.prefixes == nil {
+		return nil
+	}
+	return []string{m.prefixes.label(key)}
+}
+
+// Granted implements locker.Observer.
+func (m *Metrics) Granted(namespace, key string, waited time.Duration) {
+	m.wait.WithLabelValues(m.keyLabels(key)...).Observe(waited.Seconds())
+}
+
+// Released implements locker.Observer.
+func (m *Metrics) Released(namespace, key string, held time.Duration, expired bool) {
+	labels := m.keyLabels(key)
+	m.hold.WithLabelValues(labels...).Observe(held.Seconds())
+	if expired {
+		m.expirations.WithLabelValues(labels...).Inc()
+	}
+}
+
+// keyPrefixes turns keys into key_prefix label values, at most max of
+// them. Later prefixes all become OtherKeyPrefix.
+type keyPrefixes struct {
+	depth     int
+	separator string
+	max       int
+	dropped   prometheus.Counter
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newKeyPrefixes(opts Options) *keyPrefixes {
+	if opts.KeyPrefixDepth <= 0 {
+		return nil
+	}
+	return &keyPrefixes{
+		depth:     opts.KeyPrefixDepth,
+		separator: opts.KeyPrefixSeparator,
+		max:       opts.MaxKeyPrefixes,
+		seen:      make(map[string]bool),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "key_prefixes_dropped_total",
+			Help:      "Observations labelled " + OtherKeyPrefix + " because the key_prefix label reached its maximum number of values.",
+		}),
+	}
+}
+
+func (p *keyPrefixes) label(key string) string {
+	prefix := key
+	if p.separator != "" {
+		segments := strings.SplitN(key, p.separator, p.depth+1)
+		if len(segments) > p.depth {
+			prefix = strings.Join(segments[:p.depth], p.separator)
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen[prefix] {
+		return prefix
+	}
+	if len(p.seen) >= p.max {
+		p.dropped.Inc()
+		return OtherKeyPrefix
+	}
+	p.seen[prefix] = true
+	return prefix
+}
+
+var (
+	upDesc = prometheus.NewDesc(namespace+"_locker_up",
+		"Whether the locker loop answered the scrape.", nil, nil)
+	namespacesDesc = prometheus.NewDesc(namespace+"_namespaces",
+		"Namespaces with at least one key.", nil, nil)
+	keysDesc = prometheus.NewDesc(namespace+"_keys",
+		"Keys held or waited for, each has a key handler.", nil, nil)
+	heldDesc = prometheus.NewDesc(namespace+"_held_locks",
+		"Keys currently held.", nil, nil)
+	waitersDesc = prometheus.NewDesc(namespace+"_waiters",
+		"Lock requests queued behind holders.", nil, nil)
+	queueDesc = prometheus.NewDesc(namespace+"_locker_queue_depth",
+		"Requests buffered in front of the locker loop, by channel.", []string{"queue"}, nil)
+)
+
+// lockerCollector reads the gauges of a locker on every scrape. A loop
+// that does not answer in time only reports locker_up 0 and the queue
+// depths, which do not need it.
+type lockerCollector struct {
+	locker *locker.Locker
+}
+
+func (c *lockerCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{upDesc, namespacesDesc, keysDesc, heldDesc, waitersDesc, queueDesc} {
+		ch <- desc
+	}
+}
+
+func (c *lockerCollector) Collect(ch chan<- prometheus.Metric) {
+	for queue, depth := range c.locker.QueueDepths() {
+		ch <- prometheus.MustNewConstMetric(queueDesc, prometheus.GaugeValue, float64(depth), queue)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+	stats, err := c.locker.Stats(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(namespacesDesc, prometheus.GaugeValue, float64(stats.Namespaces))
+	ch <- prometheus.MustNewConstMetric(keysDesc, prometheus.GaugeValue, float64(stats.Keys))
+	ch <- prometheus.MustNewConstMetric(heldDesc, prometheus.GaugeValue, float64(stats.HeldLocks))
+	ch <- prometheus.MustNewConstMetric(waitersDesc, prometheus.GaugeValue, float64(stats.Waiters))
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sharelock/pkg/locker"
+)
+
+// scrape reads the metrics the way Prometheus does.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// wantSamples checks that every sample, a series and its value, was
+// scraped.
+func wantSamples(t *testing.T, scraped string, samples ...string) {
+	t.Helper()
+	lines := strings.Split(scraped, "\n")
+	for _, sample := range samples {
+		found := false
+		for _, line := range lines {
+			if line == sample {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("scrape misses %q", sample)
+		}
+	}
+}
+
+func TestObserveRequest(t *testing.T) {
+	m := New(Options{})
+	m.ObserveRequest("Lock", "grpc", "OK")
+	m.ObserveRequest("Lock", "grpc", "OK")
+	m.ObserveRequest("Lock", "http", "OK")
+	m.ObserveRequest("Unlock", "http", "LOCK_NOT_HELD")
+	wantSamples(t, scrape(t, m),
+		`sharelock_requests_total{operation="Lock",status="OK",transport="grpc"} 2`,
+		`sharelock_requests_total{operation="Lock",status="OK",transport="http"} 1`,
+		`sharelock_requests_total{operation="Unlock",status="LOCK_NOT_HELD",transport="http"} 1`,
+	)
+}
+
+func TestWatchLocker(t *testing.T) {
+	m := New(Options{})
+	l := locker.NewLocker(locker.WithObserver(m))
+	m.WatchLocker(l)
+	// a locker that is not running only reports that it is down
+	wantSamples(t, scrape(t, m), "sharelock_locker_up 0", `sharelock_locker_queue_depth{queue="lock"} 0`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	lock := func(id, key string, lease time.Duration) *locker.Client {
+		client := &locker.Client{Ctx: context.Background(), Id: id, LockKey: key, Lease: lease, StatusChan: make(chan locker.Status, 1)}
+		l.Lock(client)
+		return client
+	}
+	if status := <-lock("c1", "jobs/a", time.Minute).StatusChan; status != locker.Status_Locked {
+		t.Fatalf("c1 got %s", status)
+	}
+	waiter := lock("c2", "jobs/a", time.Minute)
+	for deadline := time.Now().Add(time.Second * 5); ; time.Sleep(time.Millisecond) {
+		info, err := l.Inspect(context.Background(), "", "jobs/a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Waiters == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("c2 was not queued")
+		}
+	}
+	wantSamples(t, scrape(t, m),
+		"sharelock_locker_up 1",
+		"sharelock_namespaces 1",
+		"sharelock_keys 1",
+		"sharelock_held_locks 1",
+		"sharelock_waiters 1",
+		"sharelock_lock_wait_seconds_count 1",
+	)
+
+	unlock := &locker.Client{Ctx: context.Background(), Id: "c1", LockKey: "jobs/a", StatusChan: make(chan locker.Status, 1)}
+	l.Unlock(unlock)
+	if status := <-unlock.StatusChan; status != locker.Status_Unlocked {
+		t.Fatalf("unlock got %s", status)
+	}
+	if status := <-waiter.StatusChan; status != locker.Status_Locked {
+		t.Fatalf("c2 got %s", status)
+	}
+	if status := <-lock("c3", "jobs/b", time.Millisecond*20).StatusChan; status != locker.Status_Locked {
+		t.Fatalf("c3 got %s", status)
+	}
+	for deadline := time.Now().Add(time.Second * 5); !strings.Contains(scrape(t, m), "sharelock_lease_expirations_total 1"); time.Sleep(time.Millisecond * 10) {
+		if time.Now().After(deadline) {
+			t.Fatal("the lease of c3 did not expire")
+		}
+	}
+	wantSamples(t, scrape(t, m),
+		"sharelock_held_locks 1",
+		"sharelock_waiters 0",
+		"sharelock_lock_wait_seconds_count 3",
+		"sharelock_lock_hold_seconds_count 2",
+	)
+}
+
+func TestKeyPrefixes(t *testing.T) {
+	m := New(Options{KeyPrefixDepth: 1, KeyPrefixSeparator: "/", MaxKeyPrefixes: 2})
+	for _, key := range []string{"jobs/a", "jobs/b", "builds/a", "deploys/a", "other", "jobs/c"} {
+		m.Granted("", key, time.Millisecond)
+	}
+	scraped := scrape(t, m)
+	wantSamples(t, scraped,
+		`sharelock_lock_wait_seconds_count{key_prefix="jobs"} 3`,
+		`sharelock_lock_wait_seconds_count{key_prefix="builds"} 1`,
+		fmt.Sprintf(`sharelock_lock_wait_seconds_count{key_prefix="%s"} 2`, OtherKeyPrefix),
+		"sharelock_key_prefixes_dropped_total 2",
+	)
+	for _, prefix := range []string{"deploys", "other"} {
+		if strings.Contains(scraped, `key_prefix="`+prefix+`"`) {
+			t.Errorf("%s is labelled past MaxKeyPrefixes", prefix)
+		}
+	}
+
+	tests := []struct {
+		opts Options
+		key  string
+		want string
+	}{
+		{Options{KeyPrefixDepth: 2, KeyPrefixSeparator: "/"}, "a/b/c", "a/b"},
+		{Options{KeyPrefixDepth: 2, KeyPrefixSeparator: "/"}, "a/b", "a/b"},
+		{Options{KeyPrefixDepth: 1, KeyPrefixSeparator: ":"}, "tenant:job", "tenant"},
+		{Options{KeyPrefixDepth: 1}, "a/b", "a/b"},
+	}
+	for _, tt := range tests {
+		tt.opts.MaxKeyPrefixes = 10
+		if got := newKeyPrefixes(tt.opts).label(tt.key); got != tt.want {
+			t.Errorf("label(%q) with %+v = %q, want %q", tt.key, tt.opts, got, tt.want)
+		}
+	}
+
+	// without a depth the label is left out
+	m = New(Options{MaxKeyPrefixes: 2})
+	m.Granted("", "jobs/a", time.Millisecond)
+	wantSamples(t, scrape(t, m), "sharelock_lock_wait_seconds_count 1")
+}
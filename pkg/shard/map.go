@@ -0,0 +1,134 @@
+package shard
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrRebalancing refuses a new ring while the keys of the last change
+// are still moving.
+var ErrRebalancing = errors.New("the last ring change is still rebalancing")
+
+// Route says where a node serves a request for a key.
+type Route struct {
+	// Local requests are served by the locker of this node
+	Local bool
+	// Foreign local requests are for a key another node owns now. This
+	// node serves them only while the key is busy here, and forwards
+	// them to Owner once it is idle.
+	Foreign bool
+	// HandOff asks Previous for the key first. Once it handed the key
+	// off, this node serves it, until then Previous does.
+	HandOff bool
+	// Owner owns the key on the current ring
+	Owner Member
+	// Previous owned the key on the ring before the last change
+	Previous Member
+	// generation of the ring change the route was found in
+	generation uint64
+}
+
+// Map is the view of one node of the ring. While a change rebalances it
+// keeps the previous ring too: the previous owner of a key serves it
+// until it is idle, then hands it off to the new owner. A key therefore
+// moves only while nobody holds or waits for it.
+type Map struct {
+	self string
+	mu   sync.RWMutex
+	ring *Ring
+	// previous is nil unless a change rebalances
+	previous *Ring
+	// migrated keys were handed off to this node during the rebalance
+	migrated map[string]bool
+	// generation counts the ring changes, a hand-off only counts for
+	// the change it was asked in
+	generation uint64
+}
+
+// NewMap is the view of node self of ring.
+func NewMap(self string, ring *Ring) *Map {
+	return &Map{self: self, ring: ring, migrated: make(map[string]bool)}
+}
+
+// Self is the id of this node.
+func (m *Map) Self() string {
+	return m.self
+}
+
+// Ring is the current ring.
+func (m *Map) Ring() *Ring {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ring
+}
+
+// Previous is the ring before the last change, nil unless it rebalances.
+func (m *Map) Previous() *Ring {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.previous
+}
+
+// SetRing starts a change to ring and reports whether it differs from
+// the current one. A change is refused until the last one finished.
+func (m *Map) SetRing(ring *Ring) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ring.Version() == m.ring.Version() {
+		// the addresses may still have changed
+		m.ring = ring
+		return false, nil
+	}
+	if m.previous != nil {
+		return false, ErrRebalancing
+	}
+	m.previous = m.ring
+	m.ring = ring
+	m.migrated = make(map[string]bool)
+	m.generation++
+	return true, nil
+}
+
+// Finish ends the rebalance and forgets the previous ring. It is safe
+// once no node holds or queues a key it does not own.
+func (m *Map) Finish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.previous = nil
+	m.migrated = make(map[string]bool)
+}
+
+// Owns reports whether this node owns a key on the current ring.
+func (m *Map) Owns(namespace, key string) bool {
+	return m.Ring().Owner(namespace, key).Id == m.self
+}
+
+// Route finds where to serve a request for a key.
+func (m *Map) Route(namespace, key string) Route {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	route := Route{Owner: m.ring.Owner(namespace, key), generation: m.generation}
+	if m.previous == nil {
+		route.Local = route.Owner.Id == m.self
+		return route
+	}
+	route.Previous = m.previous.Owner(namespace, key)
+	switch {
+	case route.Owner.Id == m.self:
+		route.Local = route.Previous.Id == m.self || m.migrated[namespace+"\x00"+key]
+		route.HandOff = !route.Local
+	case route.Previous.Id == m.self:
+		route.Local = true
+		route.Foreign = true
+	}
+	return route
+}
+
+// Migrated records that the previous owner of route handed a key off.
+func (m *Map) Migrated(route Route, namespace, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.previous != nil && m.generation == route.generation {
+		m.migrated[namespace+"\x00"+key] = true
+	}
+}
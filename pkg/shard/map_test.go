@@ -0,0 +1,118 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// keyMoving finds a key that moves from one member to another between
+// two rings.
+func keyMoving(t *testing.T, before, after *Ring, from, to string) string {
+	t.Helper()
+	for i := 0; i < testKeys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if before.Owner("default", key).Id == from && after.Owner("default", key).Id == to {
+			return key
+		}
+	}
+	t.Fatalf("no key moves from %s to %s", from, to)
+	return ""
+}
+
+func TestMapRoute(t *testing.T) {
+	three := testRing(t, testMembers(3))
+	four := testRing(t, testMembers(4))
+	m := NewMap("node3", three)
+
+	key := keyMoving(t, three, four, "node0", "node3")
+	if route := m.Route("default", key); route.Local || route.Owner.Id != "node0" {
+		t.Fatalf("before the change got %+v, want node0", route)
+	}
+
+	changed, err := m.SetRing(four)
+	if err != nil || !changed {
+		t.Fatalf("SetRing : %v %v", changed, err)
+	}
+	route := m.Route("default", key)
+	if route.Local || !route.HandOff || route.Previous.Id != "node0" {
+		t.Fatalf("during the change got %+v, want a hand-off from node0", route)
+	}
+	m.Migrated(route, "default", key)
+	if route := m.Route("default", key); !route.Local || route.HandOff {
+		t.Fatalf("after the hand-off got %+v, want local", route)
+	}
+
+	// the previous owner keeps serving until the key is idle
+	previous := NewMap("node0", three)
+	_, err = previous.SetRing(four)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route := previous.Route("default", key); !route.Local || !route.Foreign || route.Owner.Id != "node3" {
+		t.Fatalf("on the previous owner got %+v, want a foreign local route", route)
+	}
+
+	// a key that stays put does not hand off
+	stays := keyMoving(t, three, four, "node1", "node1")
+	if route := previous.Route("default", stays); route.Local || route.HandOff || route.Owner.Id != "node1" {
+		t.Fatalf("for a key that stays got %+v", route)
+	}
+
+	m.Finish()
+	if m.Previous() != nil {
+		t.Fatal("the previous ring survived Finish")
+	}
+	if route := m.Route("default", key); !route.Local || route.HandOff {
+		t.Fatalf("after Finish got %+v, want local", route)
+	}
+}
+
+func TestMapSetRing(t *testing.T) {
+	three := testRing(t, testMembers(3))
+	m := NewMap("node0", three)
+
+	// the same members at new addresses are no change
+	moved := testMembers(3)
+	moved[1].GrpcAddress = "10.0.0.1:50051"
+	changed, err := m.SetRing(testRing(t, moved))
+	if err != nil || changed {
+		t.Fatalf("new addresses : changed %v, err %v", changed, err)
+	}
+	if member, _ := m.Ring().Member("node1"); member.GrpcAddress != "10.0.0.1:50051" {
+		t.Fatalf("address %s was not updated", member.GrpcAddress)
+	}
+
+	four := testRing(t, testMembers(4))
+	changed, err = m.SetRing(four)
+	if err != nil || !changed {
+		t.Fatalf("adding a member : changed %v, err %v", changed, err)
+	}
+	_, err = m.SetRing(testRing(t, testMembers(5)))
+	if !errors.Is(err, ErrRebalancing) {
+		t.Fatalf("got %v while rebalancing, want ErrRebalancing", err)
+	}
+	if m.Ring() != four {
+		t.Fatal("a refused change replaced the ring")
+	}
+
+	// a hand-off asked on an older change does not count
+	m = NewMap("node3", three)
+	key := keyMoving(t, three, four, "node1", "node3")
+	_, err = m.SetRing(four)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := m.Route("default", key)
+	for _, ring := range []*Ring{three, four} {
+		m.Finish()
+		_, err = m.SetRing(ring)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Migrated(stale, "default", key)
+	if route := m.Route("default", key); route.Local || !route.HandOff {
+		t.Fatalf("a stale hand-off made %s local : %+v", key, route)
+	}
+}
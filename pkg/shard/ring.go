@@ -0,0 +1,132 @@
+// Package shard partitions the key space between ShareLock nodes with a
+// consistent hash ring, and tracks the keys that move while the ring
+// changes.
+package shard
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DefaultVirtualNodes is the number of points of every member on the
+// ring, enough to spread the keys evenly between a handful of nodes.
+const DefaultVirtualNodes = 128
+
+// Member is a node of the ring.
+type Member struct {
+	Id string
+	// GrpcAddress is where the other nodes forward requests to
+	GrpcAddress string
+}
+
+type point struct {
+	hash   uint64
+	member int
+}
+
+// Ring maps every key to one member. Adding or removing a member only
+// moves the keys of the points it takes or frees, about 1/n of them.
+type Ring struct {
+	members []Member
+	points  []point
+	version string
+}
+
+// NewRing places virtualNodes points of every member on the ring.
+// Members are sorted by id, so every node builds the same ring from the
+// same members.
+func NewRing(members []Member, virtualNodes int) (*Ring, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("a ring needs at least one member")
+	}
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	sorted := slices.Clone(members)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Id == sorted[i-1].Id {
+			return nil, fmt.Errorf("member %s is listed twice", sorted[i].Id)
+		}
+	}
+
+	r := &Ring{members: sorted, points: make([]point, 0, len(sorted)*virtualNodes)}
+	for i, member := range sorted {
+		for v := 0; v < virtualNodes; v++ {
+			r.points = append(r.points, point{hash: hash(fmt.Sprintf("%s#%d", member.Id, v)), member: i})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+
+	// the version names the placement, not the addresses
+	ids := make([]string, len(sorted))
+	for i, member := range sorted {
+		ids[i] = member.Id
+	}
+	sum := make([]byte, 8)
+	binary.BigEndian.PutUint64(sum, hash(fmt.Sprintf("%d|%s", virtualNodes, strings.Join(ids, ","))))
+	r.version = fmt.Sprintf("%x", sum)
+	return r, nil
+}
+
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	// fnv alone clusters similar strings, mix the bits
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// Owner is the member of the first point at or after the hash of the
+// key.
+func (r *Ring) Owner(namespace, key string) Member {
+	h := hash(namespace + "\x00" + key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.members[r.points[i].member]
+}
+
+// Member finds a member by id.
+func (r *Ring) Member(id string) (Member, bool) {
+	for _, member := range r.members {
+		if member.Id == id {
+			return member, true
+		}
+	}
+	return Member{}, false
+}
+
+// Members are sorted by id.
+func (r *Ring) Members() []Member {
+	return slices.Clone(r.members)
+}
+
+// Version is the same on every node with the same members and virtual
+// nodes.
+func (r *Ring) Version() string {
+	return r.version
+}
+
+// ParseMembers reads members written as id=host:port.
+func ParseMembers(members []string) ([]Member, error) {
+	parsed := make([]Member, 0, len(members))
+	for _, member := range members {
+		id, address, ok := strings.Cut(member, "=")
+		if !ok || id == "" || address == "" {
+			return nil, fmt.Errorf("member %q is not id=host:port", member)
+		}
+		parsed = append(parsed, Member{Id: id, GrpcAddress: address})
+	}
+	return parsed, nil
+}
@@ -0,0 +1,155 @@
+package shard
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testMembers(n int) []Member {
+	members := make([]Member, n)
+	for i := range members {
+		members[i] = Member{Id: fmt.Sprintf("node%d", i), GrpcAddress: fmt.Sprintf("127.0.0.1:%d", 50051+i)}
+	}
+	return members
+}
+
+func testRing(t *testing.T, members []Member) *Ring {
+	t.Helper()
+	ring, err := NewRing(members, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+const testKeys = 20000
+
+func owners(ring *Ring) []string {
+	ids := make([]string, testKeys)
+	for i := range ids {
+		ids[i] = ring.Owner("default", fmt.Sprintf("key-%d", i)).Id
+	}
+	return ids
+}
+
+func TestRingIsDeterministic(t *testing.T) {
+	members := testMembers(4)
+	reversed := []Member{members[3], members[2], members[1], members[0]}
+	a, b := testRing(t, members), testRing(t, reversed)
+	if a.Version() != b.Version() {
+		t.Fatalf("versions %s and %s for the same members", a.Version(), b.Version())
+	}
+	ownersA, ownersB := owners(a), owners(b)
+	for i := range ownersA {
+		if ownersA[i] != ownersB[i] {
+			t.Fatalf("key-%d is owned by %s and %s", i, ownersA[i], ownersB[i])
+		}
+	}
+	if a.Members()[0].Id != "node0" {
+		t.Fatalf("members %v are not sorted", a.Members())
+	}
+
+	// addresses do not change the placement
+	moved := testMembers(4)
+	moved[0].GrpcAddress = "10.0.0.1:50051"
+	if testRing(t, moved).Version() != a.Version() {
+		t.Fatal("a new address changed the version")
+	}
+	fewer, err := NewRing(members, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fewer.Version() == a.Version() {
+		t.Fatal("other virtual nodes kept the version")
+	}
+	// namespaces are part of the key
+	if a.Owner("default", "key") == a.Owner("other", "key") && a.Owner("default", "key2") == a.Owner("other", "key2") &&
+		a.Owner("default", "key3") == a.Owner("other", "key3") {
+		t.Fatal("the namespace does not change the owner")
+	}
+}
+
+func TestRingSpread(t *testing.T) {
+	for _, n := range []int{2, 3, 5, 8} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			counts := make(map[string]int)
+			for _, owner := range owners(testRing(t, testMembers(n))) {
+				counts[owner]++
+			}
+			if len(counts) != n {
+				t.Fatalf("%d of %d members own keys", len(counts), n)
+			}
+			even := testKeys / n
+			for id, count := range counts {
+				// 128 virtual nodes keep every member within a third of
+				// its even share
+				if count < even*2/3 || count > even*4/3 {
+					t.Errorf("%s owns %d keys, an even share is %d", id, count, even)
+				}
+			}
+		})
+	}
+}
+
+func TestRingMemberChange(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after []Member
+		// changed is the member added or removed
+		changed string
+		// moved is the share of the keys expected to move
+		moved float64
+	}{
+		{name: "add a fifth", before: testMembers(4), after: testMembers(5), changed: "node4", moved: 1.0 / 5},
+		{name: "remove the fifth", before: testMembers(5), after: testMembers(4), changed: "node4", moved: 1.0 / 5},
+		{name: "add a second", before: testMembers(1), after: testMembers(2), changed: "node1", moved: 1.0 / 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := owners(testRing(t, tt.before)), owners(testRing(t, tt.after))
+			moved := 0
+			for i := range before {
+				if before[i] == after[i] {
+					continue
+				}
+				moved++
+				// only the changed member takes or frees keys
+				if before[i] != tt.changed && after[i] != tt.changed {
+					t.Fatalf("key-%d moved from %s to %s", i, before[i], after[i])
+				}
+			}
+			share := float64(moved) / testKeys
+			if share < tt.moved*0.7 || share > tt.moved*1.3 {
+				t.Fatalf("%.3f of the keys moved, want about %.3f", share, tt.moved)
+			}
+		})
+	}
+}
+
+func TestNewRing(t *testing.T) {
+	_, err := NewRing(nil, 0)
+	if err == nil {
+		t.Fatal("built a ring without members")
+	}
+	_, err = NewRing([]Member{{Id: "a"}, {Id: "b"}, {Id: "a"}}, 0)
+	if err == nil || !strings.Contains(err.Error(), "listed twice") {
+		t.Fatalf("got %v, want an error about a member listed twice", err)
+	}
+}
+
+func TestParseMembers(t *testing.T) {
+	members, err := ParseMembers([]string{"node0=127.0.0.1:1", "node1=[::1]:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members[1] != (Member{Id: "node1", GrpcAddress: "[::1]:2"}) {
+		t.Fatalf("got %+v", members[1])
+	}
+	for _, member := range []string{"node0", "=127.0.0.1:1", "node0="} {
+		_, err = ParseMembers([]string{member})
+		if err == nil {
+			t.Errorf("parsed %q", member)
+		}
+	}
+}
@@ -19,18 +19,27 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ShareLockService_Ping_FullMethodName   = "/sharelock.ShareLockService/Ping"
-	ShareLockService_Lock_FullMethodName   = "/sharelock.ShareLockService/Lock"
-	ShareLockService_Unlock_FullMethodName = "/sharelock.ShareLockService/Unlock"
+	ShareLockService_Ping_FullMethodName         = "/sharelock.ShareLockService/Ping"
+	ShareLockService_Lock_FullMethodName         = "/sharelock.ShareLockService/Lock"
+	ShareLockService_Unlock_FullMethodName       = "/sharelock.ShareLockService/Unlock"
+	ShareLockService_GetLock_FullMethodName      = "/sharelock.ShareLockService/GetLock"
+	ShareLockService_RenewLock_FullMethodName    = "/sharelock.ShareLockService/RenewLock"
+	ShareLockService_GetNamespace_FullMethodName = "/sharelock.ShareLockService/GetNamespace"
 )
 
 // ShareLockServiceClient is the client API for ShareLockService service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// The google.api.http annotations define the v2 REST API. The HTTP
+// server builds its routes and the OpenAPI document from them.
 type ShareLockServiceClient interface {
 	Ping(ctx context.Context, in *ShareLockPingRequest, opts ...grpc.CallOption) (*ShareLockPingResponse, error)
 	Lock(ctx context.Context, in *LockRequest, opts ...grpc.CallOption) (*LockResponse, error)
 	Unlock(ctx context.Context, in *UnlockRequest, opts ...grpc.CallOption) (*UnlockResponse, error)
+	GetLock(ctx context.Context, in *GetLockRequest, opts ...grpc.CallOption) (*LockInfo, error)
+	RenewLock(ctx context.Context, in *RenewLockRequest, opts ...grpc.CallOption) (*RenewLockResponse, error)
+	GetNamespace(ctx context.Context, in *GetNamespaceRequest, opts ...grpc.CallOption) (*NamespaceInfo, error)
 }
 
 type shareLockServiceClient struct {
@@ -71,13 +80,49 @@ func (c *shareLockServiceClient) Unlock(ctx context.Context, in *UnlockRequest,
 	return out, nil
 }
 
+func (c *shareLockServiceClient) GetLock(ctx context.Context, in *GetLockRequest, opts ...grpc.CallOption) (*LockInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(LockInfo)
+	err := c.cc.Invoke(ctx, ShareLockService_GetLock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockServiceClient) RenewLock(ctx context.Context, in *RenewLockRequest, opts ...grpc.CallOption) (*RenewLockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RenewLockResponse)
+	err := c.cc.Invoke(ctx, ShareLockService_RenewLock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockServiceClient) GetNamespace(ctx context.Context, in *GetNamespaceRequest, opts ...grpc.CallOption) (*NamespaceInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NamespaceInfo)
+	err := c.cc.Invoke(ctx, ShareLockService_GetNamespace_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShareLockServiceServer is the server API for ShareLockService service.
 // All implementations must embed UnimplementedShareLockServiceServer
 // for forward compatibility.
+//
+// The google.api.http annotations define the v2 REST API. The HTTP
+// server builds its routes and the OpenAPI document from them.
 type ShareLockServiceServer interface {
 	Ping(context.Context, *ShareLockPingRequest) (*ShareLockPingResponse, error)
 	Lock(context.Context, *LockRequest) (*LockResponse, error)
 	Unlock(context.Context, *UnlockRequest) (*UnlockResponse, error)
+	GetLock(context.Context, *GetLockRequest) (*LockInfo, error)
+	RenewLock(context.Context, *RenewLockRequest) (*RenewLockResponse, error)
+	GetNamespace(context.Context, *GetNamespaceRequest) (*NamespaceInfo, error)
 	mustEmbedUnimplementedShareLockServiceServer()
 }
 
@@ -97,6 +142,15 @@ func (UnimplementedShareLockServiceServer) Lock(context.Context, *LockRequest) (
 func (UnimplementedShareLockServiceServer) Unlock(context.Context, *UnlockRequest) (*UnlockResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Unlock not implemented")
 }
+func (UnimplementedShareLockServiceServer) GetLock(context.Context, *GetLockRequest) (*LockInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLock not implemented")
+}
+func (UnimplementedShareLockServiceServer) RenewLock(context.Context, *RenewLockRequest) (*RenewLockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RenewLock not implemented")
+}
+func (UnimplementedShareLockServiceServer) GetNamespace(context.Context, *GetNamespaceRequest) (*NamespaceInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNamespace not implemented")
+}
 func (UnimplementedShareLockServiceServer) mustEmbedUnimplementedShareLockServiceServer() {}
 func (UnimplementedShareLockServiceServer) testEmbeddedByValue()                          {}
 
@@ -108,7 +162,7 @@ type UnsafeShareLockServiceServer interface {
 }
 
 func RegisterShareLockServiceServer(s grpc.ServiceRegistrar, srv ShareLockServiceServer) {
-	// If the following call panics, it indicates UnimplementedShareLockServiceServer was
+	// If the following call pancis, it indicates UnimplementedShareLockServiceServer was
 	// embedded by pointer and is nil.  This will cause panics if an
 	// unimplemented method is ever invoked, so we test this at initialization
 	// time to prevent it from happening at runtime later due to I/O.
@@ -172,6 +226,60 @@ func _ShareLockService_Unlock_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShareLockService_GetLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockServiceServer).GetLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockService_GetLock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockServiceServer).GetLock(ctx, req.(*GetLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockService_RenewLock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RenewLockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockServiceServer).RenewLock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockService_RenewLock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockServiceServer).RenewLock(ctx, req.(*RenewLockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockService_GetNamespace_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNamespaceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockServiceServer).GetNamespace(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockService_GetNamespace_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockServiceServer).GetNamespace(ctx, req.(*GetNamespaceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShareLockService_ServiceDesc is the grpc.ServiceDesc for ShareLockService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -191,6 +299,580 @@ var ShareLockService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Unlock",
 			Handler:    _ShareLockService_Unlock_Handler,
 		},
+		{
+			MethodName: "GetLock",
+			Handler:    _ShareLockService_GetLock_Handler,
+		},
+		{
+			MethodName: "RenewLock",
+			Handler:    _ShareLockService_RenewLock_Handler,
+		},
+		{
+			MethodName: "GetNamespace",
+			Handler:    _ShareLockService_GetNamespace_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sharelock.proto",
+}
+
+const (
+	ShareLockAdmin_GetCluster_FullMethodName         = "/sharelock.ShareLockAdmin/GetCluster"
+	ShareLockAdmin_AddMember_FullMethodName          = "/sharelock.ShareLockAdmin/AddMember"
+	ShareLockAdmin_RemoveMember_FullMethodName       = "/sharelock.ShareLockAdmin/RemoveMember"
+	ShareLockAdmin_TransferLeadership_FullMethodName = "/sharelock.ShareLockAdmin/TransferLeadership"
+	ShareLockAdmin_ExportState_FullMethodName        = "/sharelock.ShareLockAdmin/ExportState"
+	ShareLockAdmin_ImportState_FullMethodName        = "/sharelock.ShareLockAdmin/ImportState"
+	ShareLockAdmin_ThawState_FullMethodName          = "/sharelock.ShareLockAdmin/ThawState"
+)
+
+// ShareLockAdminClient is the client API for ShareLockAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ShareLockAdmin manages the membership of a cluster, and exports and
+// imports the locker state. It is served over gRPC only. Changes are
+// forwarded to the leader of a cluster.
+type ShareLockAdminClient interface {
+	GetCluster(ctx context.Context, in *GetClusterRequest, opts ...grpc.CallOption) (*ClusterInfo, error)
+	AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*ClusterInfo, error)
+	RemoveMember(ctx context.Context, in *RemoveMemberRequest, opts ...grpc.CallOption) (*ClusterInfo, error)
+	TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*ClusterInfo, error)
+	ExportState(ctx context.Context, in *ExportStateRequest, opts ...grpc.CallOption) (*StateSnapshot, error)
+	ImportState(ctx context.Context, in *ImportStateRequest, opts ...grpc.CallOption) (*ImportStateResponse, error)
+	ThawState(ctx context.Context, in *ThawStateRequest, opts ...grpc.CallOption) (*ThawStateResponse, error)
+}
+
+type shareLockAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShareLockAdminClient(cc grpc.ClientConnInterface) ShareLockAdminClient {
+	return &shareLockAdminClient{cc}
+}
+
+func (c *shareLockAdminClient) GetCluster(ctx context.Context, in *GetClusterRequest, opts ...grpc.CallOption) (*ClusterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClusterInfo)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_GetCluster_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) AddMember(ctx context.Context, in *AddMemberRequest, opts ...grpc.CallOption) (*ClusterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClusterInfo)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_AddMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) RemoveMember(ctx context.Context, in *RemoveMemberRequest, opts ...grpc.CallOption) (*ClusterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClusterInfo)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_RemoveMember_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) TransferLeadership(ctx context.Context, in *TransferLeadershipRequest, opts ...grpc.CallOption) (*ClusterInfo, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ClusterInfo)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_TransferLeadership_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) ExportState(ctx context.Context, in *ExportStateRequest, opts ...grpc.CallOption) (*StateSnapshot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StateSnapshot)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_ExportState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) ImportState(ctx context.Context, in *ImportStateRequest, opts ...grpc.CallOption) (*ImportStateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ImportStateResponse)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_ImportState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockAdminClient) ThawState(ctx context.Context, in *ThawStateRequest, opts ...grpc.CallOption) (*ThawStateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ThawStateResponse)
+	err := c.cc.Invoke(ctx, ShareLockAdmin_ThawState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ShareLockAdminServer is the server API for ShareLockAdmin service.
+// All implementations must embed UnimplementedShareLockAdminServer
+// for forward compatibility.
+//
+// ShareLockAdmin manages the membership of a cluster, and exports and
+// imports the locker state. It is served over gRPC only. Changes are
+// forwarded to the leader of a cluster.
+type ShareLockAdminServer interface {
+	GetCluster(context.Context, *GetClusterRequest) (*ClusterInfo, error)
+	AddMember(context.Context, *AddMemberRequest) (*ClusterInfo, error)
+	RemoveMember(context.Context, *RemoveMemberRequest) (*ClusterInfo, error)
+	TransferLeadership(context.Context, *TransferLeadershipRequest) (*ClusterInfo, error)
+	ExportState(context.Context, *ExportStateRequest) (*StateSnapshot, error)
+	ImportState(context.Context, *ImportStateRequest) (*ImportStateResponse, error)
+	ThawState(context.Context, *ThawStateRequest) (*ThawStateResponse, error)
+	mustEmbedUnimplementedShareLockAdminServer()
+}
+
+// UnimplementedShareLockAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedShareLockAdminServer struct{}
+
+func (UnimplementedShareLockAdminServer) GetCluster(context.Context, *GetClusterRequest) (*ClusterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCluster not implemented")
+}
+func (UnimplementedShareLockAdminServer) AddMember(context.Context, *AddMemberRequest) (*ClusterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddMember not implemented")
+}
+func (UnimplementedShareLockAdminServer) RemoveMember(context.Context, *RemoveMemberRequest) (*ClusterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveMember not implemented")
+}
+func (UnimplementedShareLockAdminServer) TransferLeadership(context.Context, *TransferLeadershipRequest) (*ClusterInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TransferLeadership not implemented")
+}
+func (UnimplementedShareLockAdminServer) ExportState(context.Context, *ExportStateRequest) (*StateSnapshot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportState not implemented")
+}
+func (UnimplementedShareLockAdminServer) ImportState(context.Context, *ImportStateRequest) (*ImportStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImportState not implemented")
+}
+func (UnimplementedShareLockAdminServer) ThawState(context.Context, *ThawStateRequest) (*ThawStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ThawState not implemented")
+}
+func (UnimplementedShareLockAdminServer) mustEmbedUnimplementedShareLockAdminServer() {}
+func (UnimplementedShareLockAdminServer) testEmbeddedByValue()                        {}
+
+// UnsafeShareLockAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShareLockAdminServer will
+// result in compilation errors.
+type UnsafeShareLockAdminServer interface {
+	mustEmbedUnimplementedShareLockAdminServer()
+}
+
+func RegisterShareLockAdminServer(s grpc.ServiceRegistrar, srv ShareLockAdminServer) {
+	// If the following call pancis, it indicates UnimplementedShareLockAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ShareLockAdmin_ServiceDesc, srv)
+}
+
+func _ShareLockAdmin_GetCluster_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetClusterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).GetCluster(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_GetCluster_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).GetCluster(ctx, req.(*GetClusterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_AddMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).AddMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_AddMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).AddMember(ctx, req.(*AddMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_RemoveMember_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveMemberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).RemoveMember(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_RemoveMember_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).RemoveMember(ctx, req.(*RemoveMemberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_TransferLeadership_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransferLeadershipRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).TransferLeadership(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_TransferLeadership_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).TransferLeadership(ctx, req.(*TransferLeadershipRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_ExportState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).ExportState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_ExportState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).ExportState(ctx, req.(*ExportStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_ImportState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImportStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).ImportState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_ImportState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).ImportState(ctx, req.(*ImportStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockAdmin_ThawState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ThawStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockAdminServer).ThawState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockAdmin_ThawState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockAdminServer).ThawState(ctx, req.(*ThawStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ShareLockAdmin_ServiceDesc is the grpc.ServiceDesc for ShareLockAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ShareLockAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sharelock.ShareLockAdmin",
+	HandlerType: (*ShareLockAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCluster",
+			Handler:    _ShareLockAdmin_GetCluster_Handler,
+		},
+		{
+			MethodName: "AddMember",
+			Handler:    _ShareLockAdmin_AddMember_Handler,
+		},
+		{
+			MethodName: "RemoveMember",
+			Handler:    _ShareLockAdmin_RemoveMember_Handler,
+		},
+		{
+			MethodName: "TransferLeadership",
+			Handler:    _ShareLockAdmin_TransferLeadership_Handler,
+		},
+		{
+			MethodName: "ExportState",
+			Handler:    _ShareLockAdmin_ExportState_Handler,
+		},
+		{
+			MethodName: "ImportState",
+			Handler:    _ShareLockAdmin_ImportState_Handler,
+		},
+		{
+			MethodName: "ThawState",
+			Handler:    _ShareLockAdmin_ThawState_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sharelock.proto",
+}
+
+const (
+	ShareLockShard_Call_FullMethodName            = "/sharelock.ShareLockShard/Call"
+	ShareLockShard_HandOff_FullMethodName         = "/sharelock.ShareLockShard/HandOff"
+	ShareLockShard_GetShardStatus_FullMethodName  = "/sharelock.ShareLockShard/GetShardStatus"
+	ShareLockShard_FinishRebalance_FullMethodName = "/sharelock.ShareLockShard/FinishRebalance"
+)
+
+// ShareLockShardClient is the client API for ShareLockShard service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ShareLockShard is the internal service between the nodes of a sharded
+// deployment. It is served over gRPC only, and every call must carry the
+// shared secret of the nodes in the x-sharelock-shard-secret metadata.
+type ShareLockShardClient interface {
+	Call(ctx context.Context, in *ShardCall, opts ...grpc.CallOption) (*ShardReply, error)
+	HandOff(ctx context.Context, in *HandOffRequest, opts ...grpc.CallOption) (*HandOffResponse, error)
+	GetShardStatus(ctx context.Context, in *GetShardStatusRequest, opts ...grpc.CallOption) (*ShardStatus, error)
+	FinishRebalance(ctx context.Context, in *FinishRebalanceRequest, opts ...grpc.CallOption) (*ShardStatus, error)
+}
+
+type shareLockShardClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewShareLockShardClient(cc grpc.ClientConnInterface) ShareLockShardClient {
+	return &shareLockShardClient{cc}
+}
+
+func (c *shareLockShardClient) Call(ctx context.Context, in *ShardCall, opts ...grpc.CallOption) (*ShardReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ShardReply)
+	err := c.cc.Invoke(ctx, ShareLockShard_Call_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockShardClient) HandOff(ctx context.Context, in *HandOffRequest, opts ...grpc.CallOption) (*HandOffResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HandOffResponse)
+	err := c.cc.Invoke(ctx, ShareLockShard_HandOff_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockShardClient) GetShardStatus(ctx context.Context, in *GetShardStatusRequest, opts ...grpc.CallOption) (*ShardStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ShardStatus)
+	err := c.cc.Invoke(ctx, ShareLockShard_GetShardStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shareLockShardClient) FinishRebalance(ctx context.Context, in *FinishRebalanceRequest, opts ...grpc.CallOption) (*ShardStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ShardStatus)
+	err := c.cc.Invoke(ctx, ShareLockShard_FinishRebalance_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ShareLockShardServer is the server API for ShareLockShard service.
+// All implementations must embed UnimplementedShareLockShardServer
+// for forward compatibility.
+//
+// ShareLockShard is the internal service between the nodes of a sharded
+// deployment. It is served over gRPC only, and every call must carry the
+// shared secret of the nodes in the x-sharelock-shard-secret metadata.
+type ShareLockShardServer interface {
+	Call(context.Context, *ShardCall) (*ShardReply, error)
+	HandOff(context.Context, *HandOffRequest) (*HandOffResponse, error)
+	GetShardStatus(context.Context, *GetShardStatusRequest) (*ShardStatus, error)
+	FinishRebalance(context.Context, *FinishRebalanceRequest) (*ShardStatus, error)
+	mustEmbedUnimplementedShareLockShardServer()
+}
+
+// UnimplementedShareLockShardServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedShareLockShardServer struct{}
+
+func (UnimplementedShareLockShardServer) Call(context.Context, *ShardCall) (*ShardReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Call not implemented")
+}
+func (UnimplementedShareLockShardServer) HandOff(context.Context, *HandOffRequest) (*HandOffResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HandOff not implemented")
+}
+func (UnimplementedShareLockShardServer) GetShardStatus(context.Context, *GetShardStatusRequest) (*ShardStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShardStatus not implemented")
+}
+func (UnimplementedShareLockShardServer) FinishRebalance(context.Context, *FinishRebalanceRequest) (*ShardStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FinishRebalance not implemented")
+}
+func (UnimplementedShareLockShardServer) mustEmbedUnimplementedShareLockShardServer() {}
+func (UnimplementedShareLockShardServer) testEmbeddedByValue()                        {}
+
+// UnsafeShareLockShardServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ShareLockShardServer will
+// result in compilation errors.
+type UnsafeShareLockShardServer interface {
+	mustEmbedUnimplementedShareLockShardServer()
+}
+
+func RegisterShareLockShardServer(s grpc.ServiceRegistrar, srv ShareLockShardServer) {
+	// If the following call pancis, it indicates UnimplementedShareLockShardServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ShareLockShard_ServiceDesc, srv)
+}
+
+func _ShareLockShard_Call_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardCall)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockShardServer).Call(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockShard_Call_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockShardServer).Call(ctx, req.(*ShardCall))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockShard_HandOff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HandOffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockShardServer).HandOff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockShard_HandOff_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockShardServer).HandOff(ctx, req.(*HandOffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockShard_GetShardStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShardStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockShardServer).GetShardStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockShard_GetShardStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockShardServer).GetShardStatus(ctx, req.(*GetShardStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShareLockShard_FinishRebalance_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishRebalanceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShareLockShardServer).FinishRebalance(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShareLockShard_FinishRebalance_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShareLockShardServer).FinishRebalance(ctx, req.(*FinishRebalanceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ShareLockShard_ServiceDesc is the grpc.ServiceDesc for ShareLockShard service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ShareLockShard_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sharelock.ShareLockShard",
+	HandlerType: (*ShareLockShardServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Call",
+			Handler:    _ShareLockShard_Call_Handler,
+		},
+		{
+			MethodName: "HandOff",
+			Handler:    _ShareLockShard_HandOff_Handler,
+		},
+		{
+			MethodName: "GetShardStatus",
+			Handler:    _ShareLockShard_GetShardStatus_Handler,
+		},
+		{
+			MethodName: "FinishRebalance",
+			Handler:    _ShareLockShard_FinishRebalance_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "sharelock.proto",
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sharelock/pkg/locker"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltFile is the name of the database of the bolt store in its dir.
+const BoltFile = "state.db"
+
+var (
+	locksBucket = []byte("locks")
+	metaBucket  = []byte("meta")
+	leaseSeqKey = []byte("lease_seq")
+)
+
+// Bolt is a locker.Store keeping the held locks in a bbolt database,
+// one transaction per change.
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens or creates the database of the bolt store in dir.
+func OpenBolt(dir string, sync bool) (*Bolt, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating state dir : %w", err)
+	}
+	db, err := bolt.Open(filepath.Join(dir, BoltFile), 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening state database : %w", err)
+	}
+	db.NoSync = !sync
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{locksBucket, metaBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating state buckets : %w", err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+// boltKey joins namespace and key with a NUL, which namespaces may not
+// contain.
+func boltKey(namespace, key string) []byte {
+	return []byte(namespace + "\x00" + key)
+}
+
+// Load implements locker.Store.
+func (b *Bolt) Load() (locker.State, error) {
+	held := newHeldLocks()
+	err := b.db.View(func(tx *bolt.Tx) error {
+		seq := tx.Bucket(metaBucket).Get(leaseSeqKey)
+		if len(seq) == 8 {
+			held.leaseSeq = binary.BigEndian.Uint64(seq)
+		}
+		return tx.Bucket(locksBucket).ForEach(func(k, v []byte) error {
+			var record lockRecord
+			err := json.Unmarshal(v, &record)
+			if err != nil {
+				return fmt.Errorf("lock %q : %w", k, err)
+			}
+			held.hold(record.heldLock())
+			return nil
+		})
+	})
+	if err != nil {
+		return locker.State{}, fmt.Errorf("reading state database : %w", err)
+	}
+	return held.state(), nil
+}
+
+// Hold implements locker.Store.
+func (b *Bolt) Hold(lock locker.HeldLock) error {
+	data, err := json.Marshal(toRecord(lock))
+	if err != nil {
+		return fmt.Errorf("encoding held lock : %w", err)
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		seq := meta.Get(leaseSeqKey)
+		if len(seq) != 8 || binary.BigEndian.Uint64(seq) < lock.LeaseId {
+			err := meta.Put(leaseSeqKey, binary.BigEndian.AppendUint64(nil, lock.LeaseId))
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(locksBucket).Put(boltKey(lock.Namespace, lock.Key), data)
+	})
+	if err != nil {
+		return fmt.Errorf("writing state database : %w", err)
+	}
+	return nil
+}
+
+// Release implements locker.Store.
+func (b *Bolt) Release(namespace, key string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(locksBucket).Delete(boltKey(namespace, key))
+	})
+	if err != nil {
+		return fmt.Errorf("writing state database : %w", err)
+	}
+	return nil
+}
+
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
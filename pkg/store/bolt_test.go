@@ -0,0 +1,64 @@
+package store
+
+import (
+	"slices"
+	"testing"
+
+	"sharelock/pkg/locker"
+)
+
+func TestBolt(t *testing.T) {
+	dir := t.TempDir()
+	b, err := OpenBolt(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := testLock("a", 3)
+	other.Namespace = "other"
+	for _, lock := range []locker.HeldLock{testLock("a", 1), testLock("b", 2), other} {
+		if err = b.Hold(lock); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a renewal replaces the lock, releasing one namespace keeps the
+	// same key of another
+	renewed := testLock("b", 4)
+	renewed.Holder = "renewer"
+	if err = b.Hold(renewed); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Release("default", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Release("default", "missing"); err != nil {
+		t.Fatalf("releasing a key nobody holds : %v", err)
+	}
+	// an older lease id does not lower the sequence
+	if err = b.Hold(testLock("c", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = OpenBolt(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	state, err := b.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.LeaseSeq != 4 {
+		t.Fatalf("lease seq %d, want 4", state.LeaseSeq)
+	}
+	got := make([]string, 0, len(state.Locks))
+	for _, lock := range state.Locks {
+		got = append(got, lock.Namespace+"/"+lock.Key+"/"+lock.Holder)
+	}
+	want := []string{"default/b/renewer", "default/c/client", "other/a/client"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("locks %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package store
+
+import (
+	"time"
+
+	"sharelock/pkg/locker"
+)
+
+// lockRecord is a held lock as the files of the stores keep it, apart
+// from locker.HeldLock so that the file format does not follow renames.
+type lockRecord struct {
+	Namespace      string    `json:"namespace"`
+	Key            string    `json:"key"`
+	Holder         string    `json:"holder"`
+	LeaseId        uint64    `json:"lease_id"`
+	HeldSince      time.Time `json:"held_since"`
+	LeaseExpiresAt time.Time `json:"lease_expires_at"`
+}
+
+func toRecord(lock locker.HeldLock) lockRecord {
+	return lockRecord(lock)
+}
+
+func (r lockRecord) heldLock() locker.HeldLock {
+	return locker.HeldLock(r)
+}
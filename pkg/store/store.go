@@ -0,0 +1,91 @@
+// Package store keeps the held locks of a locker.Locker on disk, so
+// that a restart restores them instead of freeing every key.
+package store
+
+import (
+	"fmt"
+	"sort"
+
+	"sharelock/pkg/locker"
+)
+
+const (
+	Kind_Memory = ""
+	Kind_Wal    = "wal"
+	Kind_Bolt   = "bolt"
+)
+
+type Options struct {
+	// Kind is Kind_Wal, Kind_Bolt or Kind_Memory, which keeps nothing
+	Kind string
+	// Dir holds the files of the store
+	Dir string
+	// Sync flushes every change to the disk before the lock is reported
+	// to its client. Without it a crash of the machine, not of the
+	// process, may lose the last changes.
+	Sync bool
+	// SnapshotEvery compacts the log of the wal store after this many
+	// changes
+	SnapshotEvery int
+}
+
+// Open returns the store of opts, nil for Kind_Memory.
+func Open(opts Options) (locker.Store, error) {
+	switch opts.Kind {
+	case Kind_Memory:
+		return nil, nil
+	case Kind_Wal:
+		wal, err := OpenWal(opts.Dir, opts.Sync, opts.SnapshotEvery)
+		if err != nil {
+			return nil, err
+		}
+		return wal, nil
+	case Kind_Bolt:
+		db, err := OpenBolt(opts.Dir, opts.Sync)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	return nil, fmt.Errorf("unknown state store %q, use wal, bolt or leave it empty", opts.Kind)
+}
+
+type lockId struct {
+	namespace string
+	key       string
+}
+
+// heldLocks is the state both stores rebuild while loading.
+type heldLocks struct {
+	leaseSeq uint64
+	locks    map[lockId]locker.HeldLock
+}
+
+func newHeldLocks() *heldLocks {
+	return &heldLocks{locks: make(map[lockId]locker.HeldLock)}
+}
+
+func (h *heldLocks) hold(lock locker.HeldLock) {
+	h.locks[lockId{lock.Namespace, lock.Key}] = lock
+	h.leaseSeq = max(h.leaseSeq, lock.LeaseId)
+}
+
+func (h *heldLocks) release(namespace, key string) {
+	delete(h.locks, lockId{namespace, key})
+}
+
+// state copies h, sorted so that snapshots of the same locks are equal.
+func (h *heldLocks) state() locker.State {
+	state := locker.State{LeaseSeq: h.leaseSeq, Locks: make([]locker.HeldLock, 0, len(h.locks))}
+	for _, lock := range h.locks {
+		state.Locks = append(state.Locks, lock)
+	}
+	sort.Slice(state.Locks, func(i, j int) bool {
+		a, b := state.Locks[i], state.Locks[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Key < b.Key
+	})
+	return state
+}
@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"sharelock/pkg/locker"
+)
+
+// runLocker starts a locker restored from s and returns a stop func,
+// which stops the loop and closes s.
+func runLocker(t *testing.T, s locker.Store) (*locker.Locker, func()) {
+	t.Helper()
+	l := locker.NewLocker(locker.WithStore(s), locker.WithDefaultLease(time.Minute))
+	err := l.Restore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.Start(ctx)
+	}()
+	stopped := false
+	stop := func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		cancel()
+		<-done
+		err := s.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	t.Cleanup(stop)
+	return l, stop
+}
+
+func request(t *testing.T, call func(*locker.Client), id, key string, lease time.Duration) locker.Status {
+	t.Helper()
+	client := &locker.Client{Ctx: context.Background(), Id: id, LockKey: key, Lease: lease, StatusChan: make(chan locker.Status, 1)}
+	call(client)
+	select {
+	case status := <-client.StatusChan:
+		return status
+	case <-time.After(time.Second * 5):
+		t.Fatalf("no answer for %s on %s", id, key)
+		return 0
+	}
+}
+
+func exportState(t *testing.T, l *locker.Locker) locker.State {
+	t.Helper()
+	state, err := l.Export(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(state.Locks, func(i, j int) bool { return state.Locks[i].Key < state.Locks[j].Key })
+	return state
+}
+
+func TestRestart(t *testing.T) {
+	for _, kind := range []string{Kind_Wal, Kind_Bolt} {
+		t.Run(kind, func(t *testing.T) {
+			opts := Options{Kind: kind, Dir: t.TempDir(), Sync: true, SnapshotEvery: 3}
+			s, err := Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, stop := runLocker(t, s)
+			for _, lock := range []struct {
+				id, key string
+				lease   time.Duration
+			}{{"c1", "a", time.Minute}, {"c2", "b", 0}, {"c3", "c", time.Hour}} {
+				if status := request(t, l.Lock, lock.id, lock.key, lock.lease); status != locker.Status_Locked {
+					t.Fatalf("lock %s : %v", lock.key, status)
+				}
+			}
+			if status := request(t, l.Unlock, "c2", "b", 0); status != locker.Status_Unlocked {
+				t.Fatalf("unlock b : %v", status)
+			}
+			// a renewal takes a new lease id and deadline
+			if status := request(t, l.Renew, "c1", "a", time.Minute*2); status != locker.Status_Renewed {
+				t.Fatalf("renew a : %v", status)
+			}
+			before := exportState(t, l)
+			stop()
+
+			s, err = Open(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l, _ = runLocker(t, s)
+			after := exportState(t, l)
+			if after.LeaseSeq != before.LeaseSeq || len(after.Locks) != len(before.Locks) {
+				t.Fatalf("restored %+v, want %+v", after, before)
+			}
+			for i, lock := range after.Locks {
+				want := before.Locks[i]
+				if lock.Namespace != want.Namespace || lock.Key != want.Key || lock.Holder != want.Holder || lock.LeaseId != want.LeaseId ||
+					!lock.HeldSince.Equal(want.HeldSince) || !lock.LeaseExpiresAt.Equal(want.LeaseExpiresAt) {
+					t.Fatalf("restored lock %+v, want %+v", lock, want)
+				}
+			}
+
+			// the restored holder still holds, fencing keeps growing
+			if status := request(t, l.Renew, "c1", "a", time.Minute*2); status != locker.Status_Renewed {
+				t.Fatalf("renew a after the restart : %v", status)
+			}
+			if status := request(t, l.Lock, "c4", "d", 0); status != locker.Status_Locked {
+				t.Fatalf("lock d : %v", status)
+			}
+			for _, lock := range exportState(t, l).Locks {
+				if lock.Key == "a" && lock.Holder != "c1" {
+					t.Fatalf("a is held by %q after the restart, want c1", lock.Holder)
+				}
+				if lock.Key == "d" && lock.LeaseId <= before.LeaseSeq {
+					t.Fatalf("new lease id %d, want above %d", lock.LeaseId, before.LeaseSeq)
+				}
+			}
+		})
+	}
+}
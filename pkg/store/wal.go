@@ -0,0 +1,454 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"sharelock/pkg/locker"
+)
+
+const (
+	walPrefix      = "wal-"
+	walSuffix      = ".log"
+	snapshotPrefix = "snapshot-"
+	snapshotSuffix = ".json"
+)
+
+const (
+	op_Hold    = "hold"
+	op_Release = "release"
+)
+
+// walEntry is one change of the log. Each line of a segment is the crc32
+// of the entry in hex, a space and the entry in json.
+type walEntry struct {
+	Op        string      `json:"op"`
+	Lock      *lockRecord `json:"lock,omitempty"`
+	Namespace string      `json:"namespace,omitempty"`
+	Key       string      `json:"key,omitempty"`
+}
+
+type snapshotFile struct {
+	LeaseSeq uint64       `json:"lease_seq"`
+	Locks    []lockRecord `json:"locks"`
+}
+
+// Wal is a locker.Store appending every change to a log. Every
+// snapshotEvery changes it starts a new segment and writes the state
+// as a snapshot in the background, then removes the older files.
+//
+// Segment N and snapshot N share their number: snapshot N holds every
+// change of the segments below N, so loading reads the newest snapshot
+// and replays the segments from its number on.
+type Wal struct {
+	dir           string
+	sync          bool
+	snapshotEvery int
+
+	held    *heldLocks
+	segment uint64
+	file    *os.File
+	w       *bufio.Writer
+	changes int
+	line    bytes.Buffer
+	// size is the length of the segment up to its last whole entry
+	size int64
+	// torn is set when a failed append could not be rewound, the next
+	// append rewinds first
+	torn bool
+
+	// snapshotting is set while a snapshot is written, compactions are
+	// skipped until it is done
+	snapshotting atomic.Bool
+	snapshots    sync.WaitGroup
+}
+
+// OpenWal loads the store kept in dir and compacts it into a snapshot.
+// A torn entry at the end of the last segment, left by a crash, is
+// dropped; a broken entry anywhere else is an error.
+func OpenWal(dir string, sync bool, snapshotEvery int) (*Wal, error) {
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, fmt.Errorf("creating state dir : %w", err)
+	}
+	w := &Wal{
+		dir:           dir,
+		sync:          sync,
+		snapshotEvery: snapshotEvery,
+		held:          newHeldLocks(),
+	}
+
+	snapshots, err := w.list(snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var from uint64
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		err = w.readSnapshot(snapshots[i])
+		if err == nil {
+			from = snapshots[i]
+			break
+		}
+		slog.Warn("skipping unreadable state snapshot", "file", w.path(snapshotPrefix, snapshotSuffix, snapshots[i]), "err", err)
+		w.held = newHeldLocks()
+	}
+
+	segments, err := w.list(walPrefix, walSuffix)
+	if err != nil {
+		return nil, err
+	}
+	next := max(from, 1)
+	for i, n := range segments {
+		next = max(next, n+1)
+		if n < from {
+			continue
+		}
+		err = w.replay(n, i == len(segments)-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = w.openSegment(next)
+	if err != nil {
+		return nil, err
+	}
+	err = w.writeSnapshot(next, w.held.state())
+	if err != nil {
+		w.file.Close()
+		return nil, err
+	}
+	w.removeBefore(next)
+	return w, nil
+}
+
+func (w *Wal) path(prefix, suffix string, n uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", prefix, n, suffix))
+}
+
+// list returns the numbers of the files named prefix<N>suffix, sorted.
+func (w *Wal) list(prefix, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading state dir : %w", err)
+	}
+	numbers := make([]uint64, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
+
+func (w *Wal) readSnapshot(n uint64) error {
+	data, err := os.ReadFile(w.path(snapshotPrefix, snapshotSuffix, n))
+	if err != nil {
+		return err
+	}
+	var snapshot snapshotFile
+	err = json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return err
+	}
+	w.held.leaseSeq = snapshot.LeaseSeq
+	for _, record := range snapshot.Locks {
+		w.held.hold(record.heldLock())
+	}
+	return nil
+}
+
+func (w *Wal) replay(n uint64, last bool) error {
+	path := w.path(walPrefix, walSuffix, n)
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening state log : %w", err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		var entry walEntry
+		if err == nil {
+			err = decodeEntry(line, &entry)
+		} else if err == io.EOF {
+			err = errors.New("entry is not terminated")
+		}
+		if err != nil {
+			if last {
+				// only the end of the last segment may be torn, it was
+				// never reported to a client
+				slog.Warn("dropping the torn end of the state log", "file", path, "line", lineNumber, "err", err)
+				return nil
+			}
+			return fmt.Errorf("state log %s line %d : %w", path, lineNumber, err)
+		}
+		w.apply(entry)
+	}
+}
+
+func decodeEntry(line []byte, entry *walEntry) error {
+	sum, data, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+	if !ok {
+		return errors.New("missing checksum")
+	}
+	expected, err := strconv.ParseUint(string(sum), 16, 32)
+	if err != nil {
+		return errors.New("malformed checksum")
+	}
+	if crc32.ChecksumIEEE(data) != uint32(expected) {
+		return errors.New("checksum mismatch")
+	}
+	err = json.Unmarshal(data, entry)
+	if err != nil {
+		return err
+	}
+	switch {
+	case entry.Op == op_Hold && entry.Lock != nil:
+	case entry.Op == op_Release:
+	default:
+		return fmt.Errorf("unknown operation %q", entry.Op)
+	}
+	return nil
+}
+
+func (w *Wal) apply(entry walEntry) {
+	switch entry.Op {
+	case op_Hold:
+		w.held.hold(entry.Lock.heldLock())
+	case op_Release:
+		w.held.release(entry.Namespace, entry.Key)
+	}
+}
+
+func (w *Wal) openSegment(n uint64) error {
+	file, err := os.OpenFile(w.path(walPrefix, walSuffix, n), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating state log : %w", err)
+	}
+	w.segment = n
+	w.file = file
+	w.w = bufio.NewWriter(file)
+	w.changes = 0
+	w.size = 0
+	w.torn = false
+	return nil
+}
+
+// writeSnapshot saves state as snapshot n through a temporary file, so
+// that a crash leaves either the whole snapshot or none.
+func (w *Wal) writeSnapshot(n uint64, state locker.State) error {
+	snapshot := snapshotFile{LeaseSeq: state.LeaseSeq, Locks: make([]lockRecord, 0, len(state.Locks))}
+	for _, lock := range state.Locks {
+		snapshot.Locks = append(snapshot.Locks, toRecord(lock))
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encoding state snapshot : %w", err)
+	}
+	path := w.path(snapshotPrefix, snapshotSuffix, n)
+	tmp := path + ".tmp"
+	err = writeFileSync(tmp, data)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing state snapshot : %w", err)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing state snapshot : %w", err)
+	}
+	return syncDir(w.dir)
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// removeBefore deletes the snapshots and segments replaced by snapshot n.
+func (w *Wal) removeBefore(n uint64) {
+	for _, kind := range [][2]string{{snapshotPrefix, snapshotSuffix}, {walPrefix, walSuffix}} {
+		numbers, err := w.list(kind[0], kind[1])
+		if err != nil {
+			slog.Warn("removing old state files", "err", err)
+			return
+		}
+		for _, old := range numbers {
+			if old >= n {
+				break
+			}
+			err = os.Remove(w.path(kind[0], kind[1], old))
+			if err != nil {
+				slog.Warn("removing old state file", "err", err)
+			}
+		}
+	}
+}
+
+// Load implements locker.Store.
+func (w *Wal) Load() (locker.State, error) {
+	return w.held.state(), nil
+}
+
+// Hold implements locker.Store.
+func (w *Wal) Hold(lock locker.HeldLock) error {
+	record := toRecord(lock)
+	err := w.append(walEntry{Op: op_Hold, Lock: &record})
+	if err != nil {
+		return err
+	}
+	w.held.hold(lock)
+	w.compact()
+	return nil
+}
+
+// Release implements locker.Store.
+func (w *Wal) Release(namespace, key string) error {
+	err := w.append(walEntry{Op: op_Release, Namespace: namespace, Key: key})
+	if err != nil {
+		return err
+	}
+	w.held.release(namespace, key)
+	w.compact()
+	return nil
+}
+
+func (w *Wal) append(entry walEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding state log entry : %w", err)
+	}
+	if w.torn {
+		err = w.rewind()
+		if err != nil {
+			return fmt.Errorf("writing state log : %w", err)
+		}
+	}
+	w.line.Reset()
+	fmt.Fprintf(&w.line, "%08x ", crc32.ChecksumIEEE(data))
+	w.line.Write(data)
+	w.line.WriteByte('\n')
+	_, err = w.w.Write(w.line.Bytes())
+	if err == nil {
+		err = w.w.Flush()
+	}
+	if err == nil && w.sync {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		// the writer keeps its error and the segment may end with a part
+		// of the entry, the next append would fail or tear the log
+		rewindErr := w.rewind()
+		if rewindErr != nil {
+			slog.Warn("rewinding state log after a failed write", "err", rewindErr)
+		}
+		return fmt.Errorf("writing state log : %w", err)
+	}
+	w.size += int64(w.line.Len())
+	w.changes++
+	return nil
+}
+
+// rewind drops what a failed append left of its entry, in the writer
+// and in the segment, so that the next entry starts a whole line.
+func (w *Wal) rewind() error {
+	w.w.Reset(w.file)
+	err := w.file.Truncate(w.size)
+	if err == nil {
+		_, err = w.file.Seek(w.size, io.SeekStart)
+	}
+	w.torn = err != nil
+	return err
+}
+
+// compact starts a new segment and snapshots the state in the
+// background once snapshotEvery changes were appended. Failures are only
+// logged, the log keeps every change and the next change tries again.
+func (w *Wal) compact() {
+	if w.snapshotEvery <= 0 || w.changes < w.snapshotEvery || w.snapshotting.Load() {
+		return
+	}
+	old := w.file
+	err := w.openSegment(w.segment + 1)
+	if err != nil {
+		slog.Error("starting a new state log segment", "err", err)
+		return
+	}
+	err = old.Close()
+	if err != nil {
+		slog.Warn("closing state log segment", "err", err)
+	}
+	n, state := w.segment, w.held.state()
+	w.snapshotting.Store(true)
+	w.snapshots.Add(1)
+	go func() {
+		defer w.snapshots.Done()
+		defer w.snapshotting.Store(false)
+		err := w.writeSnapshot(n, state)
+		if err != nil {
+			slog.Error("writing state snapshot", "err", err)
+			return
+		}
+		w.removeBefore(n)
+		slog.Debug("compacted state log", "snapshot", n, "locks", len(state.Locks))
+	}()
+}
+
+// Close waits for a running snapshot and closes the log. It must not
+// be called before the locker loop stopped.
+func (w *Wal) Close() error {
+	w.snapshots.Wait()
+	err := w.w.Flush()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	closeErr := w.file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
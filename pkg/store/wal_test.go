@@ -0,0 +1,227 @@
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"sharelock/pkg/locker"
+)
+
+func TestWalAppendAfterWriteError(t *testing.T) {
+	for _, sync := range []bool{false, true} {
+		t.Run(map[bool]string{false: "buffered", true: "synced"}[sync], func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := OpenWal(dir, sync, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hold := func(key string) error {
+				return w.Hold(locker.HeldLock{Namespace: "default", Key: key, Holder: "client", LeaseId: 1, HeldSince: time.Unix(1, 0).UTC()})
+			}
+			if err = hold("a"); err != nil {
+				t.Fatal(err)
+			}
+
+			// a read only handle fails the writes, and the rewinds too
+			writable := w.file
+			readOnly, err := os.Open(w.path(walPrefix, walSuffix, w.segment))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.file, w.w = readOnly, bufio.NewWriter(readOnly)
+			for _, key := range []string{"b", "c"} {
+				if err = hold(key); err == nil {
+					t.Fatalf("hold %s: the write to a read only file succeeded", key)
+				}
+			}
+			// the next append rewinds the writer onto the file
+			w.file = writable
+			readOnly.Close()
+			if err = hold("d"); err != nil {
+				t.Fatalf("hold after the file is writable again: %v", err)
+			}
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := OpenWal(dir, sync, 0)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			defer reopened.Close()
+			state, _ := reopened.Load()
+			var keys []string
+			for _, lock := range state.Locks {
+				keys = append(keys, lock.Key)
+			}
+			sort.Strings(keys)
+			if len(keys) != 2 || keys[0] != "a" || keys[1] != "d" {
+				t.Fatalf("reopened locks %v, want [a d]", keys)
+			}
+		})
+	}
+}
+
+func testLock(key string, leaseId uint64) locker.HeldLock {
+	return locker.HeldLock{Namespace: "default", Key: key, Holder: "client", LeaseId: leaseId, HeldSince: time.Unix(1, 0).UTC()}
+}
+
+func heldKeys(t *testing.T, s locker.Store) []string {
+	t.Helper()
+	state, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(state.Locks))
+	for _, lock := range state.Locks {
+		keys = append(keys, lock.Key)
+	}
+	return keys
+}
+
+func TestWalCompactWhileSnapshotting(t *testing.T) {
+	dir := t.TempDir()
+	w, err := OpenWal(dir, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := w.segment
+
+	// a snapshot in progress holds back the compactions
+	w.snapshotting.Store(true)
+	for i, key := range []string{"a", "b", "c", "d"} {
+		if err = w.Hold(testLock(key, uint64(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.segment != first {
+		t.Fatalf("compacted into segment %d while a snapshot was written", w.segment)
+	}
+	w.snapshotting.Store(false)
+	if err = w.Release("default", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if w.segment != first+1 {
+		t.Fatalf("segment %d, want the compaction into %d once the snapshot is done", w.segment, first+1)
+	}
+
+	// the changes keep coming while the snapshots run
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("k%03d", i%20)
+		if i%3 == 0 {
+			err = w.Release("default", key)
+		} else {
+			err = w.Hold(testLock(key, uint64(i+10)))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := heldKeys(t, w)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenWal(dir, false, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if got := heldKeys(t, reopened); !slices.Equal(got, want) {
+		t.Fatalf("reopened locks %v, want %v", got, want)
+	}
+	state, _ := reopened.Load()
+	if state.LeaseSeq != 209 {
+		t.Fatalf("lease seq %d, want 209", state.LeaseSeq)
+	}
+	snapshots, err := reopened.list(snapshotPrefix, snapshotSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("snapshots %v left after opening, want only the newest", snapshots)
+	}
+}
+
+func TestWalTornTail(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+		// middle puts the tail in a segment followed by another one
+		middle  bool
+		wantErr bool
+	}{
+		{name: "unterminated", tail: `1234abcd {"op":"hold"`},
+		{name: "checksum mismatch", tail: "00000000 {\"op\":\"release\",\"namespace\":\"default\",\"key\":\"a\"}\n"},
+		{name: "no checksum", tail: "garbage\n"},
+		{name: "torn middle segment", tail: `1234abcd {"op":"hold"`, middle: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := OpenWal(dir, false, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, key := range []string{"a", "b"} {
+				if err = w.Hold(testLock(key, uint64(i+1))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			segment := w.segment
+			if err = w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			file, err := os.OpenFile(w.path(walPrefix, walSuffix, segment), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = file.WriteString(tt.tail)
+			file.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.middle {
+				err = os.WriteFile(w.path(walPrefix, walSuffix, segment+1), nil, 0o600)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			reopened, err := OpenWal(dir, false, 0)
+			if tt.wantErr {
+				if err == nil {
+					reopened.Close()
+					t.Fatal("opened a log broken before its last segment")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := heldKeys(t, reopened); !slices.Equal(got, []string{"a", "b"}) {
+				t.Fatalf("reopened locks %v, want [a b]", got)
+			}
+			// the torn end is gone from the new segment
+			if err = reopened.Hold(testLock("c", 3)); err != nil {
+				t.Fatal(err)
+			}
+			if err = reopened.Close(); err != nil {
+				t.Fatal(err)
+			}
+			again, err := OpenWal(dir, false, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer again.Close()
+			if got := heldKeys(t, again); !slices.Equal(got, []string{"a", "b", "c"}) {
+				t.Fatalf("locks after a second restart %v, want [a b c]", got)
+			}
+		})
+	}
+}
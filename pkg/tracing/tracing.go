@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the spans ShareLock creates itself.
+const TracerName = "sharelock"
+
+const (
+	Exporter_None   = ""
+	Exporter_Otlp   = "otlp"
+	Exporter_Stdout = "stdout"
+)
+
+type Options struct {
+	// Exporter is Exporter_Otlp, Exporter_Stdout or Exporter_None
+	Exporter string
+	// OtlpEndpoint is the host:port of the collector, empty uses the
+	// OTEL_EXPORTER_OTLP_ENDPOINT variable or localhost:4317
+	OtlpEndpoint string
+	// OtlpInsecure sends the spans without TLS
+	OtlpInsecure bool
+	// SampleRatio is the share of new traces recorded, incoming ones
+	// keep the decision of their parent
+	SampleRatio float64
+	ServiceName string
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// and baggage propagators. The returned func flushes the spans still
+// buffered, it must be called before exiting. Without an exporter the
+// propagators are installed but no span is recorded.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch opts.Exporter {
+	case Exporter_None:
+		return func(context.Context) error { return nil }, nil
+	case Exporter_Otlp:
+		clientOpts := make([]otlptracegrpc.Option, 0)
+		if opts.OtlpEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracegrpc.WithEndpoint(opts.OtlpEndpoint))
+		}
+		if opts.OtlpInsecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(ctx, clientOpts...)
+	case Exporter_Stdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, use otlp or stdout", opts.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating %s trace exporter : %w", opts.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(opts.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating trace resource : %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Tracer creates the spans of ShareLock with the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"sharelock/pkg/cluster"
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/codes"
+)
+
+// GetCluster lists the members of the cluster as this node sees them.
+func (s *LockService) GetCluster(ctx context.Context, meta RequestMeta, r *sharelockPB.GetClusterRequest) (resp *sharelockPB.ClusterInfo, err error) {
+	ctx, finish := s.begin(ctx, "GetCluster", meta, "")
+	defer finish(&err)
+	if s.cluster == nil {
+		return nil, helpers.Err_Srv_MethodNotAllowed
+	}
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	return s.clusterInfo()
+}
+
+// AddMember adds a voter to the cluster. The new node must be started
+// without bootstrap, it then catches up with the leader.
+func (s *LockService) AddMember(ctx context.Context, meta RequestMeta, r *sharelockPB.AddMemberRequest) (resp *sharelockPB.ClusterInfo, err error) {
+	ctx, finish := s.begin(ctx, "AddMember", meta, "")
+	defer finish(&err)
+	if s.cluster == nil {
+		return nil, helpers.Err_Srv_MethodNotAllowed
+	}
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	if r.GetId() == "" || r.GetRaftAddress() == "" {
+		return nil, helpers.Err_Srv_InvalidMember
+	}
+	err = s.cluster.AddMember(cluster.Member{
+		Id:          r.Id,
+		RaftAddress: r.RaftAddress,
+		GrpcAddress: r.GrpcAddress,
+		HttpAddress: r.HttpAddress,
+	})
+	if err != nil {
+		return nil, s.clusterChangeError(ctx, err)
+	}
+	helpers.Logger(ctx).Warn("cluster member added", "member", r.Id, "raft_address", r.RaftAddress)
+	return s.clusterInfo()
+}
+
+// RemoveMember removes a node from the cluster, the leader included.
+func (s *LockService) RemoveMember(ctx context.Context, meta RequestMeta, r *sharelockPB.RemoveMemberRequest) (resp *sharelockPB.ClusterInfo, err error) {
+	ctx, finish := s.begin(ctx, "RemoveMember", meta, "")
+	defer finish(&err)
+	if s.cluster == nil {
+		return nil, helpers.Err_Srv_MethodNotAllowed
+	}
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	if r.GetId() == "" {
+		return nil, helpers.Err_Srv_InvalidMember
+	}
+	err = s.cluster.RemoveMember(r.Id)
+	if err != nil {
+		return nil, s.clusterChangeError(ctx, err)
+	}
+	helpers.Logger(ctx).Warn("cluster member removed", "member", r.Id)
+	return s.clusterInfo()
+}
+
+// TransferLeadership hands the lead to another voter, before a planned
+// restart of the leader.
+func (s *LockService) TransferLeadership(ctx context.Context, meta RequestMeta, r *sharelockPB.TransferLeadershipRequest) (resp *sharelockPB.ClusterInfo, err error) {
+	ctx, finish := s.begin(ctx, "TransferLeadership", meta, "")
+	defer finish(&err)
+	if s.cluster == nil {
+		return nil, helpers.Err_Srv_MethodNotAllowed
+	}
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	err = s.cluster.TransferLeadership()
+	if err != nil {
+		return nil, s.clusterChangeError(ctx, err)
+	}
+	helpers.Logger(ctx).Warn("cluster leadership transferred")
+	return s.clusterInfo()
+}
+
+// admitAdmin checks the acl policy and the request rate of an admin
+// call. Only an authenticated caller with an admin grant passes, without
+// a policy nobody does.
+func (s *LockService) admitAdmin(meta RequestMeta) error {
+	if s.policy == nil || !s.policy.AllowedAdmin(meta.Principal) {
+		return helpers.Err_Srv_PermissionDenied
+	}
+	return s.rateLimit(meta)
+}
+
+func (s *LockService) clusterChangeError(ctx context.Context, err error) error {
+	if errors.Is(err, locker.ErrNotLeader) {
+		return s.notLeaderError()
+	}
+	helpers.Logger(ctx).Warn("cluster change failed", "err", err)
+	return helpers.NewError(codes.FailedPrecondition, helpers.Reason_ClusterChange, err.Error())
+}
+
+func (s *LockService) clusterInfo() (*sharelockPB.ClusterInfo, error) {
+	members, err := s.cluster.Members()
+	if err != nil {
+		return nil, helpers.NewError(codes.Unavailable, helpers.Reason_ClusterChange, err.Error())
+	}
+	info := &sharelockPB.ClusterInfo{NodeId: s.cluster.Id()}
+	for _, member := range members {
+		info.Members = append(info.Members, &sharelockPB.ClusterMember{
+			Id:          member.Id,
+			RaftAddress: member.RaftAddress,
+			GrpcAddress: member.GrpcAddress,
+			HttpAddress: member.HttpAddress,
+			Voter:       member.Voter,
+			Leader:      member.Leader,
+		})
+	}
+	return info, nil
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"testing"
+
+	"sharelock/pkg/auth"
+	"sharelock/pkg/helpers"
+)
+
+func TestAdmitAdmin(t *testing.T) {
+	policy, err := auth.NewPolicy([]auth.PolicyRule{
+		{Principals: []string{"ops"}, Keys: []string{"**"}, Actions: []auth.Action{auth.Action_Admin}},
+		{Principals: []string{"*"}, Keys: []string{"**"}, Actions: []auth.Action{auth.Action_Lock, auth.Action_Unlock}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		// withoutStore runs the service without WithPolicy
+		withoutStore bool
+		policy       *auth.Policy
+		principal    string
+		wantErr      error
+	}{
+		{name: "no policy store", withoutStore: true, principal: "ops", wantErr: helpers.Err_Srv_PermissionDenied},
+		{name: "no policy", policy: nil, principal: "ops", wantErr: helpers.Err_Srv_PermissionDenied},
+		{name: "unauthenticated", policy: policy, principal: "", wantErr: helpers.Err_Srv_PermissionDenied},
+		{name: "no admin grant", policy: policy, principal: "team-a", wantErr: helpers.Err_Srv_PermissionDenied},
+		{name: "admin grant", policy: policy, principal: "ops"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []ServiceOption
+			if !tt.withoutStore {
+				store := &auth.PolicyStore{}
+				store.Set(tt.policy)
+				opts = append(opts, WithPolicy(store))
+			}
+			s := NewLockService(nil, opts...)
+			defer s.Close()
+			err := s.admitAdmin(RequestMeta{Principal: tt.principal, Namespace: "default"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sharelock/pkg/helpers"
+
+	"golang.org/x/time/rate"
+)
+
+// AdmissionLimits keep one client from flooding the locker, zero
+// disables a limit. Held locks per client are capped by the locker, see
+// locker.WithMaxLocksPerClient.
+type AdmissionLimits struct {
+	// ClientRate is the request rate of one client id
+	ClientRate RateLimit
+	// AddressRate is the request rate of one source address, whatever
+	// client ids it sends
+	AddressRate RateLimit
+	// MaxPendingPerClient is the number of unfinished requests of one
+	// client id, most of them waiting for a lock
+	MaxPendingPerClient int
+	MaxKeyLength        int
+}
+
+// WithAdmissionLimits rejects requests over limits with
+// ResourceExhausted and a retry hint.
+func WithAdmissionLimits(limits AdmissionLimits) ServiceOption {
+	return func(s *LockService) {
+		s.SetAdmissionLimits(limits)
+	}
+}
+
+// SetAdmissionLimits replaces the admission limits of the service. The
+// rate buckets keep their tokens and pending requests stay counted.
+func (s *LockService) SetAdmissionLimits(limits AdmissionLimits) {
+	s.admission.limits.Store(&limits)
+}
+
+type admission struct {
+	limits       atomic.Pointer[AdmissionLimits]
+	clientRates  *rateLimiters
+	addressRates *rateLimiters
+
+	mu      sync.Mutex
+	pending map[string]int
+}
+
+func newAdmission(limits AdmissionLimits) *admission {
+	a := &admission{
+		pending: make(map[string]int),
+	}
+	a.limits.Store(&limits)
+	a.clientRates = newRateLimiters(func(string) RateLimit { return a.limits.Load().ClientRate })
+	a.addressRates = newRateLimiters(func(string) RateLimit { return a.limits.Load().AddressRate })
+	return a
+}
+
+func (a *admission) checkKey(key string) error {
+	max := a.limits.Load().MaxKeyLength
+	if max > 0 && len(key) > max {
+		return helpers.Err_Srv_KeyTooLong
+	}
+	return nil
+}
+
+// reserve takes a token of the source address and of the client of
+// meta, see takeTokens.
+func (a *admission) reserve(meta RequestMeta, now time.Time) []*rate.Reservation {
+	var reservations []*rate.Reservation
+	if meta.RemoteAddr != "" {
+		reservations = append(reservations, a.addressRates.reserve(meta.RemoteAddr, now))
+	}
+	if meta.ClientId != "" {
+		reservations = append(reservations, a.clientRates.reserve(meta.ClientId, now))
+	}
+	return reservations
+}
+
+// enter counts a request of meta.ClientId as pending until the returned
+// func is called. Forwarded requests were counted by the node that
+// forwarded them.
+func (a *admission) enter(meta RequestMeta) (func(), error) {
+	max := a.limits.Load().MaxPendingPerClient
+	if max <= 0 || meta.ClientId == "" || meta.Hops > 0 {
+		return func() {}, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending[meta.ClientId] >= max {
+		return nil, helpers.WithRetryDelay(helpers.Err_Srv_TooManyPending, time.Second)
+	}
+	a.pending[meta.ClientId]++
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.pending[meta.ClientId]--
+		if a.pending[meta.ClientId] <= 0 {
+			delete(a.pending, meta.ClientId)
+		}
+	}, nil
+}
+
+// remoteHost is the address of a peer without its port.
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+
+	"sharelock/pkg/audit"
+	"sharelock/pkg/helpers"
+)
+
+// deniedReasons are the refusals recorded in the audit log, the other
+// errors are mistakes of the caller or of the server.
+var deniedReasons = map[string]bool{
+	helpers.Reason_Unauthenticated:  true,
+	helpers.Reason_PermissionDenied: true,
+	helpers.Reason_QuotaExceeded:    true,
+	helpers.Reason_RateLimited:      true,
+	helpers.Reason_TooManyPending:   true,
+}
+
+// WithAuditLog records the refused requests in log, and passes the
+// source of every request on to the locker events log records.
+func WithAuditLog(log *audit.Log) ServiceOption {
+	return func(s *LockService) {
+		s.audit = log
+	}
+}
+
+func auditSource(meta RequestMeta) audit.Source {
+	return audit.Source{
+		Principal:  meta.Principal,
+		RemoteAddr: meta.RemoteAddr,
+		Transport:  meta.Transport,
+		RequestId:  meta.RequestId,
+	}
+}
+
+// withAuditSource lets the locker events caused by the request of ctx
+// name its source.
+func (s *LockService) withAuditSource(ctx context.Context, meta RequestMeta) context.Context {
+	if s.audit == nil {
+		return ctx
+	}
+	return audit.WithSource(ctx, auditSource(meta))
+}
+
+// auditDenied records a request refused with reason, when it is one of
+// deniedReasons.
+func (s *LockService) auditDenied(meta RequestMeta, operation, key, reason string) {
+	if s.audit == nil || !deniedReasons[reason] {
+		return
+	}
+	s.audit.Append(audit.Record{
+		Event:     audit.Event_Denied,
+		Operation: operation,
+		Reason:    reason,
+		Namespace: meta.Namespace,
+		Key:       key,
+		ClientId:  meta.ClientId,
+		Source:    auditSource(meta),
+	})
+}
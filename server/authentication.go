@@ -0,0 +1,212 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"strings"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// NewAuthenticator builds the authenticator described by cfg. It
+// returns nil when authentication is disabled, every request is then
+// trusted with the X-Client-Id it sends. Client certificates come first,
+// a caller that completed mutual TLS is known by its certificate.
+func NewAuthenticator(cfg *config.Auth) (auth.Authenticator, error) {
+	if cfg == nil || (!cfg.Enable && !cfg.ClientCertificates) {
+		return nil, nil
+	}
+	authenticators := make([]auth.Authenticator, 0)
+	if cfg.ClientCertificates {
+		authenticators = append(authenticators, auth.NewCertificateAuthenticator())
+	}
+	if !cfg.Enable {
+		return auth.NewChain(authenticators...), nil
+	}
+	if len(cfg.ApiKeys) > 0 {
+		apiKeys, err := auth.NewApiKeyAuthenticator(cfg.ApiKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, apiKeys)
+	}
+	if len(cfg.JwtHmacSecrets) > 0 || len(cfg.JwtPublicKeys) > 0 {
+		jwt, err := auth.NewJwtAuthenticator(auth.JwtConfig{
+			HmacSecrets:    cfg.JwtHmacSecrets,
+			PublicKeyFiles: cfg.JwtPublicKeys,
+			Issuer:         cfg.JwtIssuer,
+			Audience:       cfg.JwtAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwt)
+	}
+	if len(authenticators) == 0 {
+		return nil, errors.New("authentication is enabled without any credentials")
+	}
+	return auth.NewChain(authenticators...), nil
+}
+
+// unauthenticatedGrpcMethods may be called without credentials. The
+// internal calls between the nodes of a sharded deployment check the
+// secret of the nodes instead.
+var unauthenticatedGrpcMethods = map[string]bool{
+	"/sharelock.ShareLockService/Ping":          true,
+	"/grpc.health.v1.Health/Check":              true,
+	"/sharelock.ShareLockShard/Call":            true,
+	"/sharelock.ShareLockShard/HandOff":         true,
+	"/sharelock.ShareLockShard/GetShardStatus":  true,
+	"/sharelock.ShareLockShard/FinishRebalance": true,
+}
+
+// unauthenticatedHttpPaths may be called without credentials.
+var unauthenticatedHttpPaths = map[string]bool{
+	"/ping":            true,
+	"/v2/ping":         true,
+	"/v2/openapi.json": true,
+	"/healthz":         true,
+	"/readyz":          true,
+	"/metrics":         true,
+}
+
+// grpcAuthInterceptor rejects calls without valid credentials and
+// stores the principal in the context of the others. Rejected calls
+// are recorded in the audit log of service.
+func grpcAuthInterceptor(authenticator auth.Authenticator, service *LockService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := grpcAuthenticate(ctx, authenticator, service, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// grpcStreamAuthInterceptor is grpcAuthInterceptor for streaming calls,
+// such as server reflection, which would otherwise list every service
+// to anyone.
+func grpcStreamAuthInterceptor(authenticator auth.Authenticator, service *LockService) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := grpcAuthenticate(stream.Context(), authenticator, service, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream carries the principal in its context.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthenticate returns ctx with the principal of the call to method,
+// or an error when its credentials are missing or invalid.
+func grpcAuthenticate(ctx context.Context, authenticator auth.Authenticator, service *LockService, method string) (context.Context, error) {
+	if unauthenticatedGrpcMethods[method] {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	creds := auth.CredentialsFromHeaders(firstMetadata(md, "Authorization"), firstMetadata(md, "X-Api-Key"))
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			creds.Certificate = verifiedLeaf(tlsInfo.State)
+		}
+	}
+	principal, err := authenticator.Authenticate(ctx, creds)
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			helpers.Logger(ctx).Warn("rejected credentials", "remote_addr", peerAddr(ctx), "err", err)
+		}
+		service.auditDenied(GetGrpcMetadata(ctx).RequestMeta(), method, "", helpers.Reason_Unauthenticated)
+		return ctx, helpers.Err_Srv_Unauthenticated
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// httpAuthMiddleware is grpcAuthInterceptor for the HTTP server.
+func httpAuthMiddleware(authenticator auth.Authenticator, service *LockService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedHttpPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		creds := auth.CredentialsFromHeaders(r.Header.Get("Authorization"), r.Header.Get("X-Api-Key"))
+		if r.TLS != nil {
+			creds.Certificate = verifiedLeaf(*r.TLS)
+		}
+		principal, err := authenticator.Authenticate(r.Context(), creds)
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				helpers.Logger(r.Context()).Warn("rejected credentials", "remote_addr", r.RemoteAddr, "err", err)
+			}
+			service.auditDenied(httpRequestMeta(r), r.Method+" "+r.URL.Path, "", helpers.Reason_Unauthenticated)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sharelock"`)
+			writeHttpError(w, r, helpers.Err_Srv_Unauthenticated)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// lockOwner is the id locks are held under. An authenticated principal
+// always owns its locks, a client id sent along only names a separate
+// owner below it, so one service can run several independent holders
+// but never act as another service. The principal name is escaped so
+// it never holds a "/", the first one always ends it: principal
+// spiffe://td/ns with client id team-a cannot pass for principal
+// spiffe://td/ns/team-a.
+func lockOwner(ctx context.Context, clientId string) string {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return clientId
+	}
+	name := ownerEscaper.Replace(principal.Name)
+	if clientId == "" {
+		return name
+	}
+	return name + "/" + clientId
+}
+
+var ownerEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+func firstMetadata(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown peer"
+	}
+	return p.Addr.String()
+}
+
+// verifiedLeaf is the client certificate of a connection, only when the
+// handshake verified it against the client CA bundle.
+func verifiedLeaf(state tls.ConnectionState) *x509.Certificate {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
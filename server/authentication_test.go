@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestReflectionNeedsCredentials(t *testing.T) {
+	authenticator, err := NewAuthenticator(&config.Auth{Enable: true, ApiKeys: map[string]string{"svc": "secret-key"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := startServers(t, authenticator)
+
+	tests := []struct {
+		name   string
+		apiKey string
+		want   codes.Code
+	}{
+		{"without credentials", "", codes.Unauthenticated},
+		{"with a wrong key", "wrong-key", codes.Unauthenticated},
+		{"with a valid key", "secret-key", codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			if tt.apiKey != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "X-Api-Key", tt.apiKey)
+			}
+			stream, err := reflectionpb.NewServerReflectionClient(s.conn).ServerReflectionInfo(ctx)
+			if err == nil {
+				err = stream.Send(&reflectionpb.ServerReflectionRequest{
+					MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+				})
+			}
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			if status.Code(err) != tt.want {
+				t.Fatalf("listing services got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockOwner(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal string
+		clientId  string
+		want      string
+	}{
+		{"without a principal", "", "client-1", "client-1"},
+		{"principal alone", "svc", "", "svc"},
+		{"principal and client id", "svc", "worker-1", "svc/worker-1"},
+		{"spiffe principal", "spiffe://td/ns", "", "spiffe:%2F%2Ftd%2Fns"},
+		{"spiffe principal and client id", "spiffe://td/ns", "team-a", "spiffe:%2F%2Ftd%2Fns/team-a"},
+		{"longer spiffe principal", "spiffe://td/ns/team-a", "", "spiffe:%2F%2Ftd%2Fns%2Fteam-a"},
+		{"escaped principal", "a%2Fb", "c", "a%252Fb/c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.principal != "" {
+				ctx = auth.WithPrincipal(ctx, auth.Principal{Name: tt.principal})
+			}
+			got := lockOwner(ctx, tt.clientId)
+			if got != tt.want {
+				t.Fatalf("lockOwner got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// a principal whose name goes on where another one's client id starts
+	// still owns other locks
+	short := lockOwner(auth.WithPrincipal(context.Background(), auth.Principal{Name: "spiffe://td/ns"}), "team-a")
+	long := lockOwner(auth.WithPrincipal(context.Background(), auth.Principal{Name: "spiffe://td/ns/team-a"}), "")
+	if short == long {
+		t.Fatalf("principals share the owner %q", short)
+	}
+}
+
+func TestCertificatePrincipal(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	certs := newTestTLS(t)
+	authenticator, err := NewAuthenticator(&config.Auth{ClientCertificates: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockerInstance := locker.NewLocker()
+	lockerDone := make(chan struct{})
+	go func() {
+		defer close(lockerDone)
+		lockerInstance.Start(ctx)
+	}()
+	service := NewLockService(lockerInstance)
+	srv := NewGrpcServer(ctx, certs.server(&config.Server{Enable: true, ServiceName: "grpc", ListenAddresses: []string{"127.0.0.1:0"}}), service, authenticator)
+	go srv.Start()
+	t.Cleanup(func() {
+		stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelStopCtx()
+		srv.Stop(stopCtx)
+		cancel()
+		<-lockerDone
+		service.Close()
+	})
+	addr := srv.(Listening).Addresses()[0]
+
+	untrusted := newTestTLS(t)
+	tests := []struct {
+		name string
+		// certificates the client presents
+		certificates []tls.Certificate
+		wantHolder   string
+		wantCode     codes.Code
+	}{
+		{name: "common name", certificates: []tls.Certificate{certs.issue(t, "worker")}, wantHolder: "worker"},
+		{name: "uri san first", certificates: []tls.Certificate{certs.issue(t, "worker", "spiffe://example.org/ns/team-a")},
+			wantHolder: "spiffe:%2F%2Fexample.org%2Fns%2Fteam-a"},
+		{name: "untrusted ca", certificates: []tls.Certificate{untrusted.issue(t, "worker")}, wantCode: codes.Unavailable},
+		{name: "no certificate", wantCode: codes.Unavailable},
+		{name: "no name", certificates: []tls.Certificate{certs.issue(t, "")}, wantCode: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := certs.client.Clone()
+			client.Certificates = tt.certificates
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(client)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			locks := sharelockPB.NewShareLockServiceClient(conn)
+			callCtx, cancelCall := context.WithTimeout(ctx, time.Second*5)
+			defer cancelCall()
+			key := strings.ReplaceAll(tt.name, " ", "-")
+			_, err = locks.Lock(callCtx, &sharelockPB.LockRequest{Key: key, TimeoutMs: 1000})
+			if tt.wantCode != codes.OK {
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("got %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := locks.GetLock(callCtx, &sharelockPB.GetLockRequest{Key: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.GetHolder() != tt.wantHolder {
+				t.Fatalf("holder %q, want %q", info.GetHolder(), tt.wantHolder)
+			}
+		})
+	}
+}
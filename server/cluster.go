@@ -0,0 +1,319 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sharelock/pkg/cluster"
+	"sharelock/pkg/helpers"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// forwardedHeader marks a call forwarded by a follower, the node
+	// receiving it answers it or fails, it never forwards it again
+	forwardedHeader = "X-Sharelock-Forwarded"
+	// leaderWait bounds the wait for an election when the call has no
+	// deadline of its own
+	leaderWait = time.Second * 10
+	// leaderPoll is how often a follower looks for a new leader
+	leaderPoll = time.Millisecond * 50
+)
+
+// forwardedGrpcMethods are answered by the leader only. Ping, health
+// and GetCluster are answered by every node.
+var forwardedGrpcMethods = map[string]bool{
+	"/sharelock.ShareLockService/Lock":             true,
+	"/sharelock.ShareLockService/Unlock":           true,
+	"/sharelock.ShareLockService/GetLock":          true,
+	"/sharelock.ShareLockService/RenewLock":        true,
+	"/sharelock.ShareLockService/GetNamespace":     true,
+	"/sharelock.ShareLockAdmin/AddMember":          true,
+	"/sharelock.ShareLockAdmin/RemoveMember":       true,
+	"/sharelock.ShareLockAdmin/TransferLeadership": true,
+	"/sharelock.ShareLockAdmin/ExportState":        true,
+	"/sharelock.ShareLockAdmin/ImportState":        true,
+	"/sharelock.ShareLockAdmin/ThawState":          true,
+}
+
+// unforwardedMetadata are set again by the connection to the leader.
+var unforwardedMetadata = map[string]bool{
+	"content-type": true,
+	"user-agent":   true,
+	"te":           true,
+	"traceparent":  true,
+	"tracestate":   true,
+	"baggage":      true,
+}
+
+// WithCluster runs the service as a node of a cluster: followers
+// forward gRPC calls to the leader, over TLS with forwardTLS unless it
+// is nil, and redirect HTTP requests to it.
+func WithCluster(node *cluster.Node, forwardTLS *tls.Config) ServiceOption {
+	return func(s *LockService) {
+		if node == nil {
+			return
+		}
+		s.cluster = node
+		s.forwarder = newForwarder(forwardTLS)
+	}
+}
+
+// Close releases the connections to other nodes.
+func (s *LockService) Close() {
+	if s.forwarder != nil {
+		s.forwarder.close()
+	}
+	if s.shards != nil {
+		s.shards.forwarder.close()
+	}
+}
+
+// leader waits until this node leads the cluster, self is then true, or
+// until another node leads it with a known gRPC and HTTP address.
+func (s *LockService) leader(ctx context.Context) (leader cluster.Member, self bool, err error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, leaderWait)
+		defer cancel()
+	}
+	ticker := time.NewTicker(leaderPoll)
+	defer ticker.Stop()
+	for {
+		if s.cluster.Leading() {
+			return cluster.Member{}, true, nil
+		}
+		leader, ok := s.cluster.Leader()
+		if ok && leader.Id != s.cluster.Id() && leader.GrpcAddress != "" {
+			return leader, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return cluster.Member{}, false, s.notLeaderError()
+		case <-ticker.C:
+		}
+	}
+}
+
+// notLeaderError tells the client to retry on the leader, whose
+// addresses are in the metadata of the error when known.
+func (s *LockService) notLeaderError() error {
+	if s.cluster == nil {
+		return helpers.WithRetryDelay(helpers.Err_Srv_NotLeader, time.Second)
+	}
+	leader, ok := s.cluster.Leader()
+	if !ok || leader.Id == s.cluster.Id() {
+		return helpers.WithRetryDelay(helpers.Err_Srv_NotLeader, time.Second)
+	}
+	return helpers.WithRetryDelay(helpers.NewErrorWithMetadata(codes.Unavailable, helpers.Reason_NotLeader,
+		"this node does not lead the cluster, retry on the leader", map[string]string{
+			"leader_id":           leader.Id,
+			"leader_grpc_address": leader.GrpcAddress,
+			"leader_http_address": leader.HttpAddress,
+		}), time.Second)
+}
+
+// grpcClusterForward answers the calls of forwardedGrpcMethods on the
+// leader and forwards them from followers, with the metadata of the
+// caller. The leader authenticates and checks forwarded calls itself.
+// A call that fails with Unavailable, because the leader went away or
+// stepped down, is sent again to the next leader for up to leaderWait: a
+// lock request then takes back the place its client had in the queue.
+// A client certificate cannot be passed on, calls with one get
+// NOT_LEADER and the address of the leader instead.
+func grpcClusterForward(service *LockService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if service.cluster == nil || !forwardedGrpcMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		if firstMetadata(md, forwardedHeader) != "" || hasClientCertificate(ctx) {
+			if !service.cluster.Leading() {
+				return nil, service.notLeaderError()
+			}
+			return handler(ctx, req)
+		}
+		outgoing := metadata.MD{}
+		for key, values := range md {
+			if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || unforwardedMetadata[key] {
+				continue
+			}
+			outgoing[key] = values
+		}
+		outgoing.Set(forwardedHeader, "1")
+		outgoing.Set(requestIdHeader, RequestIdFromContext(ctx))
+		forwardCtx := metadata.NewOutgoingContext(ctx, outgoing)
+
+		var failingSince time.Time
+		for {
+			leader, self, err := service.leader(ctx)
+			if err != nil {
+				return nil, err
+			}
+			var reply any
+			if self {
+				// the lead may be lost while the call waits in the queue
+				reply, err = handler(ctx, req)
+				if helpers.ErrorReason(err) != helpers.Reason_NotLeader {
+					return reply, err
+				}
+			} else {
+				reply, err = service.forwarder.invoke(forwardCtx, leader.GrpcAddress, info.FullMethod, req)
+				// a leader frozen by ExportState answers for good
+				if status.Code(err) != codes.Unavailable || helpers.ErrorReason(err) == helpers.Reason_ShuttingDown {
+					return reply, err
+				}
+				helpers.Logger(ctx).Debug("forwarding to the leader failed, retrying", "leader", leader.Id, "err", err)
+			}
+			if failingSince.IsZero() {
+				failingSince = time.Now()
+			} else if time.Since(failingSince) > leaderWait {
+				return nil, err
+			}
+			select {
+			case <-ctx.Done():
+				return nil, err
+			case <-time.After(leaderPoll):
+			}
+		}
+	}
+}
+
+func hasClientCertificate(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	return ok && len(tlsInfo.State.PeerCertificates) > 0
+}
+
+// httpClusterRedirect sends the lock requests a follower receives to
+// the leader with 307 Temporary Redirect, which keeps the method and
+// the body.
+func httpClusterRedirect(service *LockService, scheme string, next http.Handler) http.Handler {
+	if service.cluster == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !redirectedHttpPath(r.URL.Path) || service.cluster.Leading() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		leader, self, err := service.leader(r.Context())
+		if err != nil {
+			writeHttpError(w, r, err)
+			return
+		}
+		if self {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if leader.HttpAddress == "" {
+			writeHttpError(w, r, service.notLeaderError())
+			return
+		}
+		http.Redirect(w, r, scheme+"://"+leader.HttpAddress+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	})
+}
+
+func redirectedHttpPath(path string) bool {
+	switch path {
+	case "/lock", "/unlock":
+		return true
+	}
+	return strings.HasPrefix(path, "/v2/locks/") || strings.HasPrefix(path, "/v2/namespaces/")
+}
+
+// forwarder keeps one connection to every node calls were forwarded to.
+type forwarder struct {
+	creds credentials.TransportCredentials
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func newForwarder(tlsConfig *tls.Config) *forwarder {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	return &forwarder{creds: creds, conns: make(map[string]*grpc.ClientConn)}
+}
+
+func (f *forwarder) conn(address string) (*grpc.ClientConn, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	conn, ok := f.conns[address]
+	if ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(f.creds),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	f.conns[address] = conn
+	return conn, nil
+}
+
+// invoke calls method on the node at address and returns its reply.
+func (f *forwarder) invoke(ctx context.Context, address, method string, req any) (any, error) {
+	conn, err := f.conn(address)
+	if err != nil {
+		return nil, helpers.Err_Srv_NotLeader
+	}
+	reply, err := newReply(method)
+	if err != nil {
+		return nil, err
+	}
+	err = conn.Invoke(ctx, method, req, reply)
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (f *forwarder) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for address, conn := range f.conns {
+		conn.Close()
+		delete(f.conns, address)
+	}
+}
+
+// newReply is an empty reply message of a full gRPC method name.
+func newReply(method string) (proto.Message, error) {
+	name := protoreflect.FullName(strings.ReplaceAll(strings.TrimPrefix(method, "/"), "/", "."))
+	desc, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown method %s : %w", method, err)
+	}
+	methodDesc, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a method", method)
+	}
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(methodDesc.Output().FullName())
+	if err != nil {
+		return nil, err
+	}
+	return msgType.New().Interface(), nil
+}
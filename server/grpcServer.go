@@ -2,211 +2,242 @@ package server
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
 	"net"
-	"time"
+	"sync"
 
 	"sharelock/config"
-	"sharelock/pkg/helpers"
-	"sharelock/pkg/locker"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/listener"
 	"sharelock/pkg/sharelockPB"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
 )
 
 type GrpcServer struct {
 	sharelockPB.UnimplementedShareLockServiceServer
-	listener net.Listener
-	port     int
-	srv      *grpc.Server
-	locker   *locker.Locker
+	sharelockPB.UnimplementedShareLockAdminServer
+	sharelockPB.UnimplementedShareLockShardServer
+	listeners   []net.Listener
+	serviceName string
+	srv         *grpc.Server
+	service     *LockService
+	health      *grpcHealth
+	// tls is nil without tls
+	tls *tlsStore
 }
 
-func NewGrpcServer(ctx context.Context, cfg *config.Server, locker *locker.Locker) Server {
+// NewGrpcServer serves the lock service over gRPC. A nil authenticator
+// trusts the X-Client-Id metadata of every call.
+func NewGrpcServer(ctx context.Context, cfg *config.Server, service *LockService, authenticator auth.Authenticator) Server {
 	mck := NewMockServer()
 	if cfg == nil {
-		log.Printf("[ERROR] config is nil in server.NewGrpcServer")
+		slog.Error("config is nil in server.NewGrpcServer")
 		return mck
 	}
 	if !cfg.Enable {
 		return mck
 	}
-	if cfg.Port == 0 {
-		log.Printf("[ERROR] port is 0 in server.NewGrpcServer")
+	if cfg.Port == 0 && len(cfg.ListenAddresses) == 0 {
+		slog.Error("port is 0 in server.NewGrpcServer")
 		return mck
 	}
 	if cfg.TLS {
-		log.Printf("[INFO] tls is enabled for grpc server")
+		slog.Info("tls is enabled for grpc server")
 		if cfg.CertPath == "" {
-			log.Printf("[ERROR] cert path is empty in server.NewGrpcServer")
+			slog.Error("cert path is empty in server.NewGrpcServer")
 			return mck
 		}
 		if cfg.KeyPath == "" {
-			log.Printf("[ERROR] key path is empty in server.NewGrpcServer")
+			slog.Error("key path is empty in server.NewGrpcServer")
 			return mck
 		}
 	}
 
-	var srv *grpc.Server
-
+	// the request id comes first, so even rejected calls log it, followers
+	// forward calls before authenticating them, the leader does
+	opts := []grpc.ServerOption{grpcTracing(), grpc.ChainUnaryInterceptor(grpcRequestId, grpcClusterForward(service))}
+	var store *tlsStore
 	if cfg.TLS {
-		creds, err := credentials.NewServerTLSFromFile(
-			cfg.CertPath,
-			cfg.KeyPath,
-		)
+		var err error
+		store, err = newTLSStore(ctx, "grpc server "+cfg.ServiceName, cfg, nil)
 		if err != nil {
-			log.Print("[ERROR] creating grpc server tls : ", err)
+			slog.Error("creating grpc server tls", "err", err)
 			return mck
 		}
-		srv = grpc.NewServer(grpc.Creds(creds))
-	} else {
-		srv = grpc.NewServer()
+		if cfg.ClientCAPath != "" {
+			slog.Info("mutual tls is enabled for grpc server")
+		}
+		// credentials.NewTLS adds the h2 protocol gRPC negotiates
+		opts = append(opts, grpc.Creds(credentials.NewTLS(store.serverConfig())))
+	}
+	if authenticator != nil {
+		slog.Info("client authentication is enabled for grpc server")
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(grpcAuthInterceptor(authenticator, service)),
+			grpc.ChainStreamInterceptor(grpcStreamAuthInterceptor(authenticator, service)),
+		)
 	}
+	srv := grpc.NewServer(opts...)
 
-	lis, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", cfg.Port))
+	// the port alone listens on every IPv4 interface, as it always did
+	listeners, err := openListeners("grpc", cfg, "0.0.0.0:%d")
 	if err != nil {
-		log.Printf("[ERROR] creating tcp listener for grpc on port %d : %s", cfg.Port, err)
+		slog.Error("creating listeners for grpc server", "err", err)
 		return mck
 	}
 
 	grpcServer := &GrpcServer{
-		listener: lis,
-		port:     cfg.Port,
-		srv:      srv,
-		locker:   locker,
+		listeners:   listeners,
+		serviceName: cfg.ServiceName,
+		srv:         srv,
+		service:     service,
+		health:      newGrpcHealth(service),
+		tls:         store,
 	}
 
 	sharelockPB.RegisterShareLockServiceServer(srv, grpcServer)
+	sharelockPB.RegisterShareLockAdminServer(srv, grpcServer)
+	if service.shards != nil {
+		sharelockPB.RegisterShareLockShardServer(srv, grpcServer)
+	}
+	healthpb.RegisterHealthServer(srv, grpcServer.health)
+	reflection.Register(srv)
 
 	return grpcServer
 }
 
+func (g *GrpcServer) Addresses() []string {
+	return describeListeners(g.listeners)
+}
+
+func (g *GrpcServer) PrepareTLS(cfg *config.Server) (func(), error) {
+	if g.tls == nil {
+		return func() {}, nil
+	}
+	return g.tls.prepare(cfg)
+}
+
+// Start serves every listener and returns once they are all closed.
 func (g *GrpcServer) Start() {
-	log.Printf("[INFO] starting grpc server on port %d", g.port)
-	err := g.srv.Serve(g.listener)
-	if err != nil {
-		log.Printf("[ERROR] starting grpc server on port %d : %s", g.port, err)
-		return
+	var wg sync.WaitGroup
+	for _, lis := range g.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info("starting grpc server", "server", g.serviceName, "address", listener.Describe(lis))
+			err := g.srv.Serve(lis)
+			if err != nil {
+				slog.Error("starting grpc server", "server", g.serviceName, "address", listener.Describe(lis), "err", err)
+			}
+		}()
 	}
+	wg.Wait()
 }
 
-func (g *GrpcServer) Stop() {
-	log.Print("[INFO] stopping grpc server")
-	g.srv.Stop()
+func (g *GrpcServer) Stop(ctx context.Context) {
+	slog.Info("stopping grpc server", "server", g.serviceName)
+	g.health.stop()
+	stopped := make(chan struct{})
+	go func() {
+		g.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("grpc calls still running at the end of the shutdown timeout, closing them")
+		g.srv.Stop()
+	}
 }
 
 func (g *GrpcServer) Ping(ctx context.Context, r *sharelockPB.ShareLockPingRequest) (*sharelockPB.ShareLockPingResponse, error) {
-	return &sharelockPB.ShareLockPingResponse{
-		Message: "pong",
-	}, nil
+	resp, err := g.service.Ping(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Service = g.serviceName
+	return resp, nil
 }
 
 func (g *GrpcServer) Lock(ctx context.Context, r *sharelockPB.LockRequest) (*sharelockPB.LockResponse, error) {
-	if r == nil {
-		return nil, helpers.Err_Srv_NilRequest
-	}
-	if len(r.Key) == 0 {
-		return nil, helpers.Err_Srv_Request_KeyMissing
-	}
-	md := GetGrpcMetadata(ctx)
+	return g.service.Lock(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
 
-	expiryDeadline := time.Now().Add(time.Second * 10)
-	if r.TimeoutMs <= 0 {
-		expiry, ok := ctx.Deadline()
-		if ok {
-			expiryDeadline = expiry
-		}
-	}
+func (g *GrpcServer) Unlock(ctx context.Context, r *sharelockPB.UnlockRequest) (*sharelockPB.UnlockResponse, error) {
+	return g.service.Unlock(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
 
-	lockerCtx, cancelLockerCtx := context.WithDeadline(ctx, expiryDeadline)
-	defer cancelLockerCtx()
-
-	newClient := locker.Client{
-		Ctx:        lockerCtx,
-		Id:         md.ClientId,
-		LockKey:    r.Key,
-		StatusChan: make(chan locker.Status),
-	}
-	go g.locker.Lock(&newClient)
-
-listenerLoop:
-	for {
-		select {
-		case status := <-newClient.StatusChan:
-			switch status {
-			case locker.Status_Locked:
-				return &sharelockPB.LockResponse{
-					Status: sharelockPB.Status_Acquired,
-				}, nil
-			case locker.Status_Timeout:
-				break listenerLoop
-			case locker.Status_InvalidData:
-				return &sharelockPB.LockResponse{
-					Status: sharelockPB.Status_InvalidData,
-				}, nil
-			}
-		case <-ctx.Done():
-			break listenerLoop
-		}
-	}
+func (g *GrpcServer) GetLock(ctx context.Context, r *sharelockPB.GetLockRequest) (*sharelockPB.LockInfo, error) {
+	return g.service.GetLock(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
 
-	return &sharelockPB.LockResponse{
-		Status: sharelockPB.Status_Timeout,
-	}, nil
+func (g *GrpcServer) GetNamespace(ctx context.Context, r *sharelockPB.GetNamespaceRequest) (*sharelockPB.NamespaceInfo, error) {
+	return g.service.GetNamespace(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
 }
 
-func (g *GrpcServer) Unlock(ctx context.Context, r *sharelockPB.UnlockRequest) (*sharelockPB.UnlockResponse, error) {
-	if r == nil {
-		return nil, helpers.Err_Srv_NilRequest
-	}
-	if len(r.Key) == 0 {
-		return nil, helpers.Err_Srv_Request_KeyMissing
-	}
-	md := GetGrpcMetadata(ctx)
-
-	newClient := locker.Client{
-		Ctx:        ctx,
-		Id:         md.ClientId,
-		LockKey:    r.Key,
-		StatusChan: make(chan locker.Status),
-	}
-	go g.locker.Unlock(&newClient)
-
-listenerLoop:
-	for {
-		select {
-		case status := <-newClient.StatusChan:
-			switch status {
-			case locker.Status_Unlocked:
-				return &sharelockPB.UnlockResponse{
-					Status: sharelockPB.Status_Released,
-				}, nil
-			case locker.Status_Timeout:
-				break listenerLoop
-			case locker.Status_UnknownLock:
-				return &sharelockPB.UnlockResponse{
-					Status: sharelockPB.Status_UnknownLock,
-				}, nil
-			case locker.Status_InvalidData:
-				return &sharelockPB.UnlockResponse{
-					Status: sharelockPB.Status_InvalidData,
-				}, nil
-			}
-		case <-ctx.Done():
-			break listenerLoop
-		}
-	}
+func (g *GrpcServer) RenewLock(ctx context.Context, r *sharelockPB.RenewLockRequest) (*sharelockPB.RenewLockResponse, error) {
+	return g.service.RenewLock(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) GetCluster(ctx context.Context, r *sharelockPB.GetClusterRequest) (*sharelockPB.ClusterInfo, error) {
+	return g.service.GetCluster(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) AddMember(ctx context.Context, r *sharelockPB.AddMemberRequest) (*sharelockPB.ClusterInfo, error) {
+	return g.service.AddMember(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) RemoveMember(ctx context.Context, r *sharelockPB.RemoveMemberRequest) (*sharelockPB.ClusterInfo, error) {
+	return g.service.RemoveMember(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
 
-	return &sharelockPB.UnlockResponse{
-		Status: sharelockPB.Status_Timeout,
-	}, nil
+func (g *GrpcServer) TransferLeadership(ctx context.Context, r *sharelockPB.TransferLeadershipRequest) (*sharelockPB.ClusterInfo, error) {
+	return g.service.TransferLeadership(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
 }
 
+func (g *GrpcServer) ExportState(ctx context.Context, r *sharelockPB.ExportStateRequest) (*sharelockPB.StateSnapshot, error) {
+	return g.service.ExportState(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) ImportState(ctx context.Context, r *sharelockPB.ImportStateRequest) (*sharelockPB.ImportStateResponse, error) {
+	return g.service.ImportState(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) ThawState(ctx context.Context, r *sharelockPB.ThawStateRequest) (*sharelockPB.ThawStateResponse, error) {
+	return g.service.ThawState(ctx, GetGrpcMetadata(ctx).RequestMeta(), r)
+}
+
+func (g *GrpcServer) Call(ctx context.Context, r *sharelockPB.ShardCall) (*sharelockPB.ShardReply, error) {
+	return g.service.ShardCall(ctx, r)
+}
+
+func (g *GrpcServer) HandOff(ctx context.Context, r *sharelockPB.HandOffRequest) (*sharelockPB.HandOffResponse, error) {
+	return g.service.ShardHandOff(ctx, r)
+}
+
+func (g *GrpcServer) GetShardStatus(ctx context.Context, r *sharelockPB.GetShardStatusRequest) (*sharelockPB.ShardStatus, error) {
+	return g.service.ShardStatus(ctx)
+}
+
+func (g *GrpcServer) FinishRebalance(ctx context.Context, r *sharelockPB.FinishRebalanceRequest) (*sharelockPB.ShardStatus, error) {
+	return g.service.FinishRebalance(ctx)
+}
+
+// GrpcMetadata is read from the metadata of a call. ClientId is the
+// owner of the call's locks, see lockOwner.
 type GrpcMetadata struct {
-	ClientId string
+	ClientId       string
+	Principal      string
+	Namespace      string
+	RemoteAddr     string
+	IdempotencyKey string
+	RequestId      string
 }
 
 func GetGrpcMetadata(ctx context.Context) GrpcMetadata {
@@ -217,6 +248,34 @@ func GetGrpcMetadata(ctx context.Context) GrpcMetadata {
 		if len(clientId) > 0 {
 			grpcMetadata.ClientId = clientId[0]
 		}
+		namespace := md.Get("X-Namespace")
+		if len(namespace) > 0 {
+			grpcMetadata.Namespace = namespace[0]
+		}
+		idempotencyKey := md.Get("Idempotency-Key")
+		if len(idempotencyKey) > 0 {
+			grpcMetadata.IdempotencyKey = idempotencyKey[0]
+		}
+	}
+	grpcMetadata.ClientId = lockOwner(ctx, grpcMetadata.ClientId)
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		grpcMetadata.RemoteAddr = remoteHost(p.Addr.String())
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		grpcMetadata.Principal = principal.Name
 	}
+	grpcMetadata.RequestId = RequestIdFromContext(ctx)
 	return grpcMetadata
 }
+
+func (m GrpcMetadata) RequestMeta() RequestMeta {
+	return RequestMeta{
+		ClientId:       m.ClientId,
+		Principal:      m.Principal,
+		Namespace:      m.Namespace,
+		RemoteAddr:     m.RemoteAddr,
+		IdempotencyKey: m.IdempotencyKey,
+		RequestId:      m.RequestId,
+		Transport:      "grpc",
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// healthProbeTimeout bounds the round trip of a probe through the
+	// locker loop
+	healthProbeTimeout = time.Second * 2
+	// healthWatchInterval is how often a Watch call probes the locker
+	healthWatchInterval = time.Second
+)
+
+// Live returns an error unless the locker loop answers a round trip
+// within healthProbeTimeout.
+func (s *LockService) Live(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	err := s.locker.Ping(ctx)
+	if err != nil {
+		return helpers.Err_Srv_NotReady
+	}
+	return nil
+}
+
+// Ready is Live, and also fails while draining, so that load balancers
+// stop sending new locks before the servers shut down.
+func (s *LockService) Ready(ctx context.Context) error {
+	if s.draining.Load() {
+		return helpers.Err_Srv_ShuttingDown
+	}
+	return s.Live(ctx)
+}
+
+// grpcHealth implements grpc.health.v1 with the readiness of the lock
+// service, for the empty service name and for ShareLockService.
+type grpcHealth struct {
+	healthpb.UnimplementedHealthServer
+	service *LockService
+	// stopping ends the Watch calls, which would otherwise hold up
+	// GracefulStop until the shutdown timeout
+	stopping chan struct{}
+}
+
+func newGrpcHealth(service *LockService) *grpcHealth {
+	return &grpcHealth{
+		service:  service,
+		stopping: make(chan struct{}),
+	}
+}
+
+func (h *grpcHealth) known(name string) bool {
+	return name == "" || name == sharelockPB.ShareLockService_ServiceDesc.ServiceName
+}
+
+func (h *grpcHealth) status(ctx context.Context) healthpb.HealthCheckResponse_ServingStatus {
+	select {
+	case <-h.stopping:
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	default:
+	}
+	if h.service.Ready(ctx) != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	return healthpb.HealthCheckResponse_SERVING
+}
+
+func (h *grpcHealth) Check(ctx context.Context, r *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if !h.known(r.GetService()) {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", r.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: h.status(ctx)}, nil
+}
+
+// Watch sends the status right away and then on every change. An
+// unknown service is reported as SERVICE_UNKNOWN, as the protocol asks.
+func (h *grpcHealth) Watch(r *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	if !h.known(r.GetService()) {
+		return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVICE_UNKNOWN})
+	}
+	ticker := time.NewTicker(healthWatchInterval)
+	defer ticker.Stop()
+	last := healthpb.HealthCheckResponse_UNKNOWN
+	for {
+		current := h.status(stream.Context())
+		if current != last {
+			err := stream.Send(&healthpb.HealthCheckResponse{Status: current})
+			if err != nil {
+				return err
+			}
+			last = current
+		}
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-h.stopping:
+			return status.Error(codes.Unavailable, "server is stopping")
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop reports NOT_SERVING from now on and ends the Watch calls.
+func (h *grpcHealth) stop() {
+	select {
+	case <-h.stopping:
+	default:
+		close(h.stopping)
+	}
+}
+
+// Healthz is the liveness probe: it fails when the locker loop is
+// wedged, but not while draining.
+func (h *HttpServer) Healthz(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.service.Live(r.Context()))
+}
+
+// Readyz is the readiness probe: it fails when the locker loop is
+// wedged and while draining.
+func (h *HttpServer) Readyz(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, h.service.Ready(r.Context()))
+}
+
+func (h *HttpServer) writeHealth(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		writeHttpError(w, r, err)
+		return
+	}
+	writeHttpResponse(w, r, http.StatusOK, &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
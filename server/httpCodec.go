@@ -0,0 +1,139 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	contentTypeJson     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+
+	maxHttpBodyBytes = 1 << 20
+)
+
+var (
+	// the proto3 JSON mapping: enum names and lowerCamelCase field names
+	jsonMarshaler   = protojson.MarshalOptions{}
+	jsonUnmarshaler = protojson.UnmarshalOptions{DiscardUnknown: false}
+)
+
+// readHttpRequest decodes the request body into msg according to its
+// Content-Type. Binary protobuf is used for application/x-protobuf and
+// the proto3 JSON mapping for application/json or no Content-Type at
+// all, as the HTTP API never required one before. Other types are
+// refused, and so are unknown JSON fields. An empty body leaves msg
+// untouched.
+func readHttpRequest(r *http.Request, msg proto.Message) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxHttpBodyBytes+1))
+	if err != nil {
+		return helpers.Err_Srv_MalformedRequest
+	}
+	if len(body) > maxHttpBodyBytes {
+		return helpers.Err_Srv_RequestTooLarge
+	}
+	if len(body) == 0 {
+		return nil
+	}
+
+	protobuf, err := isProtobufRequest(r)
+	if err != nil {
+		return err
+	}
+	if protobuf {
+		err = proto.Unmarshal(body, msg)
+	} else {
+		err = jsonUnmarshaler.Unmarshal(body, msg)
+	}
+	if err != nil {
+		helpers.Logger(r.Context()).Info("decoding http request body", "err", err)
+		return helpers.Err_Srv_MalformedRequest
+	}
+	return nil
+}
+
+// writeHttpResponse encodes msg in the format negotiated from Accept.
+func writeHttpResponse(w http.ResponseWriter, r *http.Request, httpStatus int, msg proto.Message) {
+	var (
+		body []byte
+		err  error
+	)
+	contentType := responseContentType(r)
+	if contentType == contentTypeProtobuf {
+		body, err = proto.Marshal(msg)
+	} else {
+		body, err = jsonMarshaler.Marshal(msg)
+	}
+	if err != nil {
+		helpers.Logger(r.Context()).Error("encoding http response body", "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+func isProtobufRequest(r *http.Request) (bool, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return false, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, helpers.Err_Srv_UnsupportedMediaType
+	}
+	switch mediaType {
+	case contentTypeProtobuf, "application/protobuf":
+		return true, nil
+	case contentTypeJson:
+		return false, nil
+	}
+	return false, helpers.Err_Srv_UnsupportedMediaType
+}
+
+// checkHttpAccept refuses a request whose Accept header allows no
+// encoding of the responses, before anything is done for it. No Accept
+// header accepts anything.
+func checkHttpAccept(r *http.Request) error {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return nil
+	}
+	for _, accepted := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeJson, contentTypeProtobuf, "application/protobuf", "application/*", "*/*":
+			return nil
+		}
+	}
+	return helpers.Err_Srv_NotAcceptable
+}
+
+// responseContentType picks protobuf only when the client asks for it
+// explicitly, JSON stays the default.
+func responseContentType(r *http.Request) string {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case contentTypeProtobuf, "application/protobuf":
+			return contentTypeProtobuf
+		case contentTypeJson:
+			return contentTypeJson
+		}
+	}
+	return contentTypeJson
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHttpNegotiation(t *testing.T) {
+	s := startServers(t, nil)
+	jsonBody, err := protojson.Marshal(&sharelockPB.LockRequest{TimeoutMs: 1000, LeaseMs: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	protobufBody, err := proto.Marshal(&sharelockPB.LockRequest{TimeoutMs: 1000, LeaseMs: 60000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		accept      string
+		body        []byte
+		wantStatus  int
+		// wantType is the Content-Type of the response
+		wantType   string
+		wantReason string
+	}{
+		{name: "json", contentType: "application/json", body: jsonBody, wantStatus: http.StatusOK, wantType: contentTypeJson},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", body: jsonBody, wantStatus: http.StatusOK, wantType: contentTypeJson},
+		{name: "no content type is json", body: jsonBody, wantStatus: http.StatusOK, wantType: contentTypeJson},
+		{name: "protobuf both ways", contentType: contentTypeProtobuf, accept: contentTypeProtobuf, body: protobufBody, wantStatus: http.StatusOK, wantType: contentTypeProtobuf},
+		{name: "protobuf in json out", contentType: "application/protobuf", body: protobufBody, wantStatus: http.StatusOK, wantType: contentTypeJson},
+		{name: "json in protobuf out", contentType: "application/json", accept: "text/html, application/x-protobuf;q=0.9", body: jsonBody, wantStatus: http.StatusOK, wantType: contentTypeProtobuf},
+		{name: "any accepted", contentType: "application/json", accept: "*/*", body: jsonBody, wantStatus: http.StatusOK, wantType: contentTypeJson},
+		{name: "unknown field", contentType: "application/json", body: []byte(`{"timeoutMs": 1000, "waitForever": true}`),
+			wantStatus: http.StatusBadRequest, wantType: contentTypeJson, wantReason: "MALFORMED_REQUEST"},
+		{name: "go field name", contentType: "application/json", body: []byte(`{"TimeoutMs": 1000}`),
+			wantStatus: http.StatusBadRequest, wantType: contentTypeJson, wantReason: "MALFORMED_REQUEST"},
+		{name: "wrong type", contentType: "application/json", body: []byte(`{"timeoutMs": "soon"}`),
+			wantStatus: http.StatusBadRequest, wantType: contentTypeJson, wantReason: "MALFORMED_REQUEST"},
+		{name: "unsupported content type", contentType: "application/xml", body: []byte(`<lock/>`),
+			wantStatus: http.StatusUnsupportedMediaType, wantType: contentTypeJson, wantReason: "UNSUPPORTED_MEDIA_TYPE"},
+		{name: "form content type", contentType: "application/x-www-form-urlencoded", body: []byte(`timeoutMs=1000`),
+			wantStatus: http.StatusUnsupportedMediaType, wantType: contentTypeJson, wantReason: "UNSUPPORTED_MEDIA_TYPE"},
+		{name: "not acceptable", contentType: "application/json", accept: "text/html", body: jsonBody,
+			wantStatus: http.StatusNotAcceptable, wantType: contentTypeJson, wantReason: "NOT_ACCEPTABLE"},
+		{name: "protobuf error", contentType: "application/xml", accept: contentTypeProtobuf, body: []byte(`<lock/>`),
+			wantStatus: http.StatusUnsupportedMediaType, wantType: contentTypeProtobuf, wantReason: "UNSUPPORTED_MEDIA_TYPE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+			key := strings.ReplaceAll(tt.name, " ", "-")
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://"+s.httpAddr+"/v2/locks/"+key, bytes.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Client-Id", "client")
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus || resp.Header.Get("Content-Type") != tt.wantType {
+				t.Fatalf("got %d %s, want %d %s : %s", resp.StatusCode, resp.Header.Get("Content-Type"), tt.wantStatus, tt.wantType, body)
+			}
+
+			switch {
+			case tt.wantReason != "" && tt.wantType == contentTypeProtobuf:
+				st := &spb.Status{}
+				err = proto.Unmarshal(body, st)
+				if err != nil {
+					t.Fatal(err)
+				}
+				info := &errdetails.ErrorInfo{}
+				if len(st.Details) != 1 || st.Details[0].UnmarshalTo(info) != nil || info.Reason != tt.wantReason {
+					t.Fatalf("got status %v, want reason %s", st, tt.wantReason)
+				}
+			case tt.wantReason != "":
+				httpErr := HttpError{}
+				err = json.Unmarshal(body, &httpErr)
+				if err != nil || httpErr.Error.Reason != tt.wantReason {
+					t.Fatalf("got %s, want reason %s", body, tt.wantReason)
+				}
+			case tt.wantType == contentTypeProtobuf:
+				lock := &sharelockPB.LockResponse{}
+				err = proto.Unmarshal(body, lock)
+				if err != nil || lock.GetStatus() != sharelockPB.Status_Acquired {
+					t.Fatalf("got %v %v, want an acquired lock", lock, err)
+				}
+			default:
+				// enums are names in the proto3 JSON mapping
+				if !bytes.Contains(body, []byte(`"status":"Acquired"`)) {
+					t.Fatalf("got %s, want the status as a name", body)
+				}
+			}
+
+			// refused requests do nothing
+			info, err := s.locks().GetLock(ctx, &sharelockPB.GetLockRequest{Key: key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if held := info.GetHolder() != ""; held != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("holder %q after a %d answer", info.GetHolder(), resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// HttpError is the body of every non 2xx HTTP response. It mirrors the
+// gRPC status of the same failure so clients can share error handling
+// between both protocols.
+//
+//	{"error": {"code": 404, "status": "NOT_FOUND", "reason": "LOCK_NOT_HELD", "message": "..."}}
+type HttpError struct {
+	Error HttpErrorBody `json:"error"`
+}
+
+type HttpErrorBody struct {
+	// Code is the HTTP status code of the response.
+	Code int `json:"code"`
+	// Status is the google.rpc.Code name of the matching gRPC status.
+	Status string `json:"status"`
+	// Reason is the stable machine readable reason, see helpers.Reason_*.
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// httpStatusOverrides are reasons with a more specific HTTP status than
+// the one derived from their gRPC code.
+var httpStatusOverrides = map[string]int{
+	helpers.Reason_MethodNotAllowed:     http.StatusMethodNotAllowed,
+	helpers.Reason_RequestTooLarge:      http.StatusRequestEntityTooLarge,
+	helpers.Reason_UnsupportedMediaType: http.StatusUnsupportedMediaType,
+	helpers.Reason_NotAcceptable:        http.StatusNotAcceptable,
+	// the semantics of the Idempotency-Key header draft
+	helpers.Reason_IdempotencyKey: http.StatusUnprocessableEntity,
+}
+
+// writeHttpError sends err as HttpError JSON, or as a binary
+// google.rpc.Status when the client accepts application/x-protobuf.
+func writeHttpError(w http.ResponseWriter, r *http.Request, err error) {
+	st := status.Convert(err)
+	httpStatus := httpStatusFromCode(st.Code())
+	if override, ok := httpStatusOverrides[helpers.ErrorReason(err)]; ok {
+		httpStatus = override
+	}
+	if delay, ok := helpers.RetryDelay(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+	}
+
+	// without a request there is no Accept header, JSON is the default
+	if r != nil && responseContentType(r) == contentTypeProtobuf {
+		writeHttpResponse(w, r, httpStatus, st.Proto())
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypeJson)
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(&HttpError{
+		Error: HttpErrorBody{
+			Code:    httpStatus,
+			Status:  code.Code(st.Code()).String(),
+			Reason:  helpers.ErrorReason(err),
+			Message: st.Message(),
+		},
+	})
+}
+
+// httpStatusFromCode follows the google.rpc.Code mapping, except that
+// DeadlineExceeded stays 408 as in earlier releases of the HTTP API.
+func httpStatusFromCode(c codes.Code) int {
+	switch c {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusRequestTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
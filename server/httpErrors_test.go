@@ -0,0 +1,33 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNilHttpRequest(t *testing.T) {
+	h := &HttpServer{}
+	handlers := map[string]func(http.ResponseWriter, *http.Request){
+		"Lock":   h.Lock,
+		"Unlock": h.Unlock,
+	}
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if got := w.Header().Get("Content-Type"); got != contentTypeJson {
+				t.Fatalf("content type %q, want %q", got, contentTypeJson)
+			}
+			body := HttpError{}
+			err := json.Unmarshal(w.Body.Bytes(), &body)
+			if err != nil || body.Error.Reason != "NIL_REQUEST" {
+				t.Fatalf("body %q, want a NIL_REQUEST error", w.Body.String())
+			}
+		})
+	}
+}
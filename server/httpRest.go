@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// restMethod calls the LockService method behind one rpc.
+type restMethod func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error)
+
+// restRoute is one google.api.http binding of an rpc in sharelock.proto.
+type restRoute struct {
+	method     protoreflect.MethodDescriptor
+	httpMethod string
+	// template is the path as written in the annotation
+	template   string
+	body       string
+	pathParams []string
+}
+
+func (h *HttpServer) restMethods() map[protoreflect.Name]restMethod {
+	return map[protoreflect.Name]restMethod{
+		"Ping": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.ping(ctx, req.(*sharelockPB.ShareLockPingRequest))
+		},
+		"Lock": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.service.Lock(ctx, meta, req.(*sharelockPB.LockRequest))
+		},
+		"Unlock": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.service.Unlock(ctx, meta, req.(*sharelockPB.UnlockRequest))
+		},
+		"GetLock": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.service.GetLock(ctx, meta, req.(*sharelockPB.GetLockRequest))
+		},
+		"GetNamespace": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.service.GetNamespace(ctx, meta, req.(*sharelockPB.GetNamespaceRequest))
+		},
+		"RenewLock": func(ctx context.Context, meta RequestMeta, req proto.Message) (proto.Message, error) {
+			return h.service.RenewLock(ctx, meta, req.(*sharelockPB.RenewLockRequest))
+		},
+	}
+}
+
+// registerRestRoutes adds a handler for every google.api.http annotation
+// of ShareLockService, so the v2 REST API always follows the proto.
+func (h *HttpServer) registerRestRoutes(mux *http.ServeMux) error {
+	routes, err := restRoutes()
+	if err != nil {
+		return err
+	}
+	methods := h.restMethods()
+	for _, route := range routes {
+		call, ok := methods[route.method.Name()]
+		if !ok {
+			return fmt.Errorf("rpc %s has an http rule but no handler", route.method.Name())
+		}
+		msgType, err := protoregistry.GlobalTypes.FindMessageByName(route.method.Input().FullName())
+		if err != nil {
+			return err
+		}
+		pattern, err := muxPattern(route)
+		if err != nil {
+			return err
+		}
+		mux.HandleFunc(pattern, restHandler(route, msgType, call))
+	}
+	return nil
+}
+
+func restRoutes() ([]restRoute, error) {
+	service := sharelockPB.File_sharelock_proto.Services().ByName("ShareLockService")
+	if service == nil {
+		return nil, fmt.Errorf("ShareLockService missing from sharelock.proto")
+	}
+	routes := make([]restRoute, 0)
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		rule, ok := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+		route := restRoute{
+			method: method,
+			body:   rule.GetBody(),
+		}
+		switch pattern := rule.GetPattern().(type) {
+		case *annotations.HttpRule_Get:
+			route.httpMethod, route.template = http.MethodGet, pattern.Get
+		case *annotations.HttpRule_Put:
+			route.httpMethod, route.template = http.MethodPut, pattern.Put
+		case *annotations.HttpRule_Post:
+			route.httpMethod, route.template = http.MethodPost, pattern.Post
+		case *annotations.HttpRule_Delete:
+			route.httpMethod, route.template = http.MethodDelete, pattern.Delete
+		case *annotations.HttpRule_Patch:
+			route.httpMethod, route.template = http.MethodPatch, pattern.Patch
+		default:
+			return nil, fmt.Errorf("unsupported http rule on rpc %s", method.Name())
+		}
+		if route.body != "" && route.body != "*" {
+			return nil, fmt.Errorf("rpc %s : only body \"*\" is supported", method.Name())
+		}
+		for _, segment := range strings.Split(route.template, "/") {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				name, _, _ := strings.Cut(segment[1:len(segment)-1], "=")
+				if method.Input().Fields().ByName(protoreflect.Name(name)) == nil {
+					return nil, fmt.Errorf("rpc %s : unknown path parameter %s", method.Name(), name)
+				}
+				route.pathParams = append(route.pathParams, name)
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// muxPattern turns a path template into a http.ServeMux pattern. A
+// trailing {name=**} matches the rest of the path, slashes included.
+func muxPattern(route restRoute) (string, error) {
+	segments := strings.Split(route.template, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+		name, match, _ := strings.Cut(segment[1:len(segment)-1], "=")
+		switch match {
+		case "", "*":
+			segments[i] = "{" + name + "}"
+		case "**":
+			if i != len(segments)-1 {
+				return "", fmt.Errorf("%s : ** must be the last segment", route.template)
+			}
+			segments[i] = "{" + name + "...}"
+		default:
+			return "", fmt.Errorf("%s : unsupported path match %s", route.template, match)
+		}
+	}
+	return route.httpMethod + " " + strings.Join(segments, "/"), nil
+}
+
+// openApiPath is the path template in OpenAPI syntax.
+func openApiPath(template string) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			name, _, _ := strings.Cut(segment[1:len(segment)-1], "=")
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// restHandler builds the request message from the body, the query
+// string and the path, in that order of precedence, and calls the rpc.
+func restHandler(route restRoute, msgType protoreflect.MessageType, call restMethod) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := checkHttpAccept(r)
+		if err != nil {
+			writeHttpError(w, r, err)
+			return
+		}
+		req := msgType.New().Interface()
+		if route.body == "*" {
+			err := readHttpRequest(r, req)
+			if err != nil {
+				writeHttpError(w, r, err)
+				return
+			}
+		} else {
+			for name, values := range r.URL.Query() {
+				fd := fieldByName(req, name)
+				if fd == nil || len(values) == 0 {
+					continue
+				}
+				err := setField(req, fd, values[len(values)-1])
+				if err != nil {
+					writeHttpError(w, r, err)
+					return
+				}
+			}
+		}
+		for _, name := range route.pathParams {
+			fd := fieldByName(req, name)
+			err := setField(req, fd, r.PathValue(name))
+			if err != nil {
+				writeHttpError(w, r, err)
+				return
+			}
+		}
+
+		resp, err := call(r.Context(), httpRequestMeta(r), req)
+		if err != nil {
+			writeHttpError(w, r, err)
+			return
+		}
+		writeHttpResponse(w, r, http.StatusOK, resp)
+	}
+}
+
+// fieldByName accepts both the proto and the JSON name of a field.
+func fieldByName(msg proto.Message, name string) protoreflect.FieldDescriptor {
+	fields := msg.ProtoReflect().Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	return fd
+}
+
+func setField(msg proto.Message, fd protoreflect.FieldDescriptor, value string) error {
+	var v protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(value)
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return helpers.Err_Srv_MalformedRequest
+		}
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return helpers.Err_Srv_MalformedRequest
+		}
+		v = protoreflect.ValueOfInt32(int32(i))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return helpers.Err_Srv_MalformedRequest
+		}
+		v = protoreflect.ValueOfInt64(i)
+	default:
+		slog.Error("field cannot be set from a url", "field", fd.FullName(), "kind", fd.Kind())
+		return helpers.Err_Srv_MalformedRequest
+	}
+	msg.ProtoReflect().Set(fd, v)
+	return nil
+}
@@ -2,212 +2,244 @@ package server
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
-	"time"
+	"sync"
 
 	"sharelock/config"
-	"sharelock/pkg/locker"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/listener"
 	"sharelock/pkg/sharelockPB"
 )
 
 type HttpServer struct {
-	port   int
-	mux    *http.ServeMux
-	locker *locker.Locker
+	listeners   []net.Listener
+	serviceName string
+	srv         *http.Server
+	handler     http.Handler
+	// tls is nil without tls
+	tls        *tlsStore
+	service    *LockService
+	openApiDoc []byte
 }
 
-func NewHttpServer(ctx context.Context, cfg *config.Server, locker *locker.Locker) Server {
-	log.Print("[INFO] creating http server")
+// NewHttpServer serves the lock service over HTTP. A nil authenticator
+// trusts the X-Client-Id header of every request.
+func NewHttpServer(ctx context.Context, cfg *config.Server, service *LockService, authenticator auth.Authenticator) Server {
+	slog.Info("creating http server")
 	mck := NewMockServer()
 	if cfg == nil {
-		log.Printf("[ERROR] config is nil in server.NewHttpServer")
+		slog.Error("config is nil in server.NewHttpServer")
 		return mck
 	}
 	if !cfg.Enable {
 		return mck
 	}
-	if cfg.Port == 0 {
-		log.Printf("[ERROR] port is 0 in server.NewHttpServer")
+	if cfg.Port == 0 && len(cfg.ListenAddresses) == 0 {
+		slog.Error("port is 0 in server.NewHttpServer")
 		return mck
 	}
 
 	httpServer := &HttpServer{
-		port:   cfg.Port,
-		locker: locker,
+		serviceName: cfg.ServiceName,
+		service:     service,
+	}
+	if cfg.TLS {
+		slog.Info("tls is enabled for http server")
+		store, err := newTLSStore(ctx, "http server "+cfg.ServiceName, cfg, []string{"h2", "http/1.1"})
+		if err != nil {
+			slog.Error("creating http server tls", "err", err)
+			return mck
+		}
+		if cfg.ClientCAPath != "" {
+			slog.Info("mutual tls is enabled for http server")
+		}
+		httpServer.tls = store
 	}
 
 	srv := http.NewServeMux()
 	srv.HandleFunc("/ping", httpServer.Ping)
+	srv.HandleFunc("GET /healthz", httpServer.Healthz)
+	srv.HandleFunc("GET /readyz", httpServer.Readyz)
+	if metricsHandler := service.MetricsHandler(); metricsHandler != nil {
+		srv.Handle("GET /metrics", metricsHandler)
+	}
 	srv.HandleFunc("/lock", httpServer.Lock)
 	srv.HandleFunc("/unlock", httpServer.Unlock)
-	httpServer.mux = srv
+	err := httpServer.registerRestRoutes(srv)
+	if err != nil {
+		slog.Error("registering v2 routes in server.NewHttpServer", "err", err)
+		return mck
+	}
+	httpServer.openApiDoc, err = buildOpenApi()
+	if err != nil {
+		slog.Error("building openapi document in server.NewHttpServer", "err", err)
+		return mck
+	}
+	srv.HandleFunc("GET /v2/openapi.json", httpServer.OpenApi)
+	httpServer.handler = srv
+	if authenticator != nil {
+		slog.Info("client authentication is enabled for http server")
+		httpServer.handler = httpAuthMiddleware(authenticator, service, srv)
+	}
+	scheme := "http"
+	if cfg.TLS {
+		scheme = "https"
+	}
+	// followers send lock requests to the leader, which authenticates them
+	httpServer.handler = httpClusterRedirect(service, scheme, httpServer.handler)
+	httpServer.handler = httpTracing(httpRequestId(httpServer.handler))
+	// listening comes last, so a failure above leaves nothing open
+	httpServer.listeners, err = openListeners("http", cfg, ":%d")
+	if err != nil {
+		slog.Error("creating listeners for http server", "err", err)
+		return mck
+	}
+	httpServer.srv = &http.Server{
+		Handler: httpServer.handler,
+	}
+	if httpServer.tls != nil {
+		httpServer.srv.TLSConfig = httpServer.tls.serverConfig()
+	}
 	return httpServer
 }
 
+func (h *HttpServer) Addresses() []string {
+	return describeListeners(h.listeners)
+}
+
+func (h *HttpServer) PrepareTLS(cfg *config.Server) (func(), error) {
+	if h.tls == nil {
+		return func() {}, nil
+	}
+	return h.tls.prepare(cfg)
+}
+
+// Start serves every listener and returns once they are all closed.
 func (h *HttpServer) Start() {
-	log.Printf("[INFO] starting http server on port %d", h.port)
-	err := http.ListenAndServe(fmt.Sprintf(":%d", h.port), h.mux)
-	if err != nil {
-		log.Printf("[ERROR] starting http server on port %d : %s", h.port, err)
-		return
+	var wg sync.WaitGroup
+	for _, lis := range h.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info("starting http server", "server", h.serviceName, "address", listener.Describe(lis))
+			var err error
+			if h.tls != nil {
+				// certificates come from TLSConfig
+				err = h.srv.ServeTLS(lis, "", "")
+			} else {
+				err = h.srv.Serve(lis)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("starting http server", "server", h.serviceName, "address", listener.Describe(lis), "err", err)
+			}
+		}()
 	}
+	wg.Wait()
 }
 
-func (h *HttpServer) Stop() {
-	log.Print("[INFO] stopping http server")
+func (h *HttpServer) Stop(ctx context.Context) {
+	slog.Info("stopping http server", "server", h.serviceName)
+	err := h.srv.Shutdown(ctx)
+	if err != nil {
+		slog.Warn("http requests still running at the end of the shutdown timeout, closing them", "err", err)
+		h.srv.Close()
+	}
 }
 
 func (h *HttpServer) Ping(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	resp := &sharelockPB.ShareLockPingResponse{
-		Message: "pong",
-	}
-	jsonResp, err := json.Marshal(resp)
+	resp, err := h.ping(r.Context(), &sharelockPB.ShareLockPingRequest{})
 	if err != nil {
-		log.Print("[ERROR] json marshalling ping response in http server : ", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		writeHttpError(w, r, err)
 		return
 	}
-	w.Write(jsonResp)
+	writeHttpResponse(w, r, http.StatusOK, resp)
+}
+
+// ping answers with the service name of this server.
+func (h *HttpServer) ping(ctx context.Context, r *sharelockPB.ShareLockPingRequest) (*sharelockPB.ShareLockPingResponse, error) {
+	resp, err := h.service.Ping(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	resp.Service = h.serviceName
+	return resp, nil
 }
 
 func (h *HttpServer) Lock(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r == nil || r.Body == nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeHttpError(w, r, helpers.Err_Srv_NilRequest)
 		return
 	}
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeHttpError(w, r, helpers.Err_Srv_MethodNotAllowed)
 		return
 	}
-	respEncoder := json.NewEncoder(w)
-
-	req := sharelockPB.LockRequest{}
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := checkHttpAccept(r)
 	if err != nil {
-		log.Print("[ERROR] reading request body in httpServer.Lock : ", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		writeHttpError(w, r, err)
 		return
 	}
 
-	expiryDeadline := time.Now().Add(time.Second * 10)
-	if req.TimeoutMs <= 0 {
-		expiry, ok := r.Context().Deadline()
-		if ok {
-			expiryDeadline = expiry
-		}
+	req := sharelockPB.LockRequest{}
+	err = readHttpRequest(r, &req)
+	if err != nil {
+		writeHttpError(w, r, err)
+		return
 	}
 
-	lockerCtx, cancelLockerCtx := context.WithDeadline(r.Context(), expiryDeadline)
-	defer cancelLockerCtx()
-
-	newClient := locker.Client{
-		Ctx:        lockerCtx,
-		Id:         r.Header.Get("X-Client-Id"),
-		LockKey:    req.Key,
-		StatusChan: make(chan locker.Status, 1),
-	}
-	go h.locker.Lock(&newClient)
-
-listenerLoop:
-	for {
-		select {
-		case status := <-newClient.StatusChan:
-			switch status {
-			case locker.Status_Locked:
-				resp := &sharelockPB.LockResponse{
-					Status: sharelockPB.Status_Acquired,
-				}
-				respEncoder.Encode(resp)
-				return
-			case locker.Status_InvalidData:
-				resp := &sharelockPB.LockResponse{
-					Status: sharelockPB.Status_InvalidData,
-				}
-				respEncoder.Encode(resp)
-				return
-			case locker.Status_Timeout:
-				break listenerLoop
-			}
-		case <-r.Context().Done():
-			break listenerLoop
-		}
+	resp, err := h.service.Lock(r.Context(), httpRequestMeta(r), &req)
+	if err != nil {
+		writeHttpError(w, r, err)
+		return
 	}
-
-	w.WriteHeader(http.StatusRequestTimeout)
-	respEncoder.Encode(
-		&sharelockPB.UnlockResponse{Status: sharelockPB.Status_Timeout},
-	)
+	writeHttpResponse(w, r, http.StatusOK, resp)
 }
 
 func (h *HttpServer) Unlock(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	if r == nil || r.Body == nil {
-		w.WriteHeader(http.StatusBadRequest)
+		writeHttpError(w, r, helpers.Err_Srv_NilRequest)
 		return
 	}
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		writeHttpError(w, r, helpers.Err_Srv_MethodNotAllowed)
+		return
+	}
+	err := checkHttpAccept(r)
+	if err != nil {
+		writeHttpError(w, r, err)
 		return
 	}
-	respEncoder := json.NewEncoder(w)
 
 	req := sharelockPB.UnlockRequest{}
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err = readHttpRequest(r, &req)
 	if err != nil {
-		log.Print("[ERROR] reading request body in httpServer.Unlock : ", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		writeHttpError(w, r, err)
 		return
 	}
 
-	newClient := locker.Client{
-		Ctx:        r.Context(),
-		Id:         r.Header.Get("X-Client-Id"),
-		LockKey:    req.Key,
-		StatusChan: make(chan locker.Status, 1),
-	}
-	go h.locker.Unlock(&newClient)
-
-listenerLoop:
-	for {
-		select {
-		case status := <-newClient.StatusChan:
-			switch status {
-			case locker.Status_Unlocked:
-				respEncoder.Encode(
-					&sharelockPB.UnlockResponse{
-						Status: sharelockPB.Status_Released,
-					},
-				)
-				return
-			case locker.Status_Timeout:
-				break listenerLoop
-			case locker.Status_UnknownLock:
-				respEncoder.Encode(
-					&sharelockPB.UnlockResponse{
-						Status: sharelockPB.Status_UnknownLock,
-					},
-				)
-				w.WriteHeader(http.StatusNotFound)
-				return
-			case locker.Status_InvalidData:
-				resp := &sharelockPB.LockResponse{
-					Status: sharelockPB.Status_InvalidData,
-				}
-				respEncoder.Encode(resp)
-				return
-			}
-		case <-r.Context().Done():
-			break listenerLoop
-		}
+	resp, err := h.service.Unlock(r.Context(), httpRequestMeta(r), &req)
+	if err != nil {
+		writeHttpError(w, r, err)
+		return
 	}
+	writeHttpResponse(w, r, http.StatusOK, resp)
+}
 
-	w.WriteHeader(http.StatusRequestTimeout)
-	respEncoder.Encode(
-		&sharelockPB.UnlockResponse{Status: sharelockPB.Status_Timeout},
-	)
+func httpRequestMeta(r *http.Request) RequestMeta {
+	meta := RequestMeta{
+		ClientId:       lockOwner(r.Context(), r.Header.Get("X-Client-Id")),
+		Namespace:      r.Header.Get("X-Namespace"),
+		RemoteAddr:     remoteHost(r.RemoteAddr),
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+		RequestId:      RequestIdFromContext(r.Context()),
+		Transport:      "http",
+	}
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		meta.Principal = principal.Name
+	}
+	return meta
 }
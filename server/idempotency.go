@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const idempotencyTTL = time.Minute * 10
+
+// idempotencyCache remembers the successful result of mutating calls
+// made with an idempotency key, per namespace and client. A retry
+// arriving while the first attempt is still running waits for it.
+// Failures are not remembered, so a retry after an error runs again.
+// A key reused for another operation or another request body is
+// refused, the cached result would answer a request never made.
+type idempotencyCache struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+}
+
+type idempotencyEntry struct {
+	// fingerprint is the hash of the operation and the request
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	resp        any
+	err         error
+	expires     time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{
+		entries:   make(map[string]*idempotencyEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (c *idempotencyCache) do(ctx context.Context, meta RequestMeta, op string, req proto.Message, call func() (any, error)) (any, error) {
+	if meta.IdempotencyKey == "" {
+		return call()
+	}
+	cacheKey := meta.Namespace + "\x00" + meta.ClientId + "\x00" + meta.IdempotencyKey
+	fingerprint, err := requestFingerprint(op, req)
+	if err != nil {
+		return nil, helpers.Err_Srv_MalformedRequest
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	c.sweep(now)
+	entry, exist := c.entries[cacheKey]
+	if exist && now.Before(entry.expires) {
+		c.mu.Unlock()
+		if entry.fingerprint != fingerprint {
+			return nil, helpers.Err_Srv_IdempotencyKeyReused
+		}
+		select {
+		case <-entry.done:
+			if entry.err == nil {
+				return entry.resp, nil
+			}
+			// the first attempt failed, this retry runs on its own
+			return c.do(ctx, meta, op, req, call)
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	}
+	entry = &idempotencyEntry{
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+		expires:     now.Add(idempotencyTTL),
+	}
+	c.entries[cacheKey] = entry
+	c.mu.Unlock()
+
+	entry.resp, entry.err = call()
+	if entry.err != nil {
+		c.mu.Lock()
+		if c.entries[cacheKey] == entry {
+			delete(c.entries, cacheKey)
+		}
+		c.mu.Unlock()
+	}
+	close(entry.done)
+	return entry.resp, entry.err
+}
+
+// requestFingerprint hashes op and the deterministic encoding of req.
+func requestFingerprint(op string, req proto.Message) ([sha256.Size]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(append([]byte(op+"\x00"), data...)), nil
+}
+
+// sweep drops expired entries at most once a minute. c.mu must be held.
+func (c *idempotencyCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			select {
+			case <-entry.done:
+				delete(c.entries, key)
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	first := RequestMeta{Namespace: "default", ClientId: "client-a", IdempotencyKey: "key-1"}
+	lockA := &sharelockPB.LockRequest{Key: "a"}
+	tests := []struct {
+		name    string
+		meta    RequestMeta
+		op      string
+		req     proto.Message
+		wantErr error
+		// wantCached tells that the first result answers the retry
+		wantCached bool
+	}{
+		{name: "same request", meta: first, op: "Lock", req: &sharelockPB.LockRequest{Key: "a"}, wantCached: true},
+		{name: "another lock key", meta: first, op: "Lock", req: &sharelockPB.LockRequest{Key: "b"}, wantErr: helpers.Err_Srv_IdempotencyKeyReused},
+		{name: "another wait timeout", meta: first, op: "Lock", req: &sharelockPB.LockRequest{Key: "a", TimeoutMs: 10}, wantErr: helpers.Err_Srv_IdempotencyKeyReused},
+		{name: "another operation", meta: first, op: "Unlock", req: &sharelockPB.UnlockRequest{Key: "a"}, wantErr: helpers.Err_Srv_IdempotencyKeyReused},
+		{name: "another client", meta: RequestMeta{Namespace: "default", ClientId: "client-b", IdempotencyKey: "key-1"}, op: "Lock", req: &sharelockPB.LockRequest{Key: "b"}},
+		{name: "another idempotency key", meta: RequestMeta{Namespace: "default", ClientId: "client-a", IdempotencyKey: "key-2"}, op: "Lock", req: &sharelockPB.LockRequest{Key: "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newIdempotencyCache()
+			_, err := c.do(context.Background(), first, "Lock", lockA, func() (any, error) {
+				return "first", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ran := false
+			resp, err := c.do(context.Background(), tt.meta, tt.op, tt.req, func() (any, error) {
+				ran = true
+				return "retry", nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if ran {
+					t.Fatal("a refused request ran")
+				}
+				return
+			}
+			if ran == tt.wantCached || (resp == "first") != tt.wantCached {
+				t.Fatalf("got %v and ran %v, want the cached result %v", resp, ran, tt.wantCached)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeyReusedHttpStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeHttpError(w, httptest.NewRequest(http.MethodPut, "/v2/locks/a", nil), helpers.Err_Srv_IdempotencyKeyReused)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
@@ -0,0 +1,644 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"sharelock/pkg/audit"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/cluster"
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/metrics"
+	"sharelock/pkg/sharelockPB"
+	"sharelock/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	grpcCodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultLockWait = time.Second * 10
+
+// RequestMeta is what a transport extracts from the headers or the
+// metadata of a request before handing it to LockService.
+type RequestMeta struct {
+	// ClientId owns the locks of the request
+	ClientId string
+	// Principal is the authenticated caller, empty without authentication
+	Principal string
+	// RemoteAddr is the source address of the request, without port
+	RemoteAddr string
+	// Namespace is taken from the X-Namespace header. LockService
+	// replaces it with the namespace the request resolved to.
+	Namespace string
+	// IdempotencyKey makes retries of a mutating call return the result
+	// of the first successful attempt instead of running it again.
+	IdempotencyKey string
+	// Transport is grpc or http
+	Transport string
+	// RequestId is sent by the caller or generated, it correlates the
+	// log lines of the request
+	RequestId string
+	// Hops counts the nodes of a sharded deployment a request was
+	// forwarded by. The first one authenticated the caller and counted it
+	// against its rates.
+	Hops int
+}
+
+// LockService holds the protocol agnostic part of every request:
+// validation, wait deadline computation and mapping of locker statuses.
+// The gRPC and HTTP servers only decode requests, extract the request
+// metadata and encode what LockService returns. Failures are returned
+// as the typed errors of the helpers package.
+type LockService struct {
+	locker         *locker.Locker
+	idempotency    *idempotencyCache
+	policy         *auth.PolicyStore
+	namespaceRates *namespaceRates
+	admission      *admission
+	draining       atomic.Bool
+	// metrics is nil without metrics
+	metrics *metrics.Metrics
+	// audit is nil without audit log
+	audit *audit.Log
+	// cluster and forwarder are nil outside of cluster mode
+	cluster   *cluster.Node
+	forwarder *forwarder
+	// shards is nil unless the keys are partitioned between nodes
+	shards *shards
+}
+
+type ServiceOption func(*LockService)
+
+// WithPolicy checks every call against the acl policy of store. Without
+// a policy every caller may do everything but the admin calls.
+func WithPolicy(store *auth.PolicyStore) ServiceOption {
+	return func(s *LockService) {
+		s.policy = store
+	}
+}
+
+// WithMetrics counts every request in m.
+func WithMetrics(m *metrics.Metrics) ServiceOption {
+	return func(s *LockService) {
+		s.metrics = m
+	}
+}
+
+func NewLockService(locker *locker.Locker, opts ...ServiceOption) *LockService {
+	s := &LockService{
+		locker:         locker,
+		idempotency:    newIdempotencyCache(),
+		admission:      newAdmission(AdmissionLimits{}),
+		namespaceRates: newNamespaceRates(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *LockService) Ping(ctx context.Context, r *sharelockPB.ShareLockPingRequest) (*sharelockPB.ShareLockPingResponse, error) {
+	return &sharelockPB.ShareLockPingResponse{
+		Message: "pong",
+	}, nil
+}
+
+// Drain makes LockService refuse new locks with Unavailable and
+// releases every queued waiter. Unlock, renew and inspection calls keep
+// working, so holders can finish their work during the drain period.
+func (s *LockService) Drain(ctx context.Context) error {
+	s.draining.Store(true)
+	return s.locker.Drain(ctx)
+}
+
+func (s *LockService) Lock(ctx context.Context, meta RequestMeta, r *sharelockPB.LockRequest) (resp *sharelockPB.LockResponse, err error) {
+	ctx, finish := s.begin(ctx, "Lock", meta, r.GetKey())
+	defer finish(&err)
+	if r == nil {
+		return nil, helpers.Err_Srv_NilRequest
+	}
+	err = validateRequest(meta, r.Key)
+	if err != nil {
+		return nil, err
+	}
+	if r.LeaseMs < 0 {
+		return nil, helpers.Err_Srv_InvalidLease
+	}
+	if s.draining.Load() {
+		return nil, helpers.WithRetryDelay(helpers.Err_Srv_ShuttingDown, time.Second)
+	}
+	meta.Namespace, err = requestNamespace(meta, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	err = s.admit(meta, auth.Action_Lock, r.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := s.admission.enter(meta)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result, err := s.idempotency.do(ctx, meta, "Lock", r, func() (any, error) {
+		return s.routed(ctx, meta, r.Key, &sharelockPB.ShardCall{Lock: r},
+			func(foreign bool) (any, error) {
+				return s.lock(ctx, meta, r, foreign)
+			},
+			func(reply *sharelockPB.ShardReply) any {
+				return reply.Lock
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*sharelockPB.LockResponse), nil
+}
+
+func (s *LockService) lock(ctx context.Context, meta RequestMeta, r *sharelockPB.LockRequest, foreign bool) (*sharelockPB.LockResponse, error) {
+	lockerCtx, cancelLockerCtx := context.WithDeadline(ctx, lockWaitDeadline(ctx, r.TimeoutMs))
+	defer cancelLockerCtx()
+
+	newClient := locker.Client{
+		Ctx:        lockerCtx,
+		Id:         meta.ClientId,
+		Namespace:  meta.Namespace,
+		LockKey:    r.Key,
+		StatusChan: make(chan locker.Status, 1),
+		Lease:      time.Duration(r.LeaseMs) * time.Millisecond,
+		Foreign:    foreign,
+	}
+	go s.locker.Lock(&newClient)
+
+	select {
+	case status := <-newClient.StatusChan:
+		switch status {
+		case locker.Status_Locked:
+			return &sharelockPB.LockResponse{
+				Status: sharelockPB.Status_Acquired,
+				Lock: &sharelockPB.LockInfo{
+					Namespace:       meta.Namespace,
+					Key:             r.Key,
+					Holder:          meta.ClientId,
+					LeaseExpireTime: timestamppb.New(newClient.LeaseExpiresAt),
+				},
+			}, nil
+		case locker.Status_Timeout:
+			return nil, s.lockWaitTimeout(meta.Namespace, r.Key)
+		case locker.Status_QuotaExceeded:
+			return nil, helpers.WithRetryDelay(helpers.Err_Srv_QuotaExceeded, time.Second)
+		case locker.Status_Shutdown:
+			return nil, helpers.WithRetryDelay(helpers.Err_Srv_ShuttingDown, time.Second)
+		case locker.Status_InvalidData:
+			return nil, helpers.Err_Srv_InvalidData
+		case locker.Status_StoreFailed:
+			// the locker logged why
+			return nil, helpers.Err_Srv_Internal
+		case locker.Status_NotLeader:
+			return nil, s.notLeaderError()
+		case locker.Status_Moved:
+			return nil, helpers.Err_Srv_KeyMoved
+		}
+		helpers.Logger(ctx).Error("unexpected locker status in LockService.Lock", "status", status.String())
+		return nil, helpers.Err_Srv_Internal
+	case <-lockerCtx.Done():
+		s.abandon(ctx, &newClient)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, helpers.Err_Srv_RequestCancelled
+		}
+		return nil, s.lockWaitTimeout(meta.Namespace, r.Key)
+	}
+}
+
+// abandon releases a lock the locker granted as the wait for it ended,
+// the caller is told it failed and would never release it.
+func (s *LockService) abandon(ctx context.Context, client *locker.Client) {
+	abandonCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second*5)
+	defer cancel()
+	abandoned, err := s.locker.Abandon(abandonCtx, client)
+	if err != nil {
+		helpers.Logger(ctx).Error("releasing a lock granted after its wait ended, it stays held until its lease expires", "err", err)
+		return
+	}
+	if abandoned {
+		helpers.Logger(ctx).Warn("released a lock granted after its wait ended")
+	}
+}
+
+func (s *LockService) Unlock(ctx context.Context, meta RequestMeta, r *sharelockPB.UnlockRequest) (resp *sharelockPB.UnlockResponse, err error) {
+	ctx, finish := s.begin(ctx, "Unlock", meta, r.GetKey())
+	defer finish(&err)
+	if r == nil {
+		return nil, helpers.Err_Srv_NilRequest
+	}
+	err = validateRequest(meta, r.Key)
+	if err != nil {
+		return nil, err
+	}
+	meta.Namespace, err = requestNamespace(meta, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	action := auth.Action_Unlock
+	if r.Force {
+		action = auth.Action_ForceRelease
+	}
+	err = s.admit(meta, action, r.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := s.admission.enter(meta)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result, err := s.idempotency.do(ctx, meta, "Unlock", r, func() (any, error) {
+		return s.routed(ctx, meta, r.Key, &sharelockPB.ShardCall{Unlock: r},
+			func(foreign bool) (any, error) {
+				if foreign {
+					err := s.foreignProbe(ctx, meta.Namespace, r.Key)
+					if err != nil {
+						return nil, err
+					}
+				}
+				return s.unlock(ctx, meta, r)
+			},
+			func(reply *sharelockPB.ShardReply) any {
+				return reply.Unlock
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*sharelockPB.UnlockResponse), nil
+}
+
+func (s *LockService) unlock(ctx context.Context, meta RequestMeta, r *sharelockPB.UnlockRequest) (*sharelockPB.UnlockResponse, error) {
+	newClient := locker.Client{
+		Ctx:        ctx,
+		Id:         meta.ClientId,
+		Namespace:  meta.Namespace,
+		LockKey:    r.Key,
+		StatusChan: make(chan locker.Status, 1),
+		Force:      r.Force,
+	}
+	go s.locker.Unlock(&newClient)
+
+	select {
+	case status := <-newClient.StatusChan:
+		switch status {
+		case locker.Status_Unlocked:
+			return &sharelockPB.UnlockResponse{
+				Status: sharelockPB.Status_Released,
+			}, nil
+		case locker.Status_UnknownLock:
+			return nil, helpers.Err_Srv_LockNotHeld
+		case locker.Status_InvalidData:
+			return nil, helpers.Err_Srv_InvalidData
+		case locker.Status_Shutdown:
+			return nil, helpers.WithRetryDelay(helpers.Err_Srv_ShuttingDown, time.Second)
+		case locker.Status_NotLeader:
+			return nil, s.notLeaderError()
+		}
+		helpers.Logger(ctx).Error("unexpected locker status in LockService.Unlock", "status", status.String())
+		return nil, helpers.Err_Srv_Internal
+	case <-ctx.Done():
+		return nil, contextError(ctx)
+	}
+}
+
+func (s *LockService) RenewLock(ctx context.Context, meta RequestMeta, r *sharelockPB.RenewLockRequest) (resp *sharelockPB.RenewLockResponse, err error) {
+	ctx, finish := s.begin(ctx, "RenewLock", meta, r.GetKey())
+	defer finish(&err)
+	if r == nil {
+		return nil, helpers.Err_Srv_NilRequest
+	}
+	err = validateRequest(meta, r.Key)
+	if err != nil {
+		return nil, err
+	}
+	if r.LeaseMs < 0 {
+		return nil, helpers.Err_Srv_InvalidLease
+	}
+	meta.Namespace, err = requestNamespace(meta, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	err = s.admit(meta, auth.Action_Lock, r.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	done, err := s.admission.enter(meta)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result, err := s.idempotency.do(ctx, meta, "RenewLock", r, func() (any, error) {
+		return s.routed(ctx, meta, r.Key, &sharelockPB.ShardCall{RenewLock: r},
+			func(foreign bool) (any, error) {
+				if foreign {
+					err := s.foreignProbe(ctx, meta.Namespace, r.Key)
+					if err != nil {
+						return nil, err
+					}
+				}
+				return s.renewLock(ctx, meta, r)
+			},
+			func(reply *sharelockPB.ShardReply) any {
+				return reply.RenewLock
+			})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*sharelockPB.RenewLockResponse), nil
+}
+
+func (s *LockService) renewLock(ctx context.Context, meta RequestMeta, r *sharelockPB.RenewLockRequest) (*sharelockPB.RenewLockResponse, error) {
+	newClient := locker.Client{
+		Ctx:        ctx,
+		Id:         meta.ClientId,
+		Namespace:  meta.Namespace,
+		LockKey:    r.Key,
+		StatusChan: make(chan locker.Status, 1),
+		Lease:      time.Duration(r.LeaseMs) * time.Millisecond,
+	}
+	go s.locker.Renew(&newClient)
+
+	select {
+	case status := <-newClient.StatusChan:
+		switch status {
+		case locker.Status_Renewed:
+			return &sharelockPB.RenewLockResponse{
+				Status: sharelockPB.Status_Renewed,
+				Lock: &sharelockPB.LockInfo{
+					Namespace:       meta.Namespace,
+					Key:             r.Key,
+					Holder:          meta.ClientId,
+					LeaseExpireTime: timestamppb.New(newClient.LeaseExpiresAt),
+				},
+			}, nil
+		case locker.Status_UnknownLock:
+			return nil, helpers.Err_Srv_LockNotHeld
+		case locker.Status_InvalidData:
+			return nil, helpers.Err_Srv_InvalidData
+		case locker.Status_StoreFailed:
+			return nil, helpers.Err_Srv_Internal
+		case locker.Status_Shutdown:
+			return nil, helpers.WithRetryDelay(helpers.Err_Srv_ShuttingDown, time.Second)
+		case locker.Status_NotLeader:
+			return nil, s.notLeaderError()
+		}
+		helpers.Logger(ctx).Error("unexpected locker status in LockService.RenewLock", "status", status.String())
+		return nil, helpers.Err_Srv_Internal
+	case <-ctx.Done():
+		return nil, contextError(ctx)
+	}
+}
+
+// GetLock needs no client id, any caller allowed to inspect the key may.
+func (s *LockService) GetLock(ctx context.Context, meta RequestMeta, r *sharelockPB.GetLockRequest) (resp *sharelockPB.LockInfo, err error) {
+	ctx, finish := s.begin(ctx, "GetLock", meta, r.GetKey())
+	defer finish(&err)
+	if r == nil {
+		return nil, helpers.Err_Srv_NilRequest
+	}
+	if len(r.Key) == 0 {
+		return nil, helpers.Err_Srv_Request_KeyMissing
+	}
+	meta.Namespace, err = requestNamespace(meta, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	err = s.admit(meta, auth.Action_Inspect, r.Key)
+	if err != nil {
+		return nil, err
+	}
+	done, err := s.admission.enter(meta)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	result, err := s.routed(ctx, meta, r.Key, &sharelockPB.ShardCall{Inspect: r},
+		func(foreign bool) (any, error) {
+			if foreign {
+				err := s.foreignProbe(ctx, meta.Namespace, r.Key)
+				if err != nil {
+					return nil, err
+				}
+			}
+			info, err := s.locker.Inspect(ctx, meta.Namespace, r.Key)
+			if err != nil {
+				return nil, contextError(ctx)
+			}
+			return lockInfo(info), nil
+		},
+		func(reply *sharelockPB.ShardReply) any {
+			return reply.LockInfo
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*sharelockPB.LockInfo), nil
+}
+
+// GetNamespace reports the usage and limits of a namespace.
+func (s *LockService) GetNamespace(ctx context.Context, meta RequestMeta, r *sharelockPB.GetNamespaceRequest) (resp *sharelockPB.NamespaceInfo, err error) {
+	ctx, finish := s.begin(ctx, "GetNamespace", meta, "")
+	defer finish(&err)
+	if r == nil {
+		return nil, helpers.Err_Srv_NilRequest
+	}
+	meta.Namespace, err = requestNamespace(meta, r.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if s.policy != nil && !s.policy.AllowedNamespace(meta.Principal, meta.Namespace) {
+		return nil, helpers.Err_Srv_PermissionDenied
+	}
+	err = s.rateLimit(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.locker.InspectNamespace(ctx, meta.Namespace)
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	resp = &sharelockPB.NamespaceInfo{
+		Namespace: info.Namespace,
+		Keys:      int32(info.Keys),
+		HeldLocks: int32(info.HeldLocks),
+		Waiters:   int32(info.Waiters),
+		Clients:   int32(info.Clients),
+		Limits: &sharelockPB.NamespaceLimits{
+			MaxHeldLocks:      int32(info.Limits.MaxHeldLocks),
+			MaxWaiters:        int32(info.Limits.MaxWaiters),
+			MaxLocksPerClient: int32(info.Limits.MaxLocksPerClient),
+		},
+	}
+	resp.Limits.RequestsPerSecond = s.namespaceRates.limit(meta.Namespace).RequestsPerSecond
+	return resp, nil
+}
+
+// MetricsHandler serves the metrics of the service, nil without metrics.
+func (s *LockService) MetricsHandler() http.Handler {
+	if s.metrics == nil {
+		return nil
+	}
+	return s.metrics.Handler()
+}
+
+// begin starts the span and the logger of a request and returns the
+// func that ends the span, counts the request and logs it once it
+// returns with *err. The status is the reason of the error, so both
+// transports report the same values. Every line logged with the context
+// of the request carries its id, operation, transport, client id and key.
+func (s *LockService) begin(ctx context.Context, operation string, meta RequestMeta, key string) (context.Context, func(*error)) {
+	start := time.Now()
+	attrs := []attribute.KeyValue{
+		attribute.String("sharelock.transport", meta.Transport),
+		attribute.String("sharelock.client_id", meta.ClientId),
+	}
+	if key != "" {
+		attrs = append(attrs, attribute.String("sharelock.key", key))
+	}
+	if meta.RequestId != "" {
+		attrs = append(attrs, attribute.String("sharelock.request_id", meta.RequestId))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "sharelock."+operation,
+		trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attrs...))
+
+	logger := slog.Default()
+	if meta.RequestId != "" {
+		logger = logger.With("request_id", meta.RequestId)
+	}
+	if spanCtx := span.SpanContext(); spanCtx.IsValid() {
+		logger = logger.With("trace_id", spanCtx.TraceID().String())
+	}
+	logger = logger.With("operation", operation, "transport", meta.Transport, "client_id", meta.ClientId, "key", key)
+	ctx = helpers.ContextWithLogger(ctx, logger)
+	ctx = s.withAuditSource(ctx, meta)
+
+	return ctx, func(err *error) {
+		result := "OK"
+		level := slog.LevelDebug
+		if *err != nil {
+			result = helpers.ErrorReason(*err)
+			if result == "" {
+				result = status.Code(*err).String()
+			}
+			span.SetStatus(codes.Error, (*err).Error())
+			span.SetAttributes(attribute.String("sharelock.error_reason", result))
+			level = slog.LevelInfo
+			if status.Code(*err) == grpcCodes.Internal {
+				level = slog.LevelError
+			}
+		}
+		span.End()
+		if s.metrics != nil {
+			s.metrics.ObserveRequest(operation, meta.Transport, result)
+		}
+		s.auditDenied(meta, operation, key, result)
+		logger.LogAttrs(ctx, level, "request done",
+			slog.String("status", result),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+}
+
+func validateRequest(meta RequestMeta, key string) error {
+	if len(key) == 0 {
+		return helpers.Err_Srv_Request_KeyMissing
+	}
+	if len(meta.ClientId) == 0 {
+		return helpers.Err_Srv_Request_ClientIdMissing
+	}
+	return nil
+}
+
+// admit checks the key length and the acl policy, then takes a token of
+// every request rate the request counts against.
+func (s *LockService) admit(meta RequestMeta, action auth.Action, key string) error {
+	err := s.admission.checkKey(key)
+	if err != nil {
+		return err
+	}
+	if s.policy != nil && !s.policy.Allowed(meta.Principal, action, meta.Namespace, key) {
+		return helpers.Err_Srv_PermissionDenied
+	}
+	return s.rateLimit(meta)
+}
+
+func (s *LockService) rateLimit(meta RequestMeta) error {
+	if meta.Hops > 0 {
+		return nil
+	}
+	now := time.Now()
+	reservations := s.admission.reserve(meta, now)
+	reservations = append(reservations, s.namespaceRates.reserve(meta.Namespace, now))
+	return takeTokens(now, reservations...)
+}
+
+// lockWaitTimeout tells the client to retry once the current lease of
+// key runs out.
+func (s *LockService) lockWaitTimeout(namespace, key string) error {
+	inspectCtx, cancelInspectCtx := context.WithTimeout(context.Background(), time.Second)
+	defer cancelInspectCtx()
+	info, err := s.locker.Inspect(inspectCtx, namespace, key)
+	if err != nil || info.LeaseExpiresAt.IsZero() {
+		return helpers.WithRetryDelay(helpers.Err_Srv_LockWaitTimeout, time.Second)
+	}
+	return helpers.WithRetryDelay(helpers.Err_Srv_LockWaitTimeout, time.Until(info.LeaseExpiresAt))
+}
+
+func lockInfo(info locker.KeyInfo) *sharelockPB.LockInfo {
+	resp := &sharelockPB.LockInfo{
+		Namespace: info.Namespace,
+		Key:       info.Key,
+		Holder:    info.HoldingId,
+		Waiters:   int32(info.Waiters),
+	}
+	if !info.LeaseExpiresAt.IsZero() {
+		resp.LeaseExpireTime = timestamppb.New(info.LeaseExpiresAt)
+	}
+	return resp
+}
+
+// contextError is the error returned when the incoming request ends
+// before the locker answered.
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return helpers.Err_Srv_RequestCancelled
+	}
+	return helpers.Err_Srv_RequestTimeout
+}
+
+// lockWaitDeadline is how long a lock request may wait in the queue.
+// An explicit timeout wins, then the deadline of the incoming request,
+// then the default. The result never outlives ctx.
+func lockWaitDeadline(ctx context.Context, timeoutMs int32) time.Time {
+	if timeoutMs > 0 {
+		return time.Now().Add(time.Duration(timeoutMs) * time.Millisecond)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(defaultLockWait)
+}
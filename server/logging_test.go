@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// logRecorder keeps the json lines of the default logger.
+type logRecorder struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (r *logRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.buf.Write(p)
+}
+
+// lines returns the records logged with msg since the last call.
+func (r *logRecorder) lines(t *testing.T, msg string) []map[string]any {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	records := make([]map[string]any, 0)
+	for _, line := range strings.Split(strings.TrimSpace(r.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := make(map[string]any)
+		err := json.Unmarshal([]byte(line), &record)
+		if err != nil {
+			t.Fatalf("log line %q is not json : %v", line, err)
+		}
+		if record["msg"] == msg {
+			records = append(records, record)
+		}
+	}
+	r.buf.Reset()
+	return records
+}
+
+// recordLogs makes the default logger write json to the returned
+// recorder at level, until the end of the test.
+func recordLogs(t *testing.T, level slog.Level) *logRecorder {
+	t.Helper()
+	recorder := &logRecorder{}
+	handler, err := helpers.NewLogHandler(recorder, helpers.LogFormat_Json)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(handler))
+	helpers.SetLogLevel(level)
+	t.Cleanup(func() {
+		slog.SetDefault(previous)
+		helpers.SetLogLevel(slog.LevelInfo)
+		// leave debug logging off, whichever state the test left
+		if helpers.ToggleDebugLogging() {
+			helpers.ToggleDebugLogging()
+		}
+	})
+	return recorder
+}
+
+func wantFields(t *testing.T, record map[string]any, fields map[string]string) {
+	t.Helper()
+	for field, want := range fields {
+		got, ok := record[field]
+		if !ok {
+			t.Errorf("%q misses %s, got %v", record["msg"], field, record)
+			continue
+		}
+		if want != "" && got != want {
+			t.Errorf("%q has %s %v, want %s", record["msg"], field, got, want)
+		}
+	}
+}
+
+func TestRequestLogs(t *testing.T) {
+	recordSpans(t)
+	logs := recordLogs(t, slog.LevelInfo)
+	s := startServers(t, nil)
+	logs.lines(t, "")
+
+	httpLock := func(method, key, requestId string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, "http://"+s.httpAddr+"/v2/locks/"+key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Client-Id", "client")
+		req.Header.Set("X-Request-Id", requestId)
+		req.Header.Set("traceparent", testTraceparent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("failed requests at info", func(t *testing.T) {
+		resp := httpLock(http.MethodDelete, "logged", "req-unlock")
+		if resp.StatusCode != http.StatusNotFound || resp.Header.Get("X-Request-Id") != "req-unlock" {
+			t.Fatalf("got %d with request id %q", resp.StatusCode, resp.Header.Get("X-Request-Id"))
+		}
+		done := logs.lines(t, "request done")
+		if len(done) != 1 {
+			t.Fatalf("logged %d request lines, want 1", len(done))
+		}
+		wantFields(t, done[0], map[string]string{
+			"level":      "INFO",
+			"request_id": "req-unlock",
+			"trace_id":   testTraceId,
+			"operation":  "Unlock",
+			"transport":  "http",
+			"client_id":  "client",
+			"key":        "logged",
+			"status":     helpers.Reason_LockNotHeld,
+			"duration":   "",
+		})
+
+		// successful requests and transitions are debug lines
+		httpLock(http.MethodPut, "logged", "req-quiet")
+		httpLock(http.MethodDelete, "logged", "req-quiet")
+		if done := logs.lines(t, "request done"); len(done) != 0 {
+			t.Fatalf("logged %d successful requests at info level", len(done))
+		}
+	})
+
+	t.Run("debug switched at runtime", func(t *testing.T) {
+		if !helpers.ToggleDebugLogging() {
+			t.Fatal("debug logging was not switched on")
+		}
+
+		httpLock(http.MethodPut, "logged", "req-lock")
+		granted := logs.lines(t, "lock granted")
+		if len(granted) != 1 {
+			t.Fatalf("logged %d grants, want 1", len(granted))
+		}
+		// the locker logs with the logger of the request
+		wantFields(t, granted[0], map[string]string{
+			"level":      "DEBUG",
+			"request_id": "req-lock",
+			"operation":  "Lock",
+			"transport":  "http",
+			"client_id":  "client",
+			"key":        "logged",
+			"duration":   "",
+		})
+
+		if helpers.ToggleDebugLogging() {
+			t.Fatal("debug logging was not switched off")
+		}
+		httpLock(http.MethodDelete, "logged", "req-unlock")
+		if released := logs.lines(t, "lock released"); len(released) != 0 {
+			t.Fatal("transitions are logged after debug logging was switched off")
+		}
+	})
+
+	t.Run("generated over grpc", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "client")
+		var header metadata.MD
+		_, err := s.locks().Unlock(ctx, &sharelockPB.UnlockRequest{Key: "logged"}, grpc.Header(&header))
+		if err == nil {
+			t.Fatal("unlocked a key that is not held")
+		}
+		ids := header.Get("X-Request-Id")
+		if len(ids) != 1 || len(ids[0]) != 32 {
+			t.Fatalf("got request ids %q, want one generated id", ids)
+		}
+		done := logs.lines(t, "request done")
+		if len(done) != 1 {
+			t.Fatalf("logged %d request lines, want 1", len(done))
+		}
+		wantFields(t, done[0], map[string]string{"request_id": ids[0], "transport": "grpc", "operation": "Unlock"})
+	})
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"sharelock/pkg/metrics"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestMetrics(t *testing.T) {
+	m := metrics.New(metrics.Options{})
+	s := startServers(t, nil, WithMetrics(m))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "client")
+
+	_, err := s.locks().Lock(ctx, &sharelockPB.LockRequest{Key: "a", TimeoutMs: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.locks().Unlock(ctx, &sharelockPB.UnlockRequest{Key: "b"})
+	if err == nil {
+		t.Fatal("unlocked a key that is not held")
+	}
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		req, err := http.NewRequestWithContext(ctx, method, "http://"+s.httpAddr+"/v2/locks/c", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Client-Id", "client")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range []string{
+		`sharelock_requests_total{operation="Lock",status="OK",transport="grpc"} 1`,
+		`sharelock_requests_total{operation="Unlock",status="LOCK_NOT_HELD",transport="grpc"} 1`,
+		`sharelock_requests_total{operation="Lock",status="OK",transport="http"} 1`,
+		`sharelock_requests_total{operation="Unlock",status="OK",transport="http"} 1`,
+	} {
+		if !strings.Contains(string(body), sample+"\n") {
+			t.Errorf("scrape misses %q", sample)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package server
 
+import "context"
+
 type MockServer struct{}
 
 func NewMockServer() Server {
@@ -8,4 +10,4 @@ func NewMockServer() Server {
 
 func (m *MockServer) Start() {}
 
-func (m *MockServer) Stop() {}
+func (m *MockServer) Stop(ctx context.Context) {}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"regexp"
+	"sync/atomic"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+)
+
+var namespacePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// requestNamespace picks the namespace of a request: the field of the
+// request message, then the X-Namespace header, then the default one.
+func requestNamespace(meta RequestMeta, field string) (string, error) {
+	namespace := field
+	if namespace == "" {
+		namespace = meta.Namespace
+	}
+	if namespace == "" {
+		return locker.DefaultNamespace, nil
+	}
+	if !namespacePattern.MatchString(namespace) {
+		return "", helpers.Err_Srv_InvalidNamespace
+	}
+	return namespace, nil
+}
+
+// WithNamespaceRateLimits limits the requests per second of every
+// namespace, perNamespace overrides defaults for the namespaces it names.
+func WithNamespaceRateLimits(defaults RateLimit, perNamespace map[string]RateLimit) ServiceOption {
+	return func(s *LockService) {
+		s.SetNamespaceRateLimits(defaults, perNamespace)
+	}
+}
+
+// SetNamespaceRateLimits replaces the request rates of the namespaces,
+// the buckets of busy namespaces keep their tokens.
+func (s *LockService) SetNamespaceRateLimits(defaults RateLimit, perNamespace map[string]RateLimit) {
+	s.namespaceRates.limits.Store(&namespaceRateLimits{
+		defaults:     defaults,
+		perNamespace: perNamespace,
+	})
+}
+
+type namespaceRates struct {
+	*rateLimiters
+	limits atomic.Pointer[namespaceRateLimits]
+}
+
+type namespaceRateLimits struct {
+	defaults     RateLimit
+	perNamespace map[string]RateLimit
+}
+
+func newNamespaceRates() *namespaceRates {
+	n := &namespaceRates{}
+	n.limits.Store(&namespaceRateLimits{})
+	n.rateLimiters = newRateLimiters(n.limit)
+	return n
+}
+
+func (n *namespaceRates) limit(namespace string) RateLimit {
+	limits := n.limits.Load()
+	if limit, ok := limits.perNamespace[namespace]; ok {
+		return limit
+	}
+	return limits.defaults
+}
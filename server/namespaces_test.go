@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuotaExceeded(t *testing.T) {
+	s := startServers(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := s.locker.Reconfigure(ctx, locker.WithNamespaceLimits(locker.Limits{}, map[string]locker.Limits{"jobs": {MaxHeldLocks: 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "client")
+	_, err = s.locks().Lock(ctx, &sharelockPB.LockRequest{Key: "a", Namespace: "jobs", TimeoutMs: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.locks().Lock(ctx, &sharelockPB.LockRequest{Key: "b", Namespace: "jobs", TimeoutMs: 1000})
+	if status.Code(err) != codes.ResourceExhausted || helpers.ErrorReason(err) != helpers.Reason_QuotaExceeded {
+		t.Fatalf("got %v, want %s", err, helpers.Reason_QuotaExceeded)
+	}
+	if _, ok := helpers.RetryDelay(err); !ok {
+		t.Fatal("a quota error carries no retry delay")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://"+s.httpAddr+"/v2/locks/b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Client-Id", "client")
+	req.Header.Set("X-Namespace", "jobs")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("got %d with Retry-After %q, want 429 with a delay", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+}
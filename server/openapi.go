@@ -0,0 +1,262 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// OpenApi serves the OpenAPI 3 document of the v2 REST API.
+func (h *HttpServer) OpenApi(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", contentTypeJson)
+	w.Write(h.openApiDoc)
+}
+
+// buildOpenApi derives the OpenAPI 3 document from the same
+// google.api.http annotations the router is built from.
+func buildOpenApi() ([]byte, error) {
+	routes, err := restRoutes()
+	if err != nil {
+		return nil, err
+	}
+	b := &openApiBuilder{
+		schemas: map[string]any{
+			"HttpError": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"error": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"code":    map[string]any{"type": "integer", "format": "int32"},
+							"status":  map[string]any{"type": "string"},
+							"reason":  map[string]any{"type": "string"},
+							"message": map[string]any{"type": "string"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	paths := map[string]any{}
+	for _, route := range routes {
+		path := openApiPath(route.template)
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.httpMethod)] = b.operation(route)
+	}
+
+	doc := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "ShareLock",
+			"version": "v2",
+		},
+		"paths": paths,
+		// only enforced when the server runs with auth_enable
+		"security": []any{
+			map[string]any{"bearerAuth": []any{}},
+			map[string]any{"apiKey": []any{}},
+		},
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey":     map[string]any{"type": "apiKey", "in": "header", "name": "X-Api-Key"},
+			},
+		},
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type openApiBuilder struct {
+	schemas map[string]any
+}
+
+func (b *openApiBuilder) operation(route restRoute) map[string]any {
+	input := route.method.Input()
+	params := make([]any, 0)
+	for _, name := range route.pathParams {
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	if route.body == "" {
+		fields := input.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if slices.Contains(route.pathParams, string(fd.Name())) {
+				continue
+			}
+			params = append(params, map[string]any{
+				"name":   fd.JSONName(),
+				"in":     "query",
+				"schema": b.field(fd),
+			})
+		}
+	}
+	// every call on a lock key acts on behalf of a client, only
+	// inspecting a lock works without one
+	if slices.Contains(route.pathParams, "key") {
+		params = append(params, map[string]any{
+			"name":        "X-Client-Id",
+			"in":          "header",
+			"description": "with authentication enabled, an optional owner below the authenticated principal",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	if !slices.Contains(route.pathParams, "namespace") && route.method.Input().Fields().ByName("namespace") != nil {
+		params = append(params, map[string]any{
+			"name":        "X-Namespace",
+			"in":          "header",
+			"description": "namespace of the request when the message leaves it empty",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	if route.httpMethod != http.MethodGet {
+		params = append(params, map[string]any{
+			"name":        "Idempotency-Key",
+			"in":          "header",
+			"description": "retries with the same key return the first successful result",
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+
+	op := map[string]any{
+		"operationId": string(route.method.Name()),
+		"parameters":  params,
+		"responses": map[string]any{
+			"200": map[string]any{
+				"description": "OK",
+				"content":     b.content(b.ref(route.method.Output())),
+			},
+			"default": map[string]any{
+				"description": "error, see the Error Model section of the README",
+				"headers": map[string]any{
+					"Retry-After": map[string]any{
+						"description": "seconds to wait before retrying",
+						"schema":      map[string]any{"type": "integer"},
+					},
+				},
+				"content": map[string]any{
+					contentTypeJson: map[string]any{
+						"schema": map[string]any{"$ref": "#/components/schemas/HttpError"},
+					},
+				},
+			},
+		},
+	}
+	if unauthenticatedGrpcMethods["/sharelock.ShareLockService/"+string(route.method.Name())] {
+		op["security"] = []any{}
+	}
+	if route.body == "*" {
+		op["requestBody"] = map[string]any{
+			"content": b.content(b.message(input, route.pathParams)),
+		}
+	}
+	return op
+}
+
+func (b *openApiBuilder) content(schema map[string]any) map[string]any {
+	return map[string]any{
+		contentTypeJson:     map[string]any{"schema": schema},
+		contentTypeProtobuf: map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}},
+	}
+}
+
+// ref registers md under components and returns a reference to it.
+func (b *openApiBuilder) ref(md protoreflect.MessageDescriptor) map[string]any {
+	if schema, ok := wellKnownSchema(md); ok {
+		return schema
+	}
+	name := string(md.FullName())
+	if _, ok := b.schemas[name]; !ok {
+		// placeholder first, messages may refer to themselves
+		b.schemas[name] = map[string]any{}
+		b.schemas[name] = b.message(md, nil)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// message is the inline schema of md without the skipped fields.
+func (b *openApiBuilder) message(md protoreflect.MessageDescriptor, skip []string) map[string]any {
+	properties := map[string]any{}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if slices.Contains(skip, string(fd.Name())) {
+			continue
+		}
+		properties[fd.JSONName()] = b.field(fd)
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+func (b *openApiBuilder) field(fd protoreflect.FieldDescriptor) map[string]any {
+	if fd.IsMap() {
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": b.singular(fd.MapValue()),
+		}
+	}
+	if fd.IsList() {
+		return map[string]any{
+			"type":  "array",
+			"items": b.singular(fd),
+		}
+	}
+	return b.singular(fd)
+}
+
+// singular follows the proto3 JSON mapping, 64 bit integers are strings.
+func (b *openApiBuilder) singular(fd protoreflect.FieldDescriptor) map[string]any {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		names := make([]string, 0)
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			names = append(names, string(values.Get(i).Name()))
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return b.ref(fd.Message())
+	}
+	return map[string]any{"type": "string"}
+}
+
+func wellKnownSchema(md protoreflect.MessageDescriptor) (map[string]any, bool) {
+	switch md.FullName() {
+	case "google.protobuf.Timestamp":
+		return map[string]any{"type": "string", "format": "date-time"}, true
+	case "google.protobuf.Duration":
+		return map[string]any{"type": "string"}, true
+	}
+	return nil, false
+}
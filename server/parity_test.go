@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/genproto/googleapis/rpc/code"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// driver performs lock operations over one protocol and reports the
+// outcome as a protocol neutral string: the sharelockPB.Status name on
+// success, or "<google.rpc.Code>/<reason>" on failure.
+type driver interface {
+	Name() string
+	Lock(ctx context.Context, clientId, key string, timeoutMs int32) string
+	Unlock(ctx context.Context, clientId, key string) string
+}
+
+type grpcDriver struct {
+	client sharelockPB.ShareLockServiceClient
+}
+
+func (g *grpcDriver) Name() string {
+	return "grpc"
+}
+
+func (g *grpcDriver) Lock(ctx context.Context, clientId, key string, timeoutMs int32) string {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	resp, err := g.client.Lock(ctx, &sharelockPB.LockRequest{Key: key, TimeoutMs: timeoutMs})
+	if err != nil {
+		return grpcOutcome(err)
+	}
+	return resp.GetStatus().String()
+}
+
+func (g *grpcDriver) Unlock(ctx context.Context, clientId, key string) string {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	resp, err := g.client.Unlock(ctx, &sharelockPB.UnlockRequest{Key: key})
+	if err != nil {
+		return grpcOutcome(err)
+	}
+	return resp.GetStatus().String()
+}
+
+type httpDriver struct {
+	baseUrl string
+	client  *http.Client
+}
+
+func (h *httpDriver) Name() string {
+	return "http"
+}
+
+func (h *httpDriver) Lock(ctx context.Context, clientId, key string, timeoutMs int32) string {
+	return h.do(ctx, "/lock", clientId, &sharelockPB.LockRequest{Key: key, TimeoutMs: timeoutMs})
+}
+
+func (h *httpDriver) Unlock(ctx context.Context, clientId, key string) string {
+	return h.do(ctx, "/unlock", clientId, &sharelockPB.UnlockRequest{Key: key})
+}
+
+func (h *httpDriver) do(ctx context.Context, path, clientId string, body proto.Message) string {
+	reqJson, err := protojson.Marshal(body)
+	if err != nil {
+		return codes.Internal.String()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.baseUrl+path, bytes.NewBuffer(reqJson))
+	if err != nil {
+		return codes.Internal.String()
+	}
+	httpReq.Header.Set("X-Client-Id", clientId)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpResp, err := h.client.Do(httpReq)
+	if err != nil {
+		return grpcOutcome(status.Error(codes.Unavailable, err.Error()))
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		httpErr := HttpError{}
+		err = json.NewDecoder(httpResp.Body).Decode(&httpErr)
+		if err != nil {
+			return fmt.Sprintf("HTTP_%d", httpResp.StatusCode)
+		}
+		return httpErr.Error.Status + "/" + httpErr.Error.Reason
+	}
+
+	// only the status is compared, it has the same field number in
+	// lock and unlock responses
+	resp := sharelockPB.UnlockResponse{}
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err == nil {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(respBody, &resp)
+	}
+	if err != nil {
+		return grpcOutcome(status.Error(codes.Internal, err.Error()))
+	}
+	return resp.GetStatus().String()
+}
+
+func grpcOutcome(err error) string {
+	return code.Code(status.Code(err)).String() + "/" + helpers.ErrorReason(err)
+}
+
+const (
+	opLock   = "lock"
+	opUnlock = "unlock"
+	// opJoin waits for every background action started so far
+	opJoin = "join"
+)
+
+type action struct {
+	op         string
+	clientId   string
+	key        string
+	timeoutMs  int32
+	delay      time.Duration
+	background bool
+	expect     string
+}
+
+var parityScenarios = []struct {
+	name    string
+	actions []action
+}{
+	{
+		name: "acquire and release",
+		actions: []action{
+			{op: opLock, clientId: "a", key: "k", expect: "Acquired"},
+			{op: opUnlock, clientId: "a", key: "k", expect: "Released"},
+		},
+	},
+	{
+		name: "release a lock that is not held",
+		actions: []action{
+			{op: opUnlock, clientId: "a", key: "k", expect: "NOT_FOUND/LOCK_NOT_HELD"},
+		},
+	},
+	{
+		name: "release a lock held by another client",
+		actions: []action{
+			{op: opLock, clientId: "a", key: "k", expect: "Acquired"},
+			{op: opUnlock, clientId: "b", key: "k", expect: "NOT_FOUND/LOCK_NOT_HELD"},
+			{op: opUnlock, clientId: "a", key: "k", expect: "Released"},
+		},
+	},
+	{
+		name: "waiter acquires after the holder releases",
+		actions: []action{
+			{op: opLock, clientId: "a", key: "k", expect: "Acquired"},
+			{op: opLock, clientId: "b", key: "k", timeoutMs: 3000, background: true, expect: "Acquired"},
+			{op: opUnlock, clientId: "a", key: "k", delay: time.Millisecond * 300, expect: "Released"},
+			{op: opJoin},
+			{op: opUnlock, clientId: "b", key: "k", expect: "Released"},
+		},
+	},
+	{
+		name: "waiter times out while the lock is held",
+		actions: []action{
+			{op: opLock, clientId: "a", key: "k", expect: "Acquired"},
+			{op: opLock, clientId: "b", key: "k", timeoutMs: 300, expect: "DEADLINE_EXCEEDED/LOCK_WAIT_TIMEOUT"},
+			{op: opUnlock, clientId: "a", key: "k", expect: "Released"},
+		},
+	},
+	{
+		name: "missing key",
+		actions: []action{
+			{op: opLock, clientId: "a", key: "", expect: "INVALID_ARGUMENT/KEY_MISSING"},
+			{op: opUnlock, clientId: "a", key: "", expect: "INVALID_ARGUMENT/KEY_MISSING"},
+		},
+	},
+	{
+		name: "missing client id",
+		actions: []action{
+			{op: opLock, clientId: "", key: "k", expect: "INVALID_ARGUMENT/CLIENT_ID_MISSING"},
+			{op: opUnlock, clientId: "", key: "k", expect: "INVALID_ARGUMENT/CLIENT_ID_MISSING"},
+		},
+	},
+}
+
+// TestParity runs the same scenarios over gRPC and HTTP against one
+// lock service, both must answer every action identically. Keys are
+// prefixed with the driver and the scenario so they never contend.
+func TestParity(t *testing.T) {
+	s := startServers(t, nil)
+	drivers := []driver{
+		&grpcDriver{client: s.locks()},
+		&httpDriver{baseUrl: "http://" + s.httpAddr, client: &http.Client{}},
+	}
+	for _, sc := range parityScenarios {
+		for _, d := range drivers {
+			t.Run(sc.name+"/"+d.Name(), func(t *testing.T) {
+				t.Parallel()
+				results := make([]string, len(sc.actions))
+				wg := sync.WaitGroup{}
+				for i, a := range sc.actions {
+					if a.op == opJoin {
+						wg.Wait()
+						continue
+					}
+					key := a.key
+					if key != "" {
+						key = d.Name() + "/" + sc.name + "/" + key
+					}
+					run := func() {
+						time.Sleep(a.delay)
+						ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+						defer cancel()
+						switch a.op {
+						case opLock:
+							results[i] = d.Lock(ctx, a.clientId, key, a.timeoutMs)
+						case opUnlock:
+							results[i] = d.Unlock(ctx, a.clientId, key)
+						}
+					}
+					if !a.background {
+						run()
+						continue
+					}
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						run()
+					}()
+					// make sure the background request is queued first
+					time.Sleep(time.Millisecond * 50)
+				}
+				wg.Wait()
+				for i, a := range sc.actions {
+					if a.op != opJoin && results[i] != a.expect {
+						t.Errorf("action %d %s got %s, want %s", i, a.op, results[i], a.expect)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"sharelock/pkg/helpers"
+
+	"golang.org/x/time/rate"
+)
+
+// rateLimiterIdle is how long the bucket of a key is kept after its
+// last request. A bucket idle that long is full again anyway.
+const rateLimiterIdle = time.Minute * 10
+
+// RateLimit is a token bucket refilled with RequestsPerSecond tokens,
+// zero RequestsPerSecond is unlimited. Burst defaults to one second of
+// requests.
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+func (l RateLimit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(1, int(l.RequestsPerSecond))
+}
+
+// rateLimiters keeps one token bucket per key, a namespace, a client
+// or a source address.
+type rateLimiters struct {
+	mu        sync.Mutex
+	limitFor  func(key string) RateLimit
+	limiters  map[string]*keyedLimiter
+	lastSweep time.Time
+}
+
+type keyedLimiter struct {
+	limiter *rate.Limiter
+	// limit the limiter was last set to, limitFor may return another
+	// one after a config reload
+	limit    RateLimit
+	lastSeen time.Time
+}
+
+func newRateLimiters(limitFor func(key string) RateLimit) *rateLimiters {
+	return &rateLimiters{
+		limitFor:  limitFor,
+		limiters:  make(map[string]*keyedLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// reserve takes a token of key as of now, nil when key has no limit.
+// The token is only lent until takeTokens keeps or cancels it.
+func (r *rateLimiters) reserve(key string, now time.Time) *rate.Reservation {
+	limit := r.limitFor(key)
+	if limit.RequestsPerSecond <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	r.sweep(now)
+	entry, exist := r.limiters[key]
+	if !exist {
+		entry = &keyedLimiter{
+			limiter: rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.burst()),
+			limit:   limit,
+		}
+		r.limiters[key] = entry
+	}
+	if entry.limit != limit {
+		// keep the tokens left in the bucket, only its rate changes
+		entry.limiter.SetLimitAt(now, rate.Limit(limit.RequestsPerSecond))
+		entry.limiter.SetBurstAt(now, limit.burst())
+		entry.limit = limit
+	}
+	entry.lastSeen = now
+	r.mu.Unlock()
+
+	return entry.limiter.ReserveN(now, 1)
+}
+
+// takeTokens keeps the reservations if every token is available now.
+// Otherwise it cancels all of them, so a request one bucket rejects
+// takes no token of the others, and fails with a retry hint of when the
+// last missing token is available.
+func takeTokens(now time.Time, reservations ...*rate.Reservation) error {
+	var delay time.Duration
+	for _, reservation := range reservations {
+		if reservation != nil {
+			delay = max(delay, reservation.DelayFrom(now))
+		}
+	}
+	if delay == 0 {
+		return nil
+	}
+	for _, reservation := range reservations {
+		if reservation != nil {
+			reservation.CancelAt(now)
+		}
+	}
+	return helpers.WithRetryDelay(helpers.Err_Srv_RateLimited, delay)
+}
+
+// sweep forgets idle buckets, at most once a minute. r.mu must be held.
+func (r *rateLimiters) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < time.Minute {
+		return
+	}
+	r.lastSweep = now
+	for key, entry := range r.limiters {
+		if now.Sub(entry.lastSeen) > rateLimiterIdle {
+			delete(r.limiters, key)
+		}
+	}
+}
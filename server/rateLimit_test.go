@@ -0,0 +1,57 @@
+package server
+
+import (
+	"testing"
+
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitTakesNoTokenOnRejection(t *testing.T) {
+	// one token per bucket and no refill during the test
+	one := RateLimit{RequestsPerSecond: 0.001, Burst: 1}
+	two := RateLimit{RequestsPerSecond: 0.001, Burst: 2}
+	tests := []struct {
+		name       string
+		limits     AdmissionLimits
+		namespaces map[string]RateLimit
+		// rejected is sent first and spends the token of one bucket, then
+		// allowed comes from the same address
+		rejected RequestMeta
+		allowed  RequestMeta
+	}{
+		{
+			name:     "client bucket rejects",
+			limits:   AdmissionLimits{ClientRate: one, AddressRate: two},
+			rejected: RequestMeta{Namespace: "default", ClientId: "client-a", RemoteAddr: "10.0.0.1"},
+			allowed:  RequestMeta{Namespace: "default", ClientId: "client-b", RemoteAddr: "10.0.0.1"},
+		},
+		{
+			name:       "namespace bucket rejects",
+			limits:     AdmissionLimits{ClientRate: two, AddressRate: two},
+			namespaces: map[string]RateLimit{"busy": one},
+			rejected:   RequestMeta{Namespace: "busy", ClientId: "client-a", RemoteAddr: "10.0.0.1"},
+			allowed:    RequestMeta{Namespace: "default", ClientId: "client-a", RemoteAddr: "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &LockService{admission: newAdmission(tt.limits), namespaceRates: newNamespaceRates()}
+			s.SetNamespaceRateLimits(RateLimit{}, tt.namespaces)
+			if err := s.rateLimit(tt.rejected); err != nil {
+				t.Fatalf("first request: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				err := s.rateLimit(tt.rejected)
+				if _, ok := helpers.RetryDelay(err); status.Code(err) != codes.ResourceExhausted || !ok {
+					t.Fatalf("request %d: got %v, want a rate limit error with a retry delay", i+2, err)
+				}
+			}
+			if err := s.rateLimit(tt.allowed); err != nil {
+				t.Fatalf("the rejected requests took a token of another bucket: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"sharelock/pkg/helpers"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIdHeader carries the request id both ways, as an HTTP header
+// and as gRPC metadata.
+const requestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength bounds the ids taken from callers, longer ones are
+// replaced by a generated id.
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// requestId propagates the id sent by the caller, or generates one. Ids
+// with characters other than printable ASCII are replaced, they end up
+// in log lines and response headers.
+func requestId(sent string) string {
+	if validRequestId(sent) {
+		return sent
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// withRequestId stores id in ctx, along with a logger adding it to
+// every line.
+func withRequestId(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIdKey{}, id)
+	return helpers.ContextWithLogger(ctx, slog.Default().With("request_id", id))
+}
+
+// RequestIdFromContext returns the id of the request ctx belongs to.
+func RequestIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// grpcRequestId gives every call a request id and sends it back in the
+// response header metadata.
+func grpcRequestId(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := requestId(firstMetadata(md, requestIdHeader))
+	grpc.SetHeader(ctx, metadata.Pairs(requestIdHeader, id))
+	return handler(withRequestId(ctx, id), req)
+}
+
+// httpRequestId is grpcRequestId for the HTTP server.
+func httpRequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := requestId(r.Header.Get(requestIdHeader))
+		w.Header().Set(requestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(withRequestId(r.Context(), id)))
+	})
+}
@@ -1,6 +1,54 @@
 package server
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+
+	"sharelock/config"
+	"sharelock/pkg/listener"
+)
+
 type Server interface {
 	Start()
-	Stop()
+	// Stop lets in-flight requests finish until ctx is done, then closes
+	// the remaining connections.
+	Stop(ctx context.Context)
+}
+
+// Listening is implemented by the servers that have their listeners
+// open, as opposed to disabled or failed ones.
+type Listening interface {
+	// Addresses are the addresses listened on, in the form of
+	// listen addresses
+	Addresses() []string
+}
+
+// openListeners takes the sockets systemd passed under name, or else
+// listens on the addresses of cfg.
+func openListeners(name string, cfg *config.Server, format string) ([]net.Listener, error) {
+	inherited := listener.Inherited(name)
+	if len(inherited) > 0 {
+		slog.Info("server uses sockets passed by systemd", "server", name, "sockets", len(inherited))
+		return inherited, nil
+	}
+	return listener.Listen(listenAddresses(cfg, format), cfg.UnixSocketMode)
+}
+
+func describeListeners(listeners []net.Listener) []string {
+	addresses := make([]string, 0, len(listeners))
+	for _, lis := range listeners {
+		addresses = append(addresses, listener.Describe(lis))
+	}
+	return addresses
+}
+
+// listenAddresses are the addresses of cfg, or the one of format on
+// its port when none is configured.
+func listenAddresses(cfg *config.Server, format string) []string {
+	if len(cfg.ListenAddresses) > 0 {
+		return cfg.ListenAddresses
+	}
+	return []string{fmt.Sprintf(format, cfg.Port)}
 }
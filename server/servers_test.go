@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/auth"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// testServers are the gRPC and HTTP servers of one in-process service,
+// listening on free ports of localhost.
+type testServers struct {
+	service  *LockService
+	locker   *locker.Locker
+	grpcAddr string
+	httpAddr string
+	conn     *grpc.ClientConn
+}
+
+// startServers runs a new locker behind service options opts until the
+// test ends. A nil authenticator trusts the client ids.
+func startServers(t *testing.T, authenticator auth.Authenticator, opts ...ServiceOption) *testServers {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	lockerInstance := locker.NewLocker()
+	lockerDone := make(chan struct{})
+	go func() {
+		defer close(lockerDone)
+		lockerInstance.Start(ctx)
+	}()
+	s := &testServers{locker: lockerInstance, service: NewLockService(lockerInstance, opts...)}
+	listen := func(name string) *config.Server {
+		return &config.Server{Enable: true, ServiceName: name, ListenAddresses: []string{"127.0.0.1:0"}}
+	}
+	servers := []Server{
+		NewGrpcServer(ctx, listen("grpc"), s.service, authenticator),
+		NewHttpServer(ctx, listen("http"), s.service, authenticator),
+	}
+	for _, srv := range servers {
+		listening, ok := srv.(Listening)
+		if !ok || len(listening.Addresses()) == 0 {
+			t.Fatalf("server did not listen")
+		}
+		go srv.Start()
+	}
+	s.grpcAddr = servers[0].(Listening).Addresses()[0]
+	s.httpAddr = servers[1].(Listening).Addresses()[0]
+
+	var err error
+	s.conn, err = grpc.NewClient(s.grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.conn.Close()
+		stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelStopCtx()
+		for _, srv := range servers {
+			srv.Stop(stopCtx)
+		}
+		cancel()
+		<-lockerDone
+		s.service.Close()
+	})
+	return s
+}
+
+// freeAddr is a localhost address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func (s *testServers) locks() sharelockPB.ShareLockServiceClient {
+	return sharelockPB.NewShareLockServiceClient(s.conn)
+}
+
+// testTLS is a ca and a certificate of it for 127.0.0.1, written to
+// files as the servers read them.
+type testTLS struct {
+	caPath   string
+	certPath string
+	keyPath  string
+	// client dials the servers with the certificate
+	client *tls.Config
+	ca     *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+}
+
+func newTestTLS(t *testing.T) *testTLS {
+	t.Helper()
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "sharelock test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "sharelock test node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testTLS{
+		caPath:   filepath.Join(dir, "ca.crt"),
+		certPath: filepath.Join(dir, "node.crt"),
+		keyPath:  filepath.Join(dir, "node.key"),
+		ca:       caCert,
+		caKey:    caKey,
+	}
+	files := map[string]*pem.Block{
+		s.caPath:   {Type: "CERTIFICATE", Bytes: caDer},
+		s.certPath: {Type: "CERTIFICATE", Bytes: der},
+		s.keyPath:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	}
+	for path, block := range files {
+		err = os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	s.client = &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+	return s
+}
+
+// issue signs a client certificate of the ca for common name cn, with
+// uris as URI SANs.
+func (s *testTLS) issue(t *testing.T, cn string, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, s.ca, &key.PublicKey, s.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// server is cfg serving tls with the certificate, and requiring client
+// certificates of the ca.
+func (s *testTLS) server(cfg *config.Server) *config.Server {
+	cfg.TLS, cfg.CertPath, cfg.KeyPath, cfg.ClientCAPath = true, s.certPath, s.keyPath, s.caPath
+	return cfg
+}
@@ -0,0 +1,390 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/shard"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// shardSecretHeader carries the secret shared by the nodes of a
+	// sharded deployment on their internal calls
+	shardSecretHeader = "X-Sharelock-Shard-Secret"
+	// shardCallTimeout bounds the internal calls that do not wait for a
+	// lock
+	shardCallTimeout = time.Second * 5
+	// shardMoves bounds how often a request follows a key that moves
+	shardMoves = 3
+	// rebalanceCheck is how often a rebalancing node asks the others
+	// whether it is done
+	rebalanceCheck = time.Second
+)
+
+// shards routes the requests of a sharded deployment.
+type shards struct {
+	m         *shard.Map
+	secret    string
+	forwarder *forwarder
+	// plaintext forwarders would send the secret in the clear
+	plaintext bool
+}
+
+// WithShards partitions the keys between the nodes of m: requests for a
+// key another node owns are forwarded to it over gRPC, with secret and
+// over TLS with forwardTLS. The secret is never sent in plaintext, the
+// nodes forward nothing while forwardTLS is nil.
+func WithShards(m *shard.Map, secret string, forwardTLS *tls.Config) ServiceOption {
+	return func(s *LockService) {
+		if m == nil {
+			return
+		}
+		s.shards = &shards{m: m, secret: secret, forwarder: newForwarder(forwardTLS), plaintext: forwardTLS == nil}
+	}
+}
+
+// ShardRebalancing reports whether the keys of the last ring change
+// still move, SetShardRing refuses another change until they are done.
+func (s *LockService) ShardRebalancing() bool {
+	return s.shards != nil && s.shards.m.Previous() != nil
+}
+
+// SetShardRing starts moving the keys to ring. It fails while the last
+// change still rebalances.
+func (s *LockService) SetShardRing(ctx context.Context, ring *shard.Ring) error {
+	if s.shards == nil {
+		return nil
+	}
+	changed, err := s.shards.m.SetRing(ring)
+	if errors.Is(err, shard.ErrRebalancing) {
+		return helpers.Err_Srv_Rebalancing
+	}
+	if err != nil || !changed {
+		return err
+	}
+	// keys handed off on an earlier ring may come back, a key this node
+	// does not own is fenced again before it could be granted
+	err = s.locker.Unfence(ctx)
+	if err != nil {
+		return err
+	}
+	slog.Warn("shard ring changed, rebalancing", "ring_version", ring.Version(), "members", len(ring.Members()))
+	return nil
+}
+
+// WatchRebalance ends the rebalance of a ring change once every node of
+// the old and the new ring runs the new one and holds or queues no key
+// it does not own. From then on no node grants a key it does not own,
+// so the previous owners need not be asked anymore.
+func (s *LockService) WatchRebalance(ctx context.Context) {
+	if s.shards == nil {
+		return
+	}
+	ticker := time.NewTicker(rebalanceCheck)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		previous := s.shards.m.Previous()
+		if previous == nil {
+			continue
+		}
+		done, waiting := s.rebalanced(ctx, previous)
+		if !done {
+			slog.Debug("rebalance still running", "waiting_for", waiting)
+			continue
+		}
+		s.shards.m.Finish()
+		slog.Warn("shard rebalance finished", "ring_version", s.shards.m.Ring().Version())
+	}
+}
+
+// rebalanced asks every node of previous and of the current ring for
+// its status, and names the first node that is not done.
+func (s *LockService) rebalanced(ctx context.Context, previous *shard.Ring) (bool, string) {
+	ring := s.shards.m.Ring()
+	members := ring.Members()
+	for _, member := range previous.Members() {
+		if _, ok := ring.Member(member.Id); !ok {
+			members = append(members, member)
+		}
+	}
+	for _, member := range members {
+		var status *sharelockPB.ShardStatus
+		var err error
+		if member.Id == s.shards.m.Self() {
+			status, err = s.shardStatus(ctx)
+		} else {
+			callCtx, cancel := context.WithTimeout(s.shards.outgoing(ctx), shardCallTimeout)
+			var conn shardClient
+			conn, err = s.shards.client(member)
+			if err == nil {
+				status, err = conn.GetShardStatus(callCtx, &sharelockPB.GetShardStatusRequest{})
+			}
+			cancel()
+		}
+		if err != nil || status.RingVersion != ring.Version() || status.ForeignKeys > 0 {
+			return false, member.Id
+		}
+	}
+	return true, ""
+}
+
+// shardStatus is the view of the ring of this node.
+func (s *LockService) shardStatus(ctx context.Context) (*sharelockPB.ShardStatus, error) {
+	foreign, err := s.locker.ForeignKeys(ctx, s.shards.m.Owns)
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	ring := s.shards.m.Ring()
+	status := &sharelockPB.ShardStatus{
+		NodeId:      s.shards.m.Self(),
+		RingVersion: ring.Version(),
+		ForeignKeys: int32(foreign),
+	}
+	if previous := s.shards.m.Previous(); previous != nil {
+		status.Rebalancing = true
+		status.PreviousRingVersion = previous.Version()
+	}
+	for _, member := range ring.Members() {
+		status.Members = append(status.Members, &sharelockPB.ShardMember{Id: member.Id, GrpcAddress: member.GrpcAddress})
+	}
+	return status, nil
+}
+
+// routed serves a request for key on the node that may grant it. local
+// serves it here, foreign when another node owns the key: the request
+// then only runs here while the key is busy here, and fails with
+// KEY_MOVED otherwise. call is the request for another node, reply
+// takes its answer out of the reply of that node.
+func (s *LockService) routed(ctx context.Context, meta RequestMeta, key string, call *sharelockPB.ShardCall,
+	local func(foreign bool) (any, error), reply func(*sharelockPB.ShardReply) any) (any, error) {
+	if s.shards == nil {
+		return local(false)
+	}
+	var err error
+	for moves := 0; moves < shardMoves; moves++ {
+		route := s.shards.m.Route(meta.Namespace, key)
+		target := route.Owner
+		if route.HandOff {
+			var handed bool
+			handed, err = s.shards.handOff(ctx, route.Previous, meta.Namespace, key)
+			if err != nil {
+				return nil, err
+			}
+			if handed {
+				s.shards.m.Migrated(route, meta.Namespace, key)
+				route.Local = true
+			} else {
+				// the previous owner serves the key until it is idle
+				target = route.Previous
+			}
+		}
+		if route.Local {
+			var result any
+			result, err = local(route.Foreign)
+			if helpers.ErrorReason(err) != helpers.Reason_KeyMoved {
+				return result, err
+			}
+			if route.Owner.Id == s.shards.m.Self() {
+				// handed off by a node that saw the ring change first
+				return nil, helpers.WithRetryDelay(err, time.Second)
+			}
+			target = route.Owner
+		}
+		// a node forwards the requests it got from a client, and the owner
+		// of a key forwards them to the previous owner still serving it.
+		// Otherwise the first node routes the request again.
+		if meta.Hops > 1 || (meta.Hops == 1 && !(route.HandOff && target.Id == route.Previous.Id)) {
+			return nil, helpers.Err_Srv_KeyMoved
+		}
+		var resp *sharelockPB.ShardReply
+		resp, err = s.shards.call(ctx, target, meta, call)
+		if helpers.ErrorReason(err) == helpers.Reason_KeyMoved {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reply(resp), nil
+	}
+	helpers.Logger(ctx).Info("key kept moving", "moves", shardMoves)
+	return nil, helpers.WithRetryDelay(helpers.Err_Srv_KeyMoved, time.Second)
+}
+
+// foreignProbe hands off a key another node owns if it is idle here,
+// and then fails with KEY_MOVED, so the request goes to the owner.
+// Requests that do not lock use it before running here.
+func (s *LockService) foreignProbe(ctx context.Context, namespace, key string) error {
+	handed, err := s.locker.HandOff(ctx, namespace, key)
+	if err != nil {
+		return contextError(ctx)
+	}
+	if handed {
+		return helpers.Err_Srv_KeyMoved
+	}
+	return nil
+}
+
+// errShardPlaintext refuses to call another node without tls.
+var errShardPlaintext = errors.New("shard forwarding needs tls")
+
+// shardClient is the internal service of another node.
+type shardClient = sharelockPB.ShareLockShardClient
+
+func (sh *shards) client(member shard.Member) (shardClient, error) {
+	if sh.plaintext {
+		slog.Error("not calling another shard without tls, the secret of the nodes is never sent in plaintext", "member", member.Id)
+		return nil, errShardPlaintext
+	}
+	conn, err := sh.forwarder.conn(member.GrpcAddress)
+	if err != nil {
+		return nil, err
+	}
+	return sharelockPB.NewShareLockShardClient(conn), nil
+}
+
+// outgoing adds the secret of the nodes to ctx.
+func (sh *shards) outgoing(ctx context.Context) context.Context {
+	md := metadata.Pairs(shardSecretHeader, sh.secret)
+	if id := RequestIdFromContext(ctx); id != "" {
+		md.Set(requestIdHeader, id)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// handOff asks member to hand off a key and reports whether it did.
+func (sh *shards) handOff(ctx context.Context, member shard.Member, namespace, key string) (bool, error) {
+	client, err := sh.client(member)
+	if err != nil {
+		return false, helpers.Err_Srv_KeyMoved
+	}
+	callCtx, cancel := context.WithTimeout(sh.outgoing(ctx), shardCallTimeout)
+	defer cancel()
+	resp, err := client.HandOff(callCtx, &sharelockPB.HandOffRequest{Namespace: namespace, Key: key})
+	if err != nil {
+		helpers.Logger(ctx).Warn("asking the previous owner of a key to hand it off", "member", member.Id, "err", err)
+		return false, helpers.WithRetryDelay(helpers.Err_Srv_KeyMoved, time.Second)
+	}
+	return resp.HandedOff, nil
+}
+
+// call sends a request to member with the metadata of the caller.
+func (sh *shards) call(ctx context.Context, member shard.Member, meta RequestMeta, call *sharelockPB.ShardCall) (*sharelockPB.ShardReply, error) {
+	client, err := sh.client(member)
+	if err != nil {
+		return nil, helpers.Err_Srv_KeyMoved
+	}
+	call.ClientId = meta.ClientId
+	call.Principal = meta.Principal
+	call.Namespace = meta.Namespace
+	call.RemoteAddr = meta.RemoteAddr
+	call.IdempotencyKey = meta.IdempotencyKey
+	call.RequestId = meta.RequestId
+	call.Transport = meta.Transport
+	call.Hops = int32(meta.Hops + 1)
+	return client.Call(sh.outgoing(ctx), call)
+}
+
+// checkShardSecret fails unless the call carries the secret of the
+// nodes.
+func (s *LockService) checkShardSecret(ctx context.Context) error {
+	if s.shards == nil {
+		return helpers.Err_Srv_MethodNotAllowed
+	}
+	if p, ok := peer.FromContext(ctx); !ok || p.AuthInfo == nil || p.AuthInfo.AuthType() != "tls" {
+		helpers.Logger(ctx).Warn("rejected internal shard call without tls", "remote_addr", peerAddr(ctx))
+		return helpers.Err_Srv_Unauthenticated
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	secret := firstMetadata(md, shardSecretHeader)
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(s.shards.secret)) != 1 {
+		helpers.Logger(ctx).Warn("rejected internal shard call", "remote_addr", peerAddr(ctx))
+		return helpers.Err_Srv_Unauthenticated
+	}
+	return nil
+}
+
+// ShardCall serves a request forwarded by another node.
+func (s *LockService) ShardCall(ctx context.Context, call *sharelockPB.ShardCall) (*sharelockPB.ShardReply, error) {
+	err := s.checkShardSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	meta := RequestMeta{
+		ClientId:       call.ClientId,
+		Principal:      call.Principal,
+		Namespace:      call.Namespace,
+		RemoteAddr:     call.RemoteAddr,
+		IdempotencyKey: call.IdempotencyKey,
+		RequestId:      call.RequestId,
+		Transport:      call.Transport,
+		Hops:           max(int(call.Hops), 1),
+	}
+	switch {
+	case call.Lock != nil:
+		resp, err := s.Lock(ctx, meta, call.Lock)
+		return &sharelockPB.ShardReply{Lock: resp}, err
+	case call.Unlock != nil:
+		resp, err := s.Unlock(ctx, meta, call.Unlock)
+		return &sharelockPB.ShardReply{Unlock: resp}, err
+	case call.RenewLock != nil:
+		resp, err := s.RenewLock(ctx, meta, call.RenewLock)
+		return &sharelockPB.ShardReply{RenewLock: resp}, err
+	case call.Inspect != nil:
+		resp, err := s.GetLock(ctx, meta, call.Inspect)
+		return &sharelockPB.ShardReply{LockInfo: resp}, err
+	}
+	return nil, helpers.Err_Srv_NilRequest
+}
+
+// ShardHandOff hands off a key to the node asking for it, if it is
+// idle here.
+func (s *LockService) ShardHandOff(ctx context.Context, r *sharelockPB.HandOffRequest) (*sharelockPB.HandOffResponse, error) {
+	err := s.checkShardSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	handed, err := s.locker.HandOff(ctx, r.GetNamespace(), r.GetKey())
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	return &sharelockPB.HandOffResponse{HandedOff: handed}, nil
+}
+
+// ShardStatus reports the view of the ring of this node.
+func (s *LockService) ShardStatus(ctx context.Context) (*sharelockPB.ShardStatus, error) {
+	err := s.checkShardSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.shardStatus(ctx)
+}
+
+// FinishRebalance ends the rebalance on this node without waiting for
+// the other nodes, when one of them is gone for good. Its locks are gone
+// with it, but a node that still runs and holds keys it does not own
+// may then see them granted twice.
+func (s *LockService) FinishRebalance(ctx context.Context) (*sharelockPB.ShardStatus, error) {
+	err := s.checkShardSecret(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.shards.m.Previous() != nil {
+		s.shards.m.Finish()
+		slog.Warn("shard rebalance finished by an operator", "ring_version", s.shards.m.Ring().Version())
+	}
+	return s.shardStatus(ctx)
+}
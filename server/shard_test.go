@@ -0,0 +1,317 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/shard"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// shardSecret the in-process shards call each other with
+const shardSecret = "shard-test-secret"
+
+// testShard is one in-process shard, with its locker, its gRPC server
+// and a client of it.
+type testShard struct {
+	id      string
+	locker  *locker.Locker
+	m       *shard.Map
+	service *LockService
+	locks   sharelockPB.ShareLockServiceClient
+}
+
+// testShards are shards of one ring on localhost.
+type testShards struct {
+	nodes []*testShard
+}
+
+// startShards runs a shard for every member of ring until the test ends.
+// The nodes serve gRPC over mutual tls and call each other with the
+// certificate of certs.
+func startShards(t *testing.T, ring *shard.Ring, certs *testTLS) *testShards {
+	s := &testShards{}
+	for _, member := range ring.Members() {
+		s.nodes = append(s.nodes, startShard(t, member, ring, certs))
+	}
+	return s
+}
+
+func startShard(t *testing.T, self shard.Member, ring *shard.Ring, certs *testTLS) *testShard {
+	n := &testShard{id: self.Id, m: shard.NewMap(self.Id, ring)}
+	ctx, cancel := context.WithCancel(context.Background())
+	n.locker = locker.NewLocker()
+	lockerEnd := make(chan struct{})
+	go func() {
+		defer close(lockerEnd)
+		n.locker.Start(ctx)
+	}()
+
+	n.service = NewLockService(n.locker, WithShards(n.m, shardSecret, certs.client))
+	go n.service.WatchRebalance(ctx)
+	srv := NewGrpcServer(ctx, certs.server(&config.Server{Enable: true, ServiceName: self.Id, ListenAddresses: []string{self.GrpcAddress}}), n.service, nil)
+	go srv.Start()
+
+	conn, err := grpc.NewClient(self.GrpcAddress, grpc.WithTransportCredentials(credentials.NewTLS(certs.client)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.locks = sharelockPB.NewShareLockServiceClient(conn)
+	t.Cleanup(func() {
+		conn.Close()
+		stopCtx, cancelStopCtx := context.WithTimeout(context.Background(), time.Second)
+		defer cancelStopCtx()
+		srv.Stop(stopCtx)
+		cancel()
+		<-lockerEnd
+		n.service.Close()
+	})
+	return n
+}
+
+// testRing is a ring of size members on free localhost ports.
+func testRing(t *testing.T, size int) *shard.Ring {
+	members := make([]shard.Member, 0, size)
+	for i := 0; i < size; i++ {
+		members = append(members, shard.Member{Id: fmt.Sprintf("node%d", i), GrpcAddress: freeAddr(t)})
+	}
+	ring, err := shard.NewRing(members, shard.DefaultVirtualNodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func (s *testShards) node(id string) *testShard {
+	for _, n := range s.nodes {
+		if n.id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// other is a node that is none of ids.
+func (s *testShards) other(ids ...string) *testShard {
+	for _, n := range s.nodes {
+		found := false
+		for _, id := range ids {
+			found = found || n.id == id
+		}
+		if !found {
+			return n
+		}
+	}
+	return nil
+}
+
+// heldBy fails unless node holds the only lock of all nodes.
+func (s *testShards) heldBy(t *testing.T, ctx context.Context, node string) {
+	t.Helper()
+	for _, n := range s.nodes {
+		held, err := n.locker.HeldLocks(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := 0
+		if n.id == node {
+			want = 1
+		}
+		if held != want {
+			t.Fatalf("%s holds %d locks, want %d", n.id, held, want)
+		}
+	}
+}
+
+// keyOf finds a key node owns on ring.
+func keyOf(ring *shard.Ring, prefix, node string) string {
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("%s-%d", prefix, i)
+		if ring.Owner("default", key).Id == node {
+			return key
+		}
+	}
+}
+
+func shardLock(ctx context.Context, n *testShard, clientId, key string, timeout time.Duration) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	resp, err := n.locks.Lock(ctx, &sharelockPB.LockRequest{
+		Key:       key,
+		TimeoutMs: int32(timeout.Milliseconds()),
+		LeaseMs:   int32(time.Minute.Milliseconds()),
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Status != sharelockPB.Status_Acquired {
+		return fmt.Errorf("lock status %s", resp.Status)
+	}
+	return nil
+}
+
+func shardUnlock(ctx context.Context, n *testShard, clientId, key string) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, "X-Client-Id", clientId)
+	_, err := n.locks.Unlock(ctx, &sharelockPB.UnlockRequest{Key: key})
+	return err
+}
+
+// TestShards runs three shards and checks that every key is granted by
+// its owner on the ring whichever node is asked, and that a key moving
+// to another node on a ring change stays with its previous owner while
+// it is held or queued there, and moves once it is idle. The steps run
+// in order on the same shards.
+func TestShards(t *testing.T) {
+	ring := testRing(t, 3)
+	s := startShards(t, ring, newTestTLS(t))
+	// the next ring leaves node2 out, its keys move to the others
+	next, err := shard.NewRing(ring.Members()[:2], shard.DefaultVirtualNodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// moving is a key of node2 on ring, waiter gets it while it moves
+	moving := keyOf(ring, "moving", "node2")
+
+	steps := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context)
+	}{
+		{"the owner grants a key whichever node is asked", func(t *testing.T, ctx context.Context) {
+			key := keyOf(ring, "routed", "node1")
+			asked := s.other("node1")
+			err := shardLock(ctx, asked, "client-a", key, time.Second)
+			if err != nil {
+				t.Fatalf("locking through %s : %v", asked.id, err)
+			}
+			s.heldBy(t, ctx, "node1")
+			// the lock is taken whichever node the other client asks
+			err = shardLock(ctx, s.other("node1", asked.id), "client-b", key, time.Millisecond*200)
+			if err == nil {
+				t.Fatal("a second client got the lock")
+			}
+			err = shardUnlock(ctx, s.node("node1"), "client-a", key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"any node inspects a key of another node", func(t *testing.T, ctx context.Context) {
+			key := keyOf(ring, "inspected", "node0")
+			err := shardLock(ctx, s.node("node0"), "client-a", key, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			infoCtx := metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "client-a")
+			info, err := s.node("node2").locks.GetLock(infoCtx, &sharelockPB.GetLockRequest{Key: key})
+			if err != nil {
+				t.Fatalf("inspecting through node2 : %v", err)
+			}
+			if info.Holder != "client-a" {
+				t.Fatalf("holder %q, want client-a", info.Holder)
+			}
+			err = shardUnlock(ctx, s.node("node2"), "client-a", key)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"a held key stays with its previous owner on a ring change", func(t *testing.T, ctx context.Context) {
+			owner := s.node(next.Owner("default", moving).Id)
+			err := shardLock(ctx, s.node("node2"), "holder", moving, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range s.nodes {
+				err = n.service.SetShardRing(ctx, next)
+				if err != nil {
+					t.Fatalf("changing the ring of %s : %v", n.id, err)
+				}
+			}
+
+			// the new owner queues the waiter on node2, which still holds the key
+			acquired := make(chan error, 1)
+			go func() {
+				acquired <- shardLock(ctx, owner, "waiter", moving, time.Second*20)
+			}()
+			time.Sleep(time.Millisecond * 300)
+			s.heldBy(t, ctx, "node2")
+			err = shardUnlock(ctx, owner, "holder", moving)
+			if err != nil {
+				t.Fatalf("unlocking holder : %v", err)
+			}
+			err = <-acquired
+			if err != nil {
+				t.Fatalf("waiter : %v", err)
+			}
+			s.heldBy(t, ctx, "node2")
+		}},
+		{"an idle key is handed off to its new owner", func(t *testing.T, ctx context.Context) {
+			err := shardUnlock(ctx, s.node("node0"), "waiter", moving)
+			if err != nil {
+				t.Fatalf("unlocking waiter : %v", err)
+			}
+			err = shardLock(ctx, s.node("node2"), "client-a", moving, time.Second)
+			if err != nil {
+				t.Fatalf("locking through node2 : %v", err)
+			}
+			s.heldBy(t, ctx, next.Owner("default", moving).Id)
+			err = shardUnlock(ctx, s.node("node2"), "client-a", moving)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"the rebalance finishes once no key is foreign", func(t *testing.T, ctx context.Context) {
+			for {
+				rebalancing := 0
+				for _, n := range s.nodes {
+					if n.m.Previous() != nil {
+						rebalancing++
+					}
+				}
+				if rebalancing == 0 {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					t.Fatalf("%d nodes still rebalance", rebalancing)
+				case <-time.After(time.Millisecond * 100):
+				}
+			}
+		}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+			defer cancel()
+			step.run(t, ctx)
+		})
+	}
+}
+
+// TestShardSecretNeedsTLS checks that the secret of the nodes never
+// travels in plaintext, neither to nor from a node without tls.
+func TestShardSecretNeedsTLS(t *testing.T) {
+	ring := testRing(t, 2)
+	m := shard.NewMap("node0", ring)
+	s := startServers(t, nil, WithShards(m, shardSecret, nil))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	secretCtx := metadata.AppendToOutgoingContext(ctx, shardSecretHeader, shardSecret)
+	_, err := sharelockPB.NewShareLockShardClient(s.conn).GetShardStatus(secretCtx, &sharelockPB.GetShardStatusRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("a plaintext internal call got %v, want Unauthenticated", err)
+	}
+
+	err = shardLock(ctx, &testShard{locks: s.locks()}, "client-a", keyOf(ring, "forwarded", "node1"), time.Second)
+	if err == nil {
+		t.Fatal("a key of another node was forwarded without tls")
+	}
+}
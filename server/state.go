@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// snapshotVersion is the version of the StateSnapshot format this node
+// writes and reads.
+const snapshotVersion = 1
+
+// ExportState returns the whole state of the locker, optionally freezing
+// it so the snapshot stays the state of record while the clients move
+// to the node it is imported into.
+func (s *LockService) ExportState(ctx context.Context, meta RequestMeta, r *sharelockPB.ExportStateRequest) (resp *sharelockPB.StateSnapshot, err error) {
+	ctx, finish := s.begin(ctx, "ExportState", meta, "")
+	defer finish(&err)
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.locker.Export(ctx, r.GetFreeze())
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	helpers.Logger(ctx).Warn("locker state exported", "held_locks", len(state.Locks), "waiters", len(state.Queued), "freeze", r.GetFreeze())
+	return s.snapshot(state, time.Now()), nil
+}
+
+// ImportState loads a snapshot into a locker that holds and queues
+// nothing. The holders and the waiters take their locks and their places
+// back when their clients retry against this node.
+func (s *LockService) ImportState(ctx context.Context, meta RequestMeta, r *sharelockPB.ImportStateRequest) (resp *sharelockPB.ImportStateResponse, err error) {
+	ctx, finish := s.begin(ctx, "ImportState", meta, "")
+	defer finish(&err)
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.snapshotState(r.GetSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	err = s.locker.Import(ctx, state)
+	switch {
+	case errors.Is(err, locker.ErrNotEmpty):
+		return nil, helpers.Err_Srv_StateNotEmpty
+	case errors.Is(err, locker.ErrNotLeader):
+		return nil, s.notLeaderError()
+	case ctx.Err() != nil:
+		return nil, contextError(ctx)
+	case err != nil:
+		helpers.Logger(ctx).Error("importing locker state", "err", err)
+		return nil, helpers.Err_Srv_Internal
+	}
+	return &sharelockPB.ImportStateResponse{
+		HeldLocks: int32(len(state.Locks)),
+		Waiters:   int32(len(state.Queued)),
+	}, nil
+}
+
+// ThawState lets a locker frozen by ExportState grant locks again.
+func (s *LockService) ThawState(ctx context.Context, meta RequestMeta, r *sharelockPB.ThawStateRequest) (resp *sharelockPB.ThawStateResponse, err error) {
+	ctx, finish := s.begin(ctx, "ThawState", meta, "")
+	defer finish(&err)
+	err = s.admitAdmin(meta)
+	if err != nil {
+		return nil, err
+	}
+	err = s.locker.Thaw(ctx)
+	if err != nil {
+		return nil, contextError(ctx)
+	}
+	return &sharelockPB.ThawStateResponse{}, nil
+}
+
+// nodeId names this node in a cluster or a sharded deployment.
+func (s *LockService) nodeId() string {
+	switch {
+	case s.cluster != nil:
+		return s.cluster.Id()
+	case s.shards != nil:
+		return s.shards.m.Self()
+	}
+	return ""
+}
+
+// snapshot is state as taken at now, its keys sorted by namespace and
+// key so that two snapshots of the same state compare equal.
+func (s *LockService) snapshot(state locker.State, now time.Time) *sharelockPB.StateSnapshot {
+	snapshot := &sharelockPB.StateSnapshot{
+		Version:    snapshotVersion,
+		CreateTime: timestamppb.New(now),
+		NodeId:     s.nodeId(),
+		LeaseSeq:   state.LeaseSeq,
+	}
+	keys := make(map[string]*sharelockPB.SnapshotKey, len(state.Locks))
+	namespaces := make(map[string]*sharelockPB.SnapshotNamespace)
+	usage := func(name string) *sharelockPB.SnapshotNamespace {
+		ns, ok := namespaces[name]
+		if !ok {
+			ns = &sharelockPB.SnapshotNamespace{Namespace: name}
+			namespaces[name] = ns
+		}
+		return ns
+	}
+	for _, lock := range state.Locks {
+		key := &sharelockPB.SnapshotKey{
+			Namespace:        lock.Namespace,
+			Key:              lock.Key,
+			Holder:           lock.Holder,
+			LeaseId:          lock.LeaseId,
+			HeldSince:        timestamppb.New(lock.HeldSince),
+			LeaseExpireTime:  timestamppb.New(lock.LeaseExpiresAt),
+			LeaseRemainingMs: max(lock.LeaseExpiresAt.Sub(now).Milliseconds(), 0),
+		}
+		keys[lock.Namespace+"\x00"+lock.Key] = key
+		snapshot.Keys = append(snapshot.Keys, key)
+		ns := usage(lock.Namespace)
+		ns.Keys++
+		ns.HeldLocks++
+	}
+	for _, queued := range state.Queued {
+		key, ok := keys[queued.Namespace+"\x00"+queued.Key]
+		if !ok {
+			// the locker grants a free key before it answers, a queue
+			// always has a holder
+			continue
+		}
+		key.Waiters = append(key.Waiters, &sharelockPB.SnapshotWaiter{
+			ClientId:  queued.ClientId,
+			LeaseMs:   queued.Lease.Milliseconds(),
+			QueuedAt:  timestamppb.New(queued.QueuedAt),
+			WaitUntil: timestamppb.New(queued.WaitUntil),
+		})
+		usage(queued.Namespace).Waiters++
+	}
+	sort.Slice(snapshot.Keys, func(i, j int) bool {
+		a, b := snapshot.Keys[i], snapshot.Keys[j]
+		return a.Namespace < b.Namespace || (a.Namespace == b.Namespace && a.Key < b.Key)
+	})
+	for _, ns := range namespaces {
+		snapshot.Namespaces = append(snapshot.Namespaces, ns)
+	}
+	sort.Slice(snapshot.Namespaces, func(i, j int) bool {
+		return snapshot.Namespaces[i].Namespace < snapshot.Namespaces[j].Namespace
+	})
+	return snapshot
+}
+
+// snapshotState checks a snapshot and turns it into the state of a
+// locker. A sharded node only takes the keys it owns.
+func (s *LockService) snapshotState(snapshot *sharelockPB.StateSnapshot) (locker.State, error) {
+	if snapshot == nil {
+		return locker.State{}, helpers.Err_Srv_NilRequest
+	}
+	if snapshot.Version != snapshotVersion {
+		return locker.State{}, invalidSnapshot("version %d is not supported, this node reads version %d", snapshot.Version, snapshotVersion)
+	}
+	state := locker.State{LeaseSeq: snapshot.LeaseSeq}
+	seen := make(map[string]bool, len(snapshot.Keys))
+	for _, key := range snapshot.Keys {
+		namespace := key.Namespace
+		if namespace == "" {
+			namespace = locker.DefaultNamespace
+		}
+		switch {
+		case !namespacePattern.MatchString(namespace):
+			return locker.State{}, invalidSnapshot("namespace %q is not allowed", namespace)
+		case key.Key == "" || key.Holder == "":
+			return locker.State{}, invalidSnapshot("a key of namespace %s misses its name or its holder", namespace)
+		case key.LeaseExpireTime == nil:
+			return locker.State{}, invalidSnapshot("key %s of namespace %s has no lease expire time", key.Key, namespace)
+		case seen[namespace+"\x00"+key.Key]:
+			return locker.State{}, invalidSnapshot("key %s of namespace %s is listed twice", key.Key, namespace)
+		case s.shards != nil && !s.shards.m.Owns(namespace, key.Key):
+			return locker.State{}, invalidSnapshot("key %s of namespace %s belongs to node %s", key.Key, namespace,
+				s.shards.m.Ring().Owner(namespace, key.Key).Id)
+		}
+		seen[namespace+"\x00"+key.Key] = true
+		state.Locks = append(state.Locks, locker.HeldLock{
+			Namespace:      namespace,
+			Key:            key.Key,
+			Holder:         key.Holder,
+			LeaseId:        key.LeaseId,
+			HeldSince:      key.HeldSince.AsTime(),
+			LeaseExpiresAt: key.LeaseExpireTime.AsTime(),
+		})
+		state.LeaseSeq = max(state.LeaseSeq, key.LeaseId)
+		waiting := make(map[string]bool, len(key.Waiters))
+		for _, waiter := range key.Waiters {
+			switch {
+			case waiter.ClientId == "" || waiter.WaitUntil == nil:
+				return locker.State{}, invalidSnapshot("a waiter for key %s of namespace %s misses its client id or its wait end", key.Key, namespace)
+			case waiter.LeaseMs < 0:
+				return locker.State{}, invalidSnapshot("waiter %s for key %s of namespace %s has a negative lease", waiter.ClientId, key.Key, namespace)
+			case waiter.ClientId == key.Holder:
+				return locker.State{}, invalidSnapshot("waiter %s for key %s of namespace %s holds the key", waiter.ClientId, key.Key, namespace)
+			case waiting[waiter.ClientId]:
+				return locker.State{}, invalidSnapshot("waiter %s for key %s of namespace %s is queued twice", waiter.ClientId, key.Key, namespace)
+			}
+			waiting[waiter.ClientId] = true
+			state.Queued = append(state.Queued, locker.QueuedLock{
+				Namespace: namespace,
+				Key:       key.Key,
+				ClientId:  waiter.ClientId,
+				Lease:     time.Duration(waiter.LeaseMs) * time.Millisecond,
+				QueuedAt:  waiter.QueuedAt.AsTime(),
+				WaitUntil: waiter.WaitUntil.AsTime(),
+			})
+		}
+	}
+	return state, nil
+}
+
+func invalidSnapshot(format string, args ...any) error {
+	return helpers.NewError(codes.InvalidArgument, helpers.Reason_InvalidSnapshot, "invalid snapshot : "+fmt.Sprintf(format, args...))
+}
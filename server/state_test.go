@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sharelock/pkg/auth"
+	"sharelock/pkg/helpers"
+	"sharelock/pkg/locker"
+	"sharelock/pkg/shard"
+	"sharelock/pkg/sharelockPB"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// adminMeta is a caller the policy of withAdminPolicy grants admin.
+var adminMeta = RequestMeta{Principal: "ops", Namespace: "default"}
+
+func withAdminPolicy(t *testing.T) ServiceOption {
+	t.Helper()
+	policy, err := auth.NewPolicy([]auth.PolicyRule{
+		{Principals: []string{"ops"}, Keys: []string{"**"}, Actions: []auth.Action{auth.Action_Admin}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &auth.PolicyStore{}
+	store.Set(policy)
+	return WithPolicy(store)
+}
+
+func TestStateNeedsAdmin(t *testing.T) {
+	calls := map[string]func(s *LockService, meta RequestMeta) error{
+		"ExportState": func(s *LockService, meta RequestMeta) error {
+			_, err := s.ExportState(context.Background(), meta, &sharelockPB.ExportStateRequest{Freeze: true})
+			return err
+		},
+		"ImportState": func(s *LockService, meta RequestMeta) error {
+			_, err := s.ImportState(context.Background(), meta, &sharelockPB.ImportStateRequest{Snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion}})
+			return err
+		},
+		"ThawState": func(s *LockService, meta RequestMeta) error {
+			_, err := s.ThawState(context.Background(), meta, &sharelockPB.ThawStateRequest{})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			withoutPolicy := startServers(t, nil)
+			if err := call(withoutPolicy.service, adminMeta); !errors.Is(err, helpers.Err_Srv_PermissionDenied) {
+				t.Fatalf("without a policy: got %v, want %v", err, helpers.Err_Srv_PermissionDenied)
+			}
+			withPolicy := startServers(t, nil, withAdminPolicy(t))
+			if err := call(withPolicy.service, RequestMeta{Namespace: "default"}); !errors.Is(err, helpers.Err_Srv_PermissionDenied) {
+				t.Fatalf("unauthenticated: got %v, want %v", err, helpers.Err_Srv_PermissionDenied)
+			}
+			if err := call(withPolicy.service, adminMeta); err != nil {
+				t.Fatalf("admin: %v", err)
+			}
+		})
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	from := startServers(t, nil, withAdminPolicy(t))
+	holder := &locker.Client{Ctx: ctx, Id: "holder", LockKey: "key", StatusChan: make(chan locker.Status, 1), Lease: time.Minute}
+	from.locker.Lock(holder)
+	if status := <-holder.StatusChan; status != locker.Status_Locked {
+		t.Fatalf("holder got %s", status)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	waiter := &locker.Client{Ctx: waitCtx, Id: "waiter", LockKey: "key", StatusChan: make(chan locker.Status, 1), Lease: time.Second * 30}
+	from.locker.Lock(waiter)
+	for {
+		info, err := from.locker.Inspect(ctx, "", "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Waiters == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	exported, err := from.service.ExportState(ctx, adminMeta, &sharelockPB.ExportStateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := startServers(t, nil, withAdminPolicy(t))
+	resp, err := to.service.ImportState(ctx, adminMeta, &sharelockPB.ImportStateRequest{Snapshot: exported})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.HeldLocks != 1 || resp.Waiters != 1 {
+		t.Fatalf("imported %d locks and %d waiters, want 1 and 1", resp.HeldLocks, resp.Waiters)
+	}
+	reexported, err := to.service.ExportState(ctx, adminMeta, &sharelockPB.ExportStateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the exports differ only in their time and the lease remaining then
+	for _, snapshot := range []*sharelockPB.StateSnapshot{exported, reexported} {
+		snapshot.CreateTime = nil
+		for _, key := range snapshot.Keys {
+			key.LeaseRemainingMs = 0
+		}
+	}
+	if !proto.Equal(exported, reexported) {
+		t.Fatalf("exported\n%v\nimported and exported again\n%v", exported, reexported)
+	}
+	if _, err = to.service.ImportState(ctx, adminMeta, &sharelockPB.ImportStateRequest{Snapshot: exported}); !errors.Is(err, helpers.Err_Srv_StateNotEmpty) {
+		t.Fatalf("second import: got %v, want %v", err, helpers.Err_Srv_StateNotEmpty)
+	}
+}
+
+func TestSnapshotState(t *testing.T) {
+	ring := testRing(t, 2)
+	expire := timestamppb.New(time.Now().Add(time.Minute))
+	waitUntil := timestamppb.New(time.Now().Add(time.Minute))
+	key := func(name, holder string, waiters ...*sharelockPB.SnapshotWaiter) *sharelockPB.SnapshotKey {
+		return &sharelockPB.SnapshotKey{Key: name, Holder: holder, LeaseId: 1, LeaseExpireTime: expire, Waiters: waiters}
+	}
+	waiter := func(clientId string, leaseMs int64) *sharelockPB.SnapshotWaiter {
+		return &sharelockPB.SnapshotWaiter{ClientId: clientId, LeaseMs: leaseMs, WaitUntil: waitUntil}
+	}
+	own, foreign := keyOf(ring, "own", "node0"), keyOf(ring, "foreign", "node1")
+	tests := []struct {
+		name     string
+		snapshot *sharelockPB.StateSnapshot
+		// wantErr tells that the snapshot is refused
+		wantErr bool
+	}{
+		{name: "valid", snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, "a", waiter("b", 1000), waiter("c", 0)),
+		}}},
+		{name: "wrong version", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion + 1}},
+		{name: "duplicate key", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, "a"), key(own, "b"),
+		}}},
+		{name: "missing holder", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, ""),
+		}}},
+		{name: "key of another shard", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(foreign, "a"),
+		}}},
+		{name: "negative waiter lease", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, "a", waiter("b", -1)),
+		}}},
+		{name: "waiter queued twice", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, "a", waiter("b", 0), waiter("b", 0)),
+		}}},
+		{name: "waiter holds the key", wantErr: true, snapshot: &sharelockPB.StateSnapshot{Version: snapshotVersion, Keys: []*sharelockPB.SnapshotKey{
+			key(own, "a", waiter("a", 0)),
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewLockService(nil, WithShards(shard.NewMap("node0", ring), "secret", nil))
+			defer s.Close()
+			state, err := s.snapshotState(tt.snapshot)
+			if tt.wantErr {
+				if helpers.ErrorReason(err) != helpers.Reason_InvalidSnapshot {
+					t.Fatalf("got %v, want an invalid snapshot error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(state.Locks) != 1 || len(state.Queued) != 2 || state.Queued[0].ClientId != "b" || state.Queued[1].ClientId != "c" {
+				t.Fatalf("state %+v", state)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sharelock/config"
+	"sharelock/pkg/filewatch"
+)
+
+// certWatchDelay groups the writes of a certificate rotation, the key
+// and the certificate are two files.
+const certWatchDelay = time.Second
+
+// TLSReloader is a server whose certificates can be replaced while it
+// runs, see PrepareTLS.
+type TLSReloader interface {
+	// PrepareTLS loads the certificate, key and client ca bundle of cfg.
+	// They are only used once the returned func is called, so a reload
+	// can check every file before touching any listener.
+	PrepareTLS(cfg *config.Server) (func(), error)
+}
+
+// tlsStore holds the certificates of a listener. Handshakes read them
+// through GetCertificate, and GetConfigForClient for the client ca
+// bundle, so swapping them interrupts neither handshakes nor open
+// connections. The files are watched and loaded again when they
+// change, a rotation needs no restart nor reload.
+type tlsStore struct {
+	name       string
+	nextProtos []string
+	mutual     bool
+	current    atomic.Pointer[tls.Config]
+
+	mu sync.Mutex
+	// cfg names the files in use
+	cfg     *config.Server
+	watcher *filewatch.Watcher
+}
+
+// newTLSStore loads the certificates of cfg and watches their files
+// until ctx is done.
+func newTLSStore(ctx context.Context, name string, cfg *config.Server, nextProtos []string) (*tlsStore, error) {
+	s := &tlsStore{
+		name:       name,
+		nextProtos: nextProtos,
+		mutual:     cfg.ClientCAPath != "",
+	}
+	watcher, err := filewatch.New(certWatchDelay, s.reloadFiles)
+	if err != nil {
+		slog.Warn("certificates are not watched, reload the config after a rotation", "server", name, "err", err)
+	}
+	s.watcher = watcher
+	commit, err := s.prepare(cfg)
+	if err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
+		return nil, err
+	}
+	commit()
+	if watcher != nil {
+		go watcher.Run(ctx)
+	}
+	return s, nil
+}
+
+// serverConfig is the config to hand to the listener.
+func (s *tlsStore) serverConfig() *tls.Config {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: s.nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &s.current.Load().Certificates[0], nil
+		},
+	}
+	if s.mutual {
+		tlsConfig.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.current.Load(), nil
+		}
+	}
+	return tlsConfig
+}
+
+func (s *tlsStore) prepare(cfg *config.Server) (func(), error) {
+	tlsConfig, err := newServerTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.NextProtos = s.nextProtos
+	return func() {
+		s.current.Store(tlsConfig)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.cfg = cfg
+		if s.watcher == nil {
+			return
+		}
+		err := s.watcher.Watch(cfg.CertPath, cfg.KeyPath, cfg.ClientCAPath)
+		if err != nil {
+			slog.Warn("watching certificates", "server", s.name, "err", err)
+		}
+	}, nil
+}
+
+// reloadFiles loads the files in use again after a change on disk. A
+// key pair caught in the middle of its rotation fails to load, the
+// current certificates then stay until the next change.
+func (s *tlsStore) reloadFiles() {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+	commit, err := s.prepare(cfg)
+	if err != nil {
+		slog.Error("reloading certificates, keeping the current ones", "server", s.name, "err", err)
+		return
+	}
+	commit()
+	slog.Info("certificates reloaded", "server", s.name)
+}
+
+// newServerTLSConfig loads the certificate of a listener. With a client
+// CA bundle configured, every client must present a certificate that
+// chains to it.
+func newServerTLSConfig(cfg *config.Server) (*tls.Config, error) {
+	if cfg.CertPath == "" || cfg.KeyPath == "" {
+		return nil, errors.New("cert path and key path are required for tls")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.CertPath, cfg.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading key pair : %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientCAPath != "" {
+		pemBytes, err := os.ReadFile(cfg.ClientCAPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading client ca bundle : %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pemBytes) {
+			return nil, fmt.Errorf("no certificate found in client ca bundle %s", cfg.ClientCAPath)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
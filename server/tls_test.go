@@ -0,0 +1,123 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"sharelock/config"
+)
+
+// rotateServerCert writes a new certificate of the ca for 127.0.0.1
+// named cn over the files of certs.
+func rotateServerCert(t *testing.T, certs *testTLS, cn string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, certs.ca, &key.PublicKey, certs.caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the key first, a handshake between the two writes fails to load
+	// and keeps the old pair
+	err = os.WriteFile(certs.keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(certs.certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// servedName dials addr and returns the common name of the certificate
+// the handshake served.
+func servedName(t *testing.T, addr string, client *tls.Config) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, client)
+	if err != nil {
+		t.Fatalf("handshake : %v", err)
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestTLSStoreReload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	certs := newTestTLS(t)
+	store, err := newTLSStore(ctx, "test", certs.server(&config.Server{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", store.serverConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+	addr := listener.Addr().String()
+	if name := servedName(t, addr, certs.client); name != "sharelock test node" {
+		t.Fatalf("served %q before the rotation", name)
+	}
+
+	// the watcher loads a rotated pair on its own
+	rotateServerCert(t, certs, "rotated node")
+	deadline := time.Now().Add(certWatchDelay * 5)
+	for servedName(t, addr, certs.client) != "rotated node" {
+		if time.Now().After(deadline) {
+			t.Fatal("the rotated certificate was not served")
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+
+	// a broken pair keeps the current one
+	err = os.WriteFile(certs.certPath, []byte("not a certificate"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.reloadFiles()
+	if name := servedName(t, addr, certs.client); name != "rotated node" {
+		t.Fatalf("served %q after a broken rotation, want the current certificate", name)
+	}
+
+	_, err = store.prepare(certs.server(&config.Server{}))
+	if err == nil {
+		t.Fatal("prepared a broken key pair")
+	}
+}
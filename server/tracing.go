@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+// untracedHttpPaths are polled by orchestrators and scrapers, tracing
+// them would drown the lock requests.
+var untracedHttpPaths = map[string]bool{
+	"/ping":    true,
+	"/v2/ping": true,
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// grpcTracing continues the W3C trace context of incoming calls to
+// ShareLockService, health checks and reflection are not traced.
+func grpcTracing() grpc.ServerOption {
+	return grpc.StatsHandler(otelgrpc.NewServerHandler(
+		otelgrpc.WithFilter(func(info *stats.RPCTagInfo) bool {
+			return strings.HasPrefix(info.FullMethodName, "/sharelock.") &&
+				info.FullMethodName != "/sharelock.ShareLockService/Ping"
+		}),
+	))
+}
+
+// httpTracing continues the W3C trace context of the traceparent and
+// tracestate headers.
+func httpTracing(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedHttpPaths[r.URL.Path]
+		}),
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"sharelock/pkg/sharelockPB"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// recordSpans installs a tracer provider keeping every span in memory
+// and the W3C propagator, until the end of the test. The servers take
+// them when they are created, so it must run before startServers.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return exporter
+}
+
+// findSpan returns the span named name of the trace, nil if there is
+// none.
+func findSpan(exporter *tracetest.InMemoryExporter, traceId trace.TraceID, name string) *tracetest.SpanStub {
+	for _, span := range exporter.GetSpans() {
+		if span.SpanContext.TraceID() == traceId && span.Name == name {
+			return &span
+		}
+	}
+	return nil
+}
+
+func wantEvents(t *testing.T, span *tracetest.SpanStub, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		found := false
+		for _, event := range span.Events {
+			found = found || event.Name == name
+		}
+		if !found {
+			t.Errorf("span %s misses the %s event, has %+v", span.Name, name, span.Events)
+		}
+	}
+}
+
+const (
+	testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceId     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentId    = "00f067aa0ba902b7"
+)
+
+func TestTracePropagation(t *testing.T) {
+	exporter := recordSpans(t)
+	s := startServers(t, nil)
+	traceId, _ := trace.TraceIDFromHex(testTraceId)
+	parentId, _ := trace.SpanIDFromHex(testParentId)
+
+	// wantTrace checks that the request continued the trace of
+	// testTraceparent and returns the span of the lock service
+	wantTrace := func(t *testing.T, name string) *tracetest.SpanStub {
+		t.Helper()
+		span := findSpan(exporter, traceId, name)
+		if span == nil {
+			t.Fatalf("no %s span in the trace of the request, got %d spans", name, len(exporter.GetSpans()))
+		}
+		// the transport span is the child of the caller, the span of
+		// the lock service the child of the transport span
+		var transport *tracetest.SpanStub
+		for _, candidate := range exporter.GetSpans() {
+			if candidate.SpanContext.SpanID() == span.Parent.SpanID() {
+				transport = &candidate
+			}
+		}
+		if transport == nil || transport.Parent.SpanID() != parentId || !transport.Parent.IsRemote() {
+			t.Fatalf("%s does not descend from the caller span %s", name, testParentId)
+		}
+		return span
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		exporter.Reset()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		holderCtx := metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "holder")
+		_, err := s.locks().Lock(holderCtx, &sharelockPB.LockRequest{Key: "traced", TimeoutMs: 1000})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		waiterCtx := metadata.AppendToOutgoingContext(ctx, "X-Client-Id", "waiter", "traceparent", testTraceparent)
+		granted := make(chan error, 1)
+		go func() {
+			_, err := s.locks().Lock(waiterCtx, &sharelockPB.LockRequest{Key: "traced", TimeoutMs: 5000})
+			granted <- err
+		}()
+		for deadline := time.Now().Add(time.Second * 5); ; time.Sleep(time.Millisecond) {
+			info, err := s.locker.Inspect(ctx, "", "traced")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Waiters == 1 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("the waiter was not queued")
+			}
+		}
+		_, err = s.locks().Unlock(holderCtx, &sharelockPB.UnlockRequest{Key: "traced"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = <-granted; err != nil {
+			t.Fatal(err)
+		}
+
+		span := wantTrace(t, "sharelock.Lock")
+		wantEvents(t, span, "sharelock.queued", "sharelock.granted")
+		for _, event := range span.Events {
+			if event.Name != "sharelock.queued" {
+				continue
+			}
+			for _, attr := range event.Attributes {
+				if attr.Key == "sharelock.holder" && attr.Value.AsString() != "holder" {
+					t.Errorf("queued behind %s, want holder", attr.Value.AsString())
+				}
+			}
+		}
+	})
+
+	t.Run("http", func(t *testing.T) {
+		exporter.Reset()
+		for _, method := range []string{http.MethodPut, http.MethodDelete} {
+			req, err := http.NewRequest(method, "http://"+s.httpAddr+"/v2/locks/traced-http", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("X-Client-Id", "client")
+			req.Header.Set("traceparent", testTraceparent)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("%s got %d", method, resp.StatusCode)
+			}
+		}
+		wantEvents(t, wantTrace(t, "sharelock.Lock"), "sharelock.granted")
+		wantEvents(t, wantTrace(t, "sharelock.Unlock"), "sharelock.released")
+	})
+
+	t.Run("untraced paths", func(t *testing.T) {
+		exporter.Reset()
+		resp, err := http.Get("http://" + s.httpAddr + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if spans := exporter.GetSpans(); len(spans) != 0 {
+			t.Fatalf("health checks were traced : %d spans", len(spans))
+		}
+	})
+}